@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+const ackUsageText = "Usage: after ack [<id>]\n\n" +
+	"Acknowledges a finished timer, stopping its --ack or --escalate alarm.\n" +
+	"<id> may be omitted when only one timer is waiting."
+
+// alertingTimerID returns id, or the only timer waiting to be acknowledged
+// when id is zero.
+func alertingTimerID(timers []controlState, id int) (int, error) {
+	if id != 0 {
+		return id, nil
+	}
+	var alerting []int
+	for _, t := range timers {
+		if t.State == timerStateAlerting {
+			alerting = append(alerting, t.ID)
+		}
+	}
+	switch len(alerting) {
+	case 0:
+		return 0, errors.New("no timer is waiting to be acknowledged")
+	case 1:
+		return alerting[0], nil
+	}
+	return 0, errors.New("several timers are waiting; pass an id")
+}
+
+func runAckCommand(args []string, stdout, stderr io.Writer) int {
+	id := 0
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && isTimerID(args[0]):
+		id, _ = strconv.Atoi(args[0])
+	default:
+		fmt.Fprintln(stderr, ackUsageText)
+		return 2
+	}
+
+	dir := controlDir(os.Getenv)
+	id, err := alertingTimerID(runningTimers(dir), id)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if _, err := sendControl(dir, id, controlRequest{Command: controlCommandAck}); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -1,90 +1,539 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 )
 
-// shouldRunInternalAlarm reports whether to run as an internal alarm worker.
-// Internal mode is activated only by an exact hidden sentinel argument.
-func shouldRunInternalAlarm(args []string) bool {
-	return len(args) >= 2 && args[1] == internalAlarmArg
+// Built-in backends are played in-process rather than looked up on PATH.
+// The names cannot collide with executables because of the colon.
+const (
+	builtinBeepBackend      = "builtin:beep"
+	builtinPlaySoundBackend = "builtin:playsound"
+	builtinPCSpeakerBackend = "builtin:pcspeaker"
+)
+
+// alarmSound is what the alarm plays: soundFile, or the platform sound
+// when it is "", and whether to ramp the volume up (--gentle). backends is
+// a comma-separated list of backends to try first, or, when pinned, the
+// only ones to try.
+type alarmSound struct {
+	soundFile string
+	gentle    bool
+	backends  string
+	pinned    bool
 }
 
-// startAlarmProcess launches a detached child process that plays alert audio.
-// The parent does not wait so the prompt returns immediately on completion.
-// Alarm is best-effort; silently skip if we can't locate the executable.
-func startAlarmProcess(soundFile string) {
-	exe, err := os.Executable()
-	if err != nil {
+// alarmSound is the sound inv asks for. --alarm-backend pins the backends;
+// otherwise the configured order applies.
+func (inv invocation) alarmSound() alarmSound {
+	sound := alarmSound{soundFile: inv.soundFile, gentle: inv.gentle, backends: inv.alarmOrder}
+	if inv.alarmBackends != "" {
+		sound.backends, sound.pinned = inv.alarmBackends, true
+	}
+	return sound
+}
+
+// The alarm keeps playing after a finished timer exits, so the prompt
+// comes back at once. Go cannot fork, so the external players are handed to
+// a detached sh (see alarmScript), which plays the repeats and moves past
+// a backend that fails or hangs as playAlarmAttempts does. Built-in
+// backends only run in-process; when one would play first, the alarm plays
+// here and after waits for it before exiting (see waitForAlarms). Either
+// way, a Ctrl-C while after is still running, as during the next timer of
+// a batch, silences the alarm (see stopAlarms).
+
+// alarmPlays is how often the alarm plays the sound.
+const alarmPlays = 4
+
+// alarmPause is the pause after each play of the alarm.
+const alarmPause = 100 * time.Millisecond
+
+// runningAlarms tracks the alarms a process started that may still be
+// playing, so that a Ctrl-C while after is still running silences them.
+type runningAlarms struct {
+	playing sync.WaitGroup
+	// ctx ends the alarms playing in-process.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// groups holds the process groups of the alarm scripts still running.
+	groups map[int]bool
+}
+
+func newRunningAlarms() *runningAlarms {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &runningAlarms{ctx: ctx, cancel: cancel, groups: map[int]bool{}}
+}
+
+var alarms = newRunningAlarms()
+
+// startAlarm plays sound alarmPlays times without waiting for it. The alarm
+// is best-effort; with no backend, nothing plays.
+func startAlarm(sound alarmSound) {
+	alarms.start(sound)
+}
+
+// waitForAlarms waits for alarms playing in-process to finish.
+func waitForAlarms() {
+	alarms.playing.Wait()
+}
+
+// stopAlarms silences every alarm still playing.
+func stopAlarms() {
+	alarms.stop()
+}
+
+func (a *runningAlarms) start(sound alarmSound) {
+	commands := resolveAlarmCommands(sound)
+	if len(commands) == 0 {
 		return
 	}
+	if _, ok := builtinAlarmBackends[commands[0].name]; ok {
+		a.playing.Add(1)
+		go func() {
+			defer a.playing.Done()
+			playAlarmAttempts(commands, alarmPlays, alarmPause, sound.gentle, func(c alarmCommand) error {
+				return runAlarmCommandContext(a.ctx, c, alarmAttemptTimeout)
+			})
+		}()
+		return
+	}
+	_ = a.startScript(alarmScript(commands, alarmPlays, sound.gentle))
+}
 
-	cmd := newInternalAlarmCmd(exe, soundFile)
-	_ = cmd.Start()
+func (a *runningAlarms) startScript(script string) error {
+	cmd := newAlarmScriptCmd(script)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ctx.Err() != nil {
+		return a.ctx.Err()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+	a.groups[pid] = true
+	go func() {
+		_ = cmd.Wait()
+		// Forget the group before its id can be reused.
+		a.mu.Lock()
+		delete(a.groups, pid)
+		a.mu.Unlock()
+	}()
+	return nil
 }
 
-func newInternalAlarmCmd(exe string, soundFile string) *exec.Cmd {
-	args := []string{internalAlarmArg}
-	if soundFile != "" {
-		args = append(args, soundFile)
+// stop cuts short the alarms playing in-process and kills the process
+// group of each alarm script, taking the player it runs with it.
+func (a *runningAlarms) stop() {
+	a.cancel()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for pid := range a.groups {
+		killProcessGroup(pid)
 	}
-	cmd := quietCmd(exe, args...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// running reports how many alarm scripts are still running.
+func (a *runningAlarms) running() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.groups)
+}
+
+func newAlarmScriptCmd(script string) *exec.Cmd {
+	cmd := quietCmd("/bin/sh", "-c", script)
+	// A process group of its own, so the alarm outlives a Ctrl-C in the
+	// shell the timer ran in, and stopAlarms can kill the player with it.
+	newProcessGroup(cmd)
 	return cmd
 }
 
-// runAlarmWorker plays an available alarm backend 4 times with 100ms pauses.
-func runAlarmWorker(soundFile string) {
-	playAlarmAttempts(resolveAlarmCommands(soundFile), 4, 100*time.Millisecond, runAlarmCommand)
+// alarmScript is an sh script playing the external commands plays times,
+// with alarmPause after each play and the volume of each play as
+// playVolume gives it. A command that fails, or runs longer than
+// alarmAttemptTimeout, is not tried again; when none is left the script
+// stops.
+func alarmScript(commands []alarmCommand, plays int, gentle bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `run() {
+	"$@" </dev/null >/dev/null 2>&1 &
+	p=$!
+	(sleep %d; kill $p) >/dev/null 2>&1 &
+	w=$!
+	wait $p
+	s=$?
+	kill $w 2>/dev/null
+	return $s
+}
+`, int(alarmAttemptTimeout/time.Second))
+	pause := strconv.FormatFloat(alarmPause.Seconds(), 'f', -1, 64)
+	for play := 0; play < plays; play++ {
+		b.WriteString("played=\n")
+		for i, c := range commands {
+			if _, ok := builtinAlarmBackends[c.name]; ok {
+				continue
+			}
+			c = c.withVolume(playVolume(play, gentle))
+			words := []string{"run", shellQuote(c.name)}
+			for _, arg := range c.args {
+				words = append(words, shellQuote(arg))
+			}
+			fmt.Fprintf(&b, "[ -z \"$played$failed%d\" ] && { %s && played=1 || failed%d=1; }\n", i, strings.Join(words, " "), i)
+		}
+		b.WriteString("[ -n \"$played\" ] || exit 0\n")
+		fmt.Fprintf(&b, "sleep %s\n", pause)
+	}
+	return b.String()
 }
 
 // playAlarmAttempts plays a sound up to attempts times, removing any backend that fails.
 // interval is the pause after each sound completes, not between start times.
-func playAlarmAttempts(commands []alarmCommand, attempts int, interval time.Duration, runner func(alarmCommand) error) {
-	if len(commands) == 0 {
-		return
+// gentle ramps the volume up over the first plays (see rampVolume).
+func playAlarmAttempts(commands []alarmCommand, attempts int, interval time.Duration, gentle bool, runner func(alarmCommand) error) {
+	for i := 0; i < attempts && len(commands) > 0; i++ {
+		var played bool
+		commands, played = playAlarmOnce(commands, playVolume(i, gentle), runner)
+		if !played {
+			return
+		}
+
+		time.Sleep(interval)
 	}
+}
 
-	for i := 0; i < attempts && len(commands) > 0; i++ {
-		played := false
+// --gentle starts quiet and gets louder with each play until it reaches
+// full volume on play alarmPlays, so a long alarm does not startle.
+func rampVolume(play int) float64 {
+	return min(float64(play+1)/alarmPlays, 1)
+}
 
-		for idx := 0; idx < len(commands); {
-			if err := runner(commands[idx]); err == nil {
-				played = true
-				break
-			}
-			commands = append(commands[:idx], commands[idx+1:]...)
+func playVolume(play int, gentle bool) float64 {
+	if !gentle {
+		return 1
+	}
+	return rampVolume(play)
+}
+
+// playAlarmOnce plays the first backend that works at volume, a fraction
+// of full volume, and returns the backends left after dropping the ones
+// that failed.
+func playAlarmOnce(commands []alarmCommand, volume float64, runner func(alarmCommand) error) ([]alarmCommand, bool) {
+	for idx := 0; idx < len(commands); {
+		if err := runner(commands[idx].withVolume(volume)); err == nil {
+			return commands, true
 		}
+		commands = append(commands[:idx], commands[idx+1:]...)
+	}
+	return commands, false
+}
+
+// --ack rings until a key is pressed, and --escalate until the timer is
+// acknowledged. after is waiting either way, so the alarm plays in-process
+// and stopping it cuts the current sound short.
+const ackAlarmInterval = time.Second
+
+// ackAlarmLimit stops an unattended alarm eventually.
+const ackAlarmLimit = 10 * time.Minute
+
+// --escalate stays silent at completion, leaving the notification to do
+// its work, and only rings if nobody acknowledges the timer within
+// escalateDelay. It then rings until acknowledged, getting louder.
+const escalateDelay = time.Minute
+
+// snoozeDelay is how long Snooze on the notification of a ringing alarm
+// silences it before it rings again.
+const snoozeDelay = 5 * time.Minute
+
+// escalatedSound is sound as rung by --escalate: ramped up from quiet, so
+// the repeats grow louder.
+func escalatedSound(sound alarmSound) alarmSound {
+	sound.gentle = true
+	return sound
+}
 
+// startAckAlarm rings sound every ackAlarmInterval until the returned stop
+// is called.
+func startAckAlarm(sound alarmSound) (stop func()) {
+	commands := resolveAlarmCommands(sound)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopC := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		playAlarmUntil(commands, ackAlarmInterval, sound.gentle, stopC, func(c alarmCommand) error {
+			return runAlarmCommandContext(ctx, c, alarmAttemptTimeout)
+		})
+	}()
+	return func() {
+		close(stopC)
+		cancel()
+		<-done
+	}
+}
+
+// playAlarmUntil plays a sound every interval until stop is closed, removing
+// any backend that fails like playAlarmAttempts.
+func playAlarmUntil(commands []alarmCommand, interval time.Duration, gentle bool, stop <-chan struct{}, runner func(alarmCommand) error) {
+	for play := 0; len(commands) > 0; play++ {
+		var played bool
+		commands, played = playAlarmOnce(commands, playVolume(play, gentle), runner)
 		if !played {
 			return
 		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
 
-		time.Sleep(interval)
+// alarmBackendName is the name a backend is picked by with --alarm-backend:
+// the program, or the name of a built-in one.
+func alarmBackendName(c alarmCommand) string {
+	if c.name == "timeout" && len(c.args) > 1 {
+		return c.args[1]
+	}
+	if name, ok := strings.CutPrefix(c.name, "builtin:"); ok {
+		return name
 	}
+	return c.name
 }
 
-func resolveAlarmCommands(soundFile string) []alarmCommand {
-	candidates := alarmCandidatesForGOOS(runtime.GOOS, soundFile)
+// formatAlarmCommandNames lists the backends of commands in order, e.g.
+// "paplay, aplay".
+func formatAlarmCommandNames(commands []alarmCommand) string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = alarmBackendName(c)
+	}
+	return strings.Join(names, ", ")
+}
+
+// alarmBackendNames lists the backends goos can use, with or without a
+// sound file.
+func alarmBackendNames(goos string) []string {
+	var names []string
+	for _, c := range append(alarmCandidatesForGOOS(goos, ""), alarmCandidatesForGOOS(goos, "sound.wav")...) {
+		if name := alarmBackendName(c); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseAlarmBackends splits a comma-separated list of backend names.
+func parseAlarmBackends(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseAlarmBackendFlag checks the --alarm-backend list against the
+// backends this platform has and returns it in canonical form.
+func parseAlarmBackendFlag(flag, value string) (string, error) {
+	names := parseAlarmBackends(value)
+	available := alarmBackendNames(currentAlarmPlatform())
+	if len(names) == 0 || slices.ContainsFunc(names, func(name string) bool { return !slices.Contains(available, name) }) {
+		return "", invalidFlagValueError{flag: flag, value: value}
+	}
+	return strings.Join(names, ","), nil
+}
+
+// alarmSettings are the [alarm] section of the config file.
+type alarmSettings struct {
+	// order lists the backends to try before the rest.
+	order      string
+	quietHours quietHours
+}
+
+func parseAlarmSection(section configSection) (alarmSettings, error) {
+	var settings alarmSettings
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "order":
+			names := parseAlarmBackends(entry.value)
+			if len(names) == 0 {
+				return alarmSettings{}, configError{line: entry.line, msg: "order: expected a list of alarm backends"}
+			}
+			settings.order = strings.Join(names, ",")
+		case "quiet-hours":
+			q, err := parseQuietHours(entry.value)
+			if err != nil {
+				return alarmSettings{}, configError{line: entry.line, msg: err.Error()}
+			}
+			settings.quietHours = q
+		default:
+			return alarmSettings{}, configError{line: entry.line, msg: fmt.Sprintf("unknown alarm setting %q", entry.key)}
+		}
+	}
+	return settings, nil
+}
+
+// orderAlarmCandidates moves the candidates of the named backends to the
+// front, in the order named, or keeps only them when pinned. Names that do
+// not apply here are ignored, so one config serves several platforms.
+func orderAlarmCandidates(candidates []alarmCommand, names []string, pinned bool) []alarmCommand {
+	if len(names) == 0 {
+		return candidates
+	}
+	var ordered []alarmCommand
+	for _, name := range names {
+		for _, c := range candidates {
+			if alarmBackendName(c) == name {
+				ordered = append(ordered, c)
+			}
+		}
+	}
+	if pinned {
+		return ordered
+	}
+	for _, c := range candidates {
+		if !slices.Contains(names, alarmBackendName(c)) {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered
+}
+
+// resolveCandidateSounds replaces built-in sound names among c's arguments
+// with their files, or reports false when one cannot be written.
+func resolveCandidateSounds(c alarmCommand) (alarmCommand, bool) {
+	for i, arg := range c.args {
+		if !strings.HasPrefix(arg, soundNamePrefix) {
+			continue
+		}
+		path := resolveSoundName(arg)
+		if path == "" {
+			return c, false
+		}
+		c.args = slices.Clone(c.args)
+		c.args[i] = path
+	}
+	return c, true
+}
+
+// withVolume returns c set to play at volume, a fraction of full volume,
+// on backends that can; the others always play at full volume.
+func (c alarmCommand) withVolume(volume float64) alarmCommand {
+	if volume >= 1 {
+		return c
+	}
+	var opts []string
+	switch c.name {
+	case "afplay":
+		opts = []string{"-v", strconv.FormatFloat(volume, 'f', 2, 64)}
+	case "paplay":
+		opts = []string{"--volume=" + strconv.Itoa(int(volume*paVolumeNorm))}
+	case "pw-play":
+		opts = []string{"--volume=" + strconv.FormatFloat(volume, 'f', 2, 64)}
+	case "ffplay":
+		opts = []string{"-volume", strconv.Itoa(int(volume * 100))}
+	case "mpv":
+		opts = []string{"--volume=" + strconv.Itoa(int(volume*100))}
+	case "play":
+		opts = []string{"-v", strconv.FormatFloat(volume, 'f', 2, 64)}
+	case "canberra-gtk-play":
+		// The volume is a change in decibels.
+		opts = []string{"--volume=" + strconv.FormatFloat(20*math.Log10(volume), 'f', 1, 64)}
+	default:
+		return c
+	}
+	c.args = append(opts, c.args...)
+	return c
+}
+
+// paVolumeNorm is PulseAudio's full volume.
+const paVolumeNorm = 65536
+
+// resolveAlarmCommands lists the backends that can play sound, in the order
+// they are tried.
+func resolveAlarmCommands(sound alarmSound) []alarmCommand {
+	soundFile := resolveSoundName(sound.soundFile)
+	candidates := orderAlarmCandidates(alarmCandidatesForGOOS(currentAlarmPlatform(), soundFile), parseAlarmBackends(sound.backends), sound.pinned)
 	commands := make([]alarmCommand, 0, len(candidates))
 
 	for _, candidate := range candidates {
-		if _, err := exec.LookPath(candidate.name); err == nil {
+		if _, ok := builtinAlarmBackends[candidate.name]; ok {
+			commands = append(commands, candidate)
+			continue
+		}
+		if !alarmCommandInstalled(candidate) {
+			continue
+		}
+		// Built-in sounds are only written out for players that exist.
+		if candidate, ok := resolveCandidateSounds(candidate); ok {
 			commands = append(commands, candidate)
 		}
 	}
 	return commands
 }
 
+// alarmCommandInstalled reports whether c can run here. Wrapped commands
+// ("timeout 0.15s speaker-test") need the wrapped program as well.
+func alarmCommandInstalled(c alarmCommand) bool {
+	if _, ok := builtinAlarmBackends[c.name]; ok {
+		return true
+	}
+	if _, err := exec.LookPath(c.name); err != nil {
+		return false
+	}
+	if name := alarmBackendName(c); name != c.name {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+	return true
+}
+
+// alarmAttemptTimeout bounds one play of a backend, so a wedged sound
+// server cannot keep the alarm playing forever. A backend that runs
+// out of time is dropped like one that fails.
+const alarmAttemptTimeout = 10 * time.Second
+
 func runAlarmCommand(command alarmCommand) error {
-	cmd := quietCmd(command.name, command.args...)
-	return cmd.Run()
+	return runAlarmCommandContext(context.Background(), command, alarmAttemptTimeout)
+}
+
+// runAlarmCommandContext plays command, stopping it when ctx is done or
+// limit has passed.
+func runAlarmCommandContext(ctx context.Context, command alarmCommand, limit time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if play, ok := builtinAlarmBackends[command.name]; ok {
+		return play(command.args)
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command.name, command.args...)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	// Do not wait on output pipes held open by the killed player's children.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: no sound after %v", command.name, limit)
+	}
+	return err
 }
 
 // quietCmd creates an exec.Cmd with stdio disconnected/discarded.
@@ -110,35 +559,117 @@ func alarmCandidatesForGOOS(goos string, soundFile string) []alarmCommand {
 			{name: "afplay", args: []string{"/System/Library/Sounds/Submarine.aiff"}},
 		}
 	case "linux":
+		if soundFile != "" {
+			return linuxFilePlayers(soundFile)
+		}
+		// Without a sound theme, the file players play the built-in bell.
+		candidates := []alarmCommand{{name: "canberra-gtk-play", args: []string{"-i", "bell"}}}
+		candidates = append(candidates, linuxFilePlayers(soundNamePrefix+"bell")...)
+		return append(candidates,
+			alarmCommand{name: "timeout", args: []string{"0.15s", "speaker-test", "-t", "sine", "-f", "1200", "-c", "1", "-s", "1"}},
+			alarmCommand{name: builtinPCSpeakerBackend, args: []string{"1200", "150"}},
+		)
+	case "freebsd":
 		if soundFile != "" {
 			return []alarmCommand{
 				{name: "canberra-gtk-play", args: []string{"--file", soundFile}},
-				{name: "paplay", args: []string{soundFile}},
 			}
 		}
 		return []alarmCommand{
+			{name: "beep"},
 			{name: "canberra-gtk-play", args: []string{"-i", "bell"}},
-			{name: "timeout", args: []string{"0.15s", "speaker-test", "-t", "sine", "-f", "1200", "-c", "1", "-s", "1"}},
 		}
-	case "freebsd":
+	case "windows":
 		if soundFile != "" {
 			return []alarmCommand{
-				{name: "canberra-gtk-play", args: []string{"--file", soundFile}},
+				{name: builtinPlaySoundBackend, args: []string{soundFile}},
+				{name: "powershell", args: powershellArgs("(New-Object Media.SoundPlayer " + powershellQuote(soundFile) + ").PlaySync()")},
 			}
 		}
 		return []alarmCommand{
-			{name: "beep"},
-			{name: "canberra-gtk-play", args: []string{"-i", "bell"}},
+			{name: builtinBeepBackend, args: []string{"1200", "150"}},
+			{name: "powershell", args: powershellArgs("[console]::beep(1200,150)")},
+			{name: "powershell", args: powershellArgs("[System.Media.SystemSounds]::Exclamation.Play(); Start-Sleep -Milliseconds 300")},
 		}
 	case "openbsd", "netbsd":
 		return []alarmCommand{
 			{name: "beep"},
 		}
+	case "wsl":
+		return append(wslAlarmCandidates(soundFile, os.Getenv("WSL_DISTRO_NAME")), alarmCandidatesForGOOS("linux", soundFile)...)
 	default:
 		return nil
 	}
 }
 
+// Inside WSL the Linux players rarely reach the speakers, so the alarm is
+// played by Windows first, through powershell.exe. The Linux players
+// follow for WSLg and other setups that forward sound.
+
+// alarmPlatform is the platform whose backends the alarm tries: goos, or
+// "wsl" for Linux running under the Windows Subsystem for Linux.
+func alarmPlatform(goos string, getenv func(string) string, readFile func(string) ([]byte, error)) string {
+	if goos == "linux" && isWSL(getenv, readFile) {
+		return "wsl"
+	}
+	return goos
+}
+
+var currentAlarmPlatform = sync.OnceValue(func() string {
+	return alarmPlatform(runtime.GOOS, os.Getenv, os.ReadFile)
+})
+
+// isWSL reports whether this Linux runs under WSL, which sets
+// WSL_DISTRO_NAME and builds kernels whose version names Microsoft.
+func isWSL(getenv func(string) string, readFile func(string) ([]byte, error)) bool {
+	if getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	version, err := readFile("/proc/version")
+	return err == nil && strings.Contains(strings.ToLower(string(version)), "microsoft")
+}
+
+// wslAlarmCandidates play the alarm through Windows. Windows reaches a
+// sound file of the distro through its \\wsl$ share, so a file needs the
+// distro's name; without one, or when the file is not a WAV Windows can
+// play, the Windows alert sound plays instead.
+func wslAlarmCandidates(soundFile, distro string) []alarmCommand {
+	var candidates []alarmCommand
+	if soundFile != "" && distro != "" {
+		if path, err := filepath.Abs(soundFile); err == nil {
+			windowsPath := `\\wsl$\` + distro + strings.ReplaceAll(path, "/", `\`)
+			candidates = append(candidates, alarmCommand{name: "powershell.exe", args: powershellArgs("(New-Object Media.SoundPlayer " + powershellQuote(windowsPath) + ").PlaySync()")})
+		}
+	}
+	return append(candidates, alarmCommand{name: "powershell.exe", args: powershellArgs("[System.Media.SystemSounds]::Exclamation.Play(); Start-Sleep -Milliseconds 300")})
+}
+
+// linuxFilePlayers play soundFile through whatever is installed: the
+// desktop's event sound library, then PipeWire and PulseAudio, then general
+// media players, and finally raw ALSA, which plays WAV files only and
+// bypasses any sound server.
+func linuxFilePlayers(soundFile string) []alarmCommand {
+	return []alarmCommand{
+		{name: "canberra-gtk-play", args: []string{"--file", soundFile}},
+		{name: "pw-play", args: []string{soundFile}},
+		{name: "paplay", args: []string{soundFile}},
+		{name: "ffplay", args: []string{"-nodisp", "-autoexit", "-loglevel", "quiet", soundFile}},
+		{name: "mpv", args: []string{"--no-video", "--really-quiet", soundFile}},
+		{name: "play", args: []string{"-q", soundFile}},
+		{name: "aplay", args: []string{"-q", soundFile}},
+	}
+}
+
+// powershellArgs wraps a script for a non-interactive powershell invocation.
+func powershellArgs(script string) []string {
+	return []string{"-NoProfile", "-NonInteractive", "-Command", script}
+}
+
+// powershellQuote returns s as a single-quoted PowerShell literal.
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 func resolveSoundFilePath(path string) (string, error) {
 	if path != "~" && !strings.HasPrefix(path, "~/") {
 		return path, nil
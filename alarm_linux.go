@@ -0,0 +1,107 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var builtinAlarmBackends = map[string]func(args []string) error{
+	builtinPCSpeakerBackend: pcSpeakerBeep,
+}
+
+const (
+	// pcSpeakerDevice is the pcspkr driver's input device, which plays
+	// tones written to it as EV_SND events.
+	pcSpeakerDevice = "/dev/input/by-path/platform-pcspkr-event-spkr"
+	sndTone         = 0x02
+	// kiocSound starts a tone on a virtual console, given the PIT clock
+	// divided by the frequency, and stops it given 0.
+	kiocSound = 0x4B2F
+	pitClock  = 1193180
+)
+
+// consoleDevices are tried in turn for KIOCSOUND; only a virtual console,
+// not a pseudo-terminal, accepts it.
+var consoleDevices = []string{"/dev/tty0", "/dev/console", "/dev/tty"}
+
+// pcSpeakerBeep sounds the PC speaker for servers and VMs without a sound
+// server. args are the frequency in hertz and the duration in
+// milliseconds. Both ways in usually need root or membership in the input
+// or tty group; without it the error moves the alarm on to the next
+// backend.
+func pcSpeakerBeep(args []string) error {
+	if len(args) != 2 {
+		return errors.New("PC speaker beep requires frequency and duration")
+	}
+	freq, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	ms, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	if freq <= 0 {
+		return errors.New("PC speaker beep requires a positive frequency")
+	}
+	d := time.Duration(ms) * time.Millisecond
+
+	err = beepInputDevice(pcSpeakerDevice, freq, d)
+	if err == nil {
+		return nil
+	}
+	for _, dev := range consoleDevices {
+		if err = beepConsole(dev, freq, d); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func beepInputDevice(path string, freq int, d time.Duration) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(soundEvent(freq)); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	_, err = f.Write(soundEvent(0))
+	return err
+}
+
+// soundEvent encodes a struct input_event that sets the tone to freq, or
+// silences it when freq is 0. The kernel ignores the timestamp.
+func soundEvent(freq int) []byte {
+	var b bytes.Buffer
+	b.Write(make([]byte, unsafe.Sizeof(unix.Timeval{})))
+	_ = binary.Write(&b, binary.NativeEndian, uint16(unix.EV_SND))
+	_ = binary.Write(&b, binary.NativeEndian, uint16(sndTone))
+	_ = binary.Write(&b, binary.NativeEndian, int32(freq))
+	return b.Bytes()
+}
+
+func beepConsole(path string, freq int, d time.Duration) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fd := int(f.Fd())
+	if err := unix.IoctlSetInt(fd, kiocSound, pitClock/freq); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return unix.IoctlSetInt(fd, kiocSound, 0)
+}
@@ -0,0 +1,6 @@
+//go:build !windows && !linux
+
+package main
+
+// builtinAlarmBackends is empty outside Windows and Linux; every backend is an external command.
+var builtinAlarmBackends = map[string]func(args []string) error{}
@@ -0,0 +1,65 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procBeep       = windows.NewLazySystemDLL("kernel32.dll").NewProc("Beep")
+	procPlaySoundW = windows.NewLazySystemDLL("winmm.dll").NewProc("PlaySoundW")
+)
+
+const (
+	sndSync      = 0x0000
+	sndNoDefault = 0x0002
+	sndFilename  = 0x00020000
+)
+
+var builtinAlarmBackends = map[string]func(args []string) error{
+	builtinBeepBackend:      consoleBeep,
+	builtinPlaySoundBackend: playSoundFile,
+}
+
+// consoleBeep plays a tone through the kernel32 Beep API.
+// args are the frequency in hertz and the duration in milliseconds.
+func consoleBeep(args []string) error {
+	if len(args) != 2 {
+		return errors.New("console beep requires frequency and duration")
+	}
+	freq, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	ms, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	if freq < 37 || freq > 32767 {
+		return errors.New("console beep frequency must be from 37 to 32767 hertz")
+	}
+	if r, _, callErr := procBeep.Call(uintptr(freq), uintptr(ms)); r == 0 {
+		return callErr
+	}
+	return nil
+}
+
+// playSoundFile plays args[0] synchronously through winmm PlaySound.
+func playSoundFile(args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return errors.New("PlaySound requires a file path")
+	}
+	path, err := windows.UTF16PtrFromString(args[0])
+	if err != nil {
+		return err
+	}
+	if r, _, callErr := procPlaySoundW.Call(uintptr(unsafe.Pointer(path)), 0, sndFilename|sndSync|sndNoDefault); r == 0 {
+		return callErr
+	}
+	return nil
+}
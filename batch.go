@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// --stdin (or "-") runs a list of timers read from standard input, one per
+// line, back to back:
+//
+//	25m focus
+//	5m  break
+//	# comments and blank lines are skipped
+//	25m focus again
+//
+// The first field is a duration or time, the rest of the line its label.
+
+type batchEntry struct {
+	duration time.Duration
+	target   time.Time
+	label    string
+}
+
+type batchLineError struct {
+	line int
+	err  error
+}
+
+func (e batchLineError) Error() string {
+	return fmt.Sprintf("stdin:%d: %v", e.line, e.err)
+}
+
+func (e batchLineError) Unwrap() error {
+	return e.err
+}
+
+// parseBatch reads every entry up front, so a typo on the last line is
+// reported before the first timer starts.
+func parseBatch(r io.Reader, units map[string]time.Duration) ([]batchEntry, error) {
+	var entries []batchEntry
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		token := fields[0]
+		rest := fields[1:]
+		if len(rest) > 0 && isClockToken(token) && isAMPMToken(rest[0]) {
+			token += " " + rest[0]
+			rest = rest[1:]
+		}
+
+		entry := batchEntry{label: strings.Join(rest, " ")}
+		if d, ok, err := parseCustomUnitDuration(normalizeDecimalComma(token), units); ok {
+			if err != nil {
+				return nil, batchLineError{line: lineNo, err: err}
+			}
+			entry.duration = d
+		} else {
+			d, target, err := parseDurationToken(token)
+			if err != nil {
+				return nil, batchLineError{line: lineNo, err: err}
+			}
+			entry.duration, entry.target = d, target
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errEmptyBatch
+	}
+	return entries, nil
+}
+
+func formatBatchEntryStart(index, total int, entry batchEntry) string {
+	what := formatLifecycleStarted(entry.duration, entry.target)
+	what = strings.TrimPrefix(what, "after: started ")
+	if entry.label != "" {
+		return fmt.Sprintf("after: [%d/%d] %s %s", index, total, entry.label, what)
+	}
+	return fmt.Sprintf("after: [%d/%d] %s", index, total, what)
+}
+
+// runBatch runs entries in order with the flags from inv. Cancelling any
+// entry stops the whole batch.
+func runBatch(ctx context.Context, cancel context.CancelCauseFunc, inv invocation, entries []batchEntry, status statusDisplay, sideEffectsInteractive bool) error {
+	for i, entry := range entries {
+		if !inv.quiet {
+			if status.interactive {
+				writeInteractiveLine(status, formatBatchEntryStart(i+1, len(entries), entry))
+			} else {
+				writeStatusln(status.writer, formatBatchEntryStart(i+1, len(entries), entry))
+			}
+		}
+		entryInv := inv
+		entryInv.duration = entry.duration
+		entryInv.wallClockTarget = entry.target
+		if entry.label != "" {
+			entryInv.label = entry.label
+		}
+		if !entry.target.IsZero() {
+			// Times of day were resolved when the list was read; count down
+			// to the same instant even if earlier entries ran long.
+			entryInv.duration = max(time.Until(entry.target), 0)
+		}
+		if err := runTimer(ctx, cancel, entryInv, status, sideEffectsInteractive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// wallTimeout bounds waiting for wall(1), which can stall on a terminal
+// that is not draining output.
+const wallTimeout = 5 * time.Second
+
+// terminalGlobsForGOOS lists where pseudo-terminal devices live.
+func terminalGlobsForGOOS(goos string) []string {
+	switch goos {
+	case "linux", "freebsd":
+		return []string{"/dev/pts/[0-9]*"}
+	case "darwin":
+		return []string{"/dev/ttys[0-9]*"}
+	case "openbsd", "netbsd":
+		return []string{"/dev/ttyp*", "/dev/pts/[0-9]*"}
+	}
+	return nil
+}
+
+// broadcastText is the line announcing completion. A --message replaces
+// the default "timer complete" text.
+func broadcastText(label, message string, at time.Time) string {
+	what := "timer complete"
+	switch {
+	case message != "":
+		what = message
+	case label != "":
+		what = fmt.Sprintf("timer complete: %s", label)
+	}
+	return fmt.Sprintf("*** after: %s at %s ***", what, at.Format("15:04"))
+}
+
+// formatBroadcastMessage announces completion on its own line of a busy
+// terminal, with a bell.
+func formatBroadcastMessage(label, message string, at time.Time) string {
+	return "\r\n\a" + broadcastText(label, message, at) + "\r\n"
+}
+
+// broadcastCompletion writes msg to every terminal the user has open, so a
+// timer started in a forgotten session is still noticed. Writes are
+// non-blocking: a terminal that is not draining output is skipped.
+func broadcastCompletion(ttys []string, msg string) {
+	for _, path := range ttys {
+		f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			continue
+		}
+		_, _ = f.WriteString(msg)
+		_ = f.Close()
+	}
+}
+
+// wallCompletion announces text on every logged-in terminal, like wall(1).
+// wall itself is set-group-id tty, so it can reach other users' terminals;
+// when it is missing or fails, text goes to the terminals after can open
+// itself, which for anyone but root are usually only their own.
+func wallCompletion(globs []string, text string) {
+	if path, err := exec.LookPath("wall"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), wallTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, path)
+		cmd.Stdin = strings.NewReader(text + "\n")
+		if cmd.Run() == nil {
+			return
+		}
+	}
+	broadcastCompletion(wallTerminals(globs, os.Getuid()), "\r\n\a"+text+"\r\n")
+}
@@ -0,0 +1,64 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// userTerminals returns the terminal devices owned by uid, excluding the
+// device with rdev skip (the timer's own terminal, already showing the result).
+func userTerminals(globs []string, uid int, skip uint64) []string {
+	var ttys []string
+	for _, pattern := range globs {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			var st syscall.Stat_t
+			if err := syscall.Stat(path, &st); err != nil {
+				continue
+			}
+			if int(st.Uid) != uid || (skip != 0 && uint64(st.Rdev) == skip) {
+				continue
+			}
+			ttys = append(ttys, path)
+		}
+	}
+	return ttys
+}
+
+// wallTerminals returns the terminal devices of every user that accept
+// messages (mesg y, which makes them group-writable), and all of uid's.
+func wallTerminals(globs []string, uid int) []string {
+	var ttys []string
+	for _, pattern := range globs {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			var st syscall.Stat_t
+			if err := syscall.Stat(path, &st); err != nil {
+				continue
+			}
+			if int(st.Uid) != uid && st.Mode&0o020 == 0 {
+				continue
+			}
+			ttys = append(ttys, path)
+		}
+	}
+	return ttys
+}
+
+// ownTerminalRdev returns the device number of the terminal attached to the
+// standard streams, or 0 when none of them is a terminal.
+func ownTerminalRdev() uint64 {
+	for _, f := range []*os.File{os.Stderr, os.Stdout, os.Stdin} {
+		if !isTerminal(f.Fd()) {
+			continue
+		}
+		var st syscall.Stat_t
+		if err := syscall.Fstat(int(f.Fd()), &st); err == nil {
+			return uint64(st.Rdev)
+		}
+	}
+	return 0
+}
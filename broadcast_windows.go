@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+// Windows consoles are not device files another process can write to, so
+// --broadcast and --wall find no terminals to reach.
+
+func userTerminals(globs []string, uid int, skip uint64) []string {
+	return nil
+}
+
+func wallTerminals(globs []string, uid int) []string {
+	return nil
+}
+
+func ownTerminalRdev() uint64 {
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockStepThreshold is the smallest wall clock change reported as a step.
+// Like suspendThreshold it sits well above jitter on a 1s check interval.
+const clockStepThreshold = 2 * time.Second
+
+// clockReading is time.Now together with the boot clock, which, unlike Go's
+// monotonic clock, keeps counting while the system is suspended.
+type clockReading struct {
+	now    time.Time
+	boot   time.Duration
+	bootOK bool
+}
+
+func readClock() clockReading {
+	boot, ok := bootClock()
+	return clockReading{now: time.Now(), boot: boot, bootOK: ok}
+}
+
+// clockStep reports how far the wall clock was stepped (NTP, manual change)
+// between two readings: positive when it jumped forward, negative when it
+// was set back.
+func clockStep(prev, cur clockReading) time.Duration {
+	return clockStepExcludingSuspend(cur.now.Round(0).Sub(prev.now.Round(0)), cur.now.Sub(prev.now), cur.boot-prev.boot, prev.bootOK && cur.bootOK)
+}
+
+// clockStepExcludingSuspend is clockStepFromElapsed for a wall clock that
+// also moved forward by any time spent suspended, which is not a step. boot
+// is the elapsed time on the boot clock, when bootOK.
+func clockStepExcludingSuspend(wall, mono, boot time.Duration, bootOK bool) time.Duration {
+	if !bootOK {
+		// Without the boot clock a forward step looks just like a
+		// suspend, so only steps back are reported.
+		return min(clockStepFromElapsed(wall, mono), 0)
+	}
+	return clockStepFromElapsed(wall, boot)
+}
+
+// clockStepFromElapsed compares wall and monotonic elapsed time. Steps
+// smaller than clockStepThreshold in either direction return 0.
+func clockStepFromElapsed(wall, mono time.Duration) time.Duration {
+	step := wall - mono
+	if step > -clockStepThreshold && step < clockStepThreshold {
+		return 0
+	}
+	return step
+}
+
+// formatClockStep explains that a wall clock target kept its time of day
+// even though the clock moved, so the countdown itself jumped.
+func formatClockStep(step time.Duration, target time.Time) string {
+	direction := "forward"
+	if step < 0 {
+		direction = "back"
+		step = -step
+	}
+	return fmt.Sprintf("clock moved %s %s; still counting to %s", direction, step.Round(time.Second), target.Format("15:04:05"))
+}
+
+func printClockStep(status statusDisplay, quiet bool, step time.Duration, target time.Time) {
+	if quiet {
+		return
+	}
+	if status.interactive {
+		writeInteractiveLine(status, "after "+formatClockStep(step, target))
+		return
+	}
+	writeStatusln(status.writer, "after: "+formatClockStep(step, target))
+}
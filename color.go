@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// colorMode is the --color setting.
+type colorMode int
+
+const (
+	colorAuto colorMode = iota
+	colorNever
+	colorAlways
+)
+
+func parseColorMode(s string) (colorMode, bool) {
+	switch s {
+	case "auto":
+		return colorAuto, true
+	case "always":
+		return colorAlways, true
+	case "never":
+		return colorNever, true
+	}
+	return 0, false
+}
+
+// colorThresholds are the remaining times at which the countdown turns
+// yellow and then red. The zero value means the defaults.
+type colorThresholds struct {
+	yellow time.Duration
+	red    time.Duration
+}
+
+var defaultColorThresholds = colorThresholds{yellow: time.Minute, red: 10 * time.Second}
+
+// parseColorThresholds parses "<yellow>,<red>", e.g. "5m,30s".
+func parseColorThresholds(s string) (colorThresholds, error) {
+	yellowValue, redValue, ok := strings.Cut(s, ",")
+	if !ok {
+		return colorThresholds{}, invalidFlagValueError{flag: "--color-thresholds", value: s}
+	}
+	yellow, yellowErr := parseFlagDuration("--color-thresholds", yellowValue)
+	red, redErr := parseFlagDuration("--color-thresholds", redValue)
+	if yellowErr != nil || redErr != nil || red > yellow {
+		return colorThresholds{}, invalidFlagValueError{flag: "--color-thresholds", value: s}
+	}
+	return colorThresholds{yellow: yellow, red: red}, nil
+}
+
+// colorEnabled decides whether the countdown is colored. auto colors only
+// ANSI-capable terminals and honors NO_COLOR (https://no-color.org).
+func colorEnabled(mode colorMode, status statusDisplay, getenv func(string) string) bool {
+	switch mode {
+	case colorAlways:
+		return status.interactive
+	case colorNever:
+		return false
+	}
+	return status.interactive && status.supportsAdvanced && !status.monochrome && getenv("NO_COLOR") == ""
+}
+
+// countdownColor returns the SGR parameter for remaining in the default
+// theme: green, then yellow and red as the thresholds are crossed.
+func countdownColor(remaining time.Duration, th colorThresholds) string {
+	return themes[defaultThemeName].color(remaining, th)
+}
+
+// colorLevel is 0 before the yellow threshold, 1 from it, and 2 from the
+// red threshold on.
+func colorLevel(remaining time.Duration, th colorThresholds) int {
+	if th == (colorThresholds{}) {
+		th = defaultColorThresholds
+	}
+	switch {
+	case remaining <= th.red:
+		return 2
+	case remaining <= th.yellow:
+		return 1
+	}
+	return 0
+}
+
+func colorize(s, sgr string) string {
+	if sgr == "" {
+		return s
+	}
+	return fmt.Sprintf("\033[%sm%s\033[0m", sgr, s)
+}
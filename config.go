@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The config file is a small INI-style document. Each section has a kind and
+// a name ("[alert loud]"), followed by "key = value" lines. Blank lines and
+// lines starting with '#' are ignored.
+//
+//	[alert loud]
+//	sound = on
+//	sound-file = ~/sounds/gong.wav
+//
+//	[alert silent]
+//	sound = off
+//
+//	[preset brew]
+//	args = {{.minutes}}m --alert loud
+//	minutes = 4
+//
+// A few kinds hold a single table and take no name:
+//
+//	[units]
+//	pomo = 25m
+//
+//	[display]
+//	theme = bold
+//	style = full
+//
+//	[alarm]
+//	order = pw-play, paplay
+//	quiet-hours = 22:00-07:00
+//
+//	[pushover]
+//	token = <application token>
+//	user = <user key>
+//
+//	[telegram]
+//	token = <bot token>
+//	chat = <chat id>
+//
+//	[slack]
+//	webhook = https://hooks.slack.com/services/...
+//
+//	[discord]
+//	webhook = https://discord.com/api/webhooks/...
+//
+//	[email]
+//	server = smtp.example.com:587
+//	from = after@example.com
+//	to = me@example.com
+//
+//	[toggl]
+//	token = <API token>
+
+const configEnvVar = "AFTER_CONFIG"
+
+type config struct {
+	alerts  map[string]alertProfile
+	presets map[string]preset
+	units   map[string]time.Duration
+	theme   string
+	style   string
+	// alarmOrder lists the alarm backends to try first.
+	alarmOrder string
+	quietHours quietHours
+	pushover   pushoverConfig
+	telegram   telegramConfig
+	slack      slackConfig
+	discord    discordConfig
+	email      emailConfig
+	toggl      togglConfig
+}
+
+// unnamedSectionKinds are the section kinds written without a name.
+var unnamedSectionKinds = map[string]bool{
+	"units":    true,
+	"display":  true,
+	"alarm":    true,
+	"pushover": true,
+	"telegram": true,
+	"slack":    true,
+	"discord":  true,
+	"email":    true,
+	"toggl":    true,
+}
+
+type configSection struct {
+	kind    string
+	name    string
+	line    int
+	entries []configEntry
+}
+
+type configEntry struct {
+	key   string
+	value string
+	line  int
+}
+
+type configError struct {
+	path string
+	line int
+	msg  string
+}
+
+func (e configError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("config %s: %s", e.path, e.msg)
+	}
+	return fmt.Sprintf("config %s:%d: %s", e.path, e.line, e.msg)
+}
+
+// configPath returns the config file location: $AFTER_CONFIG if set, otherwise
+// $XDG_CONFIG_HOME/after/config, falling back to ~/.config/after/config.
+func configPath(getenv func(string) string) string {
+	if path := getenv(configEnvVar); path != "" {
+		return path
+	}
+	if dir := getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "after", "config")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "after", "config")
+}
+
+// loadConfig reads the config file. A missing file yields an empty config.
+func loadConfig(path string) (config, error) {
+	if path == "" {
+		return config{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config{}, nil
+		}
+		return config{}, configError{path: path, msg: err.Error()}
+	}
+	defer f.Close()
+
+	sections, err := parseConfigSections(f)
+	if err != nil {
+		var cfgErr configError
+		if errors.As(err, &cfgErr) {
+			cfgErr.path = path
+			return config{}, cfgErr
+		}
+		return config{}, configError{path: path, msg: err.Error()}
+	}
+
+	cfg, err := buildConfig(sections)
+	if err != nil {
+		var cfgErr configError
+		if errors.As(err, &cfgErr) {
+			cfgErr.path = path
+			return config{}, cfgErr
+		}
+		return config{}, err
+	}
+	return cfg, nil
+}
+
+func parseConfigSections(r io.Reader) ([]configSection, error) {
+	var sections []configSection
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, configError{line: lineNo, msg: "unterminated section header"}
+			}
+			fields := strings.Fields(line[1 : len(line)-1])
+			if len(fields) == 1 && unnamedSectionKinds[fields[0]] {
+				sections = append(sections, configSection{kind: fields[0], line: lineNo})
+				continue
+			}
+			if len(fields) == 2 && unnamedSectionKinds[fields[0]] {
+				return nil, configError{line: lineNo, msg: fmt.Sprintf("[%s] takes no name", fields[0])}
+			}
+			if len(fields) != 2 {
+				return nil, configError{line: lineNo, msg: "section header must be [<kind> <name>]"}
+			}
+			sections = append(sections, configSection{kind: fields[0], name: fields[1], line: lineNo})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, configError{line: lineNo, msg: "expected key = value"}
+		}
+		if len(sections) == 0 {
+			return nil, configError{line: lineNo, msg: "setting outside of a section"}
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, configError{line: lineNo, msg: "missing key"}
+		}
+		current := &sections[len(sections)-1]
+		current.entries = append(current.entries, configEntry{key: key, value: strings.TrimSpace(value), line: lineNo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func buildConfig(sections []configSection) (config, error) {
+	cfg := config{alerts: map[string]alertProfile{}, presets: map[string]preset{}, units: map[string]time.Duration{}}
+
+	for _, section := range sections {
+		switch section.kind {
+		case "alert":
+			if _, dup := cfg.alerts[section.name]; dup {
+				return config{}, configError{line: section.line, msg: fmt.Sprintf("duplicate alert profile %q", section.name)}
+			}
+			profile, err := parseAlertProfile(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.alerts[section.name] = profile
+		case "preset":
+			if _, dup := cfg.presets[section.name]; dup {
+				return config{}, configError{line: section.line, msg: fmt.Sprintf("duplicate preset %q", section.name)}
+			}
+			p, err := parsePreset(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.presets[section.name] = p
+		case "units":
+			units, err := parseUnits(section)
+			if err != nil {
+				return config{}, err
+			}
+			for name, d := range units {
+				if _, dup := cfg.units[name]; dup {
+					return config{}, configError{line: section.line, msg: fmt.Sprintf("duplicate unit %q", name)}
+				}
+				cfg.units[name] = d
+			}
+		case "display":
+			display, err := parseDisplaySection(section)
+			if err != nil {
+				return config{}, err
+			}
+			if display.theme != "" {
+				cfg.theme = display.theme
+			}
+			if display.style != "" {
+				cfg.style = display.style
+			}
+		case "alarm":
+			settings, err := parseAlarmSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			if settings.order != "" {
+				cfg.alarmOrder = settings.order
+			}
+			if settings.quietHours.set {
+				cfg.quietHours = settings.quietHours
+			}
+		case "pushover":
+			p, err := parsePushoverSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.pushover = p
+		case "telegram":
+			c, err := parseTelegramSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.telegram = c
+		case "slack":
+			c, err := parseSlackSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.slack = c
+		case "discord":
+			c, err := parseDiscordSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.discord = c
+		case "email":
+			c, err := parseEmailSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.email = c
+		case "toggl":
+			c, err := parseTogglSection(section)
+			if err != nil {
+				return config{}, err
+			}
+			cfg.toggl = c
+		default:
+			return config{}, configError{line: section.line, msg: fmt.Sprintf("unknown section kind %q", section.kind)}
+		}
+	}
+	return cfg, nil
+}
+
+// parseConfigBool accepts on/off, true/false, and yes/no, case-insensitively.
+func parseConfigBool(entry configEntry) (bool, error) {
+	switch strings.ToLower(entry.value) {
+	case "on", "true", "yes":
+		return true, nil
+	case "off", "false", "no":
+		return false, nil
+	}
+	return false, configError{line: entry.line, msg: fmt.Sprintf("%s: expected on or off, got %q", entry.key, entry.value)}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableVirtualTerminal is a no-op outside Windows; Unix terminals interpret
+// escape sequences natively and advertise their capabilities through TERM.
+func enableVirtualTerminal(fd uintptr) bool {
+	return false
+}
+
+// consoleTitleSetter returns nil outside Windows; titles are set via OSC.
+func consoleTitleSetter() func(string) {
+	return nil
+}
+
+// notifyResize relays SIGWINCH, sent when the terminal window is resized,
+// to c. The returned function stops the relay.
+func notifyResize(c chan<- os.Signal) func() {
+	signal.Notify(c, syscall.SIGWINCH)
+	return func() { signal.Stop(c) }
+}
+
+func isInForeground(fd uintptr) bool {
+	pgrp, err := unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	if err != nil {
+		return true // assume foreground if we can't determine
+	}
+	return pgrp == unix.Getpgrp()
+}
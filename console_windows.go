@@ -0,0 +1,54 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procSetConsoleTitleW = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetConsoleTitleW")
+
+// enableVirtualTerminal turns on ANSI escape handling for the console behind fd.
+// Classic conhost leaves it off by default, which would print raw escape
+// sequences instead of rewriting the countdown line in place.
+func enableVirtualTerminal(fd uintptr) bool {
+	handle := windows.Handle(fd)
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+// consoleTitleSetter returns a SetConsoleTitle-based title updater for consoles
+// that cannot interpret OSC title sequences.
+func consoleTitleSetter() func(string) {
+	if procSetConsoleTitleW.Find() != nil {
+		return nil
+	}
+	return func(title string) {
+		p, err := windows.UTF16PtrFromString(title)
+		if err != nil {
+			return
+		}
+		_, _, _ = procSetConsoleTitleW.Call(uintptr(unsafe.Pointer(p)))
+	}
+}
+
+// notifyResize is a no-op on Windows, which has no SIGWINCH; the countdown
+// picks up a new console size on its next redraw.
+func notifyResize(c chan<- os.Signal) func() {
+	return func() {}
+}
+
+// isInForeground always reports true: a Windows console has no foreground
+// process group, and a background job cannot share it.
+func isInForeground(fd uintptr) bool {
+	return true
+}
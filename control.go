@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Every running timer listens on a Unix socket named <pid>.sock in the
+// control directory. Clients send one JSON request per connection and read
+// one JSON response. The pid doubles as the timer id shown to users.
+
+const controlDialTimeout = 2 * time.Second
+
+const (
+	controlCommandStatus = "status"
+	controlCommandEdit   = "edit"
+	controlCommandPause  = "pause"
+	controlCommandResume = "resume"
+	controlCommandExtend = "extend"
+	controlCommandCancel = "cancel"
+	// controlCommandAck stops a finished timer's --ack or --escalate alarm.
+	controlCommandAck = "ack"
+)
+
+const (
+	timerStateRunning = "running"
+	timerStatePaused  = "paused"
+	// timerStateAlerting is a finished timer waiting to be acknowledged.
+	timerStateAlerting = "alerting"
+)
+
+type controlRequest struct {
+	Command string `json:"command"`
+	// Deadline, when set, moves the timer's end to this instant.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Label, when set, replaces the timer label; an empty string clears it.
+	Label *string `json:"label,omitempty"`
+	// Alert, when set, switches the alert profile; an empty string clears it.
+	Alert *string `json:"alert,omitempty"`
+	// ExtendSeconds is added to the remaining time by the extend command.
+	// Negative values shorten the timer.
+	ExtendSeconds float64 `json:"extend_seconds,omitempty"`
+}
+
+type controlResponse struct {
+	OK    bool          `json:"ok"`
+	Error string        `json:"error,omitempty"`
+	State *controlState `json:"state,omitempty"`
+}
+
+// controlState is the externally visible snapshot of a running timer.
+type controlState struct {
+	ID        int       `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	Alert     string    `json:"alert,omitempty"`
+	State     string    `json:"state"`
+	Started   time.Time `json:"started"`
+	Deadline  time.Time `json:"deadline"`
+	Remaining float64   `json:"remaining_seconds"`
+	Total     float64   `json:"total_seconds"`
+}
+
+// controlCall is a request handed from the socket server to the timer loop.
+type controlCall struct {
+	req   controlRequest
+	reply chan controlResponse
+}
+
+// controlDir returns the per-user directory holding timer sockets:
+// $XDG_RUNTIME_DIR/after, falling back to $TMPDIR/after-<uid>.
+func controlDir(getenv func(string) string) string {
+	if dir := getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "after")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("after-%d", os.Getuid()))
+}
+
+func controlSocketPath(dir string, id int) string {
+	return filepath.Join(dir, strconv.Itoa(id)+".sock")
+}
+
+// controlServer accepts control connections and forwards requests to calls.
+type controlServer struct {
+	listener net.Listener
+	path     string
+	calls    chan controlCall
+	done     chan struct{}
+	// busy covers answering a connection, so a cancel is answered before
+	// the timer exits.
+	busy sync.WaitGroup
+}
+
+// listenControl opens the control socket for the current process. Failure is
+// not fatal to the timer; callers simply run without remote control.
+func listenControl(dir string) (*controlServer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := checkControlDir(dir, os.Getuid()); err != nil {
+		return nil, err
+	}
+	path := controlSocketPath(dir, os.Getpid())
+	_ = os.Remove(path) // stale socket left by a crashed process with our pid
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &controlServer{
+		listener: listener,
+		path:     path,
+		calls:    make(chan controlCall),
+		done:     make(chan struct{}),
+	}
+	go s.serve()
+	return s, nil
+}
+
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.busy.Add(1)
+		go func() {
+			defer s.busy.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlDialTimeout))
+
+	var resp controlResponse
+	var req controlRequest
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp = controlResponse{Error: "malformed request"}
+	} else {
+		resp = s.dispatch(req)
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// dispatch hands req to the timer loop and waits for its reply.
+func (s *controlServer) dispatch(req controlRequest) controlResponse {
+	call := controlCall{req: req, reply: make(chan controlResponse, 1)}
+	select {
+	case s.calls <- call:
+		return <-call.reply
+	case <-s.done:
+		return controlResponse{Error: "timer is exiting"}
+	}
+}
+
+// close stops taking connections and waits, for up to controlDialTimeout,
+// for those being answered.
+func (s *controlServer) close() {
+	close(s.done)
+	_ = s.listener.Close()
+	_ = os.Remove(s.path)
+	answered := make(chan struct{})
+	go func() {
+		s.busy.Wait()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(controlDialTimeout):
+	}
+}
+
+type noSuchTimerError struct {
+	id int
+}
+
+func (e noSuchTimerError) Error() string {
+	return fmt.Sprintf("no running timer with id %d", e.id)
+}
+
+// sendControl delivers req to the timer with the given id.
+func sendControl(dir string, id int, req controlRequest) (controlResponse, error) {
+	conn, err := net.DialTimeout("unix", controlSocketPath(dir, id), controlDialTimeout)
+	if err != nil {
+		return controlResponse{}, noSuchTimerError{id: id}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlDialTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return controlResponse{}, err
+	}
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return controlResponse{}, err
+	}
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// runningTimers returns the state of every timer with a socket in dir,
+// ordered by id. Sockets nobody listens on are left over from killed
+// processes and are removed.
+func runningTimers(dir string) []controlState {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var states []controlState
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".sock")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		resp, err := sendControl(dir, id, controlRequest{Command: controlCommandStatus})
+		if err != nil {
+			var missing noSuchTimerError
+			if errors.As(err, &missing) {
+				_ = os.Remove(controlSocketPath(dir, id))
+			}
+			continue
+		}
+		if resp.State != nil {
+			states = append(states, *resp.State)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states
+}
+
+// callsChan returns the server's call channel, or nil (blocking forever in
+// a select) when the server is not running.
+func (s *controlServer) callsChan() <-chan controlCall {
+	if s == nil {
+		return nil
+	}
+	return s.calls
+}
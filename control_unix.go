@@ -0,0 +1,30 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkControlDir refuses a control directory that another user could have
+// planted: in the shared temp directory, after-<uid> may already exist as a
+// symlink or a directory someone else owns. It must be a real directory
+// owned by uid that only uid can enter.
+func checkControlDir(dir string, uid int) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("control directory %s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != uid {
+		return fmt.Errorf("control directory %s is not owned by uid %d", dir, uid)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		return fmt.Errorf("control directory %s has mode %#o, want 0700", dir, perm)
+	}
+	return nil
+}
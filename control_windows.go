@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkControlDir refuses a control directory that is not a real directory.
+// Windows has no uid or permission bits to compare; the per-user temp
+// directory the control directory lives in is already private to the user.
+func checkControlDir(dir string, uid int) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("control directory %s is not a directory", dir)
+	}
+	return nil
+}
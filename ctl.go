@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// "after ctl" is the command line for automation: macOS Shortcuts (the Run
+// Shell Script action), AppleScript's do shell script, and scripts in
+// general. Every command prints one JSON value on stdout: a timer, as
+// after serve's REST API returns it, a list of them, or {"error": "..."}
+// with a non-zero exit status.
+
+const ctlUsageText = "Usage: after ctl <command> [<args>]\n\n" +
+	"Starts and controls timers for Shortcuts, AppleScript, and scripts,\n" +
+	"printing JSON: the timer, a list for list, or {\"error\": ...}.\n\n" +
+	"  start <duration|time> [<flags>...]  start a timer in the background\n" +
+	"  list                                every running timer\n" +
+	"  get [<id>]                          one timer\n" +
+	"  pause [<id>]                        pause a timer\n" +
+	"  resume [<id>]                       resume a paused timer\n" +
+	"  extend [<id>] <duration>            add time to a timer\n" +
+	"  cancel [<id>]                       cancel a timer\n\n" +
+	"<id> may be omitted when only one timer is running."
+
+// ctlCommands maps the ctl commands taking only an id to control commands.
+var ctlCommands = map[string]string{
+	"get":    controlCommandStatus,
+	"pause":  controlCommandPause,
+	"resume": controlCommandResume,
+	"cancel": controlCommandCancel,
+}
+
+// runCtl carries out a ctl command on the timers in dir, starting new ones
+// with start, and returns what to print.
+func runCtl(args []string, dir string, units map[string]time.Duration, start func(args []string) (int, error)) (any, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "start":
+		if len(args) == 0 {
+			return nil, errUsage
+		}
+		inv, err := parseInvocationWithUnits(append([]string{"after"}, args...), units)
+		if err == nil && inv.mode != modeRun {
+			err = errUsage
+		}
+		if err != nil {
+			return nil, err
+		}
+		return startTimer(dir, start, args)
+
+	case "list":
+		if len(args) != 0 {
+			return nil, errUsage
+		}
+		timers := runningTimers(dir)
+		if timers == nil {
+			timers = []controlState{}
+		}
+		return timers, nil
+	}
+
+	req := controlRequest{Command: ctlCommands[command]}
+	if command == "extend" {
+		if len(args) == 0 {
+			return nil, errUsage
+		}
+		d, target, err := parseDurationToken(args[len(args)-1])
+		if err == nil && !target.IsZero() {
+			err = errors.New("extend takes a duration, not a time")
+		}
+		if err != nil {
+			return nil, err
+		}
+		req = controlRequest{Command: controlCommandExtend, ExtendSeconds: d.Seconds()}
+		args = args[:len(args)-1]
+	}
+	if req.Command == "" || len(args) > 1 || len(args) == 1 && !isTimerID(args[0]) {
+		return nil, errUsage
+	}
+	id := 0
+	if len(args) == 1 {
+		id, _ = strconv.Atoi(args[0])
+	}
+	id, err := resolveTimerID(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sendControl(dir, id, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.State, nil
+}
+
+func runCtlCommand(args []string, stdout, stderr io.Writer) int {
+	// A broken config is the timers' to report; ctl just goes without
+	// custom units.
+	cfg, _ := loadConfig(configPath(os.Getenv))
+	result, err := runCtl(args, controlDir(os.Getenv), cfg.units, startDetachedTimer)
+	if err != nil {
+		result = map[string]string{"error": err.Error()}
+	}
+	_ = json.NewEncoder(stdout).Encode(result)
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintln(stderr, ctlUsageText)
+		return 2
+	case err != nil:
+		return 1
+	}
+	return 0
+}
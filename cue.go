@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Cues are short sounds played while the countdown runs: a soft tick with
+// --tick-sound, a chime at each interval with --every or halfway through
+// with --halfway, and a beep for each of the last seconds with
+// --final-beeps.
+// They go through the alarm backends, but each is rendered once and its
+// player resolved once, so a cue only starts a process.
+
+const (
+	defaultTickInterval = time.Second
+	tickVolume          = 0.3
+	// finalBeepCheckInterval is how often the remaining time is compared
+	// with the next final beep.
+	finalBeepCheckInterval = 20 * time.Millisecond
+	maxFinalBeeps          = 60
+	everyVolume            = 0.6
+)
+
+// tickSound is a short, dry click: a high note that dies away almost at
+// once.
+var tickSound = builtinSound{
+	notes:    []soundNote{{freq: 2000, length: 30 * time.Millisecond}},
+	partials: []soundPartial{{1, 1}, {2.7, 0.4}},
+	decay:    60,
+}
+
+// finalBeepSound is a short, plain beep, like a kitchen timer's.
+var finalBeepSound = builtinSound{
+	notes:    []soundNote{{freq: 1000, length: 120 * time.Millisecond}},
+	partials: []soundPartial{{1, 1}, {3, 0.15}},
+}
+
+// cuePlayer plays a cue through the first backend that works. A cue that
+// comes while the last one is still playing is skipped rather than queued,
+// so a slow backend cannot fall behind the clock.
+type cuePlayer struct {
+	mu       sync.Mutex
+	commands []alarmCommand
+	volume   float64
+	playing  bool
+	run      func(alarmCommand) error
+}
+
+// newCuePlayer renders cue as name and resolves its backends, honoring the
+// backends sound asks for. It reports false when nothing can play it.
+func newCuePlayer(sound alarmSound, name string, cue builtinSound, volume float64) (*cuePlayer, bool) {
+	dir, err := soundCacheDir()
+	if err != nil {
+		return nil, false
+	}
+	path, err := writeSound(dir, name, cue)
+	if err != nil {
+		return nil, false
+	}
+	sound.soundFile = path
+	commands := resolveAlarmCommands(sound)
+	if len(commands) == 0 {
+		return nil, false
+	}
+	return &cuePlayer{commands: commands, volume: volume, run: runAlarmCommand}, true
+}
+
+// play starts the cue in the background. A backend that fails is dropped
+// and the next one is tried the next time.
+func (p *cuePlayer) play() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.playing || len(p.commands) == 0 {
+		return
+	}
+	p.playing = true
+	command := p.commands[0]
+	go func() {
+		err := p.run(command.withVolume(p.volume))
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.playing = false
+		if err != nil && len(p.commands) > 0 && p.commands[0].name == command.name {
+			p.commands = p.commands[1:]
+		}
+	}()
+}
+
+// finalBeepMilestones are the remaining times of the last n seconds, when
+// --final-beeps beeps.
+func finalBeepMilestones(n int) []time.Duration {
+	milestones := make([]time.Duration, n)
+	for i := range milestones {
+		milestones[i] = time.Duration(n-i) * time.Second
+	}
+	return milestones
+}
+
+// everyPeriods is how many whole --every intervals fit in elapsed. The
+// chime sounds each time it goes up.
+func everyPeriods(elapsed, every time.Duration) int {
+	if every <= 0 || elapsed <= 0 {
+		return 0
+	}
+	return int(elapsed / every)
+}
+
+// everyNotificationBody is the --every-notify text, e.g. "tea: 20 minutes
+// elapsed, 10 minutes left".
+func everyNotificationBody(label string, elapsed, remaining time.Duration) string {
+	text := formatSpokenDuration(elapsed) + " elapsed, " + formatSpokenDuration(remaining) + " left"
+	if label != "" {
+		return label + ": " + text
+	}
+	return text
+}
+
+// reachedHalfway reports whether half of a countdown of length total has
+// run.
+func reachedHalfway(elapsed, total time.Duration) bool {
+	return total > 0 && elapsed >= total/2
+}
+
+// halfwayNotificationBody is the --halfway text, e.g. "talk: halfway, 15
+// minutes left".
+func halfwayNotificationBody(label string, remaining time.Duration) string {
+	text := "halfway, " + formatSpokenDuration(remaining) + " left"
+	if label != "" {
+		return label + ": " + text
+	}
+	return text
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	dashRefreshInterval = 500 * time.Millisecond
+	dashExtendStep      = time.Minute
+	dashBarWidth        = 20
+)
+
+const dashUsageText = "Usage: after dash\n\n" +
+	"Shows every running timer in a live table.\n" +
+	"Keys: up/down or j/k select, p pause/resume, + extend 1m, - shorten 1m,\n" +
+	"      c cancel, q quit."
+
+type dashKey int
+
+const (
+	dashKeyNone dashKey = iota
+	dashKeyUp
+	dashKeyDown
+	dashKeyPause
+	dashKeyExtend
+	dashKeyShorten
+	dashKeyCancel
+	dashKeyQuit
+)
+
+// parseDashKey maps one read from the terminal to a dashboard key. Arrow keys
+// arrive as the three-byte sequences ESC [ A and ESC [ B; a lone ESC quits.
+func parseDashKey(b []byte) dashKey {
+	if len(b) == 0 {
+		return dashKeyNone
+	}
+	if len(b) >= 3 && b[0] == 0x1B && b[1] == '[' {
+		switch b[2] {
+		case 'A':
+			return dashKeyUp
+		case 'B':
+			return dashKeyDown
+		}
+		return dashKeyNone
+	}
+	switch b[0] {
+	case 'k':
+		return dashKeyUp
+	case 'j':
+		return dashKeyDown
+	case 'p', ' ':
+		return dashKeyPause
+	case '+', '=':
+		return dashKeyExtend
+	case '-', '_':
+		return dashKeyShorten
+	case 'c', 'x':
+		return dashKeyCancel
+	case 'q', 'Q', 0x1B, 0x03, 0x04:
+		return dashKeyQuit
+	}
+	return dashKeyNone
+}
+
+// formatProgressBar renders elapsed progress as "[#####-----]".
+func formatProgressBar(fraction float64, width int) string {
+	fraction = min(max(fraction, 0), 1)
+	filled := int(fraction*float64(width) + 0.5)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+func timerProgress(state controlState) float64 {
+	if state.Total <= 0 {
+		return 1
+	}
+	return 1 - state.Remaining/state.Total
+}
+
+// renderDash draws the full dashboard screen. Lines end in \r\n because the
+// terminal is in raw mode.
+func renderDash(states []controlState, selectedID int, message string) string {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "after dash: %d running\r\n\r\n", len(states))
+
+	if len(states) == 0 {
+		b.WriteString("  No running timers.\r\n")
+	} else {
+		fmt.Fprintf(&b, "  %-8s %-20s %10s  %-*s  %s\r\n", "ID", "LABEL", "REMAINING", dashBarWidth+2, "PROGRESS", "STATE")
+		for _, state := range states {
+			cursor := " "
+			if state.ID == selectedID {
+				cursor = ">"
+			}
+			label := state.Label
+			if stringWidth(label) > 20 {
+				label = cutToWidth(label, 19) + "~"
+			}
+			remaining := formatRemainingTime(time.Duration(state.Remaining * float64(time.Second)))
+			fmt.Fprintf(&b, "%s %-8d %s %10s  %s  %s\r\n", cursor, state.ID, padToWidth(label, 20), remaining, formatProgressBar(timerProgress(state), dashBarWidth), state.State)
+		}
+	}
+
+	b.WriteString("\r\n  up/down select  p pause/resume  + extend 1m  - shorten 1m  c cancel  q quit\r\n")
+	if message != "" {
+		fmt.Fprintf(&b, "\r\n  %s\r\n", message)
+	}
+	return b.String()
+}
+
+// moveDashSelection returns the id after moving delta rows from selectedID,
+// staying within the list. A selection that vanished snaps to the first row.
+func moveDashSelection(states []controlState, selectedID int, delta int) int {
+	if len(states) == 0 {
+		return 0
+	}
+	index := 0
+	for i, state := range states {
+		if state.ID == selectedID {
+			index = i
+			break
+		}
+	}
+	index = min(max(index+delta, 0), len(states)-1)
+	return states[index].ID
+}
+
+// dashAction builds the control request for key acting on the selected timer.
+func dashAction(key dashKey, selected controlState) (controlRequest, bool) {
+	switch key {
+	case dashKeyPause:
+		if selected.State == timerStatePaused {
+			return controlRequest{Command: controlCommandResume}, true
+		}
+		return controlRequest{Command: controlCommandPause}, true
+	case dashKeyExtend:
+		return controlRequest{Command: controlCommandExtend, ExtendSeconds: dashExtendStep.Seconds()}, true
+	case dashKeyShorten:
+		return controlRequest{Command: controlCommandExtend, ExtendSeconds: -dashExtendStep.Seconds()}, true
+	case dashKeyCancel:
+		return controlRequest{Command: controlCommandCancel}, true
+	}
+	return controlRequest{}, false
+}
+
+func runDashCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stderr, dashUsageText)
+		return 2
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error: after dash needs an interactive terminal")
+		return 1
+	}
+	defer tty.Close()
+	oldState, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		fmt.Fprintln(stderr, "Error: after dash needs an interactive terminal")
+		return 1
+	}
+	defer func() { _ = term.Restore(int(tty.Fd()), oldState) }()
+
+	// Alternate screen with hidden cursor; both restored on exit.
+	writeStatus(tty, "\033[?1049h\033[?25l")
+	defer writeStatus(tty, "\033[?25h\033[?1049l")
+
+	keys := make(chan dashKey)
+	go func() {
+		buf := make([]byte, 8)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- parseDashKey(buf[:n])
+		}
+	}()
+
+	dir := controlDir(os.Getenv)
+	ticker := time.NewTicker(dashRefreshInterval)
+	defer ticker.Stop()
+
+	selectedID := 0
+	message := ""
+	for {
+		states := runningTimers(dir)
+		selectedID = moveDashSelection(states, selectedID, 0)
+		writeStatus(tty, renderDash(states, selectedID, message))
+
+		select {
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok || key == dashKeyQuit {
+				return 0
+			}
+			message = ""
+			switch key {
+			case dashKeyUp:
+				selectedID = moveDashSelection(states, selectedID, -1)
+			case dashKeyDown:
+				selectedID = moveDashSelection(states, selectedID, 1)
+			default:
+				for _, state := range states {
+					if state.ID != selectedID {
+						continue
+					}
+					if req, ok := dashAction(key, state); ok {
+						if _, err := sendControl(dir, state.ID, req); err != nil {
+							message = "Error: " + err.Error()
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --notify posts desktop notifications straight to the freedesktop
+// notification server over the session D-Bus, so nothing like notify-send
+// has to be installed, and --dbus serves the timer there. after speaks
+// just enough of the wire protocol for that: EXTERNAL authentication,
+// method calls and their replies, and the signals it asks for.
+
+// dbusTimeout bounds a whole exchange with the bus, so a stuck
+// notification server cannot hold up the timer.
+const dbusTimeout = 2 * time.Second
+
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+	dbusSignal       = 4
+
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldErrorName   = 4
+	dbusFieldReplySerial = 5
+	dbusFieldDestination = 6
+	dbusFieldSender      = 7
+	dbusFieldSignature   = 8
+)
+
+// sessionBusAddress is where the session bus listens: the address the
+// session exported, or the socket systemd puts in the runtime directory.
+func sessionBusAddress(getenv func(string) string) string {
+	if addr := getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	if dir := getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return "unix:path=" + dir + "/bus"
+	}
+	return ""
+}
+
+// dialDBus connects to the first usable unix socket among the
+// ';'-separated entries of addr, such as "unix:path=/run/user/1000/bus".
+func dialDBus(addr string) (net.Conn, error) {
+	err := errors.New("no session bus address")
+	for _, entry := range strings.Split(addr, ";") {
+		transport, params, ok := strings.Cut(entry, ":")
+		if !ok || transport != "unix" {
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			value, uerr := url.PathUnescape(value)
+			if uerr != nil {
+				continue
+			}
+			var path string
+			switch key {
+			case "path":
+				path = value
+			case "abstract":
+				path = "@" + value
+			default:
+				continue
+			}
+			var conn net.Conn
+			if conn, err = net.DialTimeout("unix", path, dbusTimeout); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// dbusAuthenticate proves who we are with EXTERNAL, which the bus checks
+// against the socket's peer credentials.
+func dbusAuthenticate(rw io.ReadWriter, r *bufio.Reader, uid int) error {
+	uidHex := hex.EncodeToString([]byte(strconv.Itoa(uid)))
+	if _, err := io.WriteString(rw, "\x00AUTH EXTERNAL "+uidHex+"\r\n"); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("d-bus authentication rejected: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(rw, "BEGIN\r\n")
+	return err
+}
+
+// dbusEncoder writes values in D-Bus's little-endian wire format, where
+// every value is aligned to its own size from the start of the message.
+type dbusEncoder struct {
+	bytes.Buffer
+}
+
+func (e *dbusEncoder) align(n int) {
+	for e.Len()%n != 0 {
+		e.WriteByte(0)
+	}
+}
+
+func (e *dbusEncoder) uint32(v uint32) {
+	e.align(4)
+	_ = binary.Write(e, binary.LittleEndian, v)
+}
+
+func (e *dbusEncoder) int32(v int32) {
+	e.uint32(uint32(v))
+}
+
+func (e *dbusEncoder) int64(v int64) {
+	e.align(8)
+	_ = binary.Write(e, binary.LittleEndian, v)
+}
+
+func (e *dbusEncoder) float64(v float64) {
+	e.align(8)
+	_ = binary.Write(e, binary.LittleEndian, v)
+}
+
+func (e *dbusEncoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.WriteString(s)
+	e.WriteByte(0)
+}
+
+func (e *dbusEncoder) signature(s string) {
+	e.WriteByte(byte(len(s)))
+	e.WriteString(s)
+	e.WriteByte(0)
+}
+
+// array writes an array whose elements align to elemAlign, with the
+// length filled in once elems has written them.
+func (e *dbusEncoder) array(elemAlign int, elems func()) {
+	e.uint32(0)
+	at := e.Len()
+	e.align(elemAlign)
+	start := e.Len()
+	elems()
+	binary.LittleEndian.PutUint32(e.Bytes()[at-4:at], uint32(e.Len()-start))
+}
+
+// dbusCall is a method call to a named service.
+type dbusCall struct {
+	destination string
+	path        string
+	iface       string
+	member      string
+	signature   string
+	// body writes the arguments; its encoder is aligned from the start of
+	// the body, as the protocol requires.
+	body func(e *dbusEncoder)
+}
+
+// encodeDBusCall renders call as message serial.
+func encodeDBusCall(call dbusCall, serial uint32) []byte {
+	return encodeDBusMessage(dbusMethodCall, serial, call.signature, call.body, func(field func(code byte, sig string, value any)) {
+		field(dbusFieldPath, "o", call.path)
+		field(dbusFieldDestination, "s", call.destination)
+		field(dbusFieldInterface, "s", call.iface)
+		field(dbusFieldMember, "s", call.member)
+	})
+}
+
+// dbusReply answers a method call: a method return, or an error reply
+// when errorName is set.
+type dbusReply struct {
+	destination string
+	replySerial uint32
+	errorName   string
+	signature   string
+	body        func(e *dbusEncoder)
+}
+
+// encodeDBusReply renders reply as message serial.
+func encodeDBusReply(reply dbusReply, serial uint32) []byte {
+	kind := byte(dbusMethodReturn)
+	if reply.errorName != "" {
+		kind = dbusError
+	}
+	return encodeDBusMessage(kind, serial, reply.signature, reply.body, func(field func(code byte, sig string, value any)) {
+		field(dbusFieldReplySerial, "u", reply.replySerial)
+		if reply.destination != "" {
+			field(dbusFieldDestination, "s", reply.destination)
+		}
+		if reply.errorName != "" {
+			field(dbusFieldErrorName, "s", reply.errorName)
+		}
+	})
+}
+
+// encodeDBusMessage renders a message of kind with the header fields that
+// fields writes, each a string or a uint32, and body of signature.
+func encodeDBusMessage(kind byte, serial uint32, signature string, body func(e *dbusEncoder), fields func(field func(code byte, sig string, value any))) []byte {
+	var b dbusEncoder
+	if body != nil {
+		body(&b)
+	}
+	var e dbusEncoder
+	e.WriteString("l")
+	e.WriteByte(kind)
+	e.WriteByte(0) // flags
+	e.WriteByte(1) // protocol version
+	e.uint32(uint32(b.Len()))
+	e.uint32(serial)
+	field := func(code byte, sig string, value any) {
+		e.align(8)
+		e.WriteByte(code)
+		e.signature(sig)
+		switch v := value.(type) {
+		case uint32:
+			e.uint32(v)
+		case string:
+			if sig == "g" {
+				e.signature(v)
+			} else {
+				e.string(v)
+			}
+		}
+	}
+	e.array(8, func() {
+		fields(field)
+		if signature != "" {
+			field(dbusFieldSignature, "g", signature)
+		}
+	})
+	e.align(8)
+	e.Write(b.Bytes())
+	return e.Bytes()
+}
+
+// dbusMessage is what after needs from a message the bus sends: its type
+// and serial, the call it answers, the error name of an error reply, who
+// sent a call or signal and what it is for, and the body of signature,
+// read with order.
+type dbusMessage struct {
+	kind        byte
+	serial      uint32
+	replySerial uint32
+	errorName   string
+	sender      string
+	path        string
+	iface       string
+	member      string
+	signature   string
+	order       binary.ByteOrder
+	body        []byte
+}
+
+// readDBusMessage reads one message.
+func readDBusMessage(r io.Reader) (dbusMessage, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return dbusMessage{}, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fixed[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLen := order.Uint32(fixed[4:8])
+	fieldsLen := order.Uint32(fixed[12:16])
+	if bodyLen > 1<<20 || fieldsLen > 1<<16 {
+		return dbusMessage{}, errors.New("d-bus message too large")
+	}
+	headerLen := 16 + int(fieldsLen)
+	padded := (headerLen + 7) &^ 7
+	rest := make([]byte, padded-16+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return dbusMessage{}, err
+	}
+	// Offsets below count from the start of the message.
+	msg := append(fixed[:], rest...)
+	m := dbusMessage{kind: fixed[1], serial: order.Uint32(fixed[8:12]), order: order, body: msg[padded:]}
+	for off := 16; off < headerLen; {
+		off = (off + 7) &^ 7
+		if off+3 > headerLen {
+			break
+		}
+		code, sigLen := msg[off], int(msg[off+1])
+		if off+3+sigLen > headerLen {
+			return m, errors.New("d-bus header field out of bounds")
+		}
+		sig := string(msg[off+2 : off+2+sigLen])
+		off += 3 + sigLen
+		switch sig {
+		case "u":
+			off = (off + 3) &^ 3
+			if off+4 > headerLen {
+				return m, errors.New("d-bus header field out of bounds")
+			}
+			if code == dbusFieldReplySerial {
+				m.replySerial = order.Uint32(msg[off:])
+			}
+			off += 4
+		case "s", "o":
+			off = (off + 3) &^ 3
+			if off+4 > headerLen {
+				return m, errors.New("d-bus header field out of bounds")
+			}
+			n := int(order.Uint32(msg[off:]))
+			if off+4+n > headerLen {
+				return m, errors.New("d-bus header field out of bounds")
+			}
+			value := string(msg[off+4 : off+4+n])
+			switch code {
+			case dbusFieldErrorName:
+				m.errorName = value
+			case dbusFieldSender:
+				m.sender = value
+			case dbusFieldPath:
+				m.path = value
+			case dbusFieldInterface:
+				m.iface = value
+			case dbusFieldMember:
+				m.member = value
+			}
+			off += 4 + n + 1
+		case "g":
+			if off >= headerLen || off+1+int(msg[off]) > headerLen {
+				return m, errors.New("d-bus header field out of bounds")
+			}
+			if code == dbusFieldSignature {
+				m.signature = string(msg[off+1 : off+1+int(msg[off])])
+			}
+			off += 1 + int(msg[off]) + 1
+		default:
+			return m, fmt.Errorf("d-bus header field of type %q", sig)
+		}
+	}
+	return m, nil
+}
+
+// dbusDecoder reads the values of a message body in order.
+type dbusDecoder struct {
+	b     []byte
+	off   int
+	order binary.ByteOrder
+}
+
+func (d *dbusDecoder) uint32() (uint32, error) {
+	d.off = (d.off + 3) &^ 3
+	if d.off+4 > len(d.b) {
+		return 0, errors.New("d-bus body too short")
+	}
+	v := d.order.Uint32(d.b[d.off:])
+	d.off += 4
+	return v, nil
+}
+
+func (d *dbusDecoder) int32() (int32, error) {
+	v, err := d.uint32()
+	return int32(v), err
+}
+
+func (d *dbusDecoder) string() (string, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	if d.off+int(n)+1 > len(d.b) {
+		return "", errors.New("d-bus body too short")
+	}
+	s := string(d.b[d.off : d.off+int(n)])
+	d.off += int(n) + 1
+	return s, nil
+}
+
+// dbusConn is an authenticated connection to a bus. Only one goroutine
+// reads from it at a time.
+type dbusConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	mu     sync.Mutex
+	serial uint32
+}
+
+// openSessionBus connects to the bus at addr and says Hello, which the bus
+// wants before anything else. The connection gives up on the bus after
+// dbusTimeout until the deadline is cleared.
+func openSessionBus(addr string) (*dbusConn, error) {
+	conn, err := dialDBus(addr)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(dbusTimeout))
+	c := &dbusConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := dbusAuthenticate(conn, c.r, os.Getuid()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	hello := dbusCall{destination: "org.freedesktop.DBus", path: "/org/freedesktop/DBus", iface: "org.freedesktop.DBus", member: "Hello"}
+	if _, err := c.call(hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// send writes call without waiting for the reply and returns its serial.
+func (c *dbusConn) send(call dbusCall) (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+	_, err := c.conn.Write(encodeDBusCall(call, c.serial))
+	return c.serial, err
+}
+
+// call makes call and returns its reply, reporting an error reply as an
+// error. Messages read meanwhile, such as signals, are dropped.
+func (c *dbusConn) call(call dbusCall) (dbusMessage, error) {
+	serial, err := c.send(call)
+	if err != nil {
+		return dbusMessage{}, err
+	}
+	for {
+		m, err := readDBusMessage(c.r)
+		if err != nil {
+			return dbusMessage{}, err
+		}
+		switch {
+		case m.kind == dbusError && m.replySerial == serial:
+			return m, fmt.Errorf("d-bus %s.%s: %s", call.iface, call.member, m.errorName)
+		case m.kind == dbusMethodReturn && m.replySerial == serial:
+			return m, nil
+		}
+	}
+}
+
+// reply answers a method call.
+func (c *dbusConn) reply(reply dbusReply) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serial++
+	_, err := c.conn.Write(encodeDBusReply(reply, c.serial))
+	return err
+}
+
+func (c *dbusConn) Close() error {
+	return c.conn.Close()
+}
+
+// callSessionBus makes call on the session bus at addr and waits for its
+// reply, reporting an error reply as an error.
+func callSessionBus(addr string, call dbusCall) error {
+	c, err := openSessionBus(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = c.call(call)
+	return err
+}
+
+// freedesktopCompleteSound is the sound theme's name for a finished task,
+// asked for by a notification with sound.
+const freedesktopCompleteSound = "complete"
+
+// notifyCall posts n through the freedesktop Notifications service, with
+// buttons for actions, given as pairs of key and label. A notification
+// with buttons is critical, so the server keeps it until one is pressed.
+func notifyCall(n desktopNotification, actions ...string) dbusCall {
+	return dbusCall{
+		destination: "org.freedesktop.Notifications",
+		path:        "/org/freedesktop/Notifications",
+		iface:       "org.freedesktop.Notifications",
+		member:      "Notify",
+		signature:   "susssasa{sv}i",
+		body: func(e *dbusEncoder) {
+			e.string("after") // app_name
+			e.uint32(0)       // replaces_id
+			e.string("")      // app_icon
+			e.string(n.title)
+			e.string(n.body())
+			e.array(4, func() {
+				for _, a := range actions {
+					e.string(a)
+				}
+			})
+			e.array(8, func() { // hints
+				if n.sound {
+					e.align(8)
+					e.string("sound-name")
+					e.signature("s")
+					e.string(freedesktopCompleteSound)
+				}
+				if len(actions) > 0 {
+					e.align(8)
+					e.string("urgency")
+					e.signature("y")
+					e.WriteByte(2) // critical
+				}
+			})
+			e.int32(-1) // expire_timeout: the server's default
+		},
+	}
+}
+
+// A notificationWatch reports the buttons pressed on a posted
+// notification.
+type notificationWatch struct {
+	// C receives the key of each button pressed. It is closed when the
+	// notification goes away or the bus does.
+	C    <-chan string
+	conn *dbusConn
+	id   uint32
+	done chan struct{}
+}
+
+// watchNotification posts n with buttons for actions, pairs of key and
+// label, and stays connected to hear them pressed.
+func watchNotification(addr string, n desktopNotification, actions ...string) (*notificationWatch, error) {
+	c, err := openSessionBus(addr)
+	if err != nil {
+		return nil, err
+	}
+	match := dbusCall{destination: "org.freedesktop.DBus", path: "/org/freedesktop/DBus", iface: "org.freedesktop.DBus", member: "AddMatch", signature: "s",
+		body: func(e *dbusEncoder) {
+			e.string("type='signal',interface='org.freedesktop.Notifications'")
+		}}
+	if _, err := c.call(match); err != nil {
+		c.Close()
+		return nil, err
+	}
+	reply, err := c.call(notifyCall(n, actions...))
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	id, err := (&dbusDecoder{b: reply.body, order: reply.order}).uint32()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	// The buttons may be pressed any time from now on.
+	_ = c.conn.SetDeadline(time.Time{})
+	ch := make(chan string, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(ch)
+		for {
+			m, err := readDBusMessage(c.r)
+			if err != nil || m.kind != dbusSignal || m.iface != "org.freedesktop.Notifications" {
+				if err != nil {
+					return
+				}
+				continue
+			}
+			d := &dbusDecoder{b: m.body, order: m.order}
+			if got, err := d.uint32(); err != nil || got != id {
+				continue
+			}
+			switch m.member {
+			case "ActionInvoked":
+				if key, err := d.string(); err == nil {
+					select {
+					case ch <- key:
+					case <-done:
+						return
+					}
+				}
+			case "NotificationClosed":
+				return
+			}
+		}
+	}()
+	return &notificationWatch{C: ch, conn: c, id: id, done: done}, nil
+}
+
+// Close takes the notification down and disconnects.
+func (w *notificationWatch) Close() {
+	closeCall := dbusCall{destination: "org.freedesktop.Notifications", path: "/org/freedesktop/Notifications", iface: "org.freedesktop.Notifications", member: "CloseNotification", signature: "u",
+		body: func(e *dbusEncoder) {
+			e.uint32(w.id)
+		}}
+	_ = w.conn.conn.SetWriteDeadline(time.Now().Add(dbusTimeout))
+	_, _ = w.conn.send(closeCall)
+	close(w.done)
+	w.conn.Close()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --dbus publishes the running timer on the session bus as
+// dev.mtnman.Timer, so desktop widgets and shell extensions can show the
+// countdown and control it:
+//
+//	gdbus call --session --dest dev.mtnman.Timer --object-path /dev/mtnman/Timer \
+//	    --method dev.mtnman.Timer.Extend 300
+//
+// Its properties are read-only and read fresh on every Get. With several
+// timers, the bus queues them for the name: the first one started owns it,
+// and the next takes over when it ends. Requests go through the control
+// socket's dispatch, so they behave exactly like after pause and friends.
+
+const (
+	dbusServiceName      = "dev.mtnman.Timer"
+	dbusServicePath      = "/dev/mtnman/Timer"
+	dbusServiceInterface = "dev.mtnman.Timer"
+	// dbusServiceFailed is the error a request the timer refused
+	// replies with, carrying the reason.
+	dbusServiceFailed = "dev.mtnman.Timer.Error.Failed"
+)
+
+const (
+	dbusPropertiesInterface     = "org.freedesktop.DBus.Properties"
+	dbusIntrospectableInterface = "org.freedesktop.DBus.Introspectable"
+	dbusPeerInterface           = "org.freedesktop.DBus.Peer"
+)
+
+// RequestName replies: the name is ours, or we wait in line for it.
+const (
+	dbusNamePrimaryOwner = 1
+	dbusNameInQueue      = 2
+)
+
+// dbusProperty is one property of the service, read from the timer's
+// state as an int32, int64, float64, or string matching sig.
+type dbusProperty struct {
+	name  string
+	sig   string
+	value func(s controlState) any
+}
+
+var dbusServiceProperties = []dbusProperty{
+	{name: "Id", sig: "i", value: func(s controlState) any { return int32(s.ID) }},
+	{name: "Label", sig: "s", value: func(s controlState) any { return s.Label }},
+	{name: "State", sig: "s", value: func(s controlState) any { return s.State }},
+	{name: "Remaining", sig: "d", value: func(s controlState) any { return s.Remaining }},
+	{name: "Total", sig: "d", value: func(s controlState) any { return s.Total }},
+	{name: "Started", sig: "x", value: func(s controlState) any { return s.Started.Unix() }},
+	{name: "Deadline", sig: "x", value: func(s controlState) any { return s.Deadline.Unix() }},
+}
+
+// dbusServiceMethods maps the service's methods without arguments to
+// control commands. Extend takes the seconds to add.
+var dbusServiceMethods = map[string]string{
+	"Pause":  controlCommandPause,
+	"Resume": controlCommandResume,
+	"Cancel": controlCommandCancel,
+}
+
+// dbusServiceIntrospection describes the object, for gdbus introspect and
+// the tools built on it.
+func dbusServiceIntrospection() string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="` + dbusServiceInterface + `">
+    <method name="Pause"/>
+    <method name="Resume"/>
+    <method name="Cancel"/>
+    <method name="Extend"><arg name="seconds" type="i" direction="in"/></method>
+`)
+	for _, p := range dbusServiceProperties {
+		fmt.Fprintf(&b, "    <property name=%q type=%q access=\"read\"/>\n", p.name, p.sig)
+	}
+	b.WriteString(`  </interface>
+  <interface name="` + dbusPropertiesInterface + `">
+    <method name="Get"><arg name="interface" type="s" direction="in"/><arg name="name" type="s" direction="in"/><arg name="value" type="v" direction="out"/></method>
+    <method name="GetAll"><arg name="interface" type="s" direction="in"/><arg name="properties" type="a{sv}" direction="out"/></method>
+  </interface>
+  <interface name="` + dbusIntrospectableInterface + `">
+    <method name="Introspect"><arg name="xml_data" type="s" direction="out"/></method>
+  </interface>
+  <interface name="` + dbusPeerInterface + `">
+    <method name="Ping"/>
+  </interface>
+</node>
+`)
+	return b.String()
+}
+
+// writeDBusVariant writes value as a variant of signature sig.
+func writeDBusVariant(e *dbusEncoder, sig string, value any) {
+	e.signature(sig)
+	switch v := value.(type) {
+	case int32:
+		e.int32(v)
+	case int64:
+		e.int64(v)
+	case float64:
+		e.float64(v)
+	case string:
+		e.string(v)
+	}
+}
+
+// dbusServiceCall answers m, a method call to the service, reaching the
+// timer through dispatch.
+func dbusServiceCall(m dbusMessage, dispatch func(controlRequest) controlResponse) dbusReply {
+	reply := dbusReply{destination: m.sender, replySerial: m.serial}
+	fail := func(name, format string, args ...any) dbusReply {
+		reply.errorName = name
+		reply.signature = "s"
+		reply.body = func(e *dbusEncoder) { e.string(fmt.Sprintf(format, args...)) }
+		return reply
+	}
+	if m.path != dbusServicePath {
+		return fail("org.freedesktop.DBus.Error.UnknownObject", "no object at %s", m.path)
+	}
+	args := &dbusDecoder{b: m.body, order: m.order}
+	control := func(req controlRequest) dbusReply {
+		resp := dispatch(req)
+		if !resp.OK {
+			return fail(dbusServiceFailed, "%s", resp.Error)
+		}
+		return reply
+	}
+	state := func() (controlState, error) {
+		resp := dispatch(controlRequest{Command: controlCommandStatus})
+		if !resp.OK || resp.State == nil {
+			return controlState{}, errors.New(resp.Error)
+		}
+		return *resp.State, nil
+	}
+
+	switch {
+	case (m.iface == dbusServiceInterface || m.iface == "") && dbusServiceMethods[m.member] != "":
+		return control(controlRequest{Command: dbusServiceMethods[m.member]})
+
+	case (m.iface == dbusServiceInterface || m.iface == "") && m.member == "Extend":
+		seconds, err := args.int32()
+		if m.signature != "i" || err != nil {
+			return fail("org.freedesktop.DBus.Error.InvalidArgs", "Extend takes the seconds to add, as an int32")
+		}
+		return control(controlRequest{Command: controlCommandExtend, ExtendSeconds: float64(seconds)})
+
+	case m.iface == dbusPropertiesInterface && m.member == "Get":
+		iface, _ := args.string()
+		name, err := args.string()
+		if m.signature != "ss" || err != nil {
+			return fail("org.freedesktop.DBus.Error.InvalidArgs", "Get takes an interface and a property name")
+		}
+		for _, p := range dbusServiceProperties {
+			if p.name != name || iface != dbusServiceInterface && iface != "" {
+				continue
+			}
+			s, err := state()
+			if err != nil {
+				return fail(dbusServiceFailed, "%v", err)
+			}
+			reply.signature = "v"
+			reply.body = func(e *dbusEncoder) { writeDBusVariant(e, p.sig, p.value(s)) }
+			return reply
+		}
+		return fail("org.freedesktop.DBus.Error.UnknownProperty", "no property %s.%s", iface, name)
+
+	case m.iface == dbusPropertiesInterface && m.member == "GetAll":
+		iface, err := args.string()
+		if m.signature != "s" || err != nil {
+			return fail("org.freedesktop.DBus.Error.InvalidArgs", "GetAll takes an interface")
+		}
+		var s controlState
+		if iface == dbusServiceInterface {
+			if s, err = state(); err != nil {
+				return fail(dbusServiceFailed, "%v", err)
+			}
+		}
+		reply.signature = "a{sv}"
+		reply.body = func(e *dbusEncoder) {
+			e.array(8, func() {
+				if iface != dbusServiceInterface {
+					return
+				}
+				for _, p := range dbusServiceProperties {
+					e.align(8)
+					e.string(p.name)
+					writeDBusVariant(e, p.sig, p.value(s))
+				}
+			})
+		}
+		return reply
+
+	case m.iface == dbusPropertiesInterface && m.member == "Set":
+		return fail("org.freedesktop.DBus.Error.PropertyReadOnly", "the properties of %s are read-only", dbusServiceName)
+
+	case (m.iface == dbusIntrospectableInterface || m.iface == "") && m.member == "Introspect":
+		reply.signature = "s"
+		reply.body = func(e *dbusEncoder) { e.string(dbusServiceIntrospection()) }
+		return reply
+
+	case (m.iface == dbusPeerInterface || m.iface == "") && m.member == "Ping":
+		return reply
+	}
+	return fail("org.freedesktop.DBus.Error.UnknownMethod", "no method %s.%s", m.iface, m.member)
+}
+
+// dbusService serves dev.mtnman.Timer until closed.
+type dbusService struct {
+	conn *dbusConn
+	// busy covers answering a call, so Cancel is answered before the
+	// timer exits.
+	busy sync.WaitGroup
+}
+
+// startDBusService claims the service name on the bus at addr, or a place
+// in line for it, and answers calls through dispatch.
+func startDBusService(addr string, dispatch func(controlRequest) controlResponse) (*dbusService, error) {
+	c, err := openSessionBus(addr)
+	if err != nil {
+		return nil, err
+	}
+	request := dbusCall{destination: "org.freedesktop.DBus", path: "/org/freedesktop/DBus", iface: "org.freedesktop.DBus", member: "RequestName", signature: "su",
+		body: func(e *dbusEncoder) {
+			e.string(dbusServiceName)
+			e.uint32(0) // flags: queue for the name
+		}}
+	reply, err := c.call(request)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	code, err := (&dbusDecoder{b: reply.body, order: reply.order}).uint32()
+	if err == nil && code != dbusNamePrimaryOwner && code != dbusNameInQueue {
+		err = fmt.Errorf("d-bus RequestName %s: reply %d", dbusServiceName, code)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	// Calls may come any time from now on.
+	_ = c.conn.SetDeadline(time.Time{})
+	s := &dbusService{conn: c}
+	go func() {
+		for {
+			m, err := readDBusMessage(c.r)
+			if err != nil {
+				return
+			}
+			if m.kind != dbusMethodCall {
+				continue
+			}
+			s.busy.Add(1)
+			_ = c.reply(dbusServiceCall(m, dispatch))
+			s.busy.Done()
+		}
+	}()
+	return s, nil
+}
+
+// close waits for the call being answered, if any, for up to dbusTimeout:
+// one that came in as the timer exits waits on a timer that is gone.
+func (s *dbusService) close() {
+	answered := make(chan struct{})
+	go func() {
+		s.busy.Wait()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(dbusTimeout):
+	}
+	s.conn.Close()
+}
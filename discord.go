@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// With a webhook URL in the [discord] config section, a timer posts an
+// embed to that Discord channel when it completes, and another when it is
+// cancelled unless "cancel = off":
+//
+//	[discord]
+//	webhook = https://discord.com/api/webhooks/...
+//	template = {{.text}} on {{.host}}
+
+// defaultDiscordTemplate is the embed's description; the title and fields
+// carry the rest.
+const defaultDiscordTemplate = "{{.text}} ({{.detail}})"
+
+// Embed colors, green for a completed timer and red for a cancelled one.
+const (
+	discordColorComplete  = 0x2ecc71
+	discordColorCancelled = 0xe74c3c
+)
+
+// discordConfig is the webhook to post to. The zero value posts nothing.
+type discordConfig struct {
+	webhook  string
+	template string
+	noCancel bool
+}
+
+func (c discordConfig) set() bool {
+	return c.webhook != ""
+}
+
+func parseDiscordSection(section configSection) (discordConfig, error) {
+	var c discordConfig
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "webhook":
+			if !strings.HasPrefix(entry.value, "https://") && !strings.HasPrefix(entry.value, "http://") {
+				return discordConfig{}, configError{line: entry.line, msg: fmt.Sprintf("webhook: expected a URL, got %q", entry.value)}
+			}
+			c.webhook = entry.value
+		case "template":
+			text, err := parseMessageTemplate(entry)
+			if err != nil {
+				return discordConfig{}, err
+			}
+			c.template = text
+		case "cancel":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return discordConfig{}, err
+			}
+			c.noCancel = !v
+		default:
+			return discordConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown discord setting %q", entry.key)}
+		}
+	}
+	return c, nil
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+// discordMessage is the webhook payload about event.
+func discordMessage(c discordConfig, event remoteEvent) (map[string][]discordEmbed, error) {
+	template := c.template
+	if template == "" {
+		template = defaultDiscordTemplate
+	}
+	description, err := renderMessage(template, event)
+	if err != nil {
+		return nil, err
+	}
+	embed := discordEmbed{
+		Title:       event.notification.title,
+		Description: description,
+		Color:       discordColorComplete,
+		Fields:      []discordEmbedField{{Name: "Length", Value: formatSpokenDuration(event.requested), Inline: true}},
+		Timestamp:   event.ended.Format(time.RFC3339),
+	}
+	if event.outcome == outcomeCancelled {
+		embed.Color = discordColorCancelled
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Left", Value: formatSpokenDuration(event.remaining), Inline: true})
+	}
+	if event.host != "" {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "Host", Value: event.host, Inline: true})
+	}
+	return map[string][]discordEmbed{"embeds": {embed}}, nil
+}
+
+// sendDiscord posts the embed about event to the webhook, and reports what
+// Discord rejected.
+func sendDiscord(client *http.Client, c discordConfig, event remoteEvent) error {
+	message, err := discordMessage(c, event)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	resp, err := client.Post(c.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The webhook URL is its own credential.
+		return fmt.Errorf("discord: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	var reply struct {
+		Message string `json:"message"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&reply) == nil && reply.Message != "" {
+		return fmt.Errorf("discord: %s", reply.Message)
+	}
+	return fmt.Errorf("discord: %s", resp.Status)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	discoverBrowseTimeout = 1500 * time.Millisecond
+	discoverPollInterval  = 500 * time.Millisecond
+)
+
+const discoverUsageText = "Usage: after discover [-w <name>]\n\n" +
+	"Lists timers shared on the local network with --share. With -w, mirrors\n" +
+	"the named timer's countdown until it ends; any unique part of the name\n" +
+	"is enough."
+
+// sharedTimer is a timer found on the network.
+type sharedTimer struct {
+	name  string
+	url   string
+	state controlState
+}
+
+func sharedTimerURL(s mdnsService) string {
+	path := shareStatusPath
+	for _, kv := range s.txt {
+		if v, ok := strings.CutPrefix(kv, "path="); ok {
+			path = v
+		}
+	}
+	return "http://" + net.JoinHostPort(s.ips[0].String(), strconv.Itoa(int(s.port))) + path
+}
+
+func fetchSharedStatus(client *http.Client, url string) (controlState, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return controlState{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return controlState{}, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	var state controlState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return controlState{}, err
+	}
+	return state, nil
+}
+
+func discoverSharedTimers(client *http.Client) ([]sharedTimer, error) {
+	services, err := browseMDNS(discoverBrowseTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var timers []sharedTimer
+	for _, s := range services {
+		url := sharedTimerURL(s)
+		state, err := fetchSharedStatus(client, url)
+		if err != nil {
+			continue
+		}
+		timers = append(timers, sharedTimer{name: mdnsInstanceLabel(s.instance), url: url, state: state})
+	}
+	return timers, nil
+}
+
+// matchSharedTimer picks the timer whose name contains query,
+// case-insensitively. An exact name always wins.
+func matchSharedTimer(timers []sharedTimer, query string) (sharedTimer, error) {
+	var matches []sharedTimer
+	for _, t := range timers {
+		if strings.EqualFold(t.name, query) {
+			return t, nil
+		}
+		if strings.Contains(strings.ToLower(t.name), strings.ToLower(query)) {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return sharedTimer{}, fmt.Errorf("no shared timer matches %q", query)
+	case 1:
+		return matches[0], nil
+	}
+	return sharedTimer{}, fmt.Errorf("%d shared timers match %q; be more specific", len(matches), query)
+}
+
+func formatSharedTimer(t sharedTimer) string {
+	line := formatCountdownLine("", formatRemainingTime(time.Duration(t.state.Remaining*float64(time.Second))), t.state.State == timerStatePaused)
+	return fmt.Sprintf("%s  %s remaining (until %s)", t.name, line, t.state.Deadline.Local().Format("15:04:05"))
+}
+
+func runDiscoverCommand(args []string, stdout, stderr io.Writer) int {
+	watch := ""
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && (args[0] == "-w" || args[0] == "--watch"):
+		watch = args[1]
+	default:
+		fmt.Fprintln(stderr, discoverUsageText)
+		return 2
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	timers, err := discoverSharedTimers(client)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if watch == "" {
+		if len(timers) == 0 {
+			fmt.Fprintln(stderr, "No shared timers found.")
+			return 1
+		}
+		for _, t := range timers {
+			fmt.Fprintln(stdout, formatSharedTimer(t))
+		}
+		return 0
+	}
+
+	t, err := matchSharedTimer(timers, watch)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return mirrorSharedTimer(client, t, newStderrStatusDisplay())
+}
+
+// mirrorSharedTimer shows a remote countdown until the timer ends. The
+// sharing process stops serving when its timer finishes, so a failed poll
+// right around the deadline counts as completion.
+func mirrorSharedTimer(client *http.Client, t sharedTimer, status statusDisplay) int {
+	state := t.state
+	lastSeen := time.Now()
+	for {
+		remaining := time.Duration(state.Remaining * float64(time.Second))
+		if state.State != timerStatePaused {
+			remaining -= time.Since(lastSeen)
+		}
+		if status.interactive {
+			renderInteractiveCountdown(status, formatCountdownLine(t.name, formatRemainingTime(max(remaining, 0)), state.State == timerStatePaused), false)
+		}
+
+		time.Sleep(discoverPollInterval)
+		next, err := fetchSharedStatus(client, t.url)
+		if err != nil {
+			if remaining <= discoverPollInterval*2 && state.State != timerStatePaused {
+				printFinalStatus(status, false, "after complete: "+t.name, "after: complete: "+t.name)
+				return 0
+			}
+			printFinalStatus(status, false, "after lost "+t.name, "after: lost "+t.name)
+			return 1
+		}
+		state, lastSeen = next, time.Now()
+	}
+}
@@ -3,28 +3,186 @@ package main
 import (
 	"fmt"
 	"io"
+	"os"
 	"runtime/debug"
 	"strings"
 	"time"
 )
 
 func renderInteractiveCountdown(status statusDisplay, timeStr string, noTitle bool) {
+	renderColoredCountdown(status, timeStr, timeStr, "", "", noTitle)
+}
+
+// renderColoredCountdown is renderInteractiveCountdown with a separate
+// window title and the line (but not the title) drawn in the SGR color sgr.
+// extra is written ahead of the frame, in the same write.
+func renderColoredCountdown(status statusDisplay, title, timeStr, sgr, extra string, noTitle bool) {
+	if !status.supportsAdvanced && !noTitle && status.setTitle != nil {
+		status.setTitle(title)
+	}
+	if sgr != "" {
+		timeStr = colorize(timeStr, sgr)
+	}
+	frame := countdownFrame{prefix: extra, line: "\r" + timeStr}
 	if status.supportsAdvanced {
-		if noTitle {
-			writeStatusf(status.writer, "\r\033[K%s", timeStr)
-			return
+		frame.line = "\r\033[K" + timeStr
+		if !noTitle {
+			// \033]0; sets the title, \007 terminates the OSC sequence.
+			frame.title = fmt.Sprintf("\033]0;%s\007", title)
 		}
-		// Update title bar and terminal line in a single operation.
-		// \033]0; sets title, \007 terminates the OSC sequence, \r returns to start of line.
-		writeStatusf(status.writer, "\033]0;%s\007\r\033[K%s", timeStr, timeStr)
+	}
+	submitCountdownFrame(status, frame)
+}
+
+// submitCountdownFrame hands frame to the renderer, which skips what did
+// not change, or writes it in full when there is none.
+func submitCountdownFrame(status statusDisplay, frame countdownFrame) {
+	if status.frames != nil {
+		status.frames.submitFrame(frame)
+		return
+	}
+	writeStatus(status.writer, frame.prefix+frame.title+frame.line)
+}
+
+// Terminals that keep a title stack (xterm, VTE, kitty, WezTerm, iTerm2)
+// save the title before the countdown takes it over and restore it
+// afterwards; others ignore both sequences.
+const (
+	pushTitle = "\033[22;0t"
+	popTitle  = "\033[23;0t"
+)
+
+// formatCountdownTitle prefixes the window title with progress, e.g.
+// "[42%] tea: 12:30", so it is readable in a narrow tab.
+func formatCountdownTitle(remaining, total time.Duration, line string) string {
+	return fmt.Sprintf("[%s] %s", formatPercentComplete(remaining, total), line)
+}
+
+// exclusiveStatus runs fn with the status line to itself: no countdown frame
+// is written concurrently, and any queued frame is discarded.
+func exclusiveStatus(status statusDisplay, fn func()) {
+	if status.frames != nil {
+		status.frames.exclusive(fn)
 		return
 	}
-	writeStatusf(status.writer, "\r%s", timeStr)
+	fn()
+}
+
+// newStderrStatusDisplay probes stderr for interactivity and escape sequence
+// support. On Windows consoles, virtual terminal processing is enabled
+// explicitly; if that fails, titles fall back to the console title API.
+func newStderrStatusDisplay() statusDisplay {
+	caps := lookupTerminalCaps(os.Getenv("TERM"))
+	status := statusDisplay{
+		writer:           os.Stderr,
+		interactive:      stderrIsTTY(),
+		supportsAdvanced: caps.clearLine,
+		noTitles:         !caps.title,
+		monochrome:       !caps.color,
+	}
+	if !status.interactive {
+		return status
+	}
+	if enableVirtualTerminal(os.Stderr.Fd()) {
+		status.supportsAdvanced = true
+		status.noTitles = false
+		status.monochrome = false
+	}
+	if !status.supportsAdvanced {
+		status.setTitle = consoleTitleSetter()
+	}
+	return status
+}
+
+// formatCountdownLine decorates the remaining time with the timer label and
+// paused state, e.g. "tea: 3:59 (paused)".
+func formatCountdownLine(label, remaining string, paused bool) string {
+	return themes[defaultThemeName].countdownLine(label, remaining, paused)
+}
+
+// formatPercentComplete reports progress through total, rounded down so
+// "100%" only appears once the timer is done.
+func formatPercentComplete(remaining, total time.Duration) string {
+	return fmt.Sprintf("%d%%", percentComplete(remaining, total))
+}
+
+func percentComplete(remaining, total time.Duration) int {
+	if total <= 0 {
+		return 100
+	}
+	elapsed := min(max(total-remaining, 0), total)
+	return int(int64(elapsed) * 100 / int64(total))
+}
+
+// displayMode selects what the interactive countdown shows.
+type displayMode int
+
+const (
+	displayRemaining displayMode = iota
+	displayElapsed
+	displayBoth
+)
+
+func parseDisplayMode(s string) (displayMode, bool) {
+	switch s {
+	case "remaining":
+		return displayRemaining, true
+	case "elapsed":
+		return displayElapsed, true
+	case "both":
+		return displayBoth, true
+	}
+	return 0, false
+}
+
+// finalStretch is how much remaining time is shown in tenths of a second.
+const finalStretch = 10 * time.Second
+
+// formatCountdownTime renders the countdown for mode. Remaining time rounds
+// up and elapsed time rounds down, so "both" always adds up to the total:
+// "+12:04 -17:56".
+func formatCountdownTime(mode displayMode, elapsed, remaining time.Duration) string {
+	switch mode {
+	case displayElapsed:
+		return formatElapsedTime(elapsed)
+	case displayBoth:
+		return fmt.Sprintf("+%s -%s", formatElapsedTime(elapsed), formatFinalStretchTime(remaining))
+	}
+	return formatFinalStretchTime(remaining)
+}
+
+// formatFinalStretchTime is formatRemainingTime with tenths of a second once
+// less than finalStretch remains ("3.4"), still rounding up.
+func formatFinalStretchTime(remaining time.Duration) string {
+	tenths := int((remaining + 100*time.Millisecond - 1) / (100 * time.Millisecond))
+	if tenths <= 0 || tenths >= int(finalStretch/(100*time.Millisecond)) {
+		return formatRemainingTime(remaining)
+	}
+	return fmt.Sprintf("%d.%d", tenths/10, tenths%10)
+}
+
+func formatElapsedTime(elapsed time.Duration) string {
+	return formatClockSeconds(int(max(elapsed, 0) / time.Second))
+}
+
+// truncateToWidth shortens s to fit in width columns, keeping one column
+// free: a countdown line that wraps can no longer be redrawn with \r.
+func truncateToWidth(s string, width int) string {
+	if width <= 1 || stringWidth(s) < width {
+		return s
+	}
+	if width == 2 {
+		return cutToWidth(s, 1)
+	}
+	return cutToWidth(s, width-2) + "…"
 }
 
 func formatRemainingTime(remaining time.Duration) string {
 	// Ceiling-based calculation for whole seconds.
-	totalSeconds := int((remaining + time.Second - 1) / time.Second)
+	return formatClockSeconds(int((remaining + time.Second - 1) / time.Second))
+}
+
+func formatClockSeconds(totalSeconds int) string {
 	h := totalSeconds / 3600
 	m := (totalSeconds % 3600) / 60
 	s := totalSeconds % 60
@@ -37,12 +195,60 @@ func formatRemainingTime(remaining time.Duration) string {
 	return fmt.Sprintf("%d", s)
 }
 
-func printComplete(status statusDisplay, quiet bool) {
-	printFinalStatus(status, quiet, "after complete", "after: complete")
+// printComplete and printCancelled name the timer when it has a label and
+// append summary, when non-empty, in parentheses to the final status line
+// (e.g. "after: tea: complete (paused 3m0s)"). A non-empty message, from
+// --message or --cancel-message, replaces the default text.
+func printComplete(status statusDisplay, quiet bool, label, message, summary string) {
+	interactiveMsg, nonTTYMsg := finalStatusText("complete", label, message)
+	banner := withIcon(status.icons.done, withSummary(interactiveMsg, summary))
+	if status.color {
+		banner = colorize(banner, countdownTheme(status).banner)
+	}
+	printFinalStatus(status, quiet, banner, withSummary(nonTTYMsg, summary))
+}
+
+func printCancelled(status statusDisplay, quiet bool, label, message, summary string) {
+	interactiveMsg, nonTTYMsg := finalStatusText("cancelled", label, message)
+	printFinalStatus(status, quiet, withSummary(interactiveMsg, summary), withSummary(nonTTYMsg, summary))
+}
+
+// finalStatusText returns the terminal and log forms of the final line,
+// e.g. "after complete: tea" and "after: tea: complete". Log lines keep the
+// "after:" prefix even with a custom message.
+func finalStatusText(outcome, label, message string) (string, string) {
+	if message != "" {
+		return message, labelLifecycle(label, "after: "+message)
+	}
+	return withLabel("after "+outcome, label), labelLifecycle(label, "after: "+outcome)
 }
 
-func printCancelled(status statusDisplay, quiet bool) {
-	printFinalStatus(status, quiet, "after cancelled", "after: cancelled")
+func withLabel(msg, label string) string {
+	if label == "" {
+		return msg
+	}
+	return msg + ": " + label
+}
+
+// labelLifecycle inserts label into a lifecycle line, so logs of several
+// timers can be told apart: "after: started (5m0s)" becomes
+// "after: tea: started (5m0s)".
+func labelLifecycle(label, line string) string {
+	if label == "" {
+		return line
+	}
+	rest, ok := strings.CutPrefix(line, "after: ")
+	if !ok {
+		return line
+	}
+	return "after: " + label + ": " + rest
+}
+
+func withSummary(msg, summary string) string {
+	if summary == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (%s)", msg, summary)
 }
 
 func printFinalStatus(status statusDisplay, quiet bool, interactiveMsg, nonTTYMsg string) {
@@ -52,8 +258,7 @@ func printFinalStatus(status statusDisplay, quiet bool, interactiveMsg, nonTTYMs
 	}
 
 	if status.interactive {
-		clearInteractiveStatusLine(status)
-		writeStatusln(status.writer, interactiveMsg)
+		writeInteractiveLine(status, interactiveMsg)
 		return
 	}
 	writeStatusln(status.writer, nonTTYMsg)
@@ -63,36 +268,51 @@ func clearInteractiveStatusLine(status statusDisplay) {
 	if !status.interactive {
 		return
 	}
+	writeStatus(status.writer, interactiveClearSequence(status))
+}
+
+// writeInteractiveLine replaces the countdown with msg. The clear sequence
+// and the message go out in a single write so that output from other
+// processes sharing the terminal cannot land between them.
+func writeInteractiveLine(status statusDisplay, msg string) {
+	writeStatus(status.writer, interactiveClearSequence(status)+msg+"\n")
+}
+
+func interactiveClearSequence(status statusDisplay) string {
 	if status.supportsAdvanced {
-		writeStatus(status.writer, "\r\033[K")
-		return
+		return "\r\033[K"
 	}
-	writeStatus(status.writer, "\r")
+	return "\r"
 }
 
+// writeStatus issues exactly one Write call for s. Each countdown frame and
+// each status message is composed in full beforehand, so a frame reaches
+// the terminal in a single write(2) and cannot be torn by concurrent output.
 func writeStatus(writer io.Writer, s string) {
-	_, _ = fmt.Fprint(writer, s)
+	_, _ = io.WriteString(writer, s)
 }
 
 func writeStatusln(writer io.Writer, a ...any) {
 	_, _ = fmt.Fprintln(writer, a...)
 }
 
-func writeStatusf(writer io.Writer, format string, a ...any) {
-	_, _ = fmt.Fprintf(writer, format, a...)
-}
-
 func renderHelpText() string {
 	var b strings.Builder
 	b.WriteString(usageText)
 	b.WriteString("\n\nFlags:\n")
 
+	labels := make([]string, len(cliFlags))
+	width := 0
 	for i, flag := range cliFlags {
-		label := fmt.Sprintf("%s, %s", flag.short, flag.long)
+		labels[i] = fmt.Sprintf("%s, %s", flag.short, flag.long)
 		if flag.short == "" {
-			label = "    " + flag.long
+			labels[i] = "    " + flag.long
 		}
-		fmt.Fprintf(&b, "  %-18s%s", label, flag.description)
+		width = max(width, len(labels[i]))
+	}
+
+	for i, flag := range cliFlags {
+		fmt.Fprintf(&b, "  %-*s  %s", width, labels[i], flag.description)
 		if i < len(cliFlags)-1 {
 			b.WriteByte('\n')
 		}
@@ -108,11 +328,42 @@ func formatLifecycleStarted(duration time.Duration, wallClockTarget time.Time) s
 		if wallClockTarget.Second() != 0 {
 			format = "15:04:05"
 		}
+		if duration >= 24*time.Hour {
+			format = "2006-01-02 " + format
+		}
 		return fmt.Sprintf("after: started (until %s)", wallClockTarget.Format(format))
 	}
 	return fmt.Sprintf("after: started (%s)", duration)
 }
 
+// formatLifecycleStartedWithEnd is the --show-end start line for relative
+// durations, e.g. "after: started (25m0s, ends 14:32:10)".
+func formatLifecycleStartedWithEnd(duration time.Duration, end time.Time) string {
+	format := "15:04:05"
+	if duration >= 24*time.Hour {
+		format = "2006-01-02 " + format
+	}
+	return fmt.Sprintf("after: started (%s, ends %s)", duration, end.Format(format))
+}
+
+// formatEndsAt is the --show-end countdown suffix, e.g. "(ends 14:32)". The
+// date is added once the end is no longer today.
+func formatEndsAt(end, now time.Time) string {
+	format := "15:04"
+	if y, m, d := end.Date(); y != now.Year() || m != now.Month() || d != now.Day() {
+		format = "Jan 2 15:04"
+	}
+	return fmt.Sprintf("(ends %s)", end.Format(format))
+}
+
+func formatRandomPick(durationRange string, picked time.Duration) string {
+	return fmt.Sprintf("after: picked %s from %s", picked, durationRange)
+}
+
+func formatLifecycleEdited(deadline time.Time) string {
+	return fmt.Sprintf("after: deadline changed (until %s)", deadline.Format("15:04:05"))
+}
+
 func formatVersionLine(v string) string {
 	return fmt.Sprintf("after %s\n", v)
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// dumpFileEnvVar names a file that receives the SIGQUIT state dump instead
+// of stderr, for timers whose terminal is gone or unreadable.
+const dumpFileEnvVar = "AFTER_DUMP_FILE"
+
+// debugEnvVar, when set to anything, has after explain choices it makes
+// quietly, such as which notifier posted a notification, on stderr.
+const debugEnvVar = "AFTER_DEBUG"
+
+// debugf writes a debug line to w when debugEnvVar is set.
+func debugf(w io.Writer, getenv func(string) string, format string, args ...any) {
+	if getenv(debugEnvVar) == "" {
+		return
+	}
+	writeStatusln(w, "after: debug:", fmt.Sprintf(format, args...))
+}
+
+const pauseReasonSuspend = "suspend"
+
+// pauseRecord is one entry in the pause history. A zero end means the pause
+// is still in effect.
+type pauseRecord struct {
+	reason string
+	start  time.Time
+	end    time.Time
+}
+
+// stateDump is everything printed when the timer receives SIGQUIT.
+type stateDump struct {
+	at            time.Time
+	inv           invocation
+	started       time.Time
+	deadline      time.Time
+	remaining     time.Duration
+	total         time.Duration
+	pausedBy      string
+	pauses        []pauseRecord
+	renderer      string
+	alarm         []alarmCommand
+	idle          string
+	controlSocket string
+}
+
+func formatStateDump(d stateDump) string {
+	var b strings.Builder
+	line := func(key string, format string, args ...any) {
+		fmt.Fprintf(&b, "  %-16s %s\n", key+":", fmt.Sprintf(format, args...))
+	}
+
+	fmt.Fprintf(&b, "after: state dump (pid %d) at %s\n", os.Getpid(), d.at.Format(time.RFC3339Nano))
+	line("label", "%q", d.inv.label)
+	line("mode", "%s", timerMode(d.inv))
+	line("started", "%s", d.started.Format(time.RFC3339Nano))
+	line("deadline", "%s", d.deadline.Round(0).Format(time.RFC3339Nano))
+	line("remaining", "%s", d.remaining.Round(time.Millisecond))
+	line("total", "%s", d.total.Round(time.Millisecond))
+	if d.pausedBy != "" {
+		line("state", "paused (%s)", d.pausedBy)
+	} else {
+		line("state", "running")
+	}
+
+	if len(d.pauses) == 0 {
+		line("pauses", "none")
+	} else {
+		line("pauses", "%d", len(d.pauses))
+		for _, p := range d.pauses {
+			if p.end.IsZero() {
+				fmt.Fprintf(&b, "    %-8s %s  (ongoing, %s)\n", p.reason, p.start.Format(time.RFC3339), d.at.Sub(p.start).Round(time.Second))
+				continue
+			}
+			fmt.Fprintf(&b, "    %-8s %s  %s\n", p.reason, p.start.Format(time.RFC3339), p.end.Sub(p.start).Round(time.Second))
+		}
+	}
+
+	line("renderer", "%s", d.renderer)
+	line("alarm", "%s", formatAlarmBackends(d.inv, d.alarm))
+	line("idle", "%s", d.idle)
+	line("control socket", "%s", d.controlSocket)
+	line("exec hook", "%q", d.inv.execCommand)
+	return b.String()
+}
+
+func timerMode(inv invocation) string {
+	if !inv.wallClockTarget.IsZero() {
+		return "wall clock"
+	}
+	return "duration " + inv.duration.String()
+}
+
+func formatAlarmBackends(inv invocation, commands []alarmCommand) string {
+	if inv.muteAlarm {
+		return "muted"
+	}
+	if len(commands) == 0 {
+		return "none available (terminal bell only)"
+	}
+	if inv.alarmBackends != "" {
+		return formatAlarmCommandNames(commands) + " (pinned)"
+	}
+	return formatAlarmCommandNames(commands)
+}
+
+// formatRendererMode describes how the countdown is drawn.
+func formatRendererMode(status statusDisplay, interval time.Duration) string {
+	if !status.interactive {
+		return "non-interactive (lifecycle lines only)"
+	}
+	mode := "interactive, plain"
+	if status.supportsAdvanced {
+		mode = "interactive, ansi"
+	}
+	if interval > 0 {
+		return fmt.Sprintf("%s, slow terminal (frame every %s)", mode, interval)
+	}
+	return mode
+}
+
+// goroutineSnapshot returns the stacks of all goroutines.
+func goroutineSnapshot() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// writeStateDump appends the dump to $AFTER_DUMP_FILE when set, otherwise to
+// the status stream. It returns the file written, or "" for the stream.
+func writeStateDump(status statusDisplay, getenv func(string) string, text string) (string, error) {
+	if path := getenv(dumpFileEnvVar); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if _, err := f.WriteString(text); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	prefix := ""
+	if status.interactive {
+		prefix = interactiveClearSequence(status)
+	}
+	writeStatus(status.writer, prefix+text)
+	return "", nil
+}
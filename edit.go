@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const editUsageText = "Usage: after edit [<id>] [<duration|time>] [--label <text>] [--alert <profile>]\n\n" +
+	"Changes a running timer. A duration sets the time remaining; a time of\n" +
+	"day sets a new end time. <id> may be omitted when only one timer is running.\n" +
+	"Durations need a unit here (90s, not 90) so they are not read as an id."
+
+type editArgs struct {
+	id       int
+	deadline string
+	label    *string
+	alert    *string
+}
+
+func parseEditArgs(args []string) (editArgs, error) {
+	var parsed editArgs
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-l", "--label", "-a", "--alert":
+			if i+1 >= len(args) {
+				return editArgs{}, errUsage
+			}
+			value := args[i+1]
+			i++
+			if arg == "-l" || arg == "--label" {
+				parsed.label = &value
+			} else {
+				parsed.alert = &value
+			}
+			continue
+		}
+		if len(arg) > 0 && arg[0] == '-' && !isPotentialNegativeDuration(arg) {
+			return editArgs{}, unknownOptionError{option: arg}
+		}
+		if len(positional) > 0 && isAMPMToken(arg) {
+			positional[len(positional)-1] += " " + arg
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	// A plain integer in first position is always the id; durations given
+	// to edit need a unit ("90s", not "90").
+	if len(positional) > 0 && isTimerID(positional[0]) {
+		parsed.id, _ = strconv.Atoi(positional[0])
+		positional = positional[1:]
+	}
+	switch len(positional) {
+	case 0:
+	case 1:
+		parsed.deadline = positional[0]
+	default:
+		return editArgs{}, errUsage
+	}
+	if parsed.deadline == "" && parsed.label == nil && parsed.alert == nil {
+		return editArgs{}, errUsage
+	}
+	return parsed, nil
+}
+
+func isTimerID(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func buildEditRequest(parsed editArgs, now time.Time) (controlRequest, error) {
+	req := controlRequest{Command: controlCommandEdit, Label: parsed.label, Alert: parsed.alert}
+	if parsed.deadline != "" {
+		d, target, err := parseDurationToken(parsed.deadline)
+		if err != nil {
+			return controlRequest{}, err
+		}
+		if target.IsZero() {
+			target = now.Add(d)
+		}
+		req.Deadline = &target
+	}
+	return req, nil
+}
+
+// resolveTimerID returns id, or the only running timer when id is zero.
+func resolveTimerID(dir string, id int) (int, error) {
+	if id != 0 {
+		return id, nil
+	}
+	timers := runningTimers(dir)
+	switch len(timers) {
+	case 0:
+		return 0, errors.New("no running timers")
+	case 1:
+		return timers[0].ID, nil
+	}
+	return 0, errors.New("several timers are running; pass an id")
+}
+
+func runEditCommand(args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseEditArgs(args)
+	if err != nil {
+		var unknownErr unknownOptionError
+		if errors.As(err, &unknownErr) {
+			fmt.Fprintf(stderr, "%s\n\n", unknownErr)
+		}
+		fmt.Fprintln(stderr, editUsageText)
+		return 2
+	}
+	req, err := buildEditRequest(parsed, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	dir := controlDir(os.Getenv)
+	id, err := resolveTimerID(dir, parsed.id)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	resp, err := sendControl(dir, id, req)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, formatTimerSummary(*resp.State))
+	return 0
+}
+
+// formatTimerSummary renders a one-line description of a running timer,
+// e.g. "1234  tea: 3:59 remaining (until 15:04:05)".
+func formatTimerSummary(state controlState) string {
+	line := formatCountdownLine(state.Label, formatRemainingTime(time.Duration(state.Remaining*float64(time.Second))), state.State == timerStatePaused)
+	return fmt.Sprintf("%d  %s remaining (until %s)", state.ID, line, state.Deadline.Local().Format("15:04:05"))
+}
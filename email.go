@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// With an SMTP server and addresses in the [email] config section, a
+// completed timer also sends an email, for long timers on servers nobody
+// is watching:
+//
+//	[email]
+//	server = smtp.example.com:587
+//	from = after@example.com
+//	to = ops@example.com, me@example.com
+//	user = after@example.com
+//
+// The password is read from $AFTER_SMTP_PASSWORD, or "password = ...".
+// "tls" is starttls (the default: upgrade when the server offers it), on
+// (TLS from the start, as on port 465), or off.
+
+const smtpPasswordEnvVar = "AFTER_SMTP_PASSWORD"
+
+const (
+	emailTLSStart = "starttls"
+	emailTLSOn    = "on"
+	emailTLSOff   = "off"
+)
+
+// emailConfig is the server and addresses to send through. The zero
+// value sends nothing.
+type emailConfig struct {
+	server   string
+	from     string
+	to       string
+	user     string
+	password string
+	tls      string
+	template string
+}
+
+func (c emailConfig) set() bool {
+	return c.server != "" && c.from != "" && c.to != ""
+}
+
+func parseEmailSection(section configSection) (emailConfig, error) {
+	c := emailConfig{tls: emailTLSStart}
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "server":
+			c.server = entry.value
+		case "from":
+			c.from = entry.value
+		case "to":
+			c.to = entry.value
+		case "user":
+			c.user = entry.value
+		case "password":
+			c.password = entry.value
+		case "tls":
+			switch v := strings.ToLower(entry.value); v {
+			case emailTLSStart, emailTLSOn, emailTLSOff:
+				c.tls = v
+			default:
+				return emailConfig{}, configError{line: entry.line, msg: fmt.Sprintf("tls: expected starttls, on, or off, got %q", entry.value)}
+			}
+		case "template":
+			text, err := parseMessageTemplate(entry)
+			if err != nil {
+				return emailConfig{}, err
+			}
+			c.template = text
+		default:
+			return emailConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown email setting %q", entry.key)}
+		}
+	}
+	return c, nil
+}
+
+// resolveEmail takes the password from the environment, which takes
+// precedence over the config file.
+func resolveEmail(c emailConfig, getenv func(string) string) emailConfig {
+	if password := getenv(smtpPasswordEnvVar); password != "" {
+		c.password = password
+	}
+	return c
+}
+
+// recipients splits the "to" list.
+func (c emailConfig) recipients() []string {
+	var to []string
+	for _, addr := range strings.Split(c.to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// serverAddr is the server with its port, 465 for TLS from the start and
+// 587 otherwise when none is given.
+func (c emailConfig) serverAddr() string {
+	if _, _, err := net.SplitHostPort(c.server); err == nil {
+		return c.server
+	}
+	if c.tls == emailTLSOn {
+		return net.JoinHostPort(c.server, "465")
+	}
+	return net.JoinHostPort(c.server, "587")
+}
+
+// formatEmail renders the message about event: the title and text as the
+// subject, and the message template as the body.
+func formatEmail(c emailConfig, event remoteEvent) (string, error) {
+	body, err := renderMessage(c.template, event)
+	if err != nil {
+		return "", err
+	}
+	subject := event.notification.title + ": " + event.notification.text
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", c.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(c.recipients(), ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", event.ended.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.String(), nil
+}
+
+// sendEmail sends the message about event through the server, giving up
+// after timeout.
+func sendEmail(c emailConfig, event remoteEvent, timeout time.Duration) error {
+	message, err := formatEmail(c, event)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	addr := c.serverAddr()
+	host, _, _ := net.SplitHostPort(addr)
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if c.tls == emailTLSOn {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("email: %w", err)
+	}
+	defer client.Close()
+	if c.tls == emailTLSStart {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return fmt.Errorf("email: %w", err)
+			}
+		}
+	}
+	if c.user != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.user, c.password, host)); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	if err := client.Mail(c.from); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	for _, to := range c.recipients() {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("email: %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if _, err := w.Write([]byte(message)); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
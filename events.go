@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// The --json stream on stdout: one progressEvent per line. Every event
+// carries the full countdown state so readers can start anywhere.
+const (
+	progressEventStart     = "start"
+	progressEventTick      = "tick"
+	progressEventComplete  = "complete"
+	progressEventCancelled = "cancelled"
+)
+
+type progressEvent struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Label       string    `json:"label,omitempty"`
+	RemainingMS int64     `json:"remaining_ms"`
+	TotalMS     int64     `json:"total_ms"`
+	Percent     int       `json:"percent"`
+	Deadline    time.Time `json:"deadline"`
+	Paused      bool      `json:"paused,omitempty"`
+	// Reason and Signal explain a cancelled event, as in timerResult.
+	Reason string `json:"reason,omitempty"`
+	Signal string `json:"signal,omitempty"`
+}
+
+func newProgressEvent(event, label string, remaining, total time.Duration, deadline time.Time, paused bool, cause error) progressEvent {
+	e := progressEvent{
+		Event:       event,
+		Time:        time.Now().Round(0),
+		Label:       label,
+		RemainingMS: remaining.Milliseconds(),
+		TotalMS:     total.Milliseconds(),
+		Percent:     percentComplete(remaining, total),
+		Deadline:    deadline.Round(0),
+		Paused:      paused,
+	}
+	if event == progressEventCancelled {
+		e.Reason = reasonSignal
+		if errors.Is(cause, errCancelledRemotely) {
+			e.Reason = reasonRemote
+		}
+		var sc signalCause
+		if errors.As(cause, &sc) {
+			e.Signal = signalName(sc.sig)
+		}
+	}
+	return e
+}
+
+func writeProgressEvent(w io.Writer, e progressEvent) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Focus reporting (xterm mode 1004) makes the terminal send ESC [ I when it
+// gains focus and ESC [ O when it loses it. tmux forwards these with
+// "set -g focus-events on", including when a client re-attaches.
+const (
+	focusReportingOn  = "\033[?1004h"
+	focusReportingOff = "\033[?1004l"
+)
+
+var (
+	focusInSequence  = []byte("\033[I")
+	focusOutSequence = []byte("\033[O")
+)
+
+type timerInput int
+
+const (
+	timerInputCancel timerInput = iota + 1
+	timerInputFocusIn
+	timerInputFocusOut
+)
+
+// parseTimerInput maps one read from the raw terminal to input events. Focus
+// reports are recognized anywhere in the read; any other escape, q, or
+// ctrl+c/ctrl+d cancels, as before focus reporting existed.
+func parseTimerInput(b []byte) []timerInput {
+	var events []timerInput
+	for len(b) > 0 {
+		switch {
+		case bytes.HasPrefix(b, focusInSequence):
+			events = append(events, timerInputFocusIn)
+			b = b[len(focusInSequence):]
+			continue
+		case bytes.HasPrefix(b, focusOutSequence):
+			events = append(events, timerInputFocusOut)
+			b = b[len(focusOutSequence):]
+			continue
+		}
+		switch b[0] {
+		case 'q', 'Q', 0x1B, 0x03, 0x04:
+			return append(events, timerInputCancel)
+		}
+		b = b[1:]
+	}
+	return events
+}
+
+// shouldTrackFocus reports whether --realert can work: it needs the raw
+// terminal for input and escape sequence support for focus reporting.
+func shouldTrackFocus(inv invocation, status statusDisplay) bool {
+	return inv.realert && !inv.quiet && status.interactive && status.supportsAdvanced
+}
+
+func formatRealert(label, message string, completedAt time.Time) string {
+	what := "after complete"
+	switch {
+	case message != "":
+		what = message
+	case label != "":
+		what = fmt.Sprintf("after complete: %s", label)
+	}
+	return fmt.Sprintf("%s at %s (while you were away)", what, completedAt.Format("15:04"))
+}
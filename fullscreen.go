@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// --fullscreen draws the countdown centered on the terminal's alternate
+// screen, which is left again before the final status line, so the
+// scrollback is untouched. --big draws the time in large digits.
+const (
+	enterFullscreen = "\033[?1049h\033[?25l"
+	leaveFullscreen = "\033[?25h\033[?1049l"
+)
+
+// bigGlyphs are five rows tall; every row of a glyph has the same width.
+var bigGlyphs = map[rune][5]string{
+	'0': {"█████", "█   █", "█   █", "█   █", "█████"},
+	'1': {"  █  ", " ██  ", "  █  ", "  █  ", " ███ "},
+	'2': {"█████", "    █", "█████", "█    ", "█████"},
+	'3': {"█████", "    █", " ████", "    █", "█████"},
+	'4': {"█   █", "█   █", "█████", "    █", "    █"},
+	'5': {"█████", "█    ", "█████", "    █", "█████"},
+	'6': {"█████", "█    ", "█████", "█   █", "█████"},
+	'7': {"█████", "    █", "   █ ", "  █  ", "  █  "},
+	'8': {"█████", "█   █", "█████", "█   █", "█████"},
+	'9': {"█████", "█   █", "█████", "    █", "█████"},
+	':': {"   ", " █ ", "   ", " █ ", "   "},
+	'.': {"   ", "   ", "   ", "   ", " █ "},
+}
+
+// bigText renders s in bigGlyphs. It reports false when s has a character
+// without a glyph, such as a percentage or end time, so the caller can fall
+// back to plain text.
+func bigText(s string) ([]string, bool) {
+	rows := make([]string, 5)
+	for i, r := range s {
+		glyph, ok := bigGlyphs[r]
+		if !ok {
+			return nil, false
+		}
+		for row := range rows {
+			if i > 0 {
+				rows[row] += " "
+			}
+			rows[row] += glyph[row]
+		}
+	}
+	return rows, true
+}
+
+// formatFullscreenFrame clears the screen and draws lines centered in a
+// width x height terminal, each wrapped in the SGR color sgr. Lines are
+// placed with cursor addressing, so the frame also works in raw mode.
+func formatFullscreenFrame(lines []string, sgr string, width, height int) string {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	top := max((height-len(lines))/2, 0)
+	for i, line := range lines {
+		col := max((width-stringWidth(line))/2, 0)
+		fmt.Fprintf(&b, "\033[%d;%dH%s", top+i+1, col+1, colorize(line, sgr))
+	}
+	return b.String()
+}
+
+// fullscreenLines lays out the countdown: an optional label above the time,
+// which is drawn big when requested and possible.
+func fullscreenLines(label, timeStr string, paused, big bool) []string {
+	var lines []string
+	if label != "" {
+		lines = append(lines, label, "")
+	}
+	if paused {
+		timeStr += " (paused)"
+	}
+	if big {
+		if rows, ok := bigText(timeStr); ok {
+			return append(lines, rows...)
+		}
+	}
+	return append(lines, timeStr)
+}
+
+// stderrSize returns the terminal size, assuming 80x24 when unknown.
+func stderrSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	outcomeComplete  = "complete"
+	outcomeCancelled = "cancelled"
+)
+
+// timerEvent describes how a timer run ended. It is the single source for the
+// AFTER_* environment exported to --exec hooks.
+type timerEvent struct {
+	outcome   string
+	requested time.Duration
+	started   time.Time
+	ended     time.Time
+	deadline  time.Time
+	signal    os.Signal
+	suspended time.Duration
+	label     string
+	message   string
+}
+
+func newTimerEvent(outcome string, inv invocation, started, ended, deadline time.Time, cause error) timerEvent {
+	event := timerEvent{
+		outcome:   outcome,
+		requested: deadline.Sub(started),
+		started:   started,
+		ended:     ended,
+		deadline:  deadline,
+		label:     inv.label,
+	}
+	if inv.wallClockTarget.IsZero() {
+		event.requested = inv.duration
+	}
+	switch outcome {
+	case outcomeComplete:
+		event.message = inv.message
+	case outcomeCancelled:
+		event.message = inv.cancelMessage
+	}
+	var sc signalCause
+	if errors.As(cause, &sc) {
+		event.signal = sc.sig
+	}
+	return event
+}
+
+// hookEnv returns the documented AFTER_* variables for event:
+//
+//	AFTER_OUTCOME            complete or cancelled
+//	AFTER_REQUESTED          requested duration (Go duration syntax, e.g. 5m0s)
+//	AFTER_REQUESTED_SECONDS  requested duration in seconds
+//	AFTER_ELAPSED_SECONDS    actual elapsed seconds, millisecond precision
+//	AFTER_STARTED            start time, RFC 3339
+//	AFTER_DEADLINE           scheduled completion time, RFC 3339
+//	AFTER_SIGNAL             SIGINT, SIGTERM, or SIGQUIT when cancelled by a signal or key, else empty
+//	AFTER_SUSPENDED_SECONDS  time paused during system suspend (--pause-on-suspend)
+//	AFTER_LABEL              --label, else empty
+//	AFTER_MESSAGE            --message or --cancel-message for the outcome, else empty
+//
+// Every variable is set even when empty, so a value exported by the
+// calling shell cannot leak into the hook.
+func hookEnv(event timerEvent) []string {
+	return []string{
+		"AFTER_OUTCOME=" + event.outcome,
+		"AFTER_REQUESTED=" + event.requested.String(),
+		"AFTER_REQUESTED_SECONDS=" + formatSeconds(event.requested),
+		"AFTER_ELAPSED_SECONDS=" + formatSeconds(event.ended.Sub(event.started)),
+		"AFTER_STARTED=" + event.started.Format(time.RFC3339),
+		"AFTER_DEADLINE=" + event.deadline.Format(time.RFC3339),
+		"AFTER_SIGNAL=" + signalName(event.signal),
+		"AFTER_SUSPENDED_SECONDS=" + formatSeconds(event.suspended),
+		"AFTER_LABEL=" + event.label,
+		"AFTER_MESSAGE=" + event.message,
+	}
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Millisecond).Seconds(), 'f', -1, 64)
+}
+
+func signalName(sig os.Signal) string {
+	switch sig {
+	case nil:
+		return ""
+	case os.Interrupt:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	}
+	return sig.String()
+}
+
+// runExecHook runs command through sh with the event environment. The hook
+// writes to stdout and stderr so its output reaches the user; after waits for
+// it so the hook finishes before the shell prompt returns.
+func runExecHook(command string, event timerEvent, stdout io.Writer) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), hookEnv(event)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("--exec hook failed: %w", err)
+	}
+	return nil
+}
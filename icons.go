@@ -0,0 +1,101 @@
+package main
+
+import "strings"
+
+// iconMode is the --icons setting.
+type iconMode int
+
+const (
+	iconsAuto iconMode = iota
+	iconsEmoji
+	iconsNerd
+	iconsASCII
+)
+
+func parseIconMode(s string) (iconMode, bool) {
+	switch s {
+	case "auto":
+		return iconsAuto, true
+	case "emoji":
+		return iconsEmoji, true
+	case "nerd":
+		return iconsNerd, true
+	case "ascii":
+		return iconsASCII, true
+	}
+	return 0, false
+}
+
+// iconSet holds the glyphs put in front of the countdown and the
+// completion line. The zero value, used for ascii, shows none.
+type iconSet struct {
+	running string
+	paused  string
+	done    string
+	// notify goes in desktop notifications, which are drawn in the
+	// system font even when the terminal uses a Nerd Font.
+	notify string
+}
+
+var iconSets = map[iconMode]iconSet{
+	iconsEmoji: {running: "⏳", paused: "⏸", done: "🔔", notify: "🔔"},
+	// nf-fa-hourglass_half, nf-fa-pause, nf-fa-bell
+	iconsNerd: {running: "\uf252", paused: "\uf04c", done: "\uf0f3", notify: "🔔"},
+}
+
+// resolveIcons picks the icon set for mode. Auto shows emoji on terminal
+// emulators (those with a window title) in a UTF-8 locale, where they can
+// be drawn; Nerd Fonts cannot be detected and must be asked for.
+func resolveIcons(mode iconMode, status statusDisplay, getenv func(string) string) iconSet {
+	if mode != iconsAuto {
+		return iconSets[mode]
+	}
+	if status.interactive && status.supportsAdvanced && !status.noTitles && utf8Locale(getenv) {
+		return iconSets[iconsEmoji]
+	}
+	return iconSet{}
+}
+
+// utf8Locale reports whether the character set of the locale, taken from
+// the first of LC_ALL, LC_CTYPE, and LANG that is set, is UTF-8.
+func utf8Locale(getenv func(string) string) bool {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := getenv(key); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
+// withIcon puts icon in front of s, or returns s when icon is "".
+func withIcon(icon, s string) string {
+	if icon == "" {
+		return s
+	}
+	return icon + " " + s
+}
+
+// countdownIcon is the icon for a running or paused countdown.
+func (s iconSet) countdownIcon(paused bool) string {
+	if paused {
+		return s.paused
+	}
+	return s.running
+}
+
+// spinnerFrames turn by the time with --spinner: braille dots where icons
+// can be drawn, a bar otherwise.
+var (
+	spinnerFrames      = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	asciiSpinnerFrames = []string{"|", "/", "-", "\\"}
+)
+
+// spinnerFrame is frame n of the spinner for icon set s.
+func (s iconSet) spinnerFrame(n int) string {
+	frames := spinnerFrames
+	if s.running == "" {
+		frames = asciiSpinnerFrames
+	}
+	return frames[n%len(frames)]
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// --ics writes the timer's end as an iCalendar event when it starts, so a
+// long countdown shows up in a calendar too, with the calendar's own
+// reminders. "-" writes it to stdout. The event is not updated when the
+// timer is paused or extended.
+
+const icsTimeFormat = "20060102T150405Z"
+
+// icsEscape escapes text for an iCalendar TEXT value.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsFold folds a content line at 75 octets, continuing on lines that
+// start with a space, without splitting a UTF-8 sequence.
+func icsFold(line string) string {
+	var b strings.Builder
+	width := 75
+	for len(line) > width {
+		cut := width
+		for cut > 0 && line[cut]&0xc0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		width = 74
+	}
+	b.WriteString(line + "\r\n")
+	return b.String()
+}
+
+// formatICSEvent renders the calendar with one event at deadline, named
+// for the timer's label or message, and a reminder as it ends.
+func formatICSEvent(label, message string, started, deadline, now time.Time, host string, pid int) string {
+	summary := "Timer complete"
+	switch {
+	case label != "":
+		summary = label
+	case message != "":
+		summary = message
+	}
+	description := fmt.Sprintf("%s timer started at %s", formatSpokenDuration(deadline.Sub(started)), started.Format("15:04"))
+	if host != "" {
+		description += " on " + host
+	}
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//mtn-man//after//EN",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%d-%d@%s", started.UnixNano(), pid, host),
+		"DTSTAMP:" + now.UTC().Format(icsTimeFormat),
+		"DTSTART:" + deadline.UTC().Format(icsTimeFormat),
+		"SUMMARY:" + icsEscape(summary),
+		"DESCRIPTION:" + icsEscape(description),
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:" + icsEscape(summary),
+		"TRIGGER:PT0S",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line))
+	}
+	return b.String()
+}
+
+// writeICS writes the calendar to path, or to stdout for "-".
+func writeICS(path, calendar string) error {
+	if path == "-" {
+		_, err := os.Stdout.WriteString(calendar)
+		return err
+	}
+	return os.WriteFile(path, []byte(calendar), 0o644)
+}
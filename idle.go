@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const idlePollInterval = 2 * time.Second
+
+var errIdleUnsupported = errors.New("idle detection is not available on this system")
+
+// idleProbe returns how long the user has been idle (no keyboard or mouse input).
+type idleProbe func() (time.Duration, error)
+
+// idleProbeForGOOS picks the platform idle source: IOKit's HIDIdleTime on
+// macOS, and on Linux xprintidle under X11, falling back to logind's idle hint.
+func idleProbeForGOOS(goos string, getenv func(string) string) (idleProbe, error) {
+	switch goos {
+	case "darwin":
+		if _, err := exec.LookPath("ioreg"); err != nil {
+			return nil, errIdleUnsupported
+		}
+		return func() (time.Duration, error) {
+			out, err := exec.Command("ioreg", "-c", "IOHIDSystem", "-d", "4").Output()
+			if err != nil {
+				return 0, err
+			}
+			return parseIORegIdleTime(out)
+		}, nil
+	case "linux":
+		if getenv("DISPLAY") != "" {
+			if _, err := exec.LookPath("xprintidle"); err == nil {
+				return func() (time.Duration, error) {
+					out, err := exec.Command("xprintidle").Output()
+					if err != nil {
+						return 0, err
+					}
+					ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+					if err != nil {
+						return 0, err
+					}
+					return time.Duration(ms) * time.Millisecond, nil
+				}, nil
+			}
+		}
+		if _, err := exec.LookPath("loginctl"); err == nil {
+			session := getenv("XDG_SESSION_ID")
+			if session == "" {
+				session = "auto"
+			}
+			return func() (time.Duration, error) {
+				out, err := exec.Command("loginctl", "show-session", session, "-p", "IdleHint", "-p", "IdleSinceHint").Output()
+				if err != nil {
+					return 0, err
+				}
+				return parseLogindIdle(out, time.Now())
+			}, nil
+		}
+	}
+	return nil, errIdleUnsupported
+}
+
+// parseIORegIdleTime extracts HIDIdleTime (nanoseconds) from ioreg output.
+func parseIORegIdleTime(out []byte) (time.Duration, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		_, value, ok := strings.Cut(line, `"HIDIdleTime" = `)
+		if !ok {
+			continue
+		}
+		ns, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(ns), nil
+	}
+	return 0, errors.New("HIDIdleTime not found in ioreg output")
+}
+
+// parseLogindIdle interprets loginctl IdleHint/IdleSinceHint properties.
+// IdleSinceHint is a realtime timestamp in microseconds since the epoch.
+func parseLogindIdle(out []byte, now time.Time) (time.Duration, error) {
+	props := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[key] = value
+		}
+	}
+	if props["IdleHint"] != "yes" {
+		return 0, nil
+	}
+	us, err := strconv.ParseInt(props["IdleSinceHint"], 10, 64)
+	if err != nil || us == 0 {
+		return 0, errors.New("missing IdleSinceHint in loginctl output")
+	}
+	idle := now.Sub(time.UnixMicro(us))
+	if idle < 0 {
+		return 0, nil
+	}
+	return idle, nil
+}
+
+// watchIdle polls probe every interval and reports whether the user is idle for at least
+// threshold. Only transitions are sent. The watcher stops on the first probe
+// error, leaving the timer running normally.
+func watchIdle(ctx context.Context, probe idleProbe, threshold time.Duration, interval time.Duration) <-chan bool {
+	ch := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		idle := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			d, err := probe()
+			if err != nil {
+				return
+			}
+			if now := d >= threshold; now != idle {
+				idle = now
+				select {
+				case ch <- idle:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+func idleUnsupportedWarning() string {
+	return "Warning: --idle-pause needs ioreg (macOS), xprintidle, or loginctl; continuing without idle detection"
+}
+
+func formatIdlePause(d time.Duration) string {
+	return fmt.Sprintf("paused %s while idle", d.Round(time.Second))
+}
+
+// printIdleTransition reports idle pauses in non-TTY mode. Interactive
+// sessions show the paused state in the countdown line instead.
+func printIdleTransition(status statusDisplay, quiet bool, idle bool) {
+	if quiet || status.interactive {
+		return
+	}
+	if idle {
+		writeStatusln(status.writer, "after: paused (idle)")
+		return
+	}
+	writeStatusln(status.writer, "after: resumed")
+}
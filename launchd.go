@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const launchdUsageText = "Usage: after launchd --at <time> [--weekdays <days>] [--name <name>] [--install] [--] [<duration|time>] [<flags>...]\n\n" +
+	"Prints a LaunchAgent plist that runs after with the given flags at <time>\n" +
+	"every day, or on --weekdays (e.g. mon,tue,fri). Without a duration the\n" +
+	"alarm rings at <time>; with one, the timer starts then. --install writes\n" +
+	"it to ~/Library/LaunchAgents and loads it. The job is named\n" +
+	"dev.mtnman.after-<label>, or dev.mtnman.<name> with --name."
+
+// launchdLabelPrefix namespaces the jobs' labels, as launchd expects
+// reverse-DNS names.
+const launchdLabelPrefix = "dev.mtnman."
+
+// launchdArgs are the options of "after launchd", and the after command
+// line its job runs.
+type launchdArgs struct {
+	at       string
+	weekdays string
+	name     string
+	install  bool
+	after    []string
+}
+
+func parseLaunchdArgs(args []string) (launchdArgs, error) {
+	var parsed launchdArgs
+	i := 0
+	for ; i < len(args); i++ {
+		var value *string
+		switch args[i] {
+		case "--at":
+			value = &parsed.at
+		case "--weekdays":
+			value = &parsed.weekdays
+		case "--name":
+			value = &parsed.name
+		case "--install":
+			parsed.install = true
+			continue
+		case "--":
+			i++
+		}
+		if value == nil {
+			break
+		}
+		if i+1 >= len(args) {
+			return launchdArgs{}, errUsage
+		}
+		*value = args[i+1]
+		i++
+	}
+	parsed.after = args[i:]
+	if parsed.at == "" {
+		return launchdArgs{}, errUsage
+	}
+	return parsed, nil
+}
+
+// launchdWeekdays maps day names to launchd's Weekday, 0 being Sunday.
+var launchdWeekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// parseLaunchdWeekdays reads a comma-separated list of day names, or
+// nothing for every day.
+func parseLaunchdWeekdays(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var days []int
+	for _, name := range strings.Split(s, ",") {
+		day, ok := launchdWeekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, invalidFlagValueError{flag: "--weekdays", value: s}
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseLaunchdTime reads the time of day the job runs at, in any form
+// after accepts for one, e.g. 07:00 or 7am.
+func parseLaunchdTime(token string) (hour, minute int, err error) {
+	// ":30" is the next half hour, not a time of day.
+	_, target, ok, err := parseWallClockTime(token, time.Now())
+	if !ok || err != nil || strings.HasPrefix(token, ":") {
+		return 0, 0, invalidFlagValueError{flag: "--at", value: token}
+	}
+	return target.Hour(), target.Minute(), nil
+}
+
+// launchdArgsToRun checks the after command line, and gives it the zero
+// duration when it has none, so the alarm rings as the job starts.
+func launchdArgsToRun(args []string, units map[string]time.Duration) ([]string, invocation, error) {
+	parse := func(args []string) (invocation, error) {
+		inv, err := parseInvocationWithUnits(append([]string{"after"}, args...), units)
+		if err == nil && inv.mode != modeRun {
+			err = errors.New("the job must run a timer")
+		}
+		return inv, err
+	}
+	inv, err := parse(args)
+	if err == nil {
+		return args, inv, nil
+	}
+	withZero := append([]string{"0s"}, args...)
+	if inv, zeroErr := parse(withZero); zeroErr == nil {
+		return withZero, inv, nil
+	}
+	return nil, invocation{}, err
+}
+
+func writePlistString(b *strings.Builder, indent, s string) {
+	b.WriteString(indent + "<string>")
+	_ = xml.EscapeText(b, []byte(s))
+	b.WriteString("</string>\n")
+}
+
+func writePlistCalendar(b *strings.Builder, indent string, weekday, hour, minute int) {
+	b.WriteString(indent + "<dict>\n")
+	if weekday >= 0 {
+		fmt.Fprintf(b, "%s\t<key>Weekday</key>\n%s\t<integer>%d</integer>\n", indent, indent, weekday)
+	}
+	fmt.Fprintf(b, "%s\t<key>Hour</key>\n%s\t<integer>%d</integer>\n", indent, indent, hour)
+	fmt.Fprintf(b, "%s\t<key>Minute</key>\n%s\t<integer>%d</integer>\n", indent, indent, minute)
+	b.WriteString(indent + "</dict>\n")
+}
+
+// launchdPlist renders the LaunchAgent running exe with args at hour and
+// minute, every day or on weekdays.
+func launchdPlist(label, exe string, args []string, hour, minute int, weekdays []int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+`)
+	writePlistString(&b, "\t", label)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{exe}, args...) {
+		writePlistString(&b, "\t\t", arg)
+	}
+	b.WriteString("\t</array>\n\t<key>StartCalendarInterval</key>\n")
+	if len(weekdays) == 0 {
+		writePlistCalendar(&b, "\t", -1, hour, minute)
+	} else {
+		b.WriteString("\t<array>\n")
+		for _, day := range weekdays {
+			writePlistCalendar(&b, "\t\t", day, hour, minute)
+		}
+		b.WriteString("\t</array>\n")
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func runLaunchdCommand(args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseLaunchdArgs(args)
+	if err != nil {
+		fmt.Fprintln(stderr, launchdUsageText)
+		return 2
+	}
+	hour, minute, err := parseLaunchdTime(parsed.at)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	weekdays, err := parseLaunchdWeekdays(parsed.weekdays)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	// The job is checked now rather than when it first runs.
+	cfg, _ := loadConfig(configPath(os.Getenv))
+	run, inv, err := launchdArgsToRun(parsed.after, cfg.units)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	name := parsed.name
+	if name == "" {
+		name = defaultScheduleName(inv.label)
+	}
+	if !systemdUnitName.MatchString(name) {
+		fmt.Fprintf(stderr, "Error: --name: %q is not a valid job name\n", name)
+		return 2
+	}
+	label := launchdLabelPrefix + name
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	plist := launchdPlist(label, exe, run, hour, minute, weekdays)
+
+	if !parsed.install {
+		fmt.Fprint(stdout, plist)
+		return 0
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	path := filepath.Join(home, "Library", "LaunchAgents", label+".plist")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(plist), 0o644); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "after: wrote %s\n", path)
+	if out, err := exec.Command("launchctl", "bootstrap", "gui/"+strconv.Itoa(os.Getuid()), path).CombinedOutput(); err != nil {
+		fmt.Fprintf(stderr, "Error: launchctl bootstrap: %v %s\n", err, strings.TrimSpace(string(out)))
+		return 1
+	}
+	fmt.Fprintf(stdout, "after: loaded %s\n", label)
+	return 0
+}
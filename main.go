@@ -1,5 +1,3 @@
-//go:build !windows
-
 package main
 
 // after is a simple countdown utility with visual feedback and audio alerts.
@@ -17,6 +15,7 @@ package main
 //   (e.g. 9am, 2:30 PM); always wraps to the next day if the time has already passed
 // - Prevent sleep on macOS while after is active (when both streams are interactive by default, or forced with --caffeinate)
 // - Non-TTY-safe lifecycle logging (started/complete/cancelled) in stderr
+// - git-style plugins: "after foo args..." execs after-foo from PATH when foo is not a duration
 
 import (
 	"context"
@@ -24,13 +23,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // --tz must work where the system has no zoneinfo
 )
 
-const internalAlarmArg = "__after_internal_alarm_worker"
 const (
 	usageText = "Usage: after [options] <duration|time>\n\nExamples:\n" +
 		"  after 30              after 9am\n" +
@@ -49,6 +51,10 @@ var (
 	errInvalidDuration           = errors.New("invalid duration format")
 	errInvalidTime               = errors.New("invalid time format")
 	errDurationMustBeAtLeastZero = errors.New("duration must be >= 0")
+	errDurationOutOfRange        = errors.New("duration out of range")
+	errTargetInPast              = errors.New("target time is in the past")
+	errTimeZoneWithoutTarget     = errors.New("--tz applies only to a time of day or --until")
+	errEmptyBatch                = errors.New("no timers on stdin")
 	// version is overridden in release builds via:
 	// go build -ldflags "-X main.version=vX.Y.Z"
 	version = defaultVersion
@@ -75,6 +81,26 @@ func (e unknownOptionError) Error() string {
 	return fmt.Sprintf("unknown option: %s", e.option)
 }
 
+type invalidFlagValueError struct {
+	flag  string
+	value string
+}
+
+func (e invalidFlagValueError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %q", e.flag, e.value)
+}
+
+// flagConflictError reports a flag that the mode chosen by another flag
+// cannot honor.
+type flagConflictError struct {
+	flag string
+	with string
+}
+
+func (e flagConflictError) Error() string {
+	return fmt.Sprintf("%s cannot be used with %s", e.flag, e.with)
+}
+
 type invocationMode int
 
 const (
@@ -89,9 +115,74 @@ type invocation struct {
 	wallClockTarget time.Time
 	quiet           bool
 	noTitle         bool
+	titleOnly       bool
+	showPercent     bool
+	showEnd         bool
+	spinner         bool
+	stdoutStream    bool
+	jsonEvents      bool
+	porcelain       bool
+	logTo           string
+	display         displayMode
+	refresh         time.Duration
+	color           colorMode
+	fullscreen      bool
+	bigDigits       bool
+	tmuxPopup       bool
+	colorThresholds colorThresholds
+	icons           iconMode
+	theme           string
+	style           string
 	forceAlarm      bool
 	forceAwake      bool
 	soundFile       string
+	alertProfile    string
+	alertBase       alertFlags
+	muteAlarm       bool
+	execCommand     string
+	realert         bool
+	ack             bool
+	escalate        bool
+	gentle          bool
+	alarmBackends   string
+	alarmOrder      string
+	quietHours      quietHours
+	respectSilence  bool
+	announce        string
+	tickSound       bool
+	tickInterval    time.Duration
+	finalBeeps      int
+	every           time.Duration
+	everyNotify     bool
+	halfway         bool
+	noNotify        bool
+	notify          bool
+	notifyCancel    bool
+	pushover        pushoverConfig
+	telegram        telegramConfig
+	slack           slackConfig
+	discord         discordConfig
+	email           emailConfig
+	remote          string
+	emergency       bool
+	toggl           togglConfig
+	trackToggl      bool
+	resultFD        int
+	resultFile      string
+	ics             string
+	pauseOnSuspend  bool
+	idlePause       time.Duration
+	label           string
+	message         string
+	cancelMessage   string
+	durationRange   string
+	broadcast       bool
+	wall            bool
+	flash           bool
+	share           bool
+	dbus            bool
+	batch           bool
+	parallel        bool
 }
 
 type cliFlag struct {
@@ -102,34 +193,146 @@ type cliFlag struct {
 }
 
 type statusDisplay struct {
-	writer           io.Writer
-	interactive      bool
+	writer      io.Writer
+	interactive bool
+	// supportsAdvanced means escape sequences work at all and the line can
+	// be redrawn in place. noTitles and monochrome narrow it down for
+	// terminals without a window title (screen, the Linux console) or
+	// without color (vt100, *-mono); see lookupTerminalCaps.
 	supportsAdvanced bool
+	noTitles         bool
+	monochrome       bool
+	// setTitle updates the window title out-of-band when OSC sequences are
+	// unavailable (e.g. classic Windows consoles). nil when unsupported.
+	setTitle func(string)
+	// frames, when set, writes countdown frames asynchronously so slow
+	// terminals cannot stall the timer loop. See frameRenderer.
+	frames *frameRenderer
+	// color draws the countdown in threshold colors; see colorEnabled.
+	color bool
+	// theme styles the countdown; nil means the default theme.
+	theme *theme
+	// style picks the parts of the countdown line.
+	style countdownStyle
+	// notifier posts a desktop notification on completion through the
+	// terminal, wrapped for tmux passthrough when inTmux is set.
+	notifier terminalNotifier
+	inTmux   bool
+	// chrome mirrors the countdown into the terminal's badge or user vars.
+	chrome chromeIntegration
+	// icons decorate the countdown, completion line, and notification.
+	icons iconSet
+	// stream, when set, receives the remaining time as plain lines for
+	// other programs to read (--stdout).
+	stream io.Writer
 }
 
 var cliFlags = []cliFlag{
 	{short: "-h", long: "--help", description: "Show help and exit"},
 	{short: "-v", long: "--version", description: "Show version and exit"},
+	{long: "--at", description: "Count down to this time of day (e.g. 14:30, 9pm)", takesValue: true},
+	{long: "--until", description: "Count down to a date and time (e.g. 2025-12-31T09:00)", takesValue: true},
+	{long: "--round", description: "Count down to the next multiple of this interval (e.g. 15m)", takesValue: true},
+	{long: "--stdin", description: "Run timers read from stdin, one per line (also: -)"},
+	{long: "--parallel", description: "Run the --stdin timers at the same time, one line each"},
+	{long: "--tz", description: "Time zone for the target time (e.g. Europe/Berlin)", takesValue: true},
+	{short: "-l", long: "--label", description: "Name the timer (shown in the countdown, title, and logs)", takesValue: true},
+	{short: "-m", long: "--message", description: "Text shown instead of \"after complete\"", takesValue: true},
+	{long: "--cancel-message", description: "Text shown instead of \"after cancelled\"", takesValue: true},
 	{short: "-q", long: "--quiet", description: "Suppress alarm and status messages"},
 	{short: "-t", long: "--no-title", description: "Disable terminal title bar updates"},
+	{long: "--title-only", description: "Show the countdown only in the title bar, not on the line"},
+	{long: "--percent", description: "Show percent complete in the countdown and title"},
+	{long: "--elapsed", description: "Count up from zero instead of down"},
+	{long: "--display", description: "Countdown shows remaining, elapsed, or both", takesValue: true},
+	{long: "--refresh", description: "Redraw the countdown at this interval (e.g. 100ms, 2s)", takesValue: true},
+	{long: "--color", description: "Color the countdown green, yellow, red: auto, always, or never", takesValue: true},
+	{long: "--color-thresholds", description: "When the color turns yellow and red (default 1m,10s)", takesValue: true},
+	{long: "--fullscreen", description: "Show the countdown centered on the alternate screen"},
+	{long: "--big", description: "Draw the countdown in large digits (implies --fullscreen)"},
+	{long: "--tmux-popup", description: "Run the countdown in a tmux popup and return to the shell"},
+	{long: "--theme", description: "Countdown theme: default, plain, minimal, bold, solarized", takesValue: true},
+	{long: "--style", description: "Countdown parts: compact (time only), default, or full", takesValue: true},
+	{long: "--icons", description: "Icons by the countdown: auto, emoji, nerd, or ascii (none)", takesValue: true},
+	{long: "--show-end", description: "Show when the timer will end in the countdown and logs"},
+	{long: "--spinner", description: "Turn a spinner by the time with every redraw"},
+	{long: "--stdout", description: "Print the remaining time to stdout once a second"},
+	{long: "--json", description: "Print start, tick, and end events to stdout as JSON lines"},
+	{long: "--porcelain", description: "Print stable tab-separated start, end, and error records to stdout"},
+	{long: "--log", description: "Record start, completion, and cancellation in syslog (the journal)", takesValue: true},
 	{short: "-s", long: "--sound", description: "Force alarm even in quiet or non-TTY mode"},
 	{short: "-f", long: "--sound-file", description: "Custom audio file for completion alarm (implies --sound)", takesValue: true},
+	{long: "--sound-name", description: "Built-in alarm sound: beep, bell, chime, or marimba (implies --sound)", takesValue: true},
+	{long: "--sound-dir", description: "Play a random sound file from a directory (implies --sound)", takesValue: true},
+	{long: "--alarm-backend", description: "Play the alarm only through these comma-separated backends", takesValue: true},
+	{short: "-a", long: "--alert", description: "Use a named alert profile from the config file", takesValue: true},
+	{short: "-b", long: "--broadcast", description: "Announce completion on all of your open terminals"},
+	{long: "--wall", description: "Announce completion on every logged-in terminal, like wall(1)"},
+	{long: "--flash", description: "Flash the terminal on completion, a visible bell"},
+	{long: "--share", description: "Share read-only status on the local network (see after discover)"},
+	{long: "--dbus", description: "Publish the timer on the session bus as dev.mtnman.Timer"},
+	{long: "--no-notify", description: "Do not post terminal desktop notifications (completion, --halfway)"},
+	{long: "--notify", description: "Post a desktop notification over D-Bus when the timer completes"},
+	{long: "--notify-cancel", description: "Also post one when the timer is cancelled (implies --notify)"},
+	{long: "--emergency", description: "Send the Pushover push at emergency priority, repeated until acknowledged"},
+	{long: "--toggl", description: "Track the timer as a Toggl Track time entry named for its label"},
+	{long: "--realert", description: "Alert again when the terminal regains focus after you missed completion"},
+	{long: "--ack", description: "Keep ringing until you press a key in the terminal"},
+	{long: "--escalate", description: "Notify first, ring a minute later unless acknowledged, then louder"},
+	{long: "--gentle", description: "Start the alarm quiet and raise the volume with each repeat"},
+	{long: "--announce", description: "Say the time left aloud at these points (e.g. 5m,1m)", takesValue: true},
+	{long: "--tick-sound", description: "Play a soft tick every second during the countdown"},
+	{long: "--tick-interval", description: "Time between ticks (implies --tick-sound)", takesValue: true},
+	{long: "--every", description: "Chime each time this much of the countdown has run (e.g. 10m)", takesValue: true},
+	{long: "--every-notify", description: "Also post a desktop notification with each --every chime"},
+	{long: "--halfway", description: "Chime and notify when half of the countdown has run"},
+	{long: "--final-beeps", description: "Beep once a second for the last N seconds (e.g. 10)", takesValue: true},
+	{short: "-e", long: "--exec", description: "Run a shell command when the timer ends (see AFTER_* env)", takesValue: true},
+	{long: "--result-fd", description: "Write a JSON result summary to this file descriptor", takesValue: true},
+	{long: "--result-file", description: "Write a JSON result summary to this file", takesValue: true},
+	{long: "--ics", description: "Write the end time as a calendar event to this file (- for stdout)", takesValue: true},
+	{long: "--pause-on-suspend", description: "Pause while the system sleeps and report the pause"},
+	{long: "--idle-pause", description: "Pause while the user is idle for at least this long", takesValue: true},
 	{short: "-c", long: "--caffeinate", description: "Prevent sleep even in non-TTY mode (macOS only)"},
 }
 
 func main() {
-	if shouldRunInternalAlarm(os.Args) {
-		soundFile := ""
-		if len(os.Args) >= 3 {
-			soundFile = os.Args[2]
+	if run, ok := lookupSubcommand(os.Args); ok {
+		os.Exit(run(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	// The config is only fatal once something needs it: a preset, a custom
+	// unit, or an alert profile.
+	cfg, cfgErr := loadConfig(configPath(os.Getenv))
+	args := os.Args
+	presetCandidate := isPresetCandidate(args)
+	if presetCandidate {
+		if p, ok := cfg.presets[args[1]]; ok {
+			var err error
+			args, err = expandPreset(args, args[1], p)
+			if err != nil {
+				fail(args, err, 2)
+			}
 		}
-		runAlarmWorker(soundFile)
-		return
+	}
+	if path, argv, ok := externalSubcommand(args, exec.LookPath); ok {
+		err := execExternalSubcommand(path, argv)
+		fail(args, fmt.Errorf("%s: %w", path, err), 126)
+	}
+	// Neither a preset nor a plugin: a broken config may be hiding the
+	// preset the user meant.
+	if presetCandidate && cfgErr != nil {
+		fail(args, cfgErr, 2)
 	}
 
-	inv, err := parseInvocation(os.Args)
+	inv, err := parseInvocationWithUnits(args, cfg.units)
 	if err != nil {
+		if errors.Is(err, errInvalidDuration) && cfgErr != nil {
+			err = cfgErr
+		}
 		message, exitCode := renderInvocationError(err)
+		if wantsPorcelain(args) {
+			writePorcelain(os.Stdout, formatPorcelainError(exitCode, err))
+		}
 		fmt.Fprintln(os.Stderr, message)
 		os.Exit(exitCode)
 	}
@@ -141,18 +344,57 @@ func main() {
 		fmt.Print(formatVersionLine(resolveVersion(version, mainModuleVersion())))
 		return
 	}
+	// A sound asked for on the command line beats quiet hours and a muted
+	// system; one from an alert profile does not.
+	if !inv.forceAlarm {
+		inv.quietHours = cfg.quietHours
+		inv.respectSilence = true
+	}
+	// A broken config only fails runs that name a profile; the default
+	// profile is skipped then.
+	if inv.alertProfile != "" || cfgErr == nil {
+		err := cfgErr
+		if err == nil {
+			inv, err = resolveAlertProfile(inv, cfg)
+		}
+		if err != nil {
+			fail(args, err, 2)
+		}
+	}
+	if inv.tmuxPopup {
+		if err := startTmuxPopup(args[1:], inv.bigDigits, os.Getenv); err != nil {
+			fail(args, err, 2)
+		}
+		return
+	}
 	if inv.forceAwake && runtime.GOOS != "darwin" {
 		fmt.Fprintln(os.Stderr, awakeUnsupportedWarning())
 	}
 
-	if inv.soundFile != "" {
-		if soundFileIgnoredForGOOS(runtime.GOOS) {
-			fmt.Fprintln(os.Stderr, soundFileIgnoredWarning())
-		}
-		original := inv.soundFile
-		inv.soundFile = resolveUsableSoundFilePath(inv.soundFile)
-		if inv.soundFile == "" {
-			fmt.Fprintln(os.Stderr, soundFileWarning(original))
+	inv = resolveRunSoundFile(inv, os.Stderr)
+	inv.alarmOrder = cfg.alarmOrder
+	inv.pushover = resolvePushover(cfg.pushover, os.Getenv)
+	inv.telegram = cfg.telegram
+	inv.slack = cfg.slack
+	inv.discord = cfg.discord
+	inv.email = resolveEmail(cfg.email, os.Getenv)
+	inv.toggl = resolveToggl(cfg.toggl, os.Getenv)
+
+	th, err := lookupTheme(resolveThemeName(inv.theme, cfg.theme))
+	if err != nil {
+		fail(args, err, 2)
+	}
+
+	style, err := lookupStyle(resolveStyleName(inv.style, cfg.style))
+	if err != nil {
+		fail(args, err, 2)
+	}
+
+	var batch []batchEntry
+	if inv.batch {
+		batch, err = parseBatch(os.Stdin, cfg.units)
+		if err != nil {
+			fail(args, err, 2)
 		}
 	}
 
@@ -168,20 +410,94 @@ func main() {
 			return
 		}
 		cancel(signalCause{sig: sig})
+		// The user wants quiet: an alarm from an earlier timer stops too.
+		stopAlarms()
 	}()
 
-	status := statusDisplay{
-		writer:           os.Stderr,
-		interactive:      stderrIsTTY(),
-		supportsAdvanced: supportsAdvancedTerminal(os.Getenv("TERM")),
-	}
+	status := newStderrStatusDisplay()
+	status.color = colorEnabled(inv.color, status, os.Getenv)
+	status.theme = &th
+	status.style = style
+	status.notifier = detectTerminalNotifier(os.Getenv)
+	status.inTmux = os.Getenv("TMUX") != ""
+	status.chrome = detectChromeIntegration(os.Getenv)
+	status.icons = resolveIcons(inv.icons, status, os.Getenv)
 	sideEffectsInteractive := stdoutIsTTY()
+	if inv.stdoutStream || inv.jsonEvents || inv.porcelain {
+		// stdout is piped on purpose; stderr alone says whether a person
+		// is watching.
+		status.stream = os.Stdout
+		sideEffectsInteractive = status.interactive
+	}
+
+	if inv.durationRange != "" && !inv.quiet {
+		writeStatusln(status.writer, formatRandomPick(inv.durationRange, inv.duration))
+	}
 
-	if err := runTimer(ctx, cancel, inv.duration, inv.wallClockTarget, status, sideEffectsInteractive, inv.quiet, inv.noTitle, inv.forceAlarm, inv.forceAwake, inv.soundFile); err != nil {
+	switch {
+	case inv.parallel:
+		err = runParallel(ctx, inv, batch, status, sideEffectsInteractive, startAlarm)
+	case inv.batch:
+		err = runBatch(ctx, cancel, inv, batch, status, sideEffectsInteractive)
+	default:
+		err = runTimer(ctx, cancel, inv, status, sideEffectsInteractive)
+	}
+	// A built-in alarm backend plays in-process; let it finish.
+	waitForAlarms()
+	if err != nil {
 		os.Exit(exitCodeForCancelError(err))
 	}
 }
 
+// resolveRunSoundFile expands and checks inv.soundFile, writing warnings to w
+// and falling back to the default alarm when the file is unusable.
+func resolveRunSoundFile(inv invocation, w io.Writer) invocation {
+	if inv.soundFile == "" {
+		return inv
+	}
+	if soundFileIgnoredForGOOS(runtime.GOOS) {
+		fmt.Fprintln(w, soundFileIgnoredWarning())
+	}
+	if strings.HasPrefix(inv.soundFile, soundNamePrefix) {
+		// Built-in sounds are written out when the alarm plays.
+		return inv
+	}
+	if dir, ok := strings.CutPrefix(inv.soundFile, soundDirPrefix); ok {
+		// The file is picked when the alarm plays; check there is one.
+		inv.soundFile = ""
+		if resolved, err := resolveSoundFilePath(dir); err == nil {
+			if _, err := soundDirFiles(resolved); err == nil {
+				inv.soundFile = soundDirPrefix + resolved
+				return inv
+			}
+		}
+		fmt.Fprintln(w, soundDirWarning(dir))
+		return inv
+	}
+	original := inv.soundFile
+	inv.soundFile = resolveUsableSoundFilePath(inv.soundFile)
+	if inv.soundFile == "" {
+		fmt.Fprintln(w, soundFileWarning(original))
+	}
+	return inv
+}
+
+// fail reports err on stderr, and as an error record on stdout under
+// --porcelain, then exits with code.
+func fail(args []string, err error, code int) {
+	if wantsPorcelain(args) {
+		writePorcelain(os.Stdout, formatPorcelainError(code, err))
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(code)
+}
+
+// wantsPorcelain reports whether --porcelain was given, for errors found
+// before or while the command line is parsed.
+func wantsPorcelain(args []string) bool {
+	return slices.Contains(args[1:], "--porcelain")
+}
+
 func exitCodeForCancelError(err error) int {
 	var cause signalCause
 	if errors.As(err, &cause) {
@@ -190,6 +506,8 @@ func exitCodeForCancelError(err error) int {
 			return 130
 		case syscall.SIGTERM:
 			return 143
+		case syscall.SIGQUIT:
+			return 131
 		}
 	}
 	return 130
@@ -203,6 +521,10 @@ func soundFileWarning(path string) string {
 	return fmt.Sprintf("Warning: sound file not found or unreadable: %s; using default alarm", path)
 }
 
+func soundDirWarning(dir string) string {
+	return fmt.Sprintf("Warning: no playable sound files in %s; using default alarm", dir)
+}
+
 func soundFileIgnoredWarning() string {
 	return "Warning: --sound-file is not supported on this platform; using default alarm"
 }
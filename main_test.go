@@ -1,111 +1,106 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
-func TestShouldRunInternalAlarm(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name string
-		args []string
-		want bool
-	}{
-		{
-			name: "worker mode with exact hidden worker arg",
-			args: []string{"after", internalAlarmArg},
-			want: true,
-		},
-		{
-			name: "worker mode with sound file arg",
-			args: []string{"after", internalAlarmArg, "path/to/sound.mp3"},
-			want: true,
-		},
-		{
-			name: "normal mode when no args",
-			args: []string{"after"},
-			want: false,
-		},
-		{
-			name: "normal mode with duration arg",
-			args: []string{"after", "1s"},
-			want: false,
-		},
-		{
-			name: "worker mode with extra trailing args",
-			args: []string{"after", internalAlarmArg, "path/to/sound.mp3", "1s"},
-			want: true,
-		},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-			got := shouldRunInternalAlarm(tc.args)
-			if got != tc.want {
-				t.Fatalf("shouldRunInternalAlarm() = %v, want %v", got, tc.want)
-			}
-		})
-	}
-}
-
-func TestNewInternalAlarmCmd(t *testing.T) {
-	t.Parallel()
-
-	t.Run("without sound file", func(t *testing.T) {
-		cmd := newInternalAlarmCmd("/tmp/after-bin", "")
-		if len(cmd.Args) != 2 {
-			t.Fatalf("newInternalAlarmCmd() args length = %d, want 2", len(cmd.Args))
-		}
-		if cmd.Args[0] != "/tmp/after-bin" {
-			t.Fatalf("newInternalAlarmCmd() args[0] = %q, want %q", cmd.Args[0], "/tmp/after-bin")
-		}
-		if cmd.Args[1] != internalAlarmArg {
-			t.Fatalf("newInternalAlarmCmd() args[1] = %q, want %q", cmd.Args[1], internalAlarmArg)
-		}
-	})
-
-	t.Run("with sound file", func(t *testing.T) {
-		cmd := newInternalAlarmCmd("/tmp/after-bin", "path/to/sound.mp3")
-		if len(cmd.Args) != 3 {
-			t.Fatalf("newInternalAlarmCmd() args length = %d, want 3", len(cmd.Args))
-		}
-		if cmd.Args[1] != internalAlarmArg {
-			t.Fatalf("newInternalAlarmCmd() args[1] = %q, want %q", cmd.Args[1], internalAlarmArg)
-		}
-		if cmd.Args[2] != "path/to/sound.mp3" {
-			t.Fatalf("newInternalAlarmCmd() args[2] = %q, want %q", cmd.Args[2], "path/to/sound.mp3")
-		}
-	})
-
-	cmd := newInternalAlarmCmd("/tmp/after-bin", "")
-	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
-		t.Fatal("newInternalAlarmCmd() should set Setpgid=true")
-	}
-}
-
 func TestRenderHelpText(t *testing.T) {
 	t.Parallel()
 
 	want := usageText + "\n\nFlags:\n" +
-		"  -h, --help        Show help and exit\n" +
-		"  -v, --version     Show version and exit\n" +
-		"  -q, --quiet       Suppress alarm and status messages\n" +
-		"  -t, --no-title    Disable terminal title bar updates\n" +
-		"  -s, --sound       Force alarm even in quiet or non-TTY mode\n" +
-		"  -f, --sound-file  Custom audio file for completion alarm (implies --sound)\n" +
-		"  -c, --caffeinate  Prevent sleep even in non-TTY mode (macOS only)\n" +
+		"  -h, --help              Show help and exit\n" +
+		"  -v, --version           Show version and exit\n" +
+		"      --at                Count down to this time of day (e.g. 14:30, 9pm)\n" +
+		"      --until             Count down to a date and time (e.g. 2025-12-31T09:00)\n" +
+		"      --round             Count down to the next multiple of this interval (e.g. 15m)\n" +
+		"      --stdin             Run timers read from stdin, one per line (also: -)\n" +
+		"      --parallel          Run the --stdin timers at the same time, one line each\n" +
+		"      --tz                Time zone for the target time (e.g. Europe/Berlin)\n" +
+		"  -l, --label             Name the timer (shown in the countdown, title, and logs)\n" +
+		"  -m, --message           Text shown instead of \"after complete\"\n" +
+		"      --cancel-message    Text shown instead of \"after cancelled\"\n" +
+		"  -q, --quiet             Suppress alarm and status messages\n" +
+		"  -t, --no-title          Disable terminal title bar updates\n" +
+		"      --title-only        Show the countdown only in the title bar, not on the line\n" +
+		"      --percent           Show percent complete in the countdown and title\n" +
+		"      --elapsed           Count up from zero instead of down\n" +
+		"      --display           Countdown shows remaining, elapsed, or both\n" +
+		"      --refresh           Redraw the countdown at this interval (e.g. 100ms, 2s)\n" +
+		"      --color             Color the countdown green, yellow, red: auto, always, or never\n" +
+		"      --color-thresholds  When the color turns yellow and red (default 1m,10s)\n" +
+		"      --fullscreen        Show the countdown centered on the alternate screen\n" +
+		"      --big               Draw the countdown in large digits (implies --fullscreen)\n" +
+		"      --tmux-popup        Run the countdown in a tmux popup and return to the shell\n" +
+		"      --theme             Countdown theme: default, plain, minimal, bold, solarized\n" +
+		"      --style             Countdown parts: compact (time only), default, or full\n" +
+		"      --icons             Icons by the countdown: auto, emoji, nerd, or ascii (none)\n" +
+		"      --show-end          Show when the timer will end in the countdown and logs\n" +
+		"      --spinner           Turn a spinner by the time with every redraw\n" +
+		"      --stdout            Print the remaining time to stdout once a second\n" +
+		"      --json              Print start, tick, and end events to stdout as JSON lines\n" +
+		"      --porcelain         Print stable tab-separated start, end, and error records to stdout\n" +
+		"      --log               Record start, completion, and cancellation in syslog (the journal)\n" +
+		"  -s, --sound             Force alarm even in quiet or non-TTY mode\n" +
+		"  -f, --sound-file        Custom audio file for completion alarm (implies --sound)\n" +
+		"      --sound-name        Built-in alarm sound: beep, bell, chime, or marimba (implies --sound)\n" +
+		"      --sound-dir         Play a random sound file from a directory (implies --sound)\n" +
+		"      --alarm-backend     Play the alarm only through these comma-separated backends\n" +
+		"  -a, --alert             Use a named alert profile from the config file\n" +
+		"  -b, --broadcast         Announce completion on all of your open terminals\n" +
+		"      --wall              Announce completion on every logged-in terminal, like wall(1)\n" +
+		"      --flash             Flash the terminal on completion, a visible bell\n" +
+		"      --share             Share read-only status on the local network (see after discover)\n" +
+		"      --dbus              Publish the timer on the session bus as dev.mtnman.Timer\n" +
+		"      --no-notify         Do not post terminal desktop notifications (completion, --halfway)\n" +
+		"      --notify            Post a desktop notification over D-Bus when the timer completes\n" +
+		"      --notify-cancel     Also post one when the timer is cancelled (implies --notify)\n" +
+		"      --emergency         Send the Pushover push at emergency priority, repeated until acknowledged\n" +
+		"      --toggl             Track the timer as a Toggl Track time entry named for its label\n" +
+		"      --realert           Alert again when the terminal regains focus after you missed completion\n" +
+		"      --ack               Keep ringing until you press a key in the terminal\n" +
+		"      --escalate          Notify first, ring a minute later unless acknowledged, then louder\n" +
+		"      --gentle            Start the alarm quiet and raise the volume with each repeat\n" +
+		"      --announce          Say the time left aloud at these points (e.g. 5m,1m)\n" +
+		"      --tick-sound        Play a soft tick every second during the countdown\n" +
+		"      --tick-interval     Time between ticks (implies --tick-sound)\n" +
+		"      --every             Chime each time this much of the countdown has run (e.g. 10m)\n" +
+		"      --every-notify      Also post a desktop notification with each --every chime\n" +
+		"      --halfway           Chime and notify when half of the countdown has run\n" +
+		"      --final-beeps       Beep once a second for the last N seconds (e.g. 10)\n" +
+		"  -e, --exec              Run a shell command when the timer ends (see AFTER_* env)\n" +
+		"      --result-fd         Write a JSON result summary to this file descriptor\n" +
+		"      --result-file       Write a JSON result summary to this file\n" +
+		"      --ics               Write the end time as a calendar event to this file (- for stdout)\n" +
+		"      --pause-on-suspend  Pause while the system sleeps and report the pause\n" +
+		"      --idle-pause        Pause while the user is idle for at least this long\n" +
+		"  -c, --caffeinate        Prevent sleep even in non-TTY mode (macOS only)\n" +
 		"\nCancel: q, esc, ctrl+c, or ctrl+d\n"
 
 	got := renderHelpText()
@@ -446,6 +441,12 @@ func TestParseInvocation_RunModeFlagsAndDuration(t *testing.T) {
 		{name: "valid duration invocation", args: cliArgs("1s"), want: invocation{mode: modeRun, duration: time.Second}},
 		{name: "zero duration invocation", args: cliArgs("0s"), want: invocation{mode: modeRun, duration: 0}},
 		{name: "bare integer duration is seconds", args: cliArgs("5"), want: invocation{mode: modeRun, duration: 5 * time.Second}},
+		{name: "bare integer over a minute stays seconds", args: cliArgs("90"), want: invocation{mode: modeRun, duration: 90 * time.Second}},
+		{name: "decimal comma duration", args: cliArgs("1,5h"), want: invocation{mode: modeRun, duration: 90 * time.Minute}},
+		{name: "decimal comma bare seconds", args: cliArgs("2,5"), want: invocation{mode: modeRun, duration: 2500 * time.Millisecond}},
+		{name: "decimal comma idle pause", args: cliArgs("--idle-pause", "0,5m", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, idlePause: 30 * time.Second}},
+		{name: "plus-prefixed MM:SS is a duration", args: cliArgs("+1:30"), want: invocation{mode: modeRun, duration: 90 * time.Second}},
+		{name: "plus-prefixed HH:MM:SS is a duration", args: cliArgs("+01:15:00"), want: invocation{mode: modeRun, duration: 75 * time.Minute}},
 		{name: "bare decimal duration is seconds", args: cliArgs("0.5"), want: invocation{mode: modeRun, duration: 500 * time.Millisecond}},
 		{name: "bare leading-dot decimal duration is seconds", args: cliArgs(".5"), want: invocation{mode: modeRun, duration: 500 * time.Millisecond}},
 		{name: "bare trailing-dot decimal duration is seconds", args: cliArgs("5."), want: invocation{mode: modeRun, duration: 5 * time.Second}},
@@ -479,11 +480,74 @@ func TestParseInvocation_RunModeFlagsAndDuration(t *testing.T) {
 		{name: "combined quiet sound file awake short flags", args: cliArgs("-qfc", "path/to/sound.mp3", "1s"), want: invocation{mode: modeRun, duration: time.Second, quiet: true, forceAlarm: true, forceAwake: true, soundFile: "path/to/sound.mp3"}},
 		{name: "combined awake sound file quiet short flags", args: cliArgs("-cfq", "path/to/sound.mp3", "1s"), want: invocation{mode: modeRun, duration: time.Second, quiet: true, forceAlarm: true, forceAwake: true, soundFile: "path/to/sound.mp3"}},
 		{name: "sound file and quiet", args: cliArgs("--quiet", "--sound-file", "path/to/sound.mp3", "1s"), want: invocation{mode: modeRun, duration: time.Second, quiet: true, forceAlarm: true, soundFile: "path/to/sound.mp3"}},
+		{name: "alert long flag", args: cliArgs("--alert", "loud", "1s"), want: invocation{mode: modeRun, duration: time.Second, alertProfile: "loud"}},
+		{name: "alert short flag combined with quiet", args: cliArgs("-qa", "silent", "1s"), want: invocation{mode: modeRun, duration: time.Second, quiet: true, alertProfile: "silent"}},
+		{name: "exec long flag", args: cliArgs("--exec", "say done", "1s"), want: invocation{mode: modeRun, duration: time.Second, execCommand: "say done"}},
+		{name: "broadcast flag", args: cliArgs("-b", "1s"), want: invocation{mode: modeRun, duration: time.Second, broadcast: true}},
+		{name: "wall flag", args: cliArgs("--wall", "1s"), want: invocation{mode: modeRun, duration: time.Second, wall: true}},
+		{name: "log flag", args: cliArgs("--log", "syslog", "1s"), want: invocation{mode: modeRun, duration: time.Second, logTo: logSyslog}},
+		{name: "toggl flag", args: cliArgs("--toggl", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, trackToggl: true}},
+		{name: "ics flag", args: cliArgs("--ics", "end.ics", "1s"), want: invocation{mode: modeRun, duration: time.Second, ics: "end.ics"}},
+		{name: "flash flag", args: cliArgs("--flash", "1s"), want: invocation{mode: modeRun, duration: time.Second, flash: true}},
+		{name: "style flag", args: cliArgs("--style", "compact", "1s"), want: invocation{mode: modeRun, duration: time.Second, style: "compact"}},
+		{name: "icons flag", args: cliArgs("--icons", "nerd", "1s"), want: invocation{mode: modeRun, duration: time.Second, icons: iconsNerd}},
+		{name: "icons flag with equals", args: cliArgs("--icons=ascii", "1s"), want: invocation{mode: modeRun, duration: time.Second, icons: iconsASCII}},
+		{name: "pause on suspend flag", args: cliArgs("--pause-on-suspend", "1s"), want: invocation{mode: modeRun, duration: time.Second, pauseOnSuspend: true}},
+		{name: "idle pause with duration value", args: cliArgs("--idle-pause", "5m", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, idlePause: 5 * time.Minute}},
+		{name: "idle pause with bare seconds value", args: cliArgs("--idle-pause", "90", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, idlePause: 90 * time.Second}},
+		{name: "idle pause as last arg returns usage error", args: cliArgs("25m", "--idle-pause"), wantErr: errUsage},
+		{name: "share", args: cliArgs("--share", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, share: true}},
+		{name: "dbus", args: cliArgs("--dbus", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, dbus: true}},
+		{name: "realert", args: cliArgs("--realert", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, realert: true}},
+		{name: "ack", args: cliArgs("--ack", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, ack: true}},
+		{name: "gentle", args: cliArgs("--gentle", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, gentle: true}},
+		{name: "notify", args: cliArgs("--notify", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, notify: true}},
+		{name: "notify cancel", args: cliArgs("--notify-cancel", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, notify: true, notifyCancel: true}},
+		{name: "emergency", args: cliArgs("--emergency", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, emergency: true}},
+		{name: "result fd", args: cliArgs("--result-fd", "3", "25m"), want: invocation{mode: modeRun, duration: 25 * time.Minute, resultFD: 3}},
+		{name: "result file", args: cliArgs("25m", "--result-file", "out.json"), want: invocation{mode: modeRun, duration: 25 * time.Minute, resultFile: "out.json"}},
+		{name: "result fd as last arg returns usage error", args: cliArgs("25m", "--result-fd"), wantErr: errUsage},
+		{name: "exec as last arg returns usage error", args: cliArgs("1s", "-e"), wantErr: errUsage},
+		{name: "alert as last arg returns usage error", args: cliArgs("1s", "--alert"), wantErr: errUsage},
 		{name: "sound file as last arg returns usage error", args: cliArgs("1s", "--sound-file"), wantErr: errUsage},
 		{name: "short sound file as last arg returns usage error", args: cliArgs("1s", "-f"), wantErr: errUsage},
+		{name: "sound name", args: cliArgs("--sound-name", "chime", "1s"), want: invocation{mode: modeRun, duration: time.Second, soundFile: "sound:chime", forceAlarm: true}},
+		{name: "unknown sound name", args: cliArgs("--sound-name", "gong", "1s"), wantErr: invalidFlagValueError{flag: "--sound-name", value: "gong"}},
+		{name: "unknown log destination", args: cliArgs("--log", "file", "1s"), wantErr: invalidFlagValueError{flag: "--log", value: "file"}},
+		{name: "sound dir", args: cliArgs("--sound-dir", "~/sounds/timer/", "1s"), want: invocation{mode: modeRun, duration: time.Second, soundFile: "dir:~/sounds/timer/", forceAlarm: true}},
+		{name: "unknown alarm backend", args: cliArgs("--alarm-backend", "gong", "1s"), wantErr: invalidFlagValueError{flag: "--alarm-backend", value: "gong"}},
+		{name: "empty alarm backend", args: cliArgs("--alarm-backend", ",", "1s"), wantErr: invalidFlagValueError{flag: "--alarm-backend", value: ","}},
+		{name: "alarm backend missing value", args: cliArgs("--alarm-backend"), wantErr: errUsage},
+		{name: "announce", args: cliArgs("--announce", "1m, 5m,1m", "10m"), want: invocation{mode: modeRun, duration: 10 * time.Minute, announce: "5m0s,1m0s"}},
+		{name: "announce zero", args: cliArgs("--announce", "5m,0s", "10m"), wantErr: invalidFlagValueError{flag: "--announce", value: "5m,0s"}},
+		{name: "tick sound", args: cliArgs("--tick-sound", "1m"), want: invocation{mode: modeRun, duration: time.Minute, tickSound: true}},
+		{name: "tick interval", args: cliArgs("--tick-interval", "2s", "1m"), want: invocation{mode: modeRun, duration: time.Minute, tickSound: true, tickInterval: 2 * time.Second}},
+		{name: "tick interval too short", args: cliArgs("--tick-interval", "10ms", "1m"), wantErr: invalidFlagValueError{flag: "--tick-interval", value: "10ms"}},
+		{name: "every", args: cliArgs("--every", "10m", "--every-notify", "1h"), want: invocation{mode: modeRun, duration: time.Hour, every: 10 * time.Minute, everyNotify: true}},
+		{name: "escalate", args: cliArgs("--escalate", "30m"), want: invocation{mode: modeRun, duration: 30 * time.Minute, escalate: true}},
+		{name: "halfway", args: cliArgs("--halfway", "30m"), want: invocation{mode: modeRun, duration: 30 * time.Minute, halfway: true}},
+		{name: "every too short", args: cliArgs("--every", "500ms", "1h"), wantErr: invalidFlagValueError{flag: "--every", value: "500ms"}},
+		{name: "final beeps", args: cliArgs("--final-beeps", "10", "1m"), want: invocation{mode: modeRun, duration: time.Minute, finalBeeps: 10}},
+		{name: "final beeps zero", args: cliArgs("--final-beeps", "0", "1m"), wantErr: invalidFlagValueError{flag: "--final-beeps", value: "0"}},
+		{name: "final beeps too many", args: cliArgs("--final-beeps", "61", "1m"), wantErr: invalidFlagValueError{flag: "--final-beeps", value: "61"}},
+		{name: "announce garbage", args: cliArgs("--announce", "soon", "10m"), wantErr: invalidFlagValueError{flag: "--announce", value: "soon"}},
 		{name: "space-separated AM/PM token is consumed as part of time arg", args: cliArgs("3:00", "pm"), wantErr: nil, want: invocation{mode: modeRun}, skipDurationCheck: true},
 		{name: "space-separated AM/PM with leading flag still parses", args: cliArgs("-q", "3:00", "pm"), wantErr: nil, want: invocation{mode: modeRun, quiet: true}, skipDurationCheck: true},
 		{name: "space-separated AM/PM with trailing flag still parses", args: cliArgs("3:00", "pm", "-q"), wantErr: nil, want: invocation{mode: modeRun, quiet: true}, skipDurationCheck: true},
+		{name: "at flag with 24-hour time", args: cliArgs("--at", "14:30"), want: invocation{mode: modeRun}, skipDurationCheck: true},
+		{name: "at flag with space-separated pm", args: cliArgs("--at", "9", "pm", "-q"), want: invocation{mode: modeRun, quiet: true}, skipDurationCheck: true},
+		{name: "at flag rejects a duration", args: cliArgs("--at", "5m"), wantErr: invalidFlagValueError{flag: "--at", value: "5m"}},
+		{name: "at flag with invalid time", args: cliArgs("--at", "25:00"), wantErr: errInvalidTime},
+		{name: "at flag with positional duration is usage error", args: cliArgs("--at", "9pm", "5m"), wantErr: errUsage},
+		{name: "at flag without value is usage error", args: cliArgs("--at"), wantErr: errUsage},
+		{name: "until flag with unquoted date and time", args: cliArgs("--until", "2999-12-31", "09:00", "-q"), want: invocation{mode: modeRun, quiet: true}, skipDurationCheck: true},
+		{name: "until flag with RFC 3339", args: cliArgs("--until", "2999-12-31T09:00:00Z"), want: invocation{mode: modeRun}, skipDurationCheck: true},
+		{name: "until flag in the past", args: cliArgs("--until", "2001-01-01 09:00"), wantErr: errTargetInPast},
+		{name: "until flag with garbage", args: cliArgs("--until", "someday"), wantErr: invalidFlagValueError{flag: "--until", value: "someday"}},
+		{name: "tz with relative duration", args: cliArgs("--tz", "Europe/Berlin", "5m"), wantErr: errTimeZoneWithoutTarget},
+		{name: "tz with unknown zone", args: cliArgs("--tz", "Mars/Olympus", "--at", "9am"), wantErr: invalidFlagValueError{flag: "--tz", value: "Mars/Olympus"}},
+		{name: "tz with positional time of day", args: cliArgs("--tz", "Asia/Tokyo", "9am"), want: invocation{mode: modeRun}, skipDurationCheck: true},
+		{name: "until flag with positional duration is usage error", args: cliArgs("--until", "2999-12-31", "5m"), wantErr: errUsage},
 		{name: "invalid time with space-separated AM/PM returns invalid time error", args: cliArgs("13:00", "pm"), wantErr: errInvalidTime},
 		{name: "a shorthand attached", args: cliArgs("7a"), wantErr: nil, want: invocation{mode: modeRun}, skipDurationCheck: true},
 		{name: "p shorthand attached", args: cliArgs("7p"), wantErr: nil, want: invocation{mode: modeRun}, skipDurationCheck: true},
@@ -740,11 +804,16 @@ func TestParseWallClockTime(t *testing.T) {
 			wantErr: errInvalidTime,
 		},
 		{
-			name:    "empty hour field returns invalid time error",
+			name:    "minute only :00 is the top of the next hour",
 			token:   ":00",
 			wantOk:  true,
-			wantErr: errInvalidTime,
+			wantDur: 30 * time.Minute,
 		},
+		{name: "minute only :45 is later this hour", token: ":45", wantOk: true, wantDur: 15 * time.Minute},
+		{name: "minute only equal to now wraps to the next hour", token: ":30", wantOk: true, wantDur: time.Hour},
+		{name: "minute only :60 returns invalid time error", token: ":60", wantOk: true, wantErr: errInvalidTime},
+		{name: "minute only needs two digits", token: ":5", wantOk: true, wantErr: errInvalidTime},
+		{name: "bare colon returns invalid time error", token: ":", wantOk: true, wantErr: errInvalidTime},
 		{
 			name:    "empty minute field returns invalid time error",
 			token:   "12:",
@@ -1051,10 +1120,12 @@ func TestAlarmCandidatesForGOOS(t *testing.T) {
 		wantFirst string
 	}{
 		{goos: "darwin", wantCount: 1, wantFirst: "afplay"},
-		{goos: "linux", wantCount: 2, wantFirst: "canberra-gtk-play"},
+		{goos: "linux", wantCount: 10, wantFirst: "canberra-gtk-play"},
 		{goos: "freebsd", wantCount: 2, wantFirst: "beep"},
 		{goos: "openbsd", wantCount: 1, wantFirst: "beep"},
 		{goos: "netbsd", wantCount: 1, wantFirst: "beep"},
+		{goos: "windows", wantCount: 3, wantFirst: builtinBeepBackend},
+		{goos: "wsl", wantCount: 11, wantFirst: "powershell.exe"},
 	}
 
 	for _, tc := range tests {
@@ -1072,6 +1143,98 @@ func TestAlarmCandidatesForGOOS(t *testing.T) {
 	}
 }
 
+func TestAlarmPlatform(t *testing.T) {
+	t.Parallel()
+
+	noEnv := func(string) string { return "" }
+	readVersion := func(version string) func(string) ([]byte, error) {
+		return func(path string) ([]byte, error) {
+			if path != "/proc/version" {
+				t.Fatalf("readFile(%q), want /proc/version", path)
+			}
+			return []byte(version), nil
+		}
+	}
+	tests := []struct {
+		name     string
+		goos     string
+		getenv   func(string) string
+		readFile func(string) ([]byte, error)
+		want     string
+	}{
+		{name: "distro name", goos: "linux", getenv: func(key string) string {
+			if key == "WSL_DISTRO_NAME" {
+				return "Ubuntu"
+			}
+			return ""
+		}, readFile: readVersion("Linux version 6.8.0-generic"), want: "wsl"},
+		{name: "WSL2 kernel", goos: "linux", getenv: noEnv, readFile: readVersion("Linux version 5.15.153.1-microsoft-standard-WSL2"), want: "wsl"},
+		{name: "WSL1 kernel", goos: "linux", getenv: noEnv, readFile: readVersion("Linux version 4.4.0-19041-Microsoft"), want: "wsl"},
+		{name: "plain linux", goos: "linux", getenv: noEnv, readFile: readVersion("Linux version 6.8.0-generic"), want: "linux"},
+		{name: "no /proc", goos: "linux", getenv: noEnv, readFile: func(string) ([]byte, error) { return nil, os.ErrNotExist }, want: "linux"},
+		{name: "darwin", goos: "darwin", getenv: noEnv, readFile: readVersion("microsoft"), want: "darwin"},
+	}
+	for _, tc := range tests {
+		if got := alarmPlatform(tc.goos, tc.getenv, tc.readFile); got != tc.want {
+			t.Errorf("%s: alarmPlatform() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWSLAlarmCandidates(t *testing.T) {
+	t.Parallel()
+
+	got := wslAlarmCandidates("/home/o'neil/bell.wav", "Ubuntu")
+	if len(got) != 2 || got[0].name != "powershell.exe" || got[1].name != "powershell.exe" {
+		t.Fatalf("wslAlarmCandidates(file) = %v, want the file then the system sound", got)
+	}
+	wantScript := `(New-Object Media.SoundPlayer '\\wsl$\Ubuntu\home\o''neil\bell.wav').PlaySync()`
+	if script := got[0].args[len(got[0].args)-1]; script != wantScript {
+		t.Fatalf("wslAlarmCandidates(file) script = %q, want %q", script, wantScript)
+	}
+	if !strings.Contains(got[1].args[len(got[1].args)-1], "SystemSounds") {
+		t.Fatalf("wslAlarmCandidates(file) fallback = %v, want a Windows system sound", got[1])
+	}
+	// Without the distro's name Windows cannot reach the file.
+	if got := wslAlarmCandidates("/home/o'neil/bell.wav", ""); len(got) != 1 {
+		t.Fatalf("wslAlarmCandidates(file, no distro) = %v, want only the system sound", got)
+	}
+}
+
+func TestPCSpeakerBackendRejectsBadTones(t *testing.T) {
+	t.Parallel()
+
+	play, ok := builtinAlarmBackends[builtinPCSpeakerBackend]
+	if !ok {
+		t.Skip("no PC speaker backend on this platform")
+	}
+	for _, args := range [][]string{nil, {"1200"}, {"high", "150"}, {"0", "150"}} {
+		if err := play(args); err == nil {
+			t.Errorf("PC speaker beep %q error = nil, want an error", args)
+		}
+	}
+}
+
+func TestWindowsBackendsRejectBadArgs(t *testing.T) {
+	t.Parallel()
+
+	beep, ok := builtinAlarmBackends[builtinBeepBackend]
+	if !ok {
+		t.Skip("no Windows backends on this platform")
+	}
+	for _, args := range [][]string{nil, {"1200"}, {"high", "150"}, {"0", "150"}, {"40000", "150"}} {
+		if err := beep(args); err == nil {
+			t.Errorf("console beep %q error = nil, want an error", args)
+		}
+	}
+	playSound := builtinAlarmBackends[builtinPlaySoundBackend]
+	for _, args := range [][]string{nil, {""}, {"a.wav", "b.wav"}} {
+		if err := playSound(args); err == nil {
+			t.Errorf("PlaySound %q error = nil, want an error", args)
+		}
+	}
+}
+
 func TestAlarmCandidatesForUnknownGOOS(t *testing.T) {
 	t.Parallel()
 
@@ -1093,14 +1256,29 @@ func TestAlarmCandidatesWithSoundFile(t *testing.T) {
 
 	t.Run("linux custom sound", func(t *testing.T) {
 		got := alarmCandidatesForGOOS("linux", "custom.mp3")
-		if len(got) != 2 {
-			t.Fatalf("alarmCandidatesForGOOS(linux, custom.mp3) length = %d, want 2", len(got))
+		var names []string
+		for _, c := range got {
+			names = append(names, c.name)
+			if c.args[len(c.args)-1] != "custom.mp3" {
+				t.Fatalf("alarmCandidatesForGOOS(linux, custom.mp3) %s args = %q, want the file last", c.name, c.args)
+			}
+		}
+		want := []string{"canberra-gtk-play", "pw-play", "paplay", "ffplay", "mpv", "play", "aplay"}
+		if !reflect.DeepEqual(names, want) {
+			t.Fatalf("alarmCandidatesForGOOS(linux, custom.mp3) = %v, want %v", names, want)
+		}
+	})
+
+	t.Run("linux default plays the built-in bell through file players", func(t *testing.T) {
+		got := alarmCandidatesForGOOS("linux", "")
+		if got[1].name != "canberra-gtk-play" || got[1].args[1] != "sound:bell" {
+			t.Fatalf("alarmCandidatesForGOOS(linux) second = %v, want the built-in bell", got[1])
 		}
-		if got[0].name != "canberra-gtk-play" || got[0].args[1] != "custom.mp3" {
-			t.Fatalf("alarmCandidatesForGOOS(linux, custom.mp3) first = %v", got[0])
+		if c := got[len(got)-2]; c.args[1] != "speaker-test" {
+			t.Fatalf("alarmCandidatesForGOOS(linux) next to last = %v, want speaker-test", c)
 		}
-		if got[1].name != "paplay" || got[1].args[0] != "custom.mp3" {
-			t.Fatalf("alarmCandidatesForGOOS(linux, custom.mp3) second = %v", got[1])
+		if last := got[len(got)-1]; last.name != builtinPCSpeakerBackend {
+			t.Fatalf("alarmCandidatesForGOOS(linux) last = %v, want the PC speaker", last)
 		}
 	})
 
@@ -1111,6 +1289,20 @@ func TestAlarmCandidatesWithSoundFile(t *testing.T) {
 		}
 	})
 
+	t.Run("windows custom sound", func(t *testing.T) {
+		got := alarmCandidatesForGOOS("windows", `C:\Users\o'neil\bell.wav`)
+		if len(got) != 2 {
+			t.Fatalf("alarmCandidatesForGOOS(windows, custom) length = %d, want 2", len(got))
+		}
+		if got[0].name != builtinPlaySoundBackend || got[0].args[0] != `C:\Users\o'neil\bell.wav` {
+			t.Fatalf("alarmCandidatesForGOOS(windows, custom) first = %v", got[0])
+		}
+		wantScript := `(New-Object Media.SoundPlayer 'C:\Users\o''neil\bell.wav').PlaySync()`
+		if got[1].name != "powershell" || got[1].args[len(got[1].args)-1] != wantScript {
+			t.Fatalf("alarmCandidatesForGOOS(windows, custom) second = %v", got[1])
+		}
+	})
+
 	t.Run("openbsd custom sound falls back to beep", func(t *testing.T) {
 		got := alarmCandidatesForGOOS("openbsd", "custom.mp3")
 		if len(got) != 1 || got[0].name != "beep" {
@@ -1204,7 +1396,7 @@ func TestRunTimerReturnsCancelCause(t *testing.T) {
 		interactive:      false,
 		supportsAdvanced: false,
 	}
-	err := runTimer(ctx, cancel, time.Hour, time.Time{}, status, false, false, false, false, false, "")
+	err := runTimer(ctx, cancel, invocation{duration: time.Hour}, status, false)
 	if err == nil {
 		t.Fatal("runTimer() error = nil, want cancellation cause")
 	}
@@ -1234,7 +1426,7 @@ func TestRunTimerWithAlarmStarter_ForceAlarmInNonTTY(t *testing.T) {
 
 	status := newStatusDisplay(io.Discard, false, false)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, false, true, false, true, false, "", func(string) {
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{quiet: true, forceAlarm: true}, status, false, func(alarmSound) {
 		alarmCalls++
 	})
 	if err != nil {
@@ -1282,7 +1474,7 @@ func TestRunTimerWithAlarmStarter_DefaultAlarmRequiresBothStreamsTTY(t *testing.
 			alarmCalls := 0
 			status := newStatusDisplay(io.Discard, tc.statusInteractive, false)
 
-			err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, tc.sideEffectsInteractive, false, false, false, false, "", func(string) {
+			err := runTimerWithAlarmStarter(ctx, cancel, invocation{}, status, tc.sideEffectsInteractive, func(alarmSound) {
 				alarmCalls++
 			})
 			if err != nil {
@@ -1486,7 +1678,7 @@ func TestRunTimerWithAlarmStarter_NonTTYLifecycleOutput(t *testing.T) {
 	defer cancel(nil)
 	out, status := newCapturedStatus(false, false)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, false, false, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{}, status, false, func(alarmSound) {})
 	if err != nil {
 		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
 	}
@@ -1505,7 +1697,7 @@ func TestRunTimerWithAlarmStarter_NonTTYLifecycleOutputWallClock(t *testing.T) {
 	out, status := newCapturedStatus(false, false)
 
 	target := time.Date(2024, 1, 1, 14, 30, 0, 0, time.UTC) // past time, fires immediately
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, target, status, false, false, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{wallClockTarget: target}, status, false, func(alarmSound) {})
 	if err != nil {
 		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
 	}
@@ -1516,6 +1708,253 @@ func TestRunTimerWithAlarmStarter_NonTTYLifecycleOutputWallClock(t *testing.T) {
 	}
 }
 
+func TestRunTimerWithAlarmStarter_StdoutStream(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	_, status := newCapturedStatus(false, false)
+	var stream bytes.Buffer
+	status.stream = &stream
+
+	inv := invocation{duration: 120 * time.Millisecond, refresh: 50 * time.Millisecond, stdoutStream: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stream.String(), "\n"), "\n")
+	if len(lines) < 2 || lines[0] != "1" || lines[len(lines)-1] != "0" {
+		t.Fatalf("stream = %q, want lines from \"1\" down to \"0\"", stream.String())
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if line != "1" {
+			t.Fatalf("stream = %q, want only \"1\" before completion", stream.String())
+		}
+	}
+}
+
+func TestRunTimerWithAlarmStarter_JSONEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	_, status := newCapturedStatus(false, false)
+	var stream bytes.Buffer
+	status.stream = &stream
+
+	inv := invocation{duration: 120 * time.Millisecond, refresh: 50 * time.Millisecond, jsonEvents: true, label: "tea"}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+
+	var events []progressEvent
+	dec := json.NewDecoder(&stream)
+	for dec.More() {
+		var e progressEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if len(events) < 2 {
+		t.Fatalf("got %d events, want at least start and complete", len(events))
+	}
+	first, last := events[0], events[len(events)-1]
+	if first.Event != progressEventStart || first.Label != "tea" || first.TotalMS != 120 || first.Percent != 0 {
+		t.Fatalf("first event = %+v, want start of 120ms timer tea", first)
+	}
+	if last.Event != progressEventComplete || last.RemainingMS != 0 || last.Percent != 100 {
+		t.Fatalf("last event = %+v, want complete at 100%%", last)
+	}
+	for _, e := range events[1 : len(events)-1] {
+		if e.Event != progressEventTick {
+			t.Fatalf("middle event = %+v, want tick", e)
+		}
+	}
+}
+
+func TestFormatPorcelainRecords(t *testing.T) {
+	t.Parallel()
+
+	berlin := time.FixedZone("CET", 3600)
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, berlin)
+	deadline := started.Add(4 * time.Minute)
+	ended := started.Add(90500 * time.Millisecond)
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "start",
+			got:  formatPorcelainStart(started, deadline, "tea\tpot"),
+			want: "start\t2025-03-01T08:00:00Z\t2025-03-01T08:04:00Z\t240\ttea pot\n",
+		},
+		{
+			name: "complete",
+			got:  formatPorcelainEnd(outcomeComplete, started, ended, "", nil),
+			want: "complete\t2025-03-01T08:01:30.5Z\t90.5\t\n",
+		},
+		{
+			name: "cancelled by signal",
+			got:  formatPorcelainEnd(outcomeCancelled, started, ended, "tea", signalCause{sig: os.Interrupt}),
+			want: "cancelled\t2025-03-01T08:01:30.5Z\t90.5\tsignal\tSIGINT\ttea\n",
+		},
+		{
+			name: "cancelled remotely",
+			got:  formatPorcelainEnd(outcomeCancelled, started, ended, "", errCancelledRemotely),
+			want: "cancelled\t2025-03-01T08:01:30.5Z\t90.5\tremote\t\t\n",
+		},
+		{
+			name: "error",
+			got:  formatPorcelainError(2, errInvalidDuration),
+			want: "error\t2\tinvalid duration format\n",
+		},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestFormatSyslogRecords(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Date(2025, 3, 1, 9, 4, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "start",
+			got:  formatSyslogStart(`backup "nightly"`, 4*time.Minute, deadline),
+			want: `start label="backup \"nightly\"" length=4m0s deadline=2025-03-01T08:04:00Z`,
+		},
+		{
+			name: "complete",
+			got:  formatSyslogEnd(outcomeComplete, "", 4*time.Minute, 4*time.Minute+30*time.Millisecond, nil),
+			want: "complete length=4m0s elapsed=4m0s",
+		},
+		{
+			name: "cancelled by signal",
+			got:  formatSyslogEnd(outcomeCancelled, "tea", 4*time.Minute, 90500*time.Millisecond, signalCause{sig: syscall.SIGTERM}),
+			want: `cancelled label="tea" length=4m0s elapsed=1m30.5s reason=signal signal=SIGTERM`,
+		},
+		{
+			name: "cancelled remotely",
+			got:  formatSyslogEnd(outcomeCancelled, "", 4*time.Minute, time.Minute, errCancelledRemotely),
+			want: "cancelled length=4m0s elapsed=1m0s reason=remote",
+		},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestSDNotify(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "notify")
+	manager, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer manager.Close()
+	env := map[string]string{sdNotifySocketEnvVar: path}
+	n, err := openSDNotify(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := func() string {
+		buf := make([]byte, 256)
+		manager.SetReadDeadline(time.Now().Add(time.Second))
+		size, err := manager.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:size])
+	}
+
+	n.send("READY=1\n" + formatSDStatus("tea", 90500*time.Millisecond, false))
+	if got, want := receive(), "READY=1\nSTATUS=tea: 1 minute 31 seconds left"; got != want {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	n.startWatchdog(10 * time.Millisecond)
+	if got := receive(); got != "WATCHDOG=1" {
+		t.Fatalf("watchdog sent %q, want WATCHDOG=1", got)
+	}
+	n.close()
+
+	if n, err := openSDNotify(func(string) string { return "" }); n != nil || err != nil {
+		t.Fatalf("openSDNotify() without a socket = %v, %v, want nil", n, err)
+	}
+	if got, want := formatSDStatus("", time.Minute, true), "STATUS=paused, 1 minute left"; got != want {
+		t.Fatalf("formatSDStatus() = %q, want %q", got, want)
+	}
+}
+
+func TestSDWatchdogInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+	}{
+		{usec: "", want: 0},
+		{usec: "30000000", want: 15 * time.Second},
+		{usec: "30000000", pid: "42", want: 15 * time.Second},
+		{usec: "30000000", pid: "43", want: 0},
+		{usec: "soon", want: 0},
+	}
+	for _, tc := range tests {
+		env := map[string]string{sdWatchdogUSecEnvVar: tc.usec, sdWatchdogPIDEnvVar: tc.pid}
+		if got := sdWatchdogInterval(func(k string) string { return env[k] }, 42); got != tc.want {
+			t.Errorf("sdWatchdogInterval(%q, %q) = %v, want %v", tc.usec, tc.pid, got, tc.want)
+		}
+	}
+}
+
+func TestRunTimerWithAlarmStarter_Porcelain(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	_, status := newCapturedStatus(false, false)
+	var stream bytes.Buffer
+	status.stream = &stream
+
+	inv := invocation{duration: 20 * time.Millisecond, porcelain: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stream.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "start\t") || !strings.HasPrefix(lines[1], "complete\t") {
+		t.Fatalf("porcelain output = %q, want a start and a complete record", stream.String())
+	}
+	if fields := strings.Split(lines[0], "\t"); len(fields) != 5 || fields[3] != "0.02" {
+		t.Fatalf("start record = %q, want 5 fields with 0.02 requested seconds", lines[0])
+	}
+}
+
+func TestNewProgressEventCancelled(t *testing.T) {
+	t.Parallel()
+
+	e := newProgressEvent(progressEventCancelled, "", time.Minute, 4*time.Minute, time.Now(), false, signalCause{sig: syscall.SIGTERM})
+	if e.Reason != reasonSignal || e.Signal != "SIGTERM" || e.Percent != 75 || e.RemainingMS != 60000 {
+		t.Fatalf("newProgressEvent() = %+v, want SIGTERM cancel at 75%%", e)
+	}
+	e = newProgressEvent(progressEventCancelled, "", 0, time.Minute, time.Now(), false, errCancelledRemotely)
+	if e.Reason != reasonRemote || e.Signal != "" {
+		t.Fatalf("newProgressEvent() = %+v, want remote cancel without signal", e)
+	}
+}
+
 func TestRunTimerWithAlarmStarter_NonTTYQuietSuppressesLifecycle(t *testing.T) {
 	t.Parallel()
 
@@ -1523,7 +1962,7 @@ func TestRunTimerWithAlarmStarter_NonTTYQuietSuppressesLifecycle(t *testing.T) {
 	defer cancel(nil)
 	out, status := newCapturedStatus(false, false)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, false, true, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{quiet: true}, status, false, func(alarmSound) {})
 	if err != nil {
 		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
 	}
@@ -1540,7 +1979,7 @@ func TestRunTimerWithAlarmStarter_NonTTYCancelLifecycleOutput(t *testing.T) {
 
 	out, status := newCapturedStatus(false, false)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 10*time.Second, time.Time{}, status, false, false, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{duration: 10 * time.Second}, status, false, func(alarmSound) {})
 	if err == nil {
 		t.Fatal("runTimerWithAlarmStarter() error = nil, want cancellation cause")
 	}
@@ -1558,7 +1997,7 @@ func TestRunTimerWithAlarmStarter_InteractiveWritesToStatusWriter(t *testing.T)
 	defer cancel(nil)
 	out, status := newCapturedStatus(true, false)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, false, false, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{}, status, false, func(alarmSound) {})
 	if err != nil {
 		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
 	}
@@ -1574,12 +2013,12 @@ func TestRunTimerWithAlarmStarter_InteractiveQuietClearsStatusLine(t *testing.T)
 	defer cancel(nil)
 	out, status := newCapturedStatus(true, true)
 
-	err := runTimerWithAlarmStarter(ctx, cancel, 0, time.Time{}, status, false, true, false, false, false, "", func(string) {})
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{quiet: true}, status, false, func(alarmSound) {})
 	if err != nil {
 		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
 	}
-	if got := out.String(); got != "\033]0;0\007\r\033[K0\r\033[K" {
-		t.Fatalf("runTimerWithAlarmStarter() output = %q, want %q", got, "\033]0;0\007\r\\033[K0\r\\033[K")
+	if want := "\033[22;0t\033]0;[100%] 0\007\r\033[K0\033[23;0t\r\033[K"; out.String() != want {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want %q", out.String(), want)
 	}
 }
 
@@ -1628,6 +2067,12 @@ func TestFormatLifecycleStarted(t *testing.T) {
 			wallClockTarget: time.Date(2024, 1, 1, 14, 30, 0, 0, time.UTC),
 			want:            "after: started (until 14:30)",
 		},
+		{
+			name:            "wall clock mode more than a day away shows the date",
+			duration:        72 * time.Hour,
+			wallClockTarget: time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC),
+			want:            "after: started (until 2024-12-31 09:00)",
+		},
 		{
 			name:            "wall clock mode with seconds",
 			wallClockTarget: time.Date(2024, 1, 1, 9, 5, 30, 0, time.UTC),
@@ -1646,259 +2091,5366 @@ func TestFormatLifecycleStarted(t *testing.T) {
 	}
 }
 
-func TestSupportsAdvancedTerminal(t *testing.T) {
+func TestLookupTerminalCaps(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name string
 		term string
-		want bool
+		want terminalCaps
 	}{
-		{name: "xterm supports advanced", term: "xterm-256color", want: true},
-		{name: "dumb does not support advanced", term: "dumb", want: false},
-		{name: "empty term does not support advanced", term: "", want: false},
-		{name: "whitespace and case are normalized", term: " DUMB ", want: false},
+		{term: "xterm-256color", want: terminalCaps{clearLine: true, title: true, color: true}},
+		{term: "tmux-256color", want: terminalCaps{clearLine: true, title: true, color: true}},
+		{term: "screen-256color", want: terminalCaps{clearLine: true, color: true}},
+		{term: "screen.xterm-256color", want: terminalCaps{clearLine: true, color: true}},
+		{term: "linux", want: terminalCaps{clearLine: true, color: true}},
+		{term: "vt100", want: terminalCaps{clearLine: true}},
+		{term: "xterm-mono", want: terminalCaps{clearLine: true, title: true}},
+		{term: "vt52", want: terminalCaps{}},
+		{term: "dumb", want: terminalCaps{}},
+		{term: " DUMB ", want: terminalCaps{}},
+		{term: "", want: terminalCaps{}},
 	}
-
 	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			got := supportsAdvancedTerminal(tc.term)
-			if got != tc.want {
-				t.Fatalf("supportsAdvancedTerminal(%q) = %v, want %v", tc.term, got, tc.want)
-			}
-		})
+		if got := lookupTerminalCaps(tc.term); got != tc.want {
+			t.Errorf("lookupTerminalCaps(%q) = %+v, want %+v", tc.term, got, tc.want)
+		}
 	}
 }
 
-func TestPlayAlarmAttempts_RemovesFailingBackendsAndFallsBack(t *testing.T) {
+func TestThemePulseSGR(t *testing.T) {
 	t.Parallel()
 
-	commands := []alarmCommand{
-		{name: "broken-backend"},
-		{name: "working-backend"},
+	th := themes["default"]
+	tests := []struct {
+		name      string
+		sgr       string
+		remaining time.Duration
+		paused    bool
+		want      string
+	}{
+		{name: "before the final stretch", sgr: "33", remaining: 30 * time.Second, want: "33"},
+		{name: "pulse on", sgr: "31", remaining: 9700 * time.Millisecond, want: "31;7"},
+		{name: "pulse off", sgr: "31", remaining: 9200 * time.Millisecond, want: "31"},
+		{name: "pulse without color", remaining: 700 * time.Millisecond, want: "7"},
+		{name: "paused", sgr: "31", remaining: 9700 * time.Millisecond, paused: true, want: "31"},
 	}
-	var calls []string
-
-	runner := func(command alarmCommand) error {
-		calls = append(calls, command.name)
-		if command.name == "broken-backend" {
-			return errors.New("boom")
+	for _, tc := range tests {
+		if got := th.pulseSGR(tc.sgr, tc.remaining, tc.paused); got != tc.want {
+			t.Errorf("%s: pulseSGR() = %q, want %q", tc.name, got, tc.want)
 		}
-		return nil
-	}
-
-	playAlarmAttempts(commands, 4, 0, runner)
-
-	wantCalls := []string{
-		"broken-backend",
-		"working-backend",
-		"working-backend",
-		"working-backend",
-		"working-backend",
 	}
-	if !reflect.DeepEqual(calls, wantCalls) {
-		t.Fatalf("playAlarmAttempts() calls = %v, want %v", calls, wantCalls)
+	if got := themes["plain"].pulseSGR("", 700*time.Millisecond, false); got != "" {
+		t.Errorf("plain theme pulseSGR() = %q, want no pulse", got)
 	}
 }
 
-func TestStripAMPM(t *testing.T) {
+func TestRunTimerWithAlarmStarter_FinalStretchPulse(t *testing.T) {
 	t.Parallel()
 
-	tests := []struct {
-		name      string
-		token     string
-		wantStrip string
-		wantIsPM  bool
-		wantFound bool
+	for _, quiet := range []bool{false, true} {
+		t.Run(fmt.Sprintf("quiet=%v", quiet), func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			out, status := newCapturedStatus(true, true)
+			err := runTimerWithAlarmStarter(ctx, cancel, invocation{duration: 600 * time.Millisecond, quiet: quiet, noTitle: true}, status, false, func(alarmSound) {})
+			if err != nil {
+				t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+			}
+			if got := strings.Contains(out.String(), "\033[7m"); got == quiet {
+				t.Fatalf("output = %q, pulsed = %v, want %v", out.String(), got, !quiet)
+			}
+		})
+	}
+}
+
+func TestResolveIcons(t *testing.T) {
+	t.Parallel()
+
+	emulator := statusDisplay{interactive: true, supportsAdvanced: true}
+	console := statusDisplay{interactive: true, supportsAdvanced: true, noTitles: true}
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	utf8 := env(map[string]string{"LANG": "en_US.UTF-8"})
+	tests := []struct {
+		name   string
+		mode   iconMode
+		status statusDisplay
+		getenv func(string) string
+		want   iconSet
 	}{
-		// --- no suffix ---
-		{name: "no suffix returns token unchanged", token: "9:00", wantStrip: "9:00", wantIsPM: false, wantFound: false},
-		{name: "bare integer no suffix falls through", token: "9", wantStrip: "9", wantIsPM: false, wantFound: false},
+		{name: "auto in a UTF-8 terminal emulator", mode: iconsAuto, status: emulator, getenv: utf8, want: iconSets[iconsEmoji]},
+		{name: "auto on the Linux console", mode: iconsAuto, status: console, getenv: utf8, want: iconSet{}},
+		{name: "auto in the C locale", mode: iconsAuto, status: emulator, getenv: env(map[string]string{"LANG": "C"}), want: iconSet{}},
+		{name: "LC_ALL overrides LANG", mode: iconsAuto, status: emulator, getenv: env(map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.UTF-8"}), want: iconSet{}},
+		{name: "auto when redirected", mode: iconsAuto, status: statusDisplay{}, getenv: utf8, want: iconSet{}},
+		{name: "nerd is never guessed", mode: iconsNerd, status: statusDisplay{}, getenv: env(nil), want: iconSets[iconsNerd]},
+		{name: "ascii", mode: iconsASCII, status: emulator, getenv: utf8, want: iconSet{}},
+	}
+	for _, tc := range tests {
+		if got := resolveIcons(tc.mode, tc.status, tc.getenv); got != tc.want {
+			t.Errorf("%s: resolveIcons() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
 
-		// --- attached AM ---
-		{name: "lowercase am attached", token: "9am", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "uppercase AM attached", token: "9AM", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "mixed case Am attached", token: "9Am", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "HH:MM with attached am", token: "9:00am", wantStrip: "9:00", wantIsPM: false, wantFound: true},
-		{name: "HH:MM:SS with attached am", token: "9:00:00am", wantStrip: "9:00:00", wantIsPM: false, wantFound: true},
+func TestRunTimerWithAlarmStarter_Icons(t *testing.T) {
+	t.Parallel()
 
-		// --- attached PM ---
-		{name: "lowercase pm attached", token: "1pm", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "uppercase PM attached", token: "1PM", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "HH:MM with attached pm", token: "1:30pm", wantStrip: "1:30", wantIsPM: true, wantFound: true},
-		{name: "HH:MM:SS with attached pm", token: "1:30:00pm", wantStrip: "1:30:00", wantIsPM: true, wantFound: true},
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, false)
+	status.icons = iconSets[iconsEmoji]
 
-		// --- space-separated AM ---
-		{name: "space separated am", token: "9 am", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "space separated AM uppercase", token: "9 AM", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "HH:MM with space separated am", token: "9:00 am", wantStrip: "9:00", wantIsPM: false, wantFound: true},
+	inv := invocation{duration: 20 * time.Millisecond, label: "tea"}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	for _, want := range []string{"\r⏳ tea: 0.", "\r🔔 after complete: tea\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("runTimerWithAlarmStarter() output = %q, want %q", out.String(), want)
+		}
+	}
+}
 
-		// --- space-separated PM ---
-		{name: "space separated pm", token: "1 pm", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "space separated PM uppercase", token: "1 PM", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "HH:MM with space separated pm", token: "1:30 pm", wantStrip: "1:30", wantIsPM: true, wantFound: true},
-		{name: "HH:MM:SS with space separated pm", token: "1:30:00 pm", wantStrip: "1:30:00", wantIsPM: true, wantFound: true},
+func TestFlashScreen(t *testing.T) {
+	t.Parallel()
 
-		// --- shorthand: attached a/p ---
-		{name: "lowercase a shorthand attached", token: "9a", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "uppercase A shorthand attached", token: "9A", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "lowercase p shorthand attached", token: "1p", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "uppercase P shorthand attached", token: "1P", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "HH:MM with attached a", token: "9:00a", wantStrip: "9:00", wantIsPM: false, wantFound: true},
-		{name: "HH:MM with attached p", token: "3:30p", wantStrip: "3:30", wantIsPM: true, wantFound: true},
+	var out bytes.Buffer
+	var slept time.Duration
+	flashScreen(&out, func(d time.Duration) {
+		slept = d
+		if out.String() != flashOn {
+			t.Fatalf("flashScreen() wrote %q before sleeping, want %q", out.String(), flashOn)
+		}
+	})
+	if out.String() != flashOn+flashOff || slept != flashDuration {
+		t.Fatalf("flashScreen() wrote %q and slept %v, want %q and %v", out.String(), slept, flashOn+flashOff, flashDuration)
+	}
+}
 
-		// --- shorthand: space-separated a/p ---
-		{name: "space separated a shorthand", token: "9 a", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "space separated A uppercase", token: "9 A", wantStrip: "9", wantIsPM: false, wantFound: true},
-		{name: "space separated p shorthand", token: "1 p", wantStrip: "1", wantIsPM: true, wantFound: true},
-		{name: "space separated P uppercase", token: "1 P", wantStrip: "1", wantIsPM: true, wantFound: true},
+func TestRunTimerWithAlarmStarter_Flash(t *testing.T) {
+	t.Parallel()
 
-		// --- am/p suffix does not match only 'a' inside "am" ---
-		{name: "9am still strips am not just a", token: "9am", wantStrip: "9", wantIsPM: false, wantFound: true},
+	for _, tc := range []struct {
+		name        string
+		interactive bool
+		want        bool
+	}{
+		{name: "interactive", interactive: true, want: true},
+		{name: "redirected", interactive: false, want: false},
+	} {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		out, status := newCapturedStatus(tc.interactive, true)
+		err := runTimerWithAlarmStarter(ctx, cancel, invocation{flash: true, quiet: true}, status, false, func(alarmSound) {})
+		cancel(nil)
+		if err != nil {
+			t.Fatalf("%s: runTimerWithAlarmStarter() error = %v, want nil", tc.name, err)
+		}
+		if got := strings.Contains(out.String(), flashOn+flashOff); got != tc.want {
+			t.Fatalf("%s: output = %q, flashed = %v, want %v", tc.name, out.String(), got, tc.want)
+		}
 	}
+}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
+func TestRunTimerWithAlarmStarter_NoTitlesTerminal(t *testing.T) {
+	t.Parallel()
 
-			gotStrip, gotIsPM, gotFound := stripAMPM(tc.token)
-			if gotFound != tc.wantFound {
-				t.Fatalf("stripAMPM(%q) found = %v, want %v", tc.token, gotFound, tc.wantFound)
-			}
-			if gotStrip != tc.wantStrip {
-				t.Fatalf("stripAMPM(%q) stripped = %q, want %q", tc.token, gotStrip, tc.wantStrip)
-			}
-			if tc.wantFound && gotIsPM != tc.wantIsPM {
-				t.Fatalf("stripAMPM(%q) isPM = %v, want %v", tc.token, gotIsPM, tc.wantIsPM)
-			}
-		})
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, true)
+	status.noTitles = true
+
+	err := runTimerWithAlarmStarter(ctx, cancel, invocation{duration: 20 * time.Millisecond}, status, false, func(alarmSound) {})
+	if err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	if strings.Contains(out.String(), "\033]0;") || strings.Contains(out.String(), pushTitle) {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want no title sequences", out.String())
+	}
+	if !strings.Contains(out.String(), "\r\033[K") {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want the line redrawn in place", out.String())
 	}
 }
 
-func TestRenderInteractiveCountdown(t *testing.T) {
+func TestPlayAlarmAttempts_GentleRampsVolume(t *testing.T) {
 	t.Parallel()
 
-	tests := []struct {
-		name             string
-		supportsAdvanced bool
-		noTitle          bool
-		want             string
-	}{
-		{
-			name:             "advanced terminal without noTitle emits OSC title sequence",
-			supportsAdvanced: true,
-			noTitle:          false,
-			want:             "\033]0;00:01:00\007\r\033[K00:01:00",
-		},
-		{
-			name:             "advanced terminal with noTitle suppresses OSC title sequence",
-			supportsAdvanced: true,
-			noTitle:          true,
-			want:             "\r\033[K00:01:00",
-		},
-		{
-			name:             "dumb terminal never emits OSC title sequence regardless of noTitle",
-			supportsAdvanced: false,
-			noTitle:          false,
-			want:             "\r00:01:00",
-		},
-		{
-			name:             "dumb terminal with noTitle still uses simple carriage return",
-			supportsAdvanced: false,
-			noTitle:          true,
-			want:             "\r00:01:00",
-		},
+	commands := []alarmCommand{
+		{name: "paplay", args: []string{"bell.wav"}},
+	}
+	var calls [][]string
+	runner := func(command alarmCommand) error {
+		calls = append(calls, command.args)
+		return nil
 	}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
+	playAlarmAttempts(commands, 4, 0, true, runner)
 
-			var buf bytes.Buffer
-			status := newStatusDisplay(&buf, true, tc.supportsAdvanced)
-			renderInteractiveCountdown(status, "00:01:00", tc.noTitle)
-			if got := buf.String(); got != tc.want {
-				t.Fatalf("renderInteractiveCountdown() = %q, want %q", got, tc.want)
-			}
-		})
+	want := [][]string{
+		{"--volume=16384", "bell.wav"},
+		{"--volume=32768", "bell.wav"},
+		{"--volume=49152", "bell.wav"},
+		{"bell.wav"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("playAlarmAttempts(gentle) args = %q, want %q", calls, want)
+	}
+	if !reflect.DeepEqual(commands[0].args, []string{"bell.wav"}) {
+		t.Fatalf("playAlarmAttempts(gentle) changed the backend to %q", commands[0].args)
 	}
 }
 
-func TestApplyAMPM(t *testing.T) {
+func TestAlarmCommandWithVolume(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name     string
-		hour     int
-		isPM     bool
-		wantHour int
-		wantOk   bool
+		command alarmCommand
+		want    []string
 	}{
-		// --- AM conversions ---
-		{name: "1am", hour: 1, isPM: false, wantHour: 1, wantOk: true},
-		{name: "11am", hour: 11, isPM: false, wantHour: 11, wantOk: true},
-		{name: "12am is midnight", hour: 12, isPM: false, wantHour: 0, wantOk: true},
+		{command: alarmCommand{name: "afplay", args: []string{"ding.aiff"}}, want: []string{"-v", "0.25", "ding.aiff"}},
+		{command: alarmCommand{name: "canberra-gtk-play", args: []string{"-i", "bell"}}, want: []string{"--volume=-12.0", "-i", "bell"}},
+		{command: alarmCommand{name: "pw-play", args: []string{"ding.wav"}}, want: []string{"--volume=0.25", "ding.wav"}},
+		{command: alarmCommand{name: "ffplay", args: []string{"-nodisp", "ding.wav"}}, want: []string{"-volume", "25", "-nodisp", "ding.wav"}},
+		{command: alarmCommand{name: "aplay", args: []string{"ding.wav"}}, want: []string{"ding.wav"}},
+		{command: alarmCommand{name: "beep"}, want: nil},
+	}
+	for _, tc := range tests {
+		if got := tc.command.withVolume(0.25).args; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s withVolume(0.25) args = %q, want %q", tc.command.name, got, tc.want)
+		}
+	}
+}
 
-		// --- PM conversions ---
-		{name: "12pm is noon", hour: 12, isPM: true, wantHour: 12, wantOk: true},
-		{name: "1pm", hour: 1, isPM: true, wantHour: 13, wantOk: true},
-		{name: "11pm", hour: 11, isPM: true, wantHour: 23, wantOk: true},
+func TestParseInvocation_AlarmBackend(t *testing.T) {
+	t.Parallel()
 
-		// --- out of range ---
-		{name: "0am is rejected", hour: 0, isPM: false, wantOk: false},
-		{name: "13pm is rejected", hour: 13, isPM: true, wantOk: false},
-		{name: "negative hour is rejected", hour: -1, isPM: false, wantOk: false},
+	names := alarmBackendNames(runtime.GOOS)
+	if len(names) < 2 {
+		t.Skipf("%s has fewer than two alarm backends", runtime.GOOS)
 	}
+	got, err := parseInvocation(cliArgs("--alarm-backend", names[1]+", "+names[0], "1s"))
+	if err != nil {
+		t.Fatalf("parseInvocation() error = %v", err)
+	}
+	want := names[1] + "," + names[0]
+	if got.alarmBackends != want {
+		t.Fatalf("alarmBackends = %q, want %q", got.alarmBackends, want)
+	}
+	if sound := got.alarmSound(); sound.backends != want || !sound.pinned {
+		t.Fatalf("alarmSound() = %+v, want backends %q pinned", sound, want)
+	}
+}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
+func TestOrderAlarmCandidates(t *testing.T) {
+	t.Parallel()
 
-			gotHour, gotOk := applyAMPM(tc.hour, tc.isPM)
-			if gotOk != tc.wantOk {
-				t.Fatalf("applyAMPM(%d, %v) ok = %v, want %v", tc.hour, tc.isPM, gotOk, tc.wantOk)
-			}
-			if gotOk && gotHour != tc.wantHour {
-				t.Fatalf("applyAMPM(%d, %v) hour = %d, want %d", tc.hour, tc.isPM, gotHour, tc.wantHour)
-			}
-		})
+	candidates := alarmCandidatesForGOOS("linux", "")
+	names := func(commands []alarmCommand) []string {
+		var out []string
+		for _, c := range commands {
+			out = append(out, alarmBackendName(c))
+		}
+		return out
+	}
+
+	got := names(orderAlarmCandidates(candidates, []string{"speaker-test", "afplay", "pcspeaker"}, false))
+	if len(got) != len(candidates) || got[0] != "speaker-test" || got[1] != "pcspeaker" || got[2] != "canberra-gtk-play" {
+		t.Errorf("reordered backends = %q, want speaker-test and pcspeaker first, then the rest", got)
+	}
+
+	got = names(orderAlarmCandidates(candidates, []string{"pcspeaker", "afplay"}, true))
+	if !reflect.DeepEqual(got, []string{"pcspeaker"}) {
+		t.Errorf("pinned backends = %q, want [pcspeaker]", got)
+	}
+
+	if got := orderAlarmCandidates(candidates, nil, false); !reflect.DeepEqual(got, candidates) {
+		t.Errorf("orderAlarmCandidates(nil) = %v, want the default order", got)
 	}
 }
 
-func TestIsAMPMToken(t *testing.T) {
+func TestBuildConfigAlarmOrder(t *testing.T) {
 	t.Parallel()
 
-	tests := []struct {
-		token string
-		want  bool
+	sections, err := parseConfigSections(strings.NewReader("[alarm]\norder = pw-play, paplay\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.alarmOrder != "pw-play,paplay" {
+		t.Fatalf("buildConfig() alarmOrder = %q, %v; want pw-play,paplay", cfg.alarmOrder, err)
+	}
+
+	for _, doc := range []string{"[alarm]\norder =\n", "[alarm]\nvolume = 3\n"} {
+		sections, err = parseConfigSections(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("parseConfigSections(%q) error = %v", doc, err)
+		}
+		var cfgErr configError
+		if _, err := buildConfig(sections); !errors.As(err, &cfgErr) || cfgErr.line != 2 {
+			t.Errorf("buildConfig(%q) error = %v, want configError on line 2", doc, err)
+		}
+	}
+}
+
+func TestCrossedMilestone(t *testing.T) {
+	t.Parallel()
+
+	milestones := []time.Duration{5 * time.Minute, time.Minute, 30 * time.Second}
+	cases := []struct {
+		prev, cur time.Duration
+		want      time.Duration
+		wantOK    bool
 	}{
-		{token: "am", want: true},
-		{token: "pm", want: true},
-		{token: "AM", want: true},
-		{token: "PM", want: true},
-		{token: "Am", want: true},
-		{token: "Pm", want: true},
-		{token: "a", want: true},
-		{token: "p", want: true},
-		{token: "A", want: true},
-		{token: "P", want: true},
-		{token: "9am", want: false},
-		{token: "9a", want: false},
-		{token: "9:00", want: false},
-		{token: "-q", want: false},
-		{token: "", want: false},
+		{prev: 5*time.Minute + 100*time.Millisecond, cur: 4*time.Minute + 59*time.Second, want: 5 * time.Minute, wantOK: true},
+		{prev: 5 * time.Minute, cur: 4 * time.Minute, wantOK: false},
+		{prev: 2 * time.Minute, cur: 90 * time.Second, wantOK: false},
+		{prev: 6 * time.Minute, cur: 20 * time.Second, want: 30 * time.Second, wantOK: true},
+		{prev: 50 * time.Second, cur: 3 * time.Minute, wantOK: false},
+	}
+	for _, tc := range cases {
+		got, ok := crossedMilestone(milestones, tc.prev, tc.cur)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("crossedMilestone(%v, %v) = %v, %v; want %v, %v", tc.prev, tc.cur, got, ok, tc.want, tc.wantOK)
+		}
 	}
+}
 
-	for _, tc := range tests {
-		t.Run(tc.token, func(t *testing.T) {
-			t.Parallel()
+func TestFormatMilestoneAnnouncement(t *testing.T) {
+	t.Parallel()
 
-			got := isAMPMToken(tc.token)
-			if got != tc.want {
-				t.Fatalf("isAMPMToken(%q) = %v, want %v", tc.token, got, tc.want)
+	cases := []struct {
+		label string
+		m     time.Duration
+		want  string
+	}{
+		{m: 5 * time.Minute, want: "5 minutes remaining"},
+		{m: time.Minute, want: "1 minute remaining"},
+		{m: 90 * time.Minute, want: "1 hour 30 minutes remaining"},
+		{label: "talk", m: 30 * time.Second, want: "talk: 30 seconds remaining"},
+	}
+	for _, tc := range cases {
+		if got := formatMilestoneAnnouncement(tc.label, tc.m); got != tc.want {
+			t.Errorf("formatMilestoneAnnouncement(%q, %v) = %q, want %q", tc.label, tc.m, got, tc.want)
+		}
+	}
+}
+
+func TestCuePlayerSkipsBusyCuesAndDropsFailures(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	played := make(chan string, 4)
+	p := &cuePlayer{
+		commands: []alarmCommand{{name: "paplay"}, {name: "aplay"}},
+		volume:   1,
+		run: func(c alarmCommand) error {
+			played <- c.name
+			<-release
+			if c.name == "paplay" {
+				return errors.New("no server")
 			}
-		})
+			return nil
+		},
+	}
+
+	p.play()
+	if got := <-played; got != "paplay" {
+		t.Fatalf("first cue played %q, want paplay", got)
+	}
+	p.play() // still playing: skipped
+	release <- struct{}{}
+
+	// Once paplay has failed, the next cue falls through to aplay.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		p.mu.Lock()
+		idle := !p.playing
+		p.mu.Unlock()
+		if idle || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	p.play()
+	if got := <-played; got != "aplay" {
+		t.Fatalf("cue after a failure played %q, want aplay", got)
+	}
+	close(release)
+	select {
+	case got := <-played:
+		t.Fatalf("skipped cue played %q later", got)
+	default:
+	}
+}
+
+func TestEveryPeriods(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{elapsed: 0, want: 0},
+		{elapsed: 9*time.Minute + 59*time.Second, want: 0},
+		{elapsed: 10 * time.Minute, want: 1},
+		{elapsed: 25 * time.Minute, want: 2},
+	}
+	for _, tc := range cases {
+		if got := everyPeriods(tc.elapsed, 10*time.Minute); got != tc.want {
+			t.Errorf("everyPeriods(%v, 10m) = %d, want %d", tc.elapsed, got, tc.want)
+		}
+	}
+	if got := everyNotificationBody("focus", 20*time.Minute, 10*time.Minute); got != "focus: 20 minutes elapsed, 10 minutes left" {
+		t.Errorf("everyNotificationBody() = %q", got)
+	}
+}
+
+func TestReachedHalfway(t *testing.T) {
+	t.Parallel()
+
+	if reachedHalfway(14*time.Minute+59*time.Second, 30*time.Minute) {
+		t.Error("reachedHalfway(14:59 of 30m) = true, want false")
+	}
+	if !reachedHalfway(15*time.Minute, 30*time.Minute) {
+		t.Error("reachedHalfway(15:00 of 30m) = false, want true")
+	}
+	if got := halfwayNotificationBody("talk", 15*time.Minute); got != "talk: halfway, 15 minutes left" {
+		t.Errorf("halfwayNotificationBody() = %q", got)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	t.Parallel()
+
+	at := func(h, m int) time.Time { return time.Date(2025, 3, 1, h, m, 0, 0, time.Local) }
+	overnight, err := parseQuietHours("22:00-07:00")
+	if err != nil {
+		t.Fatalf("parseQuietHours() error = %v", err)
+	}
+	for _, tc := range []struct {
+		h, m int
+		want bool
+	}{{21, 59, false}, {22, 0, true}, {3, 0, true}, {6, 59, true}, {7, 0, false}, {12, 0, false}} {
+		if got := overnight.contains(at(tc.h, tc.m)); got != tc.want {
+			t.Errorf("22:00-07:00 contains %02d:%02d = %v, want %v", tc.h, tc.m, got, tc.want)
+		}
+	}
+
+	afternoon, err := parseQuietHours("1pm – 3:30pm")
+	if err != nil {
+		t.Fatalf("parseQuietHours(12-hour) error = %v", err)
+	}
+	if afternoon.String() != "13:00-15:30" || !afternoon.contains(at(15, 29)) || afternoon.contains(at(15, 30)) {
+		t.Errorf("parseQuietHours(1pm – 3:30pm) = %v", afternoon)
+	}
+
+	for _, bad := range []string{"22:00", "22:00-22:00", "late-early", "25:00-07:00"} {
+		if _, err := parseQuietHours(bad); err == nil {
+			t.Errorf("parseQuietHours(%q) error = nil, want an error", bad)
+		}
+	}
+	if (quietHours{}).contains(at(3, 0)) {
+		t.Error("the zero quietHours contains 03:00")
+	}
+}
+
+func TestSystemSilence(t *testing.T) {
+	t.Parallel()
+
+	outputs := map[string]string{
+		"pactl":     "Mute: no",
+		"gsettings": "false",
+	}
+	var ran []string
+	output := func(ctx context.Context, name string, args ...string) string {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatalf("%s ran without a deadline", name)
+		}
+		ran = append(ran, name)
+		return outputs[name]
+	}
+	if got := systemSilence(silenceChecksForGOOS("linux"), output); got != "do not disturb" {
+		t.Fatalf("systemSilence() = %q, want do not disturb", got)
+	}
+	if want := []string{"pactl", "pamixer", "gsettings"}; !slices.Equal(ran, want) {
+		t.Fatalf("systemSilence() ran %q, want %q", ran, want)
+	}
+
+	outputs = map[string]string{"osascript": "true"}
+	if got := systemSilence(silenceChecksForGOOS("darwin"), output); got != "output muted" {
+		t.Fatalf("systemSilence(darwin) = %q, want output muted", got)
+	}
+	outputs = map[string]string{}
+	if got := systemSilence(silenceChecksForGOOS("linux"), output); got != "" {
+		t.Fatalf("systemSilence(no answers) = %q, want none", got)
+	}
+	if got := systemSilence(silenceChecksForGOOS("freebsd"), output); got != "" {
+		t.Fatalf("systemSilence(freebsd) = %q, want none", got)
+	}
+}
+
+func TestBuildConfigQuietHours(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[alarm]\nquiet-hours = 22:00-07:00\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.quietHours != (quietHours{start: 22 * 60, end: 7 * 60, set: true}) {
+		t.Fatalf("buildConfig() quietHours = %v, %v; want 22:00-07:00", cfg.quietHours, err)
+	}
+}
+
+func TestBuildConfigPushover(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[pushover]\ntoken = app\nuser = me\npriority = high\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.pushover != (pushoverConfig{token: "app", user: "me", priority: 1}) {
+		t.Fatalf("buildConfig() pushover = %+v, %v; want app, me, high", cfg.pushover, err)
+	}
+
+	env := map[string]string{pushoverUserEnvVar: "you"}
+	if got := resolvePushover(cfg.pushover, func(k string) string { return env[k] }); got.token != "app" || got.user != "you" {
+		t.Fatalf("resolvePushover() = %+v, want the user key from the environment", got)
+	}
+
+	sections, _ = parseConfigSections(strings.NewReader("[pushover]\npriority = urgent\n"))
+	if _, err := buildConfig(sections); err == nil || !strings.Contains(err.Error(), "priority") {
+		t.Fatalf("buildConfig(priority = urgent) error = %v, want a priority error", err)
+	}
+}
+
+func TestPushoverForm(t *testing.T) {
+	t.Parallel()
+
+	p := pushoverConfig{token: "app", user: "me"}
+	n := desktopNotification{title: "after: tea", subtitle: "4 minutes, ended 14:05", text: "timer complete"}
+	form := pushoverForm(p, n, false)
+	if form.Get("title") != "after: tea" || form.Get("message") != "timer complete (4 minutes, ended 14:05)" {
+		t.Fatalf("pushoverForm() = %v, want the label and length", form)
+	}
+	if form.Has("priority") || form.Has("retry") {
+		t.Fatalf("pushoverForm(normal) = %v, want no priority", form)
+	}
+	form = pushoverForm(p, n, true)
+	if form.Get("priority") != "2" || form.Get("retry") != "60" || form.Get("expire") != "3600" {
+		t.Fatalf("pushoverForm(emergency) = %v, want priority 2 with retry and expire", form)
+	}
+}
+
+func TestSendPushover(t *testing.T) {
+	t.Parallel()
+
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.PostForm
+		if r.PostForm.Get("user") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"user":"invalid","errors":["user identifier is invalid"],"status":0}`)
+			return
+		}
+		io.WriteString(w, `{"status":1,"request":"647d2300-702c-4b38-8b2f-d56326ae460b"}`)
+	}))
+	defer server.Close()
+
+	if err := sendPushover(server.Client(), server.URL, url.Values{"token": {"app"}, "user": {"me"}, "message": {"timer complete"}}); err != nil {
+		t.Fatalf("sendPushover() error = %v", err)
+	}
+	if got.Get("message") != "timer complete" {
+		t.Fatalf("pushed form = %v", got)
+	}
+	err := sendPushover(server.Client(), server.URL, url.Values{"token": {"app"}})
+	if err == nil || !strings.Contains(err.Error(), "user identifier is invalid") {
+		t.Fatalf("sendPushover(no user) error = %v, want Pushover's reason", err)
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2025, 3, 1, 14, 1, 0, 0, time.Local)
+	event := newRemoteEvent(timerEvent{outcome: outcomeComplete, requested: 4 * time.Minute, started: started, ended: started.Add(4*time.Minute + 30*time.Second)}, "tea", 0, "kettle")
+	got, err := renderMessage("", event)
+	if err != nil || got != "after: tea: timer complete (4 minutes, ended 14:05)" {
+		t.Fatalf("renderMessage(default) = %q, %v", got, err)
+	}
+	got, err = renderMessage("{{.label}} {{.outcome}} on {{.host}} after {{.elapsed}}", event)
+	if err != nil || got != "tea complete on kettle after 4 minutes 30 seconds" {
+		t.Fatalf("renderMessage(custom) = %q, %v", got, err)
+	}
+	if _, err := renderMessage("{{.labl}}", event); err == nil {
+		t.Fatal("renderMessage(unknown field) error = nil")
+	}
+}
+
+func TestBuildConfigTelegram(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[telegram]\ntoken = 123:abc\nchat = -100\ntemplate = \"{{.label}} done\"\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.telegram != (telegramConfig{token: "123:abc", chat: "-100", template: "{{.label}} done"}) {
+		t.Fatalf("buildConfig() telegram = %+v, %v", cfg.telegram, err)
+	}
+
+	sections, _ = parseConfigSections(strings.NewReader("[telegram]\ntemplate = {{.labl}}\n"))
+	if _, err := buildConfig(sections); err == nil || !strings.Contains(err.Error(), "labl") {
+		t.Fatalf("buildConfig(unknown field) error = %v, want it named", err)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	t.Parallel()
+
+	var path string
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["chat_id"] != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		io.WriteString(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+
+	event := newRemoteEvent(timerEvent{outcome: outcomeComplete, requested: time.Minute}, "tea", 0, "kettle")
+	c := telegramConfig{token: "123:abc", chat: "42", template: "{{.label}} is done"}
+	if err := sendTelegram(server.Client(), server.URL, c, event); err != nil {
+		t.Fatalf("sendTelegram() error = %v", err)
+	}
+	if path != "/bot123:abc/sendMessage" || got["text"] != "tea is done" {
+		t.Fatalf("sendTelegram() posted %v to %s", got, path)
+	}
+	c.chat = "7"
+	err := sendTelegram(server.Client(), server.URL, c, event)
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("sendTelegram(unknown chat) error = %v, want Telegram's reason", err)
+	}
+}
+
+func TestSendSlack(t *testing.T) {
+	t.Parallel()
+
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		if got["text"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "no_text")
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	started := time.Date(2025, 3, 1, 14, 1, 0, 0, time.Local)
+	event := newRemoteEvent(timerEvent{outcome: outcomeComplete, requested: 4 * time.Minute, started: started, ended: started.Add(4 * time.Minute)}, "bake", 0, "ci-7")
+	if err := sendSlack(server.Client(), slackConfig{webhook: server.URL}, event); err != nil {
+		t.Fatalf("sendSlack() error = %v", err)
+	}
+	if want := "*after: bake*: timer complete (4 minutes, ended 14:05) on ci-7"; got["text"] != want {
+		t.Fatalf("sendSlack() text = %q, want %q", got["text"], want)
+	}
+	err := sendSlack(server.Client(), slackConfig{webhook: server.URL, template: "{{if .host}}{{end}}"}, event)
+	if err == nil || !strings.Contains(err.Error(), "no_text") {
+		t.Fatalf("sendSlack(empty text) error = %v, want Slack's reason", err)
+	}
+
+	sections, _ := parseConfigSections(strings.NewReader("[slack]\nwebhook = hooks.slack.com/services/x\n"))
+	if _, err := buildConfig(sections); err == nil || !strings.Contains(err.Error(), "expected a URL") {
+		t.Fatalf("buildConfig(webhook without scheme) error = %v", err)
+	}
+}
+
+func TestSendDiscord(t *testing.T) {
+	t.Parallel()
+
+	var got map[string][]discordEmbed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		if len(got["embeds"]) == 0 || got["embeds"][0].Description == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"message": "Cannot send an empty message", "code": 50006}`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	started := time.Date(2025, 3, 1, 14, 1, 0, 0, time.UTC)
+	event := newRemoteEvent(timerEvent{outcome: outcomeCancelled, requested: 10 * time.Minute, started: started, ended: started.Add(4 * time.Minute)}, "bake", 6*time.Minute, "ci-7")
+	if err := sendDiscord(server.Client(), discordConfig{webhook: server.URL}, event); err != nil {
+		t.Fatalf("sendDiscord() error = %v", err)
+	}
+	want := discordEmbed{
+		Title:       "after: bake",
+		Description: "timer cancelled (6 minutes of 10 minutes left)",
+		Color:       discordColorCancelled,
+		Fields:      []discordEmbedField{{Name: "Length", Value: "10 minutes", Inline: true}, {Name: "Left", Value: "6 minutes", Inline: true}, {Name: "Host", Value: "ci-7", Inline: true}},
+		Timestamp:   "2025-03-01T14:05:00Z",
+	}
+	if len(got["embeds"]) != 1 || !reflect.DeepEqual(got["embeds"][0], want) {
+		t.Fatalf("sendDiscord() embeds = %+v, want %+v", got["embeds"], want)
+	}
+	err := sendDiscord(server.Client(), discordConfig{webhook: server.URL, template: "{{if .host}}{{end}}"}, event)
+	if err == nil || !strings.Contains(err.Error(), "Cannot send an empty message") {
+		t.Fatalf("sendDiscord(empty description) error = %v, want Discord's reason", err)
+	}
+}
+
+func TestRemoteNotifiersOnCancel(t *testing.T) {
+	t.Parallel()
+
+	inv := invocation{pushover: pushoverConfig{token: "app", user: "me"}, discord: discordConfig{webhook: "https://discord.test/x"}}
+	names := func(notifiers []remoteNotifier) []string {
+		var got []string
+		for _, n := range notifiers {
+			got = append(got, n.name)
+		}
+		return got
+	}
+	if got := names(remoteNotifiers(inv, outcomeComplete)); !slices.Equal(got, []string{"pushover", "discord"}) {
+		t.Fatalf("remoteNotifiers(complete) = %v", got)
+	}
+	if got := names(remoteNotifiers(inv, outcomeCancelled)); !slices.Equal(got, []string{"discord"}) {
+		t.Fatalf("remoteNotifiers(cancelled) = %v, want only discord", got)
+	}
+	inv.discord.noCancel = true
+	if got := names(remoteNotifiers(inv, outcomeCancelled)); len(got) != 0 {
+		t.Fatalf("remoteNotifiers(cancelled, cancel = off) = %v, want none", got)
+	}
+	inv.remote = "discord,slack"
+	if got := names(remoteNotifiers(inv, outcomeComplete)); !slices.Equal(got, []string{"discord"}) {
+		t.Fatalf("remoteNotifiers(remote = discord, slack) = %v, want only discord", got)
+	}
+	inv.remote = "none"
+	if got := names(remoteNotifiers(inv, outcomeComplete)); len(got) != 0 {
+		t.Fatalf("remoteNotifiers(remote = none) = %v, want none", got)
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// A mail server that takes one message and hands over its commands
+	// and data.
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var session strings.Builder
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "220 mail.test ESMTP\r\n")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			session.WriteString(line)
+			switch {
+			case inData:
+				if line == ".\r\n" {
+					inData = false
+					io.WriteString(conn, "250 queued\r\n")
+				}
+			case strings.HasPrefix(line, "EHLO"):
+				io.WriteString(conn, "250-mail.test\r\n250 8BITMIME\r\n")
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				io.WriteString(conn, "354 go ahead\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				io.WriteString(conn, "221 bye\r\n")
+				received <- session.String()
+				return
+			default:
+				io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	started := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	event := newRemoteEvent(timerEvent{outcome: outcomeComplete, requested: 12 * time.Hour, started: started, ended: started.Add(12 * time.Hour)}, "reindex", 0, "db-3")
+	c := emailConfig{server: ln.Addr().String(), from: "after@example.com", to: "ops@example.com, me@example.com", tls: emailTLSStart}
+	if err := sendEmail(c, event, 5*time.Second); err != nil {
+		t.Fatalf("sendEmail() error = %v", err)
+	}
+	session := <-received
+	for _, want := range []string{
+		"MAIL FROM:<after@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<me@example.com>",
+		"To: ops@example.com, me@example.com\r\n",
+		"Subject: after: reindex: timer complete\r\n",
+		"\r\n\r\nafter: reindex: timer complete (12 hours, ended 14:00)\r\n",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("mail session does not contain %q:\n%s", want, session)
+		}
+	}
+
+	sections, _ := parseConfigSections(strings.NewReader("[email]\nserver = smtp.example.com\ntls = ssl\n"))
+	if _, err := buildConfig(sections); err == nil || !strings.Contains(err.Error(), "tls") {
+		t.Fatalf("buildConfig(tls = ssl) error = %v", err)
+	}
+	if got := (emailConfig{server: "smtp.example.com", tls: emailTLSOn}).serverAddr(); got != "smtp.example.com:465" {
+		t.Fatalf("serverAddr(tls on) = %q", got)
+	}
+}
+
+func TestSendRemoteNotifications(t *testing.T) {
+	t.Parallel()
+
+	var sent atomic.Int32
+	ok := remoteNotifier{name: "ok", send: func(*http.Client, remoteEvent) error { sent.Add(1); return nil }}
+	failing := remoteNotifier{name: "failing", send: func(*http.Client, remoteEvent) error { sent.Add(1); return errors.New("failing: down") }}
+	errs := sendRemoteNotifications([]remoteNotifier{ok, failing, ok}, remoteEvent{})
+	if sent.Load() != 3 || len(errs) != 1 || errs[0].Error() != "failing: down" {
+		t.Fatalf("sendRemoteNotifications() sent %d, errors %v; want all 3 sent and the one failure", sent.Load(), errs)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_QuietHoursFlashInstead(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	status := statusDisplay{writer: &buf, interactive: true, supportsAdvanced: true}
+	// A window from a minute ago to two minutes from now.
+	now := time.Now()
+	m := now.Hour()*60 + now.Minute()
+	inv := invocation{mode: modeRun, duration: 10 * time.Millisecond, noTitle: true, quietHours: quietHours{start: (m + 24*60 - 1) % (24 * 60), end: (m + 2) % (24 * 60), set: true}}
+	alarmed := false
+	if err := runTimerWithAlarmStarter(context.Background(), nil, inv, status, true, func(alarmSound) { alarmed = true }); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v", err)
+	}
+	if alarmed {
+		t.Fatal("alarm played during quiet hours")
+	}
+	if !strings.Contains(buf.String(), flashOn) {
+		t.Fatalf("output = %q, want a flash instead of the alarm", buf.String())
+	}
+}
+
+func TestFinalBeepMilestones(t *testing.T) {
+	t.Parallel()
+
+	want := []time.Duration{3 * time.Second, 2 * time.Second, time.Second}
+	if got := finalBeepMilestones(3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("finalBeepMilestones(3) = %v, want %v", got, want)
+	}
+	// A check that lands just after 2s left beeps for 2, and not again
+	// until 1s is passed.
+	if m, ok := crossedMilestone(want, 2010*time.Millisecond, 1990*time.Millisecond); !ok || m != 2*time.Second {
+		t.Fatalf("crossedMilestone(2.01s, 1.99s) = %v, %v; want 2s", m, ok)
+	}
+	if _, ok := crossedMilestone(want, 1990*time.Millisecond, 1500*time.Millisecond); ok {
+		t.Fatal("crossedMilestone(1.99s, 1.5s) beeped between seconds")
+	}
+}
+
+func TestWriteBuiltinSound(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "sounds")
+	for _, name := range soundNames() {
+		path, err := writeBuiltinSound(dir, name)
+		if err != nil {
+			t.Fatalf("writeBuiltinSound(%s) error = %v", name, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) < 44 || string(data[:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+			t.Fatalf("writeBuiltinSound(%s) wrote %d bytes without a WAV header", name, len(data))
+		}
+		if got, want := binary.LittleEndian.Uint32(data[40:44]), uint32(len(data)-44); got != want {
+			t.Fatalf("writeBuiltinSound(%s) data size = %d, want %d", name, got, want)
+		}
+		again, err := writeBuiltinSound(dir, name)
+		if err != nil || again != path {
+			t.Fatalf("writeBuiltinSound(%s) again = %q, %v; want %q", name, again, err, path)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != len(builtinSounds) {
+		t.Fatalf("sound cache holds %d files, want %d", len(entries), len(builtinSounds))
+	}
+	if _, err := writeBuiltinSound(dir, "gong"); err == nil {
+		t.Fatal("writeBuiltinSound(gong) error = nil, want unknown sound")
+	}
+	if got := resolveSoundName("/tmp/ding.wav"); got != "/tmp/ding.wav" {
+		t.Fatalf("resolveSoundName(file) = %q, want it unchanged", got)
+	}
+}
+
+func TestPickSoundFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"bell.wav", "gong.MP3", ".hidden.wav", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "more.wav"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	for i := range 2 {
+		got, err := pickSoundFile(dir, func(n int) int {
+			if n != 2 {
+				t.Fatalf("pick(%d), want a choice of 2 sound files", n)
+			}
+			return i
+		})
+		if err != nil {
+			t.Fatalf("pickSoundFile() error = %v", err)
+		}
+		seen = append(seen, filepath.Base(got))
+	}
+	if want := []string{"bell.wav", "gong.MP3"}; !slices.Equal(seen, want) {
+		t.Fatalf("pickSoundFile() picked %q, want %q", seen, want)
+	}
+	if _, err := pickSoundFile(filepath.Join(dir, "more.wav"), rand.IntN); err == nil {
+		t.Fatal("pickSoundFile(empty) error = nil, want no sound files")
+	}
+	if got := resolveSoundName(soundDirPrefix + dir); filepath.Dir(got) != dir {
+		t.Fatalf("resolveSoundName(dir) = %q, want a file from %s", got, dir)
+	}
+
+	var w strings.Builder
+	inv := resolveRunSoundFile(invocation{soundFile: soundDirPrefix + filepath.Join(dir, "more.wav")}, &w)
+	if inv.soundFile != "" || !strings.Contains(w.String(), "no playable sound files") {
+		t.Fatalf("resolveRunSoundFile(empty dir) = %q, warning %q; want the default alarm", inv.soundFile, w.String())
+	}
+}
+
+func TestPlayAlarmUntil_RingsUntilStopped(t *testing.T) {
+	t.Parallel()
+
+	commands := []alarmCommand{{name: "broken-backend"}, {name: "working-backend"}}
+	stop := make(chan struct{})
+	var calls []string
+	runner := func(command alarmCommand) error {
+		calls = append(calls, command.name)
+		if command.name == "broken-backend" {
+			return errors.New("boom")
+		}
+		if len(calls) == 4 {
+			close(stop)
+		}
+		return nil
+	}
+
+	playAlarmUntil(commands, 20*time.Millisecond, false, stop, runner)
+
+	want := []string{"broken-backend", "working-backend", "working-backend", "working-backend"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("playAlarmUntil() calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRunAlarmCommandTimesOut(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not installed")
+	}
+	start := time.Now()
+	err := runAlarmCommandContext(context.Background(), alarmCommand{name: "sleep", args: []string{"30"}}, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "no sound after") {
+		t.Fatalf("runAlarmCommandContext(hung backend) error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("runAlarmCommandContext() returned after %v, want soon after the timeout", elapsed)
+	}
+}
+
+func TestAlarmScriptPlaysAndFailsOver(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	log := filepath.Join(t.TempDir(), "plays")
+	commands := []alarmCommand{
+		{name: "sh", args: []string{"-c", "echo broken >> \"$0\"; exit 1", log}},
+		{name: builtinPCSpeakerBackend, args: []string{"1200", "150"}},
+		{name: "sh", args: []string{"-c", "echo working >> \"$0\"", log}},
+	}
+	cmd := exec.Command("sh", "-c", alarmScript(commands, 3, false))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("alarm script error = %v, output %q", err, out)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The broken backend is tried once; built-in ones are left out.
+	if got, want := string(data), "broken\nworking\nworking\nworking\n"; got != want {
+		t.Fatalf("alarm script plays = %q, want %q", got, want)
+	}
+}
+
+func TestAlarmScriptStopsWhenNothingPlays(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+	log := filepath.Join(t.TempDir(), "plays")
+	commands := []alarmCommand{{name: "sh", args: []string{"-c", "echo broken >> \"$0\"; exit 1", log}}}
+	if out, err := exec.Command("sh", "-c", alarmScript(commands, 4, false)).CombinedOutput(); err != nil {
+		t.Fatalf("alarm script error = %v, output %q", err, out)
+	}
+	if data, _ := os.ReadFile(log); string(data) != "broken\n" {
+		t.Fatalf("alarm script plays = %q, want a single failed try", data)
+	}
+}
+
+func TestStopAlarmsKillsAlarmScripts(t *testing.T) {
+	t.Parallel()
+
+	a := newRunningAlarms()
+	// The player runs as a child of the script, as alarmScript runs it.
+	if err := a.startScript("sleep 30 & wait"); err != nil {
+		t.Fatalf("startScript() error = %v", err)
+	}
+	if got := a.running(); got != 1 {
+		t.Fatalf("running() = %d, want 1", got)
+	}
+	a.stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.running() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("alarm script still running after stop()")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := a.startScript("exit 0"); err == nil {
+		t.Fatal("startScript() after stop() error = nil, want the alarm refused")
+	}
+}
+
+func TestStartAckAlarmStopsPromptly(t *testing.T) {
+	t.Parallel()
+
+	stop := startAckAlarm(alarmSound{backends: "none", pinned: true})
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop() did not return")
+	}
+}
+
+func TestWaitForAck(t *testing.T) {
+	t.Parallel()
+
+	keyC := make(chan struct{}, 1)
+	pressC := make(chan struct{}, 1)
+	// A key pressed during the countdown does not acknowledge the alarm.
+	pressC <- struct{}{}
+	start := time.Now()
+	if got := waitForAck(context.Background(), keyC, pressC, nil, nil, nil, 30*time.Millisecond); got != ackTimedOut {
+		t.Fatalf("waitForAck() = %v, want timed out when nobody answers", got)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("waitForAck() returned after %v, want the stale key press ignored", elapsed)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pressC <- struct{}{}
+	}()
+	start = time.Now()
+	if got := waitForAck(context.Background(), keyC, pressC, nil, nil, nil, time.Minute); got != ackAnswered {
+		t.Fatalf("waitForAck() = %v, want answered on the key press", got)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("waitForAck() returned after %v, want on the key press", elapsed)
+	}
+}
+
+func TestWaitForAckOverNotification(t *testing.T) {
+	t.Parallel()
+
+	actionC := make(chan string, 2)
+	actionC <- "default"
+	actionC <- alarmActionSnooze
+	if got := waitForAck(context.Background(), nil, nil, nil, actionC, nil, time.Minute); got != ackSnoozed {
+		t.Fatalf("waitForAck() = %v, want snoozed on the Snooze button", got)
+	}
+	actionC <- alarmActionDismiss
+	if got := waitForAck(context.Background(), nil, nil, nil, actionC, nil, time.Minute); got != ackAnswered {
+		t.Fatalf("waitForAck() = %v, want answered on the Dismiss button", got)
+	}
+	// A closed notification leaves the timeout to end the wait.
+	close(actionC)
+	if got := waitForAck(context.Background(), nil, nil, nil, actionC, nil, 30*time.Millisecond); got != ackTimedOut {
+		t.Fatalf("waitForAck() = %v, want timed out once the notification is gone", got)
+	}
+}
+
+func TestWaitForAckOverControl(t *testing.T) {
+	t.Parallel()
+
+	controlC := make(chan controlCall)
+	answer := func(req controlRequest) controlResponse {
+		return controlResponse{OK: true, State: &controlState{State: timerStateAlerting}}
+	}
+	result := make(chan ackResult)
+	go func() { result <- waitForAck(context.Background(), nil, nil, controlC, nil, answer, time.Minute) }()
+
+	status := controlCall{req: controlRequest{Command: controlCommandStatus}, reply: make(chan controlResponse, 1)}
+	controlC <- status
+	if resp := <-status.reply; resp.State == nil || resp.State.State != timerStateAlerting {
+		t.Fatalf("status while waiting = %+v, want alerting", resp)
+	}
+	ack := controlCall{req: controlRequest{Command: controlCommandAck}, reply: make(chan controlResponse, 1)}
+	controlC <- ack
+	if resp := <-ack.reply; !resp.OK {
+		t.Fatalf("ack reply = %+v, want ok", resp)
+	}
+	if got := <-result; got != ackAnswered {
+		t.Fatalf("waitForAck() = %v, want answered after an ack", got)
+	}
+}
+
+func TestAlertingTimerID(t *testing.T) {
+	t.Parallel()
+
+	timers := []controlState{{ID: 10, State: timerStateRunning}, {ID: 11, State: timerStateAlerting}}
+	if id, err := alertingTimerID(timers, 0); err != nil || id != 11 {
+		t.Fatalf("alertingTimerID() = %d, %v; want 11", id, err)
+	}
+	if id, err := alertingTimerID(timers, 10); err != nil || id != 10 {
+		t.Fatalf("alertingTimerID(10) = %d, %v; want 10", id, err)
+	}
+	if _, err := alertingTimerID(timers[:1], 0); err == nil {
+		t.Fatal("alertingTimerID(none alerting) error = nil, want an error")
+	}
+	if _, err := alertingTimerID(append(timers, controlState{ID: 12, State: timerStateAlerting}), 0); err == nil {
+		t.Fatal("alertingTimerID(two alerting) error = nil, want an error")
+	}
+}
+
+func TestRunTimerWithControl_EscalateStaysSilentUntilDelay(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	controlC := make(chan controlCall)
+	inv := invocation{mode: modeRun, duration: 10 * time.Millisecond, noTitle: true, escalate: true, forceAlarm: true}
+	alarmed := make(chan struct{}, 1)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- runTimerWithControl(ctx, cancel, inv, statusDisplay{writer: io.Discard}, false, func(alarmSound) { alarmed <- struct{}{} }, controlC, nil)
+	}()
+
+	// Once finished, the timer reports itself alerting and plays nothing.
+	var state *controlState
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		call := controlCall{req: controlRequest{Command: controlCommandStatus}, reply: make(chan controlResponse, 1)}
+		controlC <- call
+		if resp := <-call.reply; resp.State != nil && resp.State.State == timerStateAlerting {
+			state = resp.State
+			break
+		}
+	}
+	if state == nil {
+		t.Fatal("timer never reported alerting")
+	}
+	call := controlCall{req: controlRequest{Command: controlCommandAck}, reply: make(chan controlResponse, 1)}
+	controlC <- call
+	<-call.reply
+	if err := <-errC; err != nil {
+		t.Fatalf("runTimerWithControl() error = %v", err)
+	}
+	select {
+	case <-alarmed:
+		t.Fatal("--escalate played the alarm at completion")
+	default:
+	}
+}
+
+func TestPlayAlarmAttempts_RemovesFailingBackendsAndFallsBack(t *testing.T) {
+	t.Parallel()
+
+	commands := []alarmCommand{
+		{name: "broken-backend"},
+		{name: "working-backend"},
+	}
+	var calls []string
+
+	runner := func(command alarmCommand) error {
+		calls = append(calls, command.name)
+		if command.name == "broken-backend" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	playAlarmAttempts(commands, 4, 0, false, runner)
+
+	wantCalls := []string{
+		"broken-backend",
+		"working-backend",
+		"working-backend",
+		"working-backend",
+		"working-backend",
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("playAlarmAttempts() calls = %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestStripAMPM(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		token     string
+		wantStrip string
+		wantIsPM  bool
+		wantFound bool
+	}{
+		// --- no suffix ---
+		{name: "no suffix returns token unchanged", token: "9:00", wantStrip: "9:00", wantIsPM: false, wantFound: false},
+		{name: "bare integer no suffix falls through", token: "9", wantStrip: "9", wantIsPM: false, wantFound: false},
+
+		// --- attached AM ---
+		{name: "lowercase am attached", token: "9am", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "uppercase AM attached", token: "9AM", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "mixed case Am attached", token: "9Am", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "HH:MM with attached am", token: "9:00am", wantStrip: "9:00", wantIsPM: false, wantFound: true},
+		{name: "HH:MM:SS with attached am", token: "9:00:00am", wantStrip: "9:00:00", wantIsPM: false, wantFound: true},
+
+		// --- attached PM ---
+		{name: "lowercase pm attached", token: "1pm", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "uppercase PM attached", token: "1PM", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "HH:MM with attached pm", token: "1:30pm", wantStrip: "1:30", wantIsPM: true, wantFound: true},
+		{name: "HH:MM:SS with attached pm", token: "1:30:00pm", wantStrip: "1:30:00", wantIsPM: true, wantFound: true},
+
+		// --- space-separated AM ---
+		{name: "space separated am", token: "9 am", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "space separated AM uppercase", token: "9 AM", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "HH:MM with space separated am", token: "9:00 am", wantStrip: "9:00", wantIsPM: false, wantFound: true},
+
+		// --- space-separated PM ---
+		{name: "space separated pm", token: "1 pm", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "space separated PM uppercase", token: "1 PM", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "HH:MM with space separated pm", token: "1:30 pm", wantStrip: "1:30", wantIsPM: true, wantFound: true},
+		{name: "HH:MM:SS with space separated pm", token: "1:30:00 pm", wantStrip: "1:30:00", wantIsPM: true, wantFound: true},
+
+		// --- shorthand: attached a/p ---
+		{name: "lowercase a shorthand attached", token: "9a", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "uppercase A shorthand attached", token: "9A", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "lowercase p shorthand attached", token: "1p", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "uppercase P shorthand attached", token: "1P", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "HH:MM with attached a", token: "9:00a", wantStrip: "9:00", wantIsPM: false, wantFound: true},
+		{name: "HH:MM with attached p", token: "3:30p", wantStrip: "3:30", wantIsPM: true, wantFound: true},
+
+		// --- shorthand: space-separated a/p ---
+		{name: "space separated a shorthand", token: "9 a", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "space separated A uppercase", token: "9 A", wantStrip: "9", wantIsPM: false, wantFound: true},
+		{name: "space separated p shorthand", token: "1 p", wantStrip: "1", wantIsPM: true, wantFound: true},
+		{name: "space separated P uppercase", token: "1 P", wantStrip: "1", wantIsPM: true, wantFound: true},
+
+		// --- am/p suffix does not match only 'a' inside "am" ---
+		{name: "9am still strips am not just a", token: "9am", wantStrip: "9", wantIsPM: false, wantFound: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotStrip, gotIsPM, gotFound := stripAMPM(tc.token)
+			if gotFound != tc.wantFound {
+				t.Fatalf("stripAMPM(%q) found = %v, want %v", tc.token, gotFound, tc.wantFound)
+			}
+			if gotStrip != tc.wantStrip {
+				t.Fatalf("stripAMPM(%q) stripped = %q, want %q", tc.token, gotStrip, tc.wantStrip)
+			}
+			if tc.wantFound && gotIsPM != tc.wantIsPM {
+				t.Fatalf("stripAMPM(%q) isPM = %v, want %v", tc.token, gotIsPM, tc.wantIsPM)
+			}
+		})
+	}
+}
+
+func TestRenderInteractiveCountdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		supportsAdvanced bool
+		noTitle          bool
+		want             string
+	}{
+		{
+			name:             "advanced terminal without noTitle emits OSC title sequence",
+			supportsAdvanced: true,
+			noTitle:          false,
+			want:             "\033]0;00:01:00\007\r\033[K00:01:00",
+		},
+		{
+			name:             "advanced terminal with noTitle suppresses OSC title sequence",
+			supportsAdvanced: true,
+			noTitle:          true,
+			want:             "\r\033[K00:01:00",
+		},
+		{
+			name:             "dumb terminal never emits OSC title sequence regardless of noTitle",
+			supportsAdvanced: false,
+			noTitle:          false,
+			want:             "\r00:01:00",
+		},
+		{
+			name:             "dumb terminal with noTitle still uses simple carriage return",
+			supportsAdvanced: false,
+			noTitle:          true,
+			want:             "\r00:01:00",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			status := newStatusDisplay(&buf, true, tc.supportsAdvanced)
+			renderInteractiveCountdown(status, "00:01:00", tc.noTitle)
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("renderInteractiveCountdown() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyAMPM(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		hour     int
+		isPM     bool
+		wantHour int
+		wantOk   bool
+	}{
+		// --- AM conversions ---
+		{name: "1am", hour: 1, isPM: false, wantHour: 1, wantOk: true},
+		{name: "11am", hour: 11, isPM: false, wantHour: 11, wantOk: true},
+		{name: "12am is midnight", hour: 12, isPM: false, wantHour: 0, wantOk: true},
+
+		// --- PM conversions ---
+		{name: "12pm is noon", hour: 12, isPM: true, wantHour: 12, wantOk: true},
+		{name: "1pm", hour: 1, isPM: true, wantHour: 13, wantOk: true},
+		{name: "11pm", hour: 11, isPM: true, wantHour: 23, wantOk: true},
+
+		// --- out of range ---
+		{name: "0am is rejected", hour: 0, isPM: false, wantOk: false},
+		{name: "13pm is rejected", hour: 13, isPM: true, wantOk: false},
+		{name: "negative hour is rejected", hour: -1, isPM: false, wantOk: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotHour, gotOk := applyAMPM(tc.hour, tc.isPM)
+			if gotOk != tc.wantOk {
+				t.Fatalf("applyAMPM(%d, %v) ok = %v, want %v", tc.hour, tc.isPM, gotOk, tc.wantOk)
+			}
+			if gotOk && gotHour != tc.wantHour {
+				t.Fatalf("applyAMPM(%d, %v) hour = %d, want %d", tc.hour, tc.isPM, gotHour, tc.wantHour)
+			}
+		})
+	}
+}
+
+func TestIsAMPMToken(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{token: "am", want: true},
+		{token: "pm", want: true},
+		{token: "AM", want: true},
+		{token: "PM", want: true},
+		{token: "Am", want: true},
+		{token: "Pm", want: true},
+		{token: "a", want: true},
+		{token: "p", want: true},
+		{token: "A", want: true},
+		{token: "P", want: true},
+		{token: "9am", want: false},
+		{token: "9a", want: false},
+		{token: "9:00", want: false},
+		{token: "-q", want: false},
+		{token: "", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.token, func(t *testing.T) {
+			t.Parallel()
+
+			got := isAMPMToken(tc.token)
+			if got != tc.want {
+				t.Fatalf("isAMPMToken(%q) = %v, want %v", tc.token, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsClockToken(t *testing.T) {
+	t.Parallel()
+
+	for token, want := range map[string]bool{
+		"9":     true,
+		"09:30": true,
+		"21:00": true,
+		"1s":    false,
+		"30m":   false,
+		"9:":    false,
+		":30":   false,
+		"9:3:0": false,
+		"":      false,
+	} {
+		if got := isClockToken(token); got != want {
+			t.Errorf("isClockToken(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+func TestExternalSubcommand(t *testing.T) {
+	t.Parallel()
+
+	lookPath := func(name string) (string, error) {
+		if name == "after-standup" {
+			return "/usr/local/bin/after-standup", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantOK   bool
+		wantArgv []string
+	}{
+		{name: "plugin found with trailing args", args: cliArgs("standup", "--team", "core"), wantOK: true, wantArgv: []string{"/usr/local/bin/after-standup", "--team", "core"}},
+		{name: "plugin found without args", args: cliArgs("standup"), wantOK: true, wantArgv: []string{"/usr/local/bin/after-standup"}},
+		{name: "missing plugin falls through", args: cliArgs("sync"), wantOK: false},
+		{name: "duration never resolves a plugin", args: cliArgs("5m"), wantOK: false},
+		{name: "named time never resolves a plugin", args: cliArgs("noon"), wantOK: false},
+		{name: "option is not a plugin name", args: cliArgs("--standup"), wantOK: false},
+		{name: "plugin must be first argument", args: cliArgs("-q", "standup"), wantOK: false},
+		{name: "no args", args: cliArgs(), wantOK: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, argv, ok := externalSubcommand(tc.args, lookPath)
+			if ok != tc.wantOK {
+				t.Fatalf("externalSubcommand() ok = %v, want %v", ok, tc.wantOK)
+			}
+			if !reflect.DeepEqual(argv, tc.wantArgv) {
+				t.Fatalf("externalSubcommand() argv = %q, want %q", argv, tc.wantArgv)
+			}
+		})
+	}
+}
+
+func TestRenderInteractiveCountdown_TitleSetterFallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		supportsAdvanced bool
+		noTitle          bool
+		wantTitles       []string
+	}{
+		{name: "fallback used when escape sequences unsupported", wantTitles: []string{"1:00"}},
+		{name: "fallback respects noTitle", noTitle: true},
+		{name: "fallback unused when OSC title is available", supportsAdvanced: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var titles []string
+			status := newStatusDisplay(io.Discard, true, tc.supportsAdvanced)
+			status.setTitle = func(title string) { titles = append(titles, title) }
+			renderInteractiveCountdown(status, "1:00", tc.noTitle)
+			if !reflect.DeepEqual(titles, tc.wantTitles) {
+				t.Fatalf("setTitle calls = %q, want %q", titles, tc.wantTitles)
+			}
+		})
+	}
+}
+
+func TestParseConfigSections(t *testing.T) {
+	t.Parallel()
+
+	input := "# alerts\n[alert loud]\nsound = on\nsound-file = ~/gong.wav\n\n[alert silent]\nsound=off\n"
+	got, err := parseConfigSections(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	want := []configSection{
+		{kind: "alert", name: "loud", line: 2, entries: []configEntry{
+			{key: "sound", value: "on", line: 3},
+			{key: "sound-file", value: "~/gong.wav", line: 4},
+		}},
+		{kind: "alert", name: "silent", line: 6, entries: []configEntry{
+			{key: "sound", value: "off", line: 7},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseConfigSections() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseAlertProfileSoundName(t *testing.T) {
+	t.Parallel()
+
+	section := configSection{kind: "alert", name: "tea", entries: []configEntry{{key: "sound-name", value: "marimba", line: 2}}}
+	profile, err := parseAlertProfile(section)
+	if err != nil || profile.soundFile != "sound:marimba" {
+		t.Fatalf("parseAlertProfile() = %+v, %v; want the marimba sound", profile, err)
+	}
+	section.entries[0].value = "gong"
+	var cfgErr configError
+	if _, err := parseAlertProfile(section); !errors.As(err, &cfgErr) || cfgErr.line != 2 {
+		t.Fatalf("parseAlertProfile(gong) error = %v, want a config error on line 2", err)
+	}
+}
+
+func TestParseAlertProfileRemote(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{value: "pushover, slack", want: "pushover,slack"},
+		{value: "email", want: "email"},
+		{value: " none ", want: "none"},
+	} {
+		section := configSection{kind: "alert", name: "away", entries: []configEntry{{key: "remote", value: tc.value, line: 2}}}
+		if profile, err := parseAlertProfile(section); err != nil || profile.remote != tc.want {
+			t.Fatalf("parseAlertProfile(remote = %s) = %+v, %v; want remote %q", tc.value, profile, err, tc.want)
+		}
+	}
+	for _, value := range []string{"pager", "slack,", "none, slack"} {
+		section := configSection{kind: "alert", name: "away", entries: []configEntry{{key: "remote", value: value, line: 2}}}
+		var cfgErr configError
+		if _, err := parseAlertProfile(section); !errors.As(err, &cfgErr) || cfgErr.line != 2 {
+			t.Fatalf("parseAlertProfile(remote = %s) error = %v, want a config error on line 2", value, err)
+		}
+	}
+}
+
+func TestParseConfigSectionsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		wantLine int
+	}{
+		{name: "setting before any section", input: "sound = on\n", wantLine: 1},
+		{name: "unterminated header", input: "[alert loud\n", wantLine: 1},
+		{name: "header without name", input: "[alert]\n", wantLine: 1},
+		{name: "line without equals", input: "[alert loud]\nsound\n", wantLine: 2},
+		{name: "units with a name", input: "[units work]\n", wantLine: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseConfigSections(strings.NewReader(tc.input))
+			var cfgErr configError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("parseConfigSections() error = %v, want configError", err)
+			}
+			if cfgErr.line != tc.wantLine {
+				t.Fatalf("parseConfigSections() error line = %d, want %d", cfgErr.line, tc.wantLine)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing file is empty config", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := loadConfig(t.TempDir() + "/missing")
+		if err != nil || len(cfg.alerts) != 0 {
+			t.Fatalf("loadConfig() = %+v, %v; want empty config", cfg, err)
+		}
+	})
+
+	t.Run("unknown alert setting reports path and line", func(t *testing.T) {
+		t.Parallel()
+		path := t.TempDir() + "/config"
+		if err := os.WriteFile(path, []byte("[alert loud]\nvolume = 11\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadConfig(path)
+		want := fmt.Sprintf("config %s:2: unknown alert setting \"volume\"", path)
+		if err == nil || err.Error() != want {
+			t.Fatalf("loadConfig() error = %v, want %q", err, want)
+		}
+	})
+}
+
+func TestConfigPath(t *testing.T) {
+	t.Parallel()
+
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	if got := configPath(env(map[string]string{configEnvVar: "/tmp/after.conf", "XDG_CONFIG_HOME": "/xdg"})); got != "/tmp/after.conf" {
+		t.Fatalf("configPath() with %s = %q", configEnvVar, got)
+	}
+	if got := configPath(env(map[string]string{"XDG_CONFIG_HOME": "/xdg"})); got != "/xdg/after/config" {
+		t.Fatalf("configPath() with XDG_CONFIG_HOME = %q", got)
+	}
+}
+
+func TestResolveAlertProfile(t *testing.T) {
+	t.Parallel()
+
+	on, off := true, false
+	cfg := config{alerts: map[string]alertProfile{
+		"loud":   {sound: &on, soundFile: "~/gong.wav"},
+		"silent": {sound: &off, quiet: &on},
+		"office": {sound: &off, flash: &on},
+		"wake":   {gentle: &on},
+		"desk":   {notifyCancel: &on},
+	}}
+
+	tests := []struct {
+		name    string
+		inv     invocation
+		want    invocation
+		wantErr bool
+	}{
+		{name: "no profile is unchanged", inv: invocation{duration: time.Second}, want: invocation{duration: time.Second}},
+		{name: "loud forces alarm with sound file", inv: invocation{alertProfile: "loud"}, want: invocation{alertProfile: "loud", forceAlarm: true, soundFile: "~/gong.wav"}},
+		{name: "explicit sound file beats profile", inv: invocation{alertProfile: "loud", soundFile: "bell.wav", forceAlarm: true}, want: invocation{alertProfile: "loud", soundFile: "bell.wav", forceAlarm: true}},
+		{name: "silent mutes alarm", inv: invocation{alertProfile: "silent"}, want: invocation{alertProfile: "silent", quiet: true, muteAlarm: true}},
+		{name: "explicit sound flag survives silent profile", inv: invocation{alertProfile: "silent", forceAlarm: true}, want: invocation{alertProfile: "silent", quiet: true, forceAlarm: true}},
+		{name: "office flashes instead of ringing", inv: invocation{alertProfile: "office"}, want: invocation{alertProfile: "office", muteAlarm: true, flash: true}},
+		{name: "wake ramps the volume", inv: invocation{alertProfile: "wake"}, want: invocation{alertProfile: "wake", gentle: true}},
+		{name: "desk notifies on cancel too", inv: invocation{alertProfile: "desk"}, want: invocation{alertProfile: "desk", notify: true, notifyCancel: true}},
+		{name: "unknown profile is an error", inv: invocation{alertProfile: "nope"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolveAlertProfile(tc.inv, cfg)
+			if tc.wantErr {
+				var profileErr unknownAlertProfileError
+				if !errors.As(err, &profileErr) {
+					t.Fatalf("resolveAlertProfile() error = %v, want unknownAlertProfileError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveAlertProfile() error = %v", err)
+			}
+			got.alertBase = alertFlags{}
+			if got != tc.want {
+				t.Fatalf("resolveAlertProfile() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveAlertProfileDefault(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	cfg := config{alerts: map[string]alertProfile{
+		"default": {soundFile: "~/ding.wav"},
+		"loud":    {soundFile: "~/gong.wav", wall: &on, remote: "pushover"},
+	}}
+	got, err := resolveAlertProfile(invocation{}, cfg)
+	if err != nil || got.alertProfile != "default" || got.soundFile != "~/ding.wav" {
+		t.Fatalf("resolveAlertProfile() = %+v, %v; want default profile's sound file", got, err)
+	}
+	got, err = resolveAlertProfile(invocation{alertProfile: "loud"}, cfg)
+	if err != nil || got.soundFile != "~/gong.wav" || !got.wall || got.remote != "pushover" {
+		t.Fatalf("resolveAlertProfile(loud) = %+v, %v; want loud's sound file, wall, and remote", got, err)
+	}
+	got, err = switchAlertProfile(got, "", cfg)
+	if err != nil || got.alertProfile != "default" || got.soundFile != "~/ding.wav" || got.wall || got.remote != "" {
+		t.Fatalf("switchAlertProfile(\"\") = %+v, %v; want back to default", got, err)
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	deadline := started.Add(5 * time.Minute)
+
+	tests := []struct {
+		name  string
+		event timerEvent
+		want  []string
+	}{
+		{
+			name:  "completed timer",
+			event: newTimerEvent(outcomeComplete, invocation{duration: 5 * time.Minute, label: "tea", message: "steeped"}, started, deadline.Add(1500*time.Microsecond), deadline, nil),
+			want: []string{
+				"AFTER_OUTCOME=complete",
+				"AFTER_REQUESTED=5m0s",
+				"AFTER_REQUESTED_SECONDS=300",
+				"AFTER_ELAPSED_SECONDS=300.002",
+				"AFTER_STARTED=2025-03-01T09:00:00Z",
+				"AFTER_DEADLINE=2025-03-01T09:05:00Z",
+				"AFTER_SIGNAL=",
+				"AFTER_SUSPENDED_SECONDS=0",
+				"AFTER_LABEL=tea",
+				"AFTER_MESSAGE=steeped",
+			},
+		},
+		{
+			name:  "wall clock timer cancelled by SIGTERM",
+			event: newTimerEvent(outcomeCancelled, invocation{wallClockTarget: deadline}, started, started.Add(90*time.Second), deadline, signalCause{sig: syscall.SIGTERM}),
+			want: []string{
+				"AFTER_OUTCOME=cancelled",
+				"AFTER_REQUESTED=5m0s",
+				"AFTER_REQUESTED_SECONDS=300",
+				"AFTER_ELAPSED_SECONDS=90",
+				"AFTER_STARTED=2025-03-01T09:00:00Z",
+				"AFTER_DEADLINE=2025-03-01T09:05:00Z",
+				"AFTER_SIGNAL=SIGTERM",
+				"AFTER_SUSPENDED_SECONDS=0",
+				"AFTER_LABEL=",
+				"AFTER_MESSAGE=",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := hookEnv(tc.event); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("hookEnv() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunTimerWithAlarmStarter_ExecHookReceivesEnv(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	out := t.TempDir() + "/hook.out"
+	inv := invocation{execCommand: `printf '%s %s' "$AFTER_OUTCOME" "$AFTER_REQUESTED" > ` + out}
+	status := newStatusDisplay(io.Discard, false, false)
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook output missing: %v", err)
+	}
+	if string(got) != "complete 0s" {
+		t.Fatalf("hook output = %q, want %q", got, "complete 0s")
+	}
+}
+
+func TestParseInvocation_InvalidResultFD(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{"three", "0", "-1"} {
+		_, err := parseInvocation(cliArgs("--result-fd", value, "25m"))
+		var flagErr invalidFlagValueError
+		if !errors.As(err, &flagErr) || flagErr.flag != "--result-fd" || flagErr.value != value {
+			t.Fatalf("parseInvocation(--result-fd %q) error = %v, want invalidFlagValueError", value, err)
+		}
+	}
+}
+
+func TestNewTimerResult(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	deadline := started.Add(5 * time.Minute)
+
+	tests := []struct {
+		name  string
+		event timerEvent
+		cause error
+		want  timerResult
+	}{
+		{
+			name:  "completed timer",
+			event: newTimerEvent(outcomeComplete, invocation{duration: 5 * time.Minute}, started, deadline, deadline, nil),
+			want:  timerResult{Outcome: "complete", Reason: "deadline", RequestedSeconds: 300, ElapsedSeconds: 300, Started: started, Ended: deadline, Deadline: deadline},
+		},
+		{
+			name:  "cancelled by SIGTERM",
+			event: newTimerEvent(outcomeCancelled, invocation{duration: 5 * time.Minute}, started, started.Add(90*time.Second), deadline, signalCause{sig: syscall.SIGTERM}),
+			cause: signalCause{sig: syscall.SIGTERM},
+			want:  timerResult{Outcome: "cancelled", Reason: "signal", Signal: "SIGTERM", ExitCode: 143, RequestedSeconds: 300, ElapsedSeconds: 90, Started: started, Ended: started.Add(90 * time.Second), Deadline: deadline},
+		},
+		{
+			name:  "cancelled remotely",
+			event: newTimerEvent(outcomeCancelled, invocation{duration: 5 * time.Minute}, started, started.Add(time.Minute), deadline, errCancelledRemotely),
+			cause: errCancelledRemotely,
+			want:  timerResult{Outcome: "cancelled", Reason: "remote", ExitCode: 130, RequestedSeconds: 300, ElapsedSeconds: 60, Started: started, Ended: started.Add(time.Minute), Deadline: deadline},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := newTimerResult(tc.event, "", tc.cause); got != tc.want {
+				t.Fatalf("newTimerResult() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunTimerWithAlarmStarter_WritesResultFile(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	path := t.TempDir() + "/result.json"
+	inv := invocation{resultFile: path}
+	status := newStatusDisplay(io.Discard, false, false)
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("result file missing: %v", err)
+	}
+	var got timerResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("result file is not JSON: %v (%q)", err, data)
+	}
+	if got.Outcome != outcomeComplete || got.Reason != reasonDeadline || got.ExitCode != 0 {
+		t.Fatalf("result = %+v, want completed by deadline", got)
+	}
+}
+
+func TestSuspendGapFromElapsed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		wall time.Duration
+		mono time.Duration
+		want time.Duration
+	}{
+		{name: "clocks agree", wall: time.Second, mono: time.Second, want: 0},
+		{name: "jitter below threshold is ignored", wall: 2500 * time.Millisecond, mono: time.Second, want: 0},
+		{name: "backward wall clock step is ignored", wall: -time.Hour, mono: time.Second, want: 0},
+		{name: "suspend shows as wall clock gap", wall: 10*time.Minute + time.Second, mono: time.Second, want: 10 * time.Minute},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := suspendGapFromElapsed(tc.wall, tc.mono); got != tc.want {
+				t.Fatalf("suspendGapFromElapsed(%v, %v) = %v, want %v", tc.wall, tc.mono, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrintCompleteWithSummary(t *testing.T) {
+	t.Parallel()
+
+	out, status := newCapturedStatus(false, false)
+	printComplete(status, false, "", "", formatSuspendPause(12*time.Minute+400*time.Millisecond))
+	if got, want := out.String(), "after: complete (paused 12m0s during system suspend)\n"; got != want {
+		t.Fatalf("printComplete() output = %q, want %q", got, want)
+	}
+}
+
+func TestParseInvocation_InvalidFlagValue(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []string{"soon", "0", "-5m"} {
+		_, err := parseInvocation(cliArgs("--idle-pause", value, "25m"))
+		var flagErr invalidFlagValueError
+		if !errors.As(err, &flagErr) || flagErr.flag != "--idle-pause" || flagErr.value != value {
+			t.Fatalf("parseInvocation(--idle-pause %q) error = %v, want invalidFlagValueError", value, err)
+		}
+	}
+}
+
+func TestParseIORegIdleTime(t *testing.T) {
+	t.Parallel()
+
+	out := []byte(`    | |   "HIDIdleTime" = 93000123456
+    | |   "HIDKeyboardModifierMappingPairs" = ()`)
+	got, err := parseIORegIdleTime(out)
+	if err != nil || got != 93000123456*time.Nanosecond {
+		t.Fatalf("parseIORegIdleTime() = %v, %v; want 93.000123456s", got, err)
+	}
+	if _, err := parseIORegIdleTime([]byte("no idle here")); err == nil {
+		t.Fatal("parseIORegIdleTime() error = nil for missing key")
+	}
+}
+
+func TestParseLogindIdle(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-7 * time.Minute).UnixMicro()
+
+	tests := []struct {
+		name    string
+		out     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "active session", out: "IdleHint=no\nIdleSinceHint=0\n", want: 0},
+		{name: "idle session", out: fmt.Sprintf("IdleHint=yes\nIdleSinceHint=%d\n", since), want: 7 * time.Minute},
+		{name: "idle without timestamp", out: "IdleHint=yes\n", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseLogindIdle([]byte(tc.out), now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseLogindIdle() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Fatalf("parseLogindIdle() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWatchIdleReportsTransitions(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	readings := []time.Duration{time.Minute, 10 * time.Minute, 11 * time.Minute, 0}
+	var calls int
+	probe := func() (time.Duration, error) {
+		if calls >= len(readings) {
+			return 0, errors.New("done")
+		}
+		d := readings[calls]
+		calls++
+		return d, nil
+	}
+
+	ch := watchIdle(ctx, probe, 5*time.Minute, time.Millisecond)
+	for _, want := range []bool{true, false} {
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Fatalf("watchIdle() transition = %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("watchIdle() did not report transition")
+		}
+	}
+}
+
+func TestNextRenderInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		current   time.Duration
+		writeCost time.Duration
+		want      time.Duration
+	}{
+		{name: "fast writes stay unthrottled", current: 0, writeCost: time.Millisecond, want: 0},
+		{name: "slow write backs off to a multiple of its cost", current: 0, writeCost: 150 * time.Millisecond, want: 600 * time.Millisecond},
+		{name: "repeated slow writes double the interval", current: time.Second, writeCost: 150 * time.Millisecond, want: 2 * time.Second},
+		{name: "backoff is capped", current: 4 * time.Second, writeCost: time.Second, want: maxRenderInterval},
+		{name: "fast writes recover gradually", current: 2 * time.Second, writeCost: time.Millisecond, want: time.Second},
+		{name: "recovery snaps to zero below threshold", current: 150 * time.Millisecond, writeCost: time.Millisecond, want: 0},
+		{name: "moderate writes hold the interval", current: time.Second, writeCost: 50 * time.Millisecond, want: time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := nextRenderInterval(tc.current, tc.writeCost); got != tc.want {
+				t.Fatalf("nextRenderInterval(%v, %v) = %v, want %v", tc.current, tc.writeCost, got, tc.want)
+			}
+		})
+	}
+}
+
+// blockingWriter holds every write until release is closed.
+type blockingWriter struct {
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.buf.Write(p)
+}
+
+func TestFrameRendererDropsIntermediateFrames(t *testing.T) {
+	t.Parallel()
+
+	w := &blockingWriter{release: make(chan struct{})}
+	r := newFrameRenderer(w)
+
+	r.submit("a")
+	// Give the renderer time to pick up "a" and block in Write.
+	time.Sleep(10 * time.Millisecond)
+	r.submit("b")
+	r.submit("c")
+	close(w.release)
+	r.close()
+
+	if got := w.buf.String(); got != "ac" {
+		t.Fatalf("frameRenderer output = %q, want %q", got, "ac")
+	}
+}
+
+func TestFrameDiffSkipsUnchangedParts(t *testing.T) {
+	t.Parallel()
+
+	var d frameDiff
+	first := countdownFrame{title: "\033]0;4:59\007", line: "\r\033[K4:59"}
+	if got, want := d.render(first), first.title+first.line; got != want {
+		t.Fatalf("render(first) = %q, want %q", got, want)
+	}
+	if got := d.render(first); got != "" {
+		t.Fatalf("render(same) = %q, want nothing", got)
+	}
+	pulsed := countdownFrame{title: first.title, line: "\r\033[K\033[7m4:59\033[0m"}
+	if got := d.render(pulsed); got != pulsed.line {
+		t.Fatalf("render(new line) = %q, want line only %q", got, pulsed.line)
+	}
+	withChrome := countdownFrame{prefix: "\033]1337;SetBadgeFormat=\007", title: pulsed.title, line: pulsed.line}
+	if got := d.render(withChrome); got != withChrome.prefix {
+		t.Fatalf("render(prefix) = %q, want prefix only", got)
+	}
+}
+
+func TestFrameRendererRedrawsAfterExclusive(t *testing.T) {
+	t.Parallel()
+
+	w := &recordingWriter{}
+	r := newFrameRenderer(w)
+	frame := countdownFrame{line: "\r\033[K4:59"}
+	write := func() {
+		r.submitFrame(frame)
+		// Let the renderer write before the next submit replaces it.
+		time.Sleep(10 * time.Millisecond)
+	}
+	write()
+	write()
+	r.exclusive(func() { writeStatus(w, "\r\033[Kafter: resumed\n") })
+	write()
+	r.close()
+
+	want := []string{frame.line, "\r\033[Kafter: resumed\n", frame.line}
+	if !reflect.DeepEqual(w.writes, want) {
+		t.Fatalf("frameRenderer writes = %q, want %q", w.writes, want)
+	}
+}
+
+// recordingWriter keeps each Write call separately.
+type recordingWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestRunTimerWithAlarmStarter_InteractiveWritesWholeFrames(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	w := &recordingWriter{}
+	status := newStatusDisplay(w, true, true)
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, invocation{}, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	want := []string{pushTitle, "\033]0;[100%] 0\007\r\033[K0", popTitle, "\r\033[Kafter complete\n"}
+	if !reflect.DeepEqual(w.writes, want) {
+		t.Fatalf("runTimerWithAlarmStarter() writes = %q, want %q", w.writes, want)
+	}
+}
+
+func TestParseTestSoundArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseTestSoundArgs([]string{"--sound-name", "chime", "--gentle"})
+	if err != nil || got != (alarmSound{soundFile: "sound:chime", gentle: true}) {
+		t.Fatalf("parseTestSoundArgs() = %+v, %v; want the chime, gentle", got, err)
+	}
+	for _, args := range [][]string{{"--sound-file"}, {"5m"}} {
+		if _, err := parseTestSoundArgs(args); !errors.Is(err, errUsage) {
+			t.Errorf("parseTestSoundArgs(%q) error = %v, want errUsage", args, err)
+		}
+	}
+	var unknownErr unknownOptionError
+	if _, err := parseTestSoundArgs([]string{"--loud"}); !errors.As(err, &unknownErr) {
+		t.Errorf("parseTestSoundArgs(--loud) error = %v, want unknownOptionError", err)
+	}
+}
+
+func TestTestSoundReportsBackends(t *testing.T) {
+	t.Parallel()
+
+	commands := []alarmCommand{{name: "paplay", args: []string{"bell.wav"}}, {name: "aplay", args: []string{"-q", "bell.wav"}}}
+	var out bytes.Buffer
+	name, ok := testSound(&out, commands, false, func(c alarmCommand) error {
+		if c.name == "paplay" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	})
+	if !ok || name != "aplay" {
+		t.Fatalf("testSound() = %q, %v; want aplay", name, ok)
+	}
+	want := "after: alarm backends: paplay, aplay\n" +
+		"after: paplay failed: exit status 1\n" +
+		"after: play 1 of 4: aplay\n" +
+		"after: play 2 of 4: aplay\n" +
+		"after: play 3 of 4: aplay\n" +
+		"after: play 4 of 4: aplay\n"
+	if out.String() != want {
+		t.Fatalf("testSound() output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if _, ok := testSound(&out, nil, false, runAlarmCommand); ok || out.String() != "after: alarm backends: none installed\n" {
+		t.Fatalf("testSound(no backends) = %v, %q; want false and none installed", ok, out.String())
+	}
+}
+
+func TestFormatSoundsReport(t *testing.T) {
+	t.Parallel()
+
+	candidates := []alarmCommand{
+		{name: "paplay", args: []string{"sound:bell"}},
+		{name: "aplay", args: []string{"-q", "sound:bell"}},
+		{name: builtinPCSpeakerBackend, args: []string{"1200", "150"}},
+	}
+	installed := func(c alarmCommand) bool { return c.name != "paplay" }
+	got := formatSoundsReport("linux", alarmSound{backends: "aplay", soundFile: "sound:bell"}, quietHours{}, "", candidates, installed)
+	want := "sound:   bell (built in)\n" +
+		"order:   aplay first (config)\n" +
+		"sounds:  beep, bell, chime, marimba\n" +
+		"backends on linux (* plays first):\n" +
+		"  paplay sound:bell           not installed\n" +
+		"* aplay -q sound:bell         installed\n" +
+		"  builtin:pcspeaker 1200 150  built in\n"
+	if got != want {
+		t.Fatalf("formatSoundsReport() =\n%s\nwant\n%s", got, want)
+	}
+
+	got = formatSoundsReport("linux", alarmSound{}, quietHours{}, "", candidates[:1], installed)
+	if !strings.HasSuffix(got, "no backend is installed; only the terminal bell will ring\n") {
+		t.Fatalf("formatSoundsReport(nothing installed) = %q, want the terminal bell note", got)
+	}
+}
+
+func TestParseEditArgs(t *testing.T) {
+	t.Parallel()
+
+	label := "tea"
+	alert := "loud"
+	tests := []struct {
+		name    string
+		args    []string
+		want    editArgs
+		wantErr bool
+	}{
+		{name: "id and duration", args: []string{"1234", "10m"}, want: editArgs{id: 1234, deadline: "10m"}},
+		{name: "duration without id", args: []string{"10m"}, want: editArgs{deadline: "10m"}},
+		{name: "time with separate meridiem", args: []string{"1234", "3:30", "pm"}, want: editArgs{id: 1234, deadline: "3:30 pm"}},
+		{name: "label and alert flags", args: []string{"--label", "tea", "1234", "-a", "loud"}, want: editArgs{id: 1234, label: &label, alert: &alert}},
+		{name: "bare number is an id, not seconds", args: []string{"90"}, wantErr: true},
+		{name: "nothing to change", args: []string{"1234"}, wantErr: true},
+		{name: "unknown option", args: []string{"1234", "--wat"}, wantErr: true},
+		{name: "too many positionals", args: []string{"1234", "10m", "5m"}, wantErr: true},
+		{name: "label without value", args: []string{"1234", "--label"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseEditArgs(tc.args)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseEditArgs() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("parseEditArgs() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildEditRequest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	req, err := buildEditRequest(editArgs{id: 1, deadline: "10m"}, now)
+	if err != nil {
+		t.Fatalf("buildEditRequest() error = %v", err)
+	}
+	if req.Command != controlCommandEdit || req.Deadline == nil || !req.Deadline.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("buildEditRequest() = %+v, want edit with deadline 10m from now", req)
+	}
+
+	if _, err := buildEditRequest(editArgs{id: 1, deadline: "soon"}, now); !errors.Is(err, errInvalidDuration) {
+		t.Fatalf("buildEditRequest() error = %v, want %v", err, errInvalidDuration)
+	}
+}
+
+func TestPickStatusTimer(t *testing.T) {
+	t.Parallel()
+
+	states := []controlState{
+		{ID: 1, State: timerStatePaused, Remaining: 30},
+		{ID: 2, State: timerStateRunning, Remaining: 300},
+		{ID: 3, State: timerStateRunning, Remaining: 120},
+	}
+	if got, ok := pickStatusTimer(states, 0); !ok || got.ID != 3 {
+		t.Fatalf("pickStatusTimer(0) = %d, %v; want 3, true", got.ID, ok)
+	}
+	if got, ok := pickStatusTimer(states, 1); !ok || got.ID != 1 {
+		t.Fatalf("pickStatusTimer(1) = %d, %v; want 1, true", got.ID, ok)
+	}
+	if _, ok := pickStatusTimer(states, 9); ok {
+		t.Fatal("pickStatusTimer(9) found a timer, want none")
+	}
+	if _, ok := pickStatusTimer(nil, 0); ok {
+		t.Fatal("pickStatusTimer(nil) found a timer, want none")
+	}
+}
+
+func TestFormatWaybarStatus(t *testing.T) {
+	t.Parallel()
+
+	deadline := time.Date(2025, 3, 1, 9, 5, 0, 0, time.Local)
+	state := controlState{ID: 42, Label: "tea", State: timerStatePaused, Deadline: deadline, Remaining: 90, Total: 360}
+	want := `{"text":"tea: 1:30 (paused)","tooltip":"42  tea: 1:30 (paused) remaining (until 09:05:00)","class":"paused","percentage":75}`
+	if got := formatWaybarStatus(state, true); got != want {
+		t.Fatalf("formatWaybarStatus() = %s, want %s", got, want)
+	}
+	if got, want := formatWaybarStatus(controlState{}, false), `{"text":"","tooltip":"","class":"idle","percentage":0}`; got != want {
+		t.Fatalf("formatWaybarStatus(idle) = %s, want %s", got, want)
+	}
+}
+
+func TestFormatPromptSegment(t *testing.T) {
+	t.Parallel()
+
+	if got, want := formatPromptSegment(controlState{State: timerStateRunning, Remaining: 750}), "⏳12:30"; got != want {
+		t.Fatalf("formatPromptSegment(running) = %q, want %q", got, want)
+	}
+	if got, want := formatPromptSegment(controlState{State: timerStatePaused, Remaining: 59.2}), "⏸1:00"; got != want {
+		t.Fatalf("formatPromptSegment(paused) = %q, want %q", got, want)
+	}
+}
+
+func TestControlServerRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	srv, err := listenControl(dir)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer srv.close()
+
+	go func() {
+		for call := range srv.calls {
+			state := controlState{ID: os.Getpid(), State: timerStateRunning, Label: *call.req.Label}
+			call.reply <- controlResponse{OK: true, State: &state}
+		}
+	}()
+
+	label := "tea"
+	resp, err := sendControl(dir, os.Getpid(), controlRequest{Command: controlCommandEdit, Label: &label})
+	if err != nil {
+		t.Fatalf("sendControl() error = %v", err)
+	}
+	if resp.State == nil || resp.State.Label != "tea" {
+		t.Fatalf("sendControl() state = %+v, want label tea", resp.State)
+	}
+
+	var missing noSuchTimerError
+	if _, err := sendControl(dir, os.Getpid()+1, controlRequest{Command: controlCommandStatus}); !errors.As(err, &missing) {
+		t.Fatalf("sendControl() to missing timer error = %v, want noSuchTimerError", err)
+	}
+}
+
+func TestCheckControlDir(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("control directory ownership and mode are not checked on Windows")
+	}
+	base := t.TempDir()
+	private := filepath.Join(base, "private")
+	if err := os.Mkdir(private, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	open := filepath.Join(base, "open")
+	if err := os.Mkdir(open, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(open, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+
+	uid := os.Getuid()
+	if err := checkControlDir(private, uid); err != nil {
+		t.Fatalf("checkControlDir(private) = %v, want nil", err)
+	}
+	for _, tc := range []struct {
+		name string
+		dir  string
+		uid  int
+	}{
+		{name: "group and world readable", dir: open, uid: uid},
+		{name: "symlink", dir: link, uid: uid},
+		{name: "owned by another user", dir: private, uid: uid + 1},
+		{name: "missing", dir: filepath.Join(base, "missing"), uid: uid},
+	} {
+		if err := checkControlDir(tc.dir, tc.uid); err == nil {
+			t.Errorf("checkControlDir(%s) = nil, want error", tc.name)
+		}
+	}
+}
+
+func TestTimerServer(t *testing.T) {
+	t.Parallel()
+
+	// This process stands in for the timer the server starts.
+	dir := t.TempDir()
+	srv, err := listenControl(dir)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer srv.close()
+	cancelled := make(chan struct{}, 1)
+	go func() {
+		for call := range srv.calls {
+			if call.req.Command == controlCommandCancel {
+				cancelled <- struct{}{}
+			}
+			state := controlState{ID: os.Getpid(), Label: "standup", State: timerStateRunning, Remaining: 90}
+			call.reply <- controlResponse{OK: true, State: &state}
+		}
+	}()
+	var started []string
+	s := timerServer{dir: dir, units: map[string]time.Duration{"pomo": 25 * time.Minute}, start: func(args []string) (int, error) {
+		started = args
+		return os.Getpid(), nil
+	}}
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/timers", "application/json", strings.NewReader(`{"duration":"1pomo","label":"standup"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state controlState
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || state.Label != "standup" {
+		t.Fatalf("POST /api/timers = %s %+v, want the new timer", resp.Status, state)
+	}
+	if want := []string{"1pomo", "--quiet", "--label", "standup"}; !slices.Equal(started, want) {
+		t.Fatalf("started %q, want %q", started, want)
+	}
+	for _, body := range []string{`{"duration":"--exec=id"}`, `{"duration":"banana"}`, `{`} {
+		resp, err := http.Post(server.URL+"/api/timers", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("POST %s = %s, want 400", body, resp.Status)
+		}
+	}
+	for _, tc := range []struct {
+		name        string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{name: "form post", contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "no content type", want: http.StatusUnsupportedMediaType},
+		{name: "rebound host", host: "attacker.example:8080", contentType: "application/json", want: http.StatusForbidden},
+		{name: "cross-site origin", origin: "http://attacker.example", contentType: "application/json", want: http.StatusForbidden},
+		{name: "localhost origin", host: "localhost:8080", origin: "http://localhost:8080", contentType: "application/json; charset=utf-8", want: http.StatusCreated},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/timers", strings.NewReader(`{"duration":"1pomo"}`))
+		if tc.host != "" {
+			req.Host = tc.host
+		}
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("POST /api/timers (%s) = %s, want %d", tc.name, resp.Status, tc.want)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/api/timers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timers []controlState
+	json.NewDecoder(resp.Body).Decode(&timers)
+	resp.Body.Close()
+	if len(timers) != 1 || timers[0].ID != os.Getpid() {
+		t.Fatalf("GET /api/timers = %+v, want this timer", timers)
+	}
+
+	resp, err = http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{`http-equiv="refresh"`, "standup", "1:30"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page does not contain %q:\n%s", want, page)
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/events", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := bufio.NewReader(resp.Body)
+	for range 2 {
+		event, _ := events.ReadString('\n')
+		data, _ := events.ReadString('\n')
+		blank, _ := events.ReadString('\n')
+		if event != "event: timers\n" || !strings.HasPrefix(data, "data: [") || !strings.Contains(data, `"display":"1:30"`) || blank != "\n" {
+			t.Fatalf("GET /api/events sent %q %q %q, want the timers", event, data, blank)
+		}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("GET /api/events Content-Type = %q, want text/event-stream", ct)
+	}
+	stop()
+	resp.Body.Close()
+
+	del := func(id int, host string) int {
+		req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/api/timers/%d", server.URL, id), nil)
+		if host != "" {
+			req.Host = host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := del(os.Getpid(), "attacker.example"); code != http.StatusForbidden || len(cancelled) != 0 {
+		t.Fatalf("DELETE through another host = %d, want 403 and the timer left running", code)
+	}
+	if code := del(os.Getpid(), ""); code != http.StatusOK || len(cancelled) != 1 {
+		t.Fatalf("DELETE = %d, want 200 and the timer cancelled", code)
+	}
+	if code := del(os.Getpid()+1, ""); code != http.StatusNotFound {
+		t.Fatalf("DELETE missing timer = %d, want 404", code)
+	}
+}
+
+func TestRunTimerWithControl_EditMovesDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(false, false)
+	controlC := make(chan controlCall)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- runTimerWithControl(ctx, cancel, invocation{duration: time.Hour}, status, false, func(alarmSound) {}, controlC, nil)
+	}()
+
+	soon := time.Now().Add(50 * time.Millisecond)
+	label := "tea"
+	call := controlCall{req: controlRequest{Command: controlCommandEdit, Deadline: &soon, Label: &label}, reply: make(chan controlResponse, 1)}
+	controlC <- call
+	resp := <-call.reply
+	if !resp.OK || resp.State.Label != "tea" || resp.State.Remaining > 1 {
+		t.Fatalf("edit response = %+v, want ok with label tea and under a second remaining", resp)
+	}
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("runTimerWithControl() error = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runTimerWithControl() did not complete at edited deadline")
+	}
+	if !strings.Contains(out.String(), "after: tea: deadline changed") {
+		t.Fatalf("runTimerWithControl() output = %q, want deadline change notice", out.String())
+	}
+}
+
+func TestParseDashKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   []byte
+		want dashKey
+	}{
+		{in: []byte("\x1b[A"), want: dashKeyUp},
+		{in: []byte("\x1b[B"), want: dashKeyDown},
+		{in: []byte("k"), want: dashKeyUp},
+		{in: []byte("j"), want: dashKeyDown},
+		{in: []byte("p"), want: dashKeyPause},
+		{in: []byte("+"), want: dashKeyExtend},
+		{in: []byte("-"), want: dashKeyShorten},
+		{in: []byte("c"), want: dashKeyCancel},
+		{in: []byte("q"), want: dashKeyQuit},
+		{in: []byte{0x1B}, want: dashKeyQuit},
+		{in: []byte("\x1b[C"), want: dashKeyNone},
+		{in: []byte("z"), want: dashKeyNone},
+	}
+
+	for _, tc := range tests {
+		if got := parseDashKey(tc.in); got != tc.want {
+			t.Fatalf("parseDashKey(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFormatProgressBar(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fraction float64
+		want     string
+	}{
+		{fraction: 0, want: "[----------]"},
+		{fraction: 0.5, want: "[#####-----]"},
+		{fraction: 1, want: "[##########]"},
+		{fraction: 1.7, want: "[##########]"},
+		{fraction: -1, want: "[----------]"},
+	}
+
+	for _, tc := range tests {
+		if got := formatProgressBar(tc.fraction, 10); got != tc.want {
+			t.Fatalf("formatProgressBar(%v) = %q, want %q", tc.fraction, got, tc.want)
+		}
+	}
+}
+
+func TestMoveDashSelection(t *testing.T) {
+	t.Parallel()
+
+	states := []controlState{{ID: 10}, {ID: 20}, {ID: 30}}
+	tests := []struct {
+		name     string
+		selected int
+		delta    int
+		want     int
+	}{
+		{name: "vanished selection snaps to first", selected: 99, delta: 0, want: 10},
+		{name: "down moves to next", selected: 10, delta: 1, want: 20},
+		{name: "down stops at last", selected: 30, delta: 1, want: 30},
+		{name: "up stops at first", selected: 10, delta: -1, want: 10},
+	}
+
+	for _, tc := range tests {
+		if got := moveDashSelection(states, tc.selected, tc.delta); got != tc.want {
+			t.Fatalf("%s: moveDashSelection() = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+	if got := moveDashSelection(nil, 10, 1); got != 0 {
+		t.Fatalf("moveDashSelection(nil) = %d, want 0", got)
+	}
+}
+
+func TestDashAction(t *testing.T) {
+	t.Parallel()
+
+	running := controlState{ID: 1, State: timerStateRunning}
+	paused := controlState{ID: 1, State: timerStatePaused}
+
+	if req, _ := dashAction(dashKeyPause, running); req.Command != controlCommandPause {
+		t.Fatalf("dashAction(pause, running) = %+v, want pause", req)
+	}
+	if req, _ := dashAction(dashKeyPause, paused); req.Command != controlCommandResume {
+		t.Fatalf("dashAction(pause, paused) = %+v, want resume", req)
+	}
+	if req, _ := dashAction(dashKeyShorten, running); req.Command != controlCommandExtend || req.ExtendSeconds != -60 {
+		t.Fatalf("dashAction(shorten) = %+v, want extend by -60s", req)
+	}
+	if _, ok := dashAction(dashKeyUp, running); ok {
+		t.Fatal("dashAction(up) ok = true, want false")
+	}
+}
+
+func TestRenderDash(t *testing.T) {
+	t.Parallel()
+
+	states := []controlState{
+		{ID: 4242, Label: "tea", Remaining: 150, Total: 300, State: timerStateRunning},
+		{ID: 4343, Remaining: 60, Total: 60, State: timerStatePaused},
+		{ID: 4444, Label: "お茶", Remaining: 60, Total: 60, State: timerStateRunning},
+	}
+	got := renderDash(states, 4242, "")
+	for _, want := range []string{
+		"after dash: 3 running",
+		"  4444     お茶                       1:00  [--------------------]  running",
+		"> 4242     tea                        2:30  [##########----------]  running",
+		"  4343                                1:00  [--------------------]  paused",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("renderDash() = %q, want line %q", got, want)
+		}
+	}
+}
+
+func TestRunTimerWithControl_PauseExtendCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(false, false)
+	controlC := make(chan controlCall)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- runTimerWithControl(ctx, cancel, invocation{duration: time.Hour}, status, false, func(alarmSound) {}, controlC, nil)
+	}()
+
+	send := func(req controlRequest) controlResponse {
+		call := controlCall{req: req, reply: make(chan controlResponse, 1)}
+		controlC <- call
+		return <-call.reply
+	}
+
+	if resp := send(controlRequest{Command: controlCommandPause}); resp.State.State != timerStatePaused {
+		t.Fatalf("pause response state = %q, want paused", resp.State.State)
+	}
+	resp := send(controlRequest{Command: controlCommandExtend, ExtendSeconds: 60})
+	if resp.State.Total != (61 * time.Minute).Seconds() {
+		t.Fatalf("extend response total = %v, want %v", resp.State.Total, (61 * time.Minute).Seconds())
+	}
+	if resp := send(controlRequest{Command: controlCommandResume}); resp.State.State != timerStateRunning {
+		t.Fatalf("resume response state = %q, want running", resp.State.State)
+	}
+	send(controlRequest{Command: controlCommandCancel})
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, errCancelledRemotely) {
+			t.Fatalf("runTimerWithControl() error = %v, want %v", err, errCancelledRemotely)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runTimerWithControl() did not stop after remote cancel")
+	}
+	for _, want := range []string{"after: paused\n", "after: resumed\n", "after: cancelled\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("runTimerWithControl() output = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestRunTimerWithControl_ShortenPastZero(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	_, status := newCapturedStatus(false, false)
+	controlC := make(chan controlCall)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- runTimerWithControl(ctx, cancel, invocation{duration: time.Hour}, status, false, func(alarmSound) {}, controlC, nil)
+	}()
+
+	send := func(req controlRequest) controlResponse {
+		call := controlCall{req: req, reply: make(chan controlResponse, 1)}
+		controlC <- call
+		return <-call.reply
+	}
+
+	// Cutting two hours from the hour left takes away only that hour, so
+	// the total is the time already run, not an hour below zero.
+	send(controlRequest{Command: controlCommandPause})
+	resp := send(controlRequest{Command: controlCommandExtend, ExtendSeconds: -(2 * time.Hour).Seconds()})
+	if resp.State.Remaining != 0 || resp.State.Total < 0 || resp.State.Total > 5 {
+		t.Fatalf("shorten while paused = remaining %v, total %v; want 0 and the time run so far", resp.State.Remaining, resp.State.Total)
+	}
+	send(controlRequest{Command: controlCommandCancel})
+	select {
+	case <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runTimerWithControl() did not stop after remote cancel")
+	}
+}
+
+func TestFormatBroadcastMessage(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 3, 1, 15, 4, 0, 0, time.Local)
+	if got, want := formatBroadcastMessage("", "", at), "\r\n\a*** after: timer complete at 15:04 ***\r\n"; got != want {
+		t.Fatalf("formatBroadcastMessage() = %q, want %q", got, want)
+	}
+	if got, want := formatBroadcastMessage("tea", "", at), "\r\n\a*** after: timer complete: tea at 15:04 ***\r\n"; got != want {
+		t.Fatalf("formatBroadcastMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestUserTerminals(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows consoles are not device files")
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"0", "1"} {
+		if err := os.WriteFile(dir+"/"+name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := userTerminals([]string{dir + "/[0-9]*"}, os.Getuid(), 0)
+	want := []string{dir + "/0", dir + "/1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("userTerminals() = %q, want %q", got, want)
+	}
+	if got := userTerminals([]string{dir + "/[0-9]*"}, os.Getuid()+1, 0); got != nil {
+		t.Fatalf("userTerminals() for another uid = %q, want none", got)
+	}
+
+	if err := os.Chmod(dir+"/1", 0o620); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wallTerminals([]string{dir + "/[0-9]*"}, os.Getuid()+1), []string{dir + "/1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wallTerminals() for another uid = %q, want only the one accepting messages %q", got, want)
+	}
+	if got := wallTerminals([]string{dir + "/[0-9]*"}, os.Getuid()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wallTerminals() = %q, want %q", got, want)
+	}
+
+	broadcastCompletion(want, "done\n")
+	for _, path := range want {
+		if b, _ := os.ReadFile(path); string(b) != "done\n" {
+			t.Fatalf("broadcastCompletion() wrote %q to %s, want %q", b, path, "done\n")
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain words", input: "4m  --sound", want: []string{"4m", "--sound"}},
+		{name: "single quotes are literal", input: `--label 'brew \ 4m'`, want: []string{"--label", `brew \ 4m`}},
+		{name: "double quotes allow escapes", input: `-e "say \"done\""`, want: []string{"-e", `say "done"`}},
+		{name: "backslash escapes space", input: `a\ b c`, want: []string{"a b", "c"}},
+		{name: "empty quoted word", input: `'' x`, want: []string{"", "x"}},
+		{name: "empty input", input: "  ", want: nil},
+		{name: "unterminated quote", input: `'oops`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := splitArgs(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("splitArgs(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("splitArgs(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpandPreset(t *testing.T) {
+	t.Parallel()
+
+	brew := preset{args: "{{.minutes}}m --sound-file '{{.sound}}' -q", defaults: map[string]string{"sound": "kettle.wav"}}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "params fill placeholders", args: cliArgs("brew", "minutes=4"), want: cliArgs("4m", "--sound-file", "kettle.wav", "-q")},
+		{name: "params override defaults", args: cliArgs("brew", "minutes=3", "sound=gong wav"), want: cliArgs("3m", "--sound-file", "gong wav", "-q")},
+		{name: "trailing args are appended", args: cliArgs("brew", "minutes=4", "--no-title"), want: cliArgs("4m", "--sound-file", "kettle.wav", "-q", "--no-title")},
+		{name: "missing param is an error", args: cliArgs("brew"), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := expandPreset(tc.args, "brew", brew)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expandPreset() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expandPreset() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildConfigPresets(t *testing.T) {
+	t.Parallel()
+
+	input := "[preset brew]\nargs = \"{{.minutes}}m --sound\"\nminutes = 4\n"
+	sections, err := parseConfigSections(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil {
+		t.Fatalf("buildConfig() error = %v", err)
+	}
+	want := preset{args: "{{.minutes}}m --sound", defaults: map[string]string{"minutes": "4"}}
+	if got := cfg.presets["brew"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("presets[brew] = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{"[preset brew]\nminutes = 4\n", "[preset brew]\nargs = {{.minutes\n"} {
+		sections, err := parseConfigSections(strings.NewReader(bad))
+		if err != nil {
+			t.Fatalf("parseConfigSections(%q) error = %v", bad, err)
+		}
+		if _, err := buildConfig(sections); err == nil {
+			t.Fatalf("buildConfig(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestIsPresetCandidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: cliArgs("brew", "minutes=4"), want: true},
+		{args: cliArgs("5m"), want: false},
+		{args: cliArgs("noon"), want: false},
+		{args: cliArgs("-q", "brew"), want: false},
+		{args: cliArgs(), want: false},
+	}
+	for _, tc := range tests {
+		if got := isPresetCandidate(tc.args); got != tc.want {
+			t.Fatalf("isPresetCandidate(%q) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestRunTimerWithControl_DumpOnQuit(t *testing.T) {
+	t.Setenv(dumpFileEnvVar, "")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(false, false)
+	controlC := make(chan controlCall)
+	dumpC := make(chan os.Signal, 1)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- runTimerWithControl(ctx, cancel, invocation{duration: time.Hour, label: "tea"}, status, false, func(alarmSound) {}, controlC, dumpC)
+	}()
+
+	call := controlCall{req: controlRequest{Command: controlCommandPause}, reply: make(chan controlResponse, 1)}
+	controlC <- call
+	<-call.reply
+	dumpC <- syscall.SIGQUIT
+
+	select {
+	case err := <-result:
+		if got := exitCodeForCancelError(err); got != 131 {
+			t.Fatalf("exit code = %d, want 131 (err %v)", got, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runTimerWithControl() did not stop after SIGQUIT")
+	}
+	for _, want := range []string{"after: state dump", `label:           "tea"`, "state:           paused (manual)", "manual", "goroutines:", "after: tea: cancelled\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("runTimerWithControl() output = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestFormatStateDump(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	got := formatStateDump(stateDump{
+		at:        started.Add(10 * time.Minute),
+		inv:       invocation{duration: 25 * time.Minute, muteAlarm: true},
+		started:   started,
+		deadline:  started.Add(27 * time.Minute),
+		remaining: 17 * time.Minute,
+		total:     25 * time.Minute,
+		pauses: []pauseRecord{
+			{reason: pauseReasonSuspend, start: started.Add(time.Minute), end: started.Add(3 * time.Minute)},
+			{reason: pauseReasonIdle, start: started.Add(8 * time.Minute)},
+		},
+		pausedBy:      pauseReasonIdle,
+		renderer:      "non-interactive (lifecycle lines only)",
+		idle:          "off",
+		controlSocket: "none",
+	})
+	for _, want := range []string{
+		"  mode:            duration 25m0s\n",
+		"  state:           paused (idle)\n",
+		"  pauses:          2\n",
+		"    suspend  2025-03-01T09:01:00Z  2m0s\n",
+		"    idle     2025-03-01T09:08:00Z  (ongoing, 2m0s)\n",
+		"  alarm:           muted\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("formatStateDump() = %q, want line %q", got, want)
+		}
+	}
+}
+
+func TestFormatRendererMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status   statusDisplay
+		interval time.Duration
+		want     string
+	}{
+		{status: statusDisplay{}, want: "non-interactive (lifecycle lines only)"},
+		{status: statusDisplay{interactive: true}, want: "interactive, plain"},
+		{status: statusDisplay{interactive: true, supportsAdvanced: true}, interval: 400 * time.Millisecond, want: "interactive, ansi, slow terminal (frame every 400ms)"},
+	}
+	for _, tc := range tests {
+		if got := formatRendererMode(tc.status, tc.interval); got != tc.want {
+			t.Fatalf("formatRendererMode(%+v, %v) = %q, want %q", tc.status, tc.interval, got, tc.want)
+		}
+	}
+}
+
+func TestWriteStateDumpToFile(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir() + "/dump.txt"
+	out, status := newCapturedStatus(false, false)
+	getenv := func(key string) string {
+		if key == dumpFileEnvVar {
+			return path
+		}
+		return ""
+	}
+	got, err := writeStateDump(status, getenv, "state\n")
+	if err != nil || got != path {
+		t.Fatalf("writeStateDump() = %q, %v; want %q, nil", got, err, path)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "state\n" {
+		t.Fatalf("dump file = %q, want %q", data, "state\n")
+	}
+	if out.Len() != 0 {
+		t.Fatalf("status output = %q, want empty", out.String())
+	}
+}
+
+func TestClockStepFromElapsed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		wall time.Duration
+		mono time.Duration
+		want time.Duration
+	}{
+		{name: "clocks agree", wall: time.Second, mono: time.Second, want: 0},
+		{name: "forward jitter is ignored", wall: 2500 * time.Millisecond, mono: time.Second, want: 0},
+		{name: "backward jitter is ignored", wall: 0, mono: time.Second, want: 0},
+		{name: "forward step", wall: 5*time.Minute + time.Second, mono: time.Second, want: 5 * time.Minute},
+		{name: "backward step", wall: -time.Hour + time.Second, mono: time.Second, want: -time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := clockStepFromElapsed(tc.wall, tc.mono); got != tc.want {
+				t.Fatalf("clockStepFromElapsed(%v, %v) = %v, want %v", tc.wall, tc.mono, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClockStepExcludingSuspend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		wall   time.Duration
+		mono   time.Duration
+		boot   time.Duration
+		bootOK bool
+		want   time.Duration
+	}{
+		{name: "suspend is not a step", wall: 10*time.Minute + time.Second, mono: time.Second, boot: 10*time.Minute + time.Second, bootOK: true, want: 0},
+		{name: "forward step", wall: 5*time.Minute + time.Second, mono: time.Second, boot: time.Second, bootOK: true, want: 5 * time.Minute},
+		{name: "forward step during a suspend", wall: 15*time.Minute + time.Second, mono: time.Second, boot: 10*time.Minute + time.Second, bootOK: true, want: 5 * time.Minute},
+		{name: "backward step", wall: -time.Hour + time.Second, mono: time.Second, boot: time.Second, bootOK: true, want: -time.Hour},
+		{name: "forward jump without boot clock is ambiguous", wall: 5*time.Minute + time.Second, mono: time.Second, want: 0},
+		{name: "backward step without boot clock", wall: -time.Hour + time.Second, mono: time.Second, want: -time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := clockStepExcludingSuspend(tc.wall, tc.mono, tc.boot, tc.bootOK); got != tc.want {
+				t.Fatalf("clockStepExcludingSuspend(%v, %v, %v, %v) = %v, want %v", tc.wall, tc.mono, tc.boot, tc.bootOK, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrintClockStep(t *testing.T) {
+	t.Parallel()
+
+	target := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	out, status := newCapturedStatus(false, false)
+	printClockStep(status, false, -90*time.Second, target)
+	printClockStep(status, false, time.Hour, target)
+	printClockStep(status, true, time.Hour, target)
+	want := "after: clock moved back 1m30s; still counting to 09:00:00\n" +
+		"after: clock moved forward 1h0m0s; still counting to 09:00:00\n"
+	if out.String() != want {
+		t.Fatalf("printClockStep() output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestPrintRemoteFailure(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("pushover: 500 Internal Server Error")
+	out, status := newCapturedStatus(true, true)
+	printRemoteFailure(status, err)
+	if got, want := out.String(), "\r\033[KWarning: pushover: 500 Internal Server Error\n"; got != want {
+		t.Fatalf("printRemoteFailure(interactive) = %q, want %q", got, want)
+	}
+	out, status = newCapturedStatus(false, false)
+	printRemoteFailure(status, err)
+	if got, want := out.String(), "Warning: pushover: 500 Internal Server Error\n"; got != want {
+		t.Fatalf("printRemoteFailure() = %q, want %q", got, want)
+	}
+}
+
+func TestParseCustomUnitDuration(t *testing.T) {
+	t.Parallel()
+
+	units := map[string]time.Duration{"pomo": 25 * time.Minute, "ep": 42 * time.Minute}
+	tests := []struct {
+		token   string
+		want    time.Duration
+		wantOK  bool
+		wantErr error
+	}{
+		{token: "2pomo", want: 50 * time.Minute, wantOK: true},
+		{token: "1ep", want: 42 * time.Minute, wantOK: true},
+		{token: "0.5pomo", want: 12*time.Minute + 30*time.Second, wantOK: true},
+		{token: "1ep5m", want: 47 * time.Minute, wantOK: true},
+		{token: "5m", wantOK: false},
+		{token: "2pom", wantOK: false},
+		{token: "pomo", wantOK: false},
+		{token: "-1pomo", wantOK: false},
+		{token: "99999999999999999999pomo", wantOK: true, wantErr: errDurationOutOfRange},
+		{token: "7000000pomo", wantOK: true, wantErr: errDurationOutOfRange},
+		{token: "6000000pomo2562047h", wantOK: true, wantErr: errDurationOutOfRange},
+	}
+	for _, tc := range tests {
+		got, ok, err := parseCustomUnitDuration(tc.token, units)
+		if ok != tc.wantOK || got != tc.want || !errors.Is(err, tc.wantErr) {
+			t.Fatalf("parseCustomUnitDuration(%q) = %v, %v, %v; want %v, %v, %v", tc.token, got, ok, err, tc.want, tc.wantOK, tc.wantErr)
+		}
+	}
+}
+
+func TestParseInvocationWithUnits(t *testing.T) {
+	t.Parallel()
+
+	units := map[string]time.Duration{"pomo": 25 * time.Minute}
+	got, err := parseInvocationWithUnits(cliArgs("-q", "2pomo"), units)
+	if want := (invocation{mode: modeRun, quiet: true, duration: 50 * time.Minute}); err != nil || got != want {
+		t.Fatalf("parseInvocationWithUnits() = %+v, %v; want %+v, nil", got, err, want)
+	}
+	if _, err := parseInvocation(cliArgs("2pomo")); !errors.Is(err, errInvalidDuration) {
+		t.Fatalf("parseInvocation(2pomo) error = %v, want %v", err, errInvalidDuration)
+	}
+}
+
+func TestBuildConfigUnits(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[units]\npomo = 25m\nep = 42m\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil {
+		t.Fatalf("buildConfig() error = %v", err)
+	}
+	want := map[string]time.Duration{"pomo": 25 * time.Minute, "ep": 42 * time.Minute}
+	if !reflect.DeepEqual(cfg.units, want) {
+		t.Fatalf("units = %v, want %v", cfg.units, want)
+	}
+
+	for _, bad := range []string{"[units]\nm = 2m\n", "[units]\npomo = soon\n", "[units]\npomo2 = 25m\n", "[units]\npomo = 0s\n"} {
+		sections, err := parseConfigSections(strings.NewReader(bad))
+		if err != nil {
+			t.Fatalf("parseConfigSections(%q) error = %v", bad, err)
+		}
+		if _, err := buildConfig(sections); err == nil {
+			t.Fatalf("buildConfig(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestParseTimerInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []timerInput
+	}{
+		{name: "q cancels", input: "q", want: []timerInput{timerInputCancel}},
+		{name: "lone escape cancels", input: "\x1b", want: []timerInput{timerInputCancel}},
+		{name: "ctrl+c cancels", input: "\x03", want: []timerInput{timerInputCancel}},
+		{name: "focus in", input: "\x1b[I", want: []timerInput{timerInputFocusIn}},
+		{name: "focus out then in", input: "\x1b[O\x1b[I", want: []timerInput{timerInputFocusOut, timerInputFocusIn}},
+		{name: "focus then cancel", input: "\x1b[Iq", want: []timerInput{timerInputFocusIn, timerInputCancel}},
+		{name: "other escape sequences still cancel", input: "\x1b[A", want: []timerInput{timerInputCancel}},
+		{name: "ordinary keys are ignored", input: "xyz", want: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := parseTimerInput([]byte(tc.input)); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("parseTimerInput(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShouldTrackFocus(t *testing.T) {
+	t.Parallel()
+
+	advanced := statusDisplay{interactive: true, supportsAdvanced: true}
+	tests := []struct {
+		name   string
+		inv    invocation
+		status statusDisplay
+		want   bool
+	}{
+		{name: "realert on capable terminal", inv: invocation{realert: true}, status: advanced, want: true},
+		{name: "off without flag", status: advanced, want: false},
+		{name: "off when quiet", inv: invocation{realert: true, quiet: true}, status: advanced, want: false},
+		{name: "off without escape support", inv: invocation{realert: true}, status: statusDisplay{interactive: true}, want: false},
+	}
+	for _, tc := range tests {
+		if got := shouldTrackFocus(tc.inv, tc.status); got != tc.want {
+			t.Fatalf("%s: shouldTrackFocus() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWaitForFocus(t *testing.T) {
+	t.Parallel()
+
+	focusC := make(chan bool, 2)
+	focusC <- false
+	focusC <- true
+	if !waitForFocus(context.Background(), focusC, nil) {
+		t.Fatal("waitForFocus() = false, want true after focus in")
+	}
+
+	keyC := make(chan struct{}, 1)
+	keyC <- struct{}{}
+	if waitForFocus(context.Background(), nil, keyC) {
+		t.Fatal("waitForFocus() = true, want false after cancel key")
+	}
+}
+
+func TestFormatRealert(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 3, 1, 15, 4, 0, 0, time.Local)
+	if got, want := formatRealert("tea", "", at), "after complete: tea at 15:04 (while you were away)"; got != want {
+		t.Fatalf("formatRealert() = %q, want %q", got, want)
+	}
+}
+
+func TestDNSMessageRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	service := mdnsService{
+		instance: "tea on kitchen (4242)." + mdnsServiceType,
+		host:     "kitchen.local.",
+		port:     8123,
+		ips:      []net.IP{net.IPv4(192, 168, 1, 20).To4()},
+		txt:      []string{"path=/status", "id=4242"},
+	}
+	msg := dnsMessage{id: 7, response: true, questions: []dnsQuestion{{name: mdnsServiceType, qtype: dnsTypePTR}}, records: service.records(mdnsTTL)}
+
+	got, err := parseDNSMessage(msg.pack())
+	if err != nil {
+		t.Fatalf("parseDNSMessage() error = %v", err)
+	}
+	if got.id != 7 || !got.response || !reflect.DeepEqual(got.questions, msg.questions) {
+		t.Fatalf("parseDNSMessage() header = %+v, want id 7 response with %+v", got, msg.questions)
+	}
+	services := servicesFromRecords(got.records)
+	if len(services) != 1 {
+		t.Fatalf("servicesFromRecords() = %+v, want one service", services)
+	}
+	if s := services[0]; s.instance != service.instance || s.host != service.host || s.port != 8123 || !s.ips[0].Equal(service.ips[0]) || !reflect.DeepEqual(s.txt, service.txt) {
+		t.Fatalf("servicesFromRecords() = %+v, want %+v", s, service)
+	}
+	if url := sharedTimerURL(services[0]); url != "http://192.168.1.20:8123/status" {
+		t.Fatalf("sharedTimerURL() = %q", url)
+	}
+}
+
+func TestReadDNSNameCompression(t *testing.T) {
+	t.Parallel()
+
+	// "local." at offset 0, then "kitchen" + pointer to offset 0.
+	msg := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 7, 'k', 'i', 't', 'c', 'h', 'e', 'n', 0xC0, 0}
+	name, next, err := readDNSName(msg, 7)
+	if err != nil || name != "kitchen.local." || next != len(msg) {
+		t.Fatalf("readDNSName() = %q, %d, %v; want kitchen.local., %d, nil", name, next, err, len(msg))
+	}
+	if _, _, err := readDNSName([]byte{0xC0, 0}, 0); err == nil {
+		t.Fatal("readDNSName() on a pointer loop error = nil, want error")
+	}
+}
+
+func TestServicesFromRecordsGoodbye(t *testing.T) {
+	t.Parallel()
+
+	service := mdnsService{instance: "x." + mdnsServiceType, host: "h.local.", port: 1, ips: []net.IP{net.IPv4(10, 0, 0, 1)}}
+	records := append(service.records(mdnsTTL), service.records(0)...)
+	if got := servicesFromRecords(records); len(got) != 0 {
+		t.Fatalf("servicesFromRecords() = %+v, want none after goodbye", got)
+	}
+}
+
+func TestMDNSServiceAnswers(t *testing.T) {
+	t.Parallel()
+
+	service := mdnsService{instance: "tea." + mdnsServiceType, host: "kitchen.local."}
+	tests := []struct {
+		q    dnsQuestion
+		want bool
+	}{
+		{q: dnsQuestion{name: mdnsServiceType, qtype: dnsTypePTR}, want: true},
+		{q: dnsQuestion{name: "_AFTER._tcp.local.", qtype: dnsTypeANY}, want: true},
+		{q: dnsQuestion{name: "tea." + mdnsServiceType, qtype: dnsTypeSRV}, want: true},
+		{q: dnsQuestion{name: "kitchen.local.", qtype: dnsTypeA}, want: true},
+		{q: dnsQuestion{name: "_http._tcp.local.", qtype: dnsTypePTR}, want: false},
+		{q: dnsQuestion{name: mdnsServiceType, qtype: dnsTypeA}, want: false},
+	}
+	for _, tc := range tests {
+		if got := service.answers(tc.q); got != tc.want {
+			t.Fatalf("answers(%+v) = %v, want %v", tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestShareInstanceName(t *testing.T) {
+	t.Parallel()
+
+	if got, want := shareInstanceName("", "kitchen", 42), "timer on kitchen (42)"; got != want {
+		t.Fatalf("shareInstanceName() = %q, want %q", got, want)
+	}
+	if got, want := shareInstanceName("v1.2 build", "kitchen", 42), "v1-2 build on kitchen (42)"; got != want {
+		t.Fatalf("shareInstanceName() = %q, want %q", got, want)
+	}
+	// 21 three-byte characters fill the 63 bytes exactly; one more byte
+	// in front leaves room for only 20 of them.
+	long := strings.Repeat("時", 30)
+	if got, want := mdnsSafeLabel(long), strings.Repeat("時", 21); got != want {
+		t.Fatalf("mdnsSafeLabel(long) = %q, want %q", got, want)
+	}
+	if got := mdnsSafeLabel("x" + long); !utf8.ValidString(got) || got != "x"+strings.Repeat("時", 20) {
+		t.Fatalf("mdnsSafeLabel(x + long) = %q, want it cut between characters", got)
+	}
+}
+
+func TestMatchSharedTimer(t *testing.T) {
+	t.Parallel()
+
+	timers := []sharedTimer{{name: "tea on kitchen (1)"}, {name: "tea on shed (2)"}, {name: "bread on kitchen (3)"}}
+	if got, err := matchSharedTimer(timers, "bread"); err != nil || got.name != "bread on kitchen (3)" {
+		t.Fatalf("matchSharedTimer(bread) = %+v, %v", got, err)
+	}
+	if _, err := matchSharedTimer(timers, "tea"); err == nil {
+		t.Fatal("matchSharedTimer(tea) error = nil, want ambiguity error")
+	}
+	if _, err := matchSharedTimer(timers, "pizza"); err == nil {
+		t.Fatal("matchSharedTimer(pizza) error = nil, want no match error")
+	}
+}
+
+func TestFetchSharedStatus(t *testing.T) {
+	t.Parallel()
+
+	want := controlState{ID: 42, Label: "tea", State: timerStateRunning, Remaining: 90, Total: 240}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(want)
+	}))
+	defer server.Close()
+
+	got, err := fetchSharedStatus(server.Client(), server.URL+shareStatusPath)
+	if err != nil || got != want {
+		t.Fatalf("fetchSharedStatus() = %+v, %v; want %+v, nil", got, err, want)
+	}
+}
+
+func TestParseColonDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		token   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{token: "1:30", want: 90 * time.Second},
+		{token: "0:05", want: 5 * time.Second},
+		{token: "90:00", want: 90 * time.Minute},
+		{token: "01:15:00", want: 75 * time.Minute},
+		{token: "25:00:00", want: 25 * time.Hour},
+		{token: "1:60", wantErr: true},
+		{token: "1:5", wantErr: true},
+		{token: "1:00:75", wantErr: true},
+		{token: ":30", wantErr: true},
+		{token: "1:2:3:4", wantErr: true},
+		{token: "a:30", wantErr: true},
+		{token: "-1:30", wantErr: true},
+		{token: "2562048:00:00", wantErr: true},
+		{token: "153722868:00", wantErr: true},
+		{token: "2562047:47:17", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseColonDuration(tc.token)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Fatalf("parseColonDuration(%q) = %v, %v; want %v, wantErr %v", tc.token, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestParseInvocation_AtSetsWallClockTarget(t *testing.T) {
+	t.Parallel()
+
+	inv, err := parseInvocation(cliArgs("--at", "noon"))
+	if err != nil {
+		t.Fatalf("parseInvocation(--at noon) error = %v", err)
+	}
+	if inv.wallClockTarget.IsZero() || inv.wallClockTarget.Hour() != 12 || inv.wallClockTarget.Minute() != 0 {
+		t.Fatalf("parseInvocation(--at noon) wallClockTarget = %v, want next 12:00", inv.wallClockTarget)
+	}
+	if got := time.Until(inv.wallClockTarget); got <= 0 || got > 24*time.Hour {
+		t.Fatalf("parseInvocation(--at noon) target %v is not within the next day", inv.wallClockTarget)
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	t.Parallel()
+
+	berlin := time.FixedZone("CET", 3600)
+	tests := []struct {
+		token string
+		want  time.Time
+	}{
+		{token: "2025-12-31 09:00", want: time.Date(2025, 12, 31, 9, 0, 0, 0, berlin)},
+		{token: "2025-12-31T09:00:30", want: time.Date(2025, 12, 31, 9, 0, 30, 0, berlin)},
+		{token: "2025-12-31", want: time.Date(2025, 12, 31, 0, 0, 0, 0, berlin)},
+		{token: "2025-12-31T09:00:00Z", want: time.Date(2025, 12, 31, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		got, err := parseDateTime(tc.token, berlin)
+		if err != nil || !got.Equal(tc.want) {
+			t.Fatalf("parseDateTime(%q) = %v, %v; want %v", tc.token, got, err, tc.want)
+		}
+	}
+	if _, err := parseDateTime("31/12/2025", berlin); !errors.Is(err, errInvalidTime) {
+		t.Fatalf("parseDateTime(31/12/2025) error = %v, want %v", err, errInvalidTime)
+	}
+}
+
+func TestParseInvocation_TimeZoneTargets(t *testing.T) {
+	t.Parallel()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("LoadLocation(Asia/Tokyo) error = %v", err)
+	}
+
+	inv, err := parseInvocation(cliArgs("--at", "09:00", "--tz", "Asia/Tokyo"))
+	if err != nil {
+		t.Fatalf("parseInvocation(--at 09:00 --tz Asia/Tokyo) error = %v", err)
+	}
+	if got := inv.wallClockTarget.In(tokyo); got.Hour() != 9 || got.Minute() != 0 {
+		t.Fatalf("target in Tokyo = %v, want 09:00", got)
+	}
+	if inv.wallClockTarget.Location() != time.Local {
+		t.Fatalf("target location = %v, want Local", inv.wallClockTarget.Location())
+	}
+
+	inv, err = parseInvocation(cliArgs("--until", "2999-01-01 09:00", "--tz", "Asia/Tokyo"))
+	if err != nil {
+		t.Fatalf("parseInvocation(--until --tz) error = %v", err)
+	}
+	if want := time.Date(2999, 1, 1, 9, 0, 0, 0, tokyo); !inv.wallClockTarget.Equal(want) {
+		t.Fatalf("--until target = %v, want %v", inv.wallClockTarget, want)
+	}
+}
+
+func TestNormalizeDecimalComma(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"1,5h":     "1.5h",
+		"1,5h2,5m": "1.5h2.5m",
+		"5m":       "5m",
+		",5":       ",5",
+		"5,":       "5,",
+		"1,,5":     "1,,5",
+	}
+	for in, want := range tests {
+		if got := normalizeDecimalComma(in); got != want {
+			t.Fatalf("normalizeDecimalComma(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseInvocation_Batch(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{cliArgs("--stdin"), cliArgs("-q", "-"), cliArgs("-", "--ics", "-")} {
+		inv, err := parseInvocation(args)
+		if err != nil || !inv.batch {
+			t.Fatalf("parseInvocation(%q) = %+v, %v; want batch", args, inv, err)
+		}
+	}
+	for _, args := range [][]string{cliArgs("--stdin", "5m"), cliArgs("-", "--at", "noon"), cliArgs("--stdin", "--tz", "UTC")} {
+		if _, err := parseInvocation(args); !errors.Is(err, errUsage) {
+			t.Fatalf("parseInvocation(%q) error = %v, want %v", args, err, errUsage)
+		}
+	}
+	for _, tc := range []struct {
+		args []string
+		flag string
+	}{
+		{args: cliArgs("--stdin", "--result-file", "out.json"), flag: "--result-file"},
+		{args: cliArgs("-", "--ics", "timer.ics"), flag: "--ics"},
+	} {
+		want := flagConflictError{flag: tc.flag, with: "--stdin"}
+		if _, err := parseInvocation(tc.args); err != want {
+			t.Fatalf("parseInvocation(%q) error = %v, want %v", tc.args, err, want)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	t.Parallel()
+
+	input := "25m focus block\n\n# a break\n5m break\n2pomo\n9 pm dinner\n"
+	entries, err := parseBatch(strings.NewReader(input), map[string]time.Duration{"pomo": 25 * time.Minute})
+	if err != nil {
+		t.Fatalf("parseBatch() error = %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("parseBatch() returned %d entries, want 4", len(entries))
+	}
+	want := []batchEntry{
+		{duration: 25 * time.Minute, label: "focus block"},
+		{duration: 5 * time.Minute, label: "break"},
+		{duration: 50 * time.Minute},
+	}
+	for i, w := range want {
+		if entries[i] != w {
+			t.Fatalf("entry %d = %+v, want %+v", i, entries[i], w)
+		}
+	}
+	if got := entries[3]; got.label != "dinner" || got.target.Hour() != 21 {
+		t.Fatalf("entry 3 = %+v, want dinner at 21:00", got)
+	}
+
+	// An AM/PM word after a duration starts the label instead.
+	entries, err = parseBatch(strings.NewReader("30m PM sync\n1s a\n1s pm check\n"), nil)
+	if err != nil {
+		t.Fatalf("parseBatch(AM/PM word after a duration) error = %v", err)
+	}
+	want = []batchEntry{
+		{duration: 30 * time.Minute, label: "PM sync"},
+		{duration: time.Second, label: "a"},
+		{duration: time.Second, label: "pm check"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("parseBatch(AM/PM word after a duration) = %+v, want %+v", entries, want)
+	}
+
+	_, err = parseBatch(strings.NewReader("5m\n\nsoon tea\n"), nil)
+	if !errors.Is(err, errInvalidDuration) || !strings.HasPrefix(err.Error(), "stdin:3: ") {
+		t.Fatalf("parseBatch(bad line) error = %v, want stdin:3: %v", err, errInvalidDuration)
+	}
+	if _, err := parseBatch(strings.NewReader("# nothing\n"), nil); !errors.Is(err, errEmptyBatch) {
+		t.Fatalf("parseBatch(empty) error = %v, want %v", err, errEmptyBatch)
+	}
+}
+
+func TestFormatBatchEntryStart(t *testing.T) {
+	t.Parallel()
+
+	if got, want := formatBatchEntryStart(2, 3, batchEntry{duration: 5 * time.Minute, label: "break"}), "after: [2/3] break (5m0s)"; got != want {
+		t.Fatalf("formatBatchEntryStart() = %q, want %q", got, want)
+	}
+	if got, want := formatBatchEntryStart(1, 3, batchEntry{duration: time.Minute}), "after: [1/3] (1m0s)"; got != want {
+		t.Fatalf("formatBatchEntryStart() = %q, want %q", got, want)
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 14, 7, 30, 0, time.UTC)
+	tests := []struct {
+		interval time.Duration
+		want     time.Time
+	}{
+		{interval: 15 * time.Minute, want: time.Date(2025, 3, 10, 14, 15, 0, 0, time.UTC)},
+		{interval: time.Hour, want: time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{interval: 7 * time.Hour, want: time.Date(2025, 3, 10, 21, 0, 0, 0, time.UTC)},
+		{interval: 24 * time.Hour, want: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		if got := nextBoundary(now, tc.interval); !got.Equal(tc.want) {
+			t.Fatalf("nextBoundary(%v) = %v, want %v", tc.interval, got, tc.want)
+		}
+	}
+	if got, want := nextBoundary(time.Date(2025, 3, 10, 14, 15, 0, 0, time.UTC), 15*time.Minute), time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("nextBoundary(on boundary) = %v, want %v", got, want)
+	}
+}
+
+func TestParseInvocation_Round(t *testing.T) {
+	t.Parallel()
+
+	inv, err := parseInvocation(cliArgs("--round", "15m"))
+	if err != nil {
+		t.Fatalf("parseInvocation(--round 15m) error = %v", err)
+	}
+	if inv.wallClockTarget.Minute()%15 != 0 || inv.wallClockTarget.Second() != 0 || inv.duration <= 0 || inv.duration > 15*time.Minute {
+		t.Fatalf("parseInvocation(--round 15m) = %+v, want next quarter hour", inv)
+	}
+	if _, err := parseInvocation(cliArgs("--round", "15m", "5m")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--round 15m 5m) error = %v, want %v", err, errUsage)
+	}
+	var flagErr invalidFlagValueError
+	if _, err := parseInvocation(cliArgs("--round", "48h")); !errors.As(err, &flagErr) {
+		t.Fatalf("parseInvocation(--round 48h) error = %v, want invalidFlagValueError", err)
+	}
+}
+
+func TestParseDurationRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		token   string
+		lo, hi  time.Duration
+		wantOK  bool
+		wantErr error
+	}{
+		{token: "5m-10m", lo: 5 * time.Minute, hi: 10 * time.Minute, wantOK: true},
+		{token: "30-90", lo: 30 * time.Second, hi: 90 * time.Second, wantOK: true},
+		{token: "1h-1h30m", lo: time.Hour, hi: 90 * time.Minute, wantOK: true},
+		{token: "10m-5m", wantOK: true, wantErr: errInvalidDuration},
+		{token: "5m-5m", wantOK: true, wantErr: errInvalidDuration},
+		{token: "5m-soon", wantOK: true, wantErr: errInvalidDuration},
+		{token: "-5m", wantOK: false},
+		{token: "5m-", wantOK: false},
+		{token: "5m", wantOK: false},
+	}
+	for _, tc := range tests {
+		lo, hi, ok, err := parseDurationRange(tc.token)
+		if ok != tc.wantOK || !errors.Is(err, tc.wantErr) || (err == nil && (lo != tc.lo || hi != tc.hi)) {
+			t.Fatalf("parseDurationRange(%q) = %v, %v, %v, %v; want %v, %v, %v, %v", tc.token, lo, hi, ok, err, tc.lo, tc.hi, tc.wantOK, tc.wantErr)
+		}
+	}
+}
+
+func TestPickDuration(t *testing.T) {
+	t.Parallel()
+
+	lowest := func(int64) int64 { return 0 }
+	highest := func(n int64) int64 { return n - 1 }
+	if got := pickDuration(5*time.Minute, 10*time.Minute, lowest); got != 5*time.Minute {
+		t.Fatalf("pickDuration(lowest) = %v, want 5m0s", got)
+	}
+	if got := pickDuration(5*time.Minute, 10*time.Minute, highest); got != 10*time.Minute {
+		t.Fatalf("pickDuration(highest) = %v, want 10m0s", got)
+	}
+	if got := pickDuration(1500*time.Millisecond, 3500*time.Millisecond, highest); got != 3*time.Second {
+		t.Fatalf("pickDuration(fractional bounds) = %v, want 3s", got)
+	}
+	if got := pickDuration(100*time.Millisecond, 200*time.Millisecond, highest); got != 200*time.Millisecond {
+		t.Fatalf("pickDuration(sub-second range) = %v, want 200ms", got)
+	}
+}
+
+func TestParseInvocation_DurationRange(t *testing.T) {
+	t.Parallel()
+
+	inv, err := parseInvocation(cliArgs("5m-10m"))
+	if err != nil {
+		t.Fatalf("parseInvocation(5m-10m) error = %v", err)
+	}
+	if inv.durationRange != "5m-10m" || inv.duration < 5*time.Minute || inv.duration > 10*time.Minute || inv.duration%time.Second != 0 {
+		t.Fatalf("parseInvocation(5m-10m) = %+v, want whole seconds in [5m, 10m]", inv)
+	}
+	if got, want := formatRandomPick("5m-10m", 7*time.Minute+12*time.Second), "after: picked 7m12s from 5m-10m"; got != want {
+		t.Fatalf("formatRandomPick() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatPercentComplete(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remaining, total time.Duration
+		want             string
+	}{
+		{remaining: 10 * time.Minute, total: 10 * time.Minute, want: "0%"},
+		{remaining: 5*time.Minute + 48*time.Second, total: 10 * time.Minute, want: "42%"},
+		{remaining: time.Millisecond, total: 10 * time.Minute, want: "99%"},
+		{remaining: 0, total: 10 * time.Minute, want: "100%"},
+		{remaining: -time.Second, total: 10 * time.Minute, want: "100%"},
+		{remaining: 20 * time.Minute, total: 10 * time.Minute, want: "0%"},
+		{remaining: 0, total: 0, want: "100%"},
+	}
+	for _, tc := range tests {
+		if got := formatPercentComplete(tc.remaining, tc.total); got != tc.want {
+			t.Fatalf("formatPercentComplete(%v, %v) = %q, want %q", tc.remaining, tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestRunTimerWithAlarmStarter_InteractivePercent(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, false)
+
+	inv := invocation{duration: 50 * time.Millisecond, showPercent: true, noTitle: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	// The first frame may land a few milliseconds in on a loaded machine.
+	if !regexp.MustCompile(`\r0\.1 \d+%`).MatchString(out.String()) {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want countdown with percent", out.String())
+	}
+}
+
+func TestRunTimerWithAlarmStarter_Spinner(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, true)
+
+	inv := invocation{duration: 250 * time.Millisecond, spinner: true, noTitle: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	for _, frame := range asciiSpinnerFrames[:2] {
+		if !strings.Contains(out.String(), " "+frame+"\r") {
+			t.Fatalf("runTimerWithAlarmStarter() output = %q, want spinner frame %q", out.String(), frame)
+		}
+	}
+}
+
+func TestSpinnerFrame(t *testing.T) {
+	t.Parallel()
+
+	if got := iconSets[iconsEmoji].spinnerFrame(11); got != "⠙" {
+		t.Errorf("spinnerFrame(emoji, 11) = %q, want ⠙", got)
+	}
+	if got := (iconSet{}).spinnerFrame(3); got != "\\" {
+		t.Errorf("spinnerFrame(ascii, 3) = %q, want \\\\", got)
+	}
+	if inv, err := parseInvocation(cliArgs("--spinner", "5m")); err != nil || !inv.spinner {
+		t.Fatalf("parseInvocation(--spinner) = %+v, %v; want spinner", inv, err)
+	}
+}
+
+func TestFormatEndsAt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 14, 7, 30, 0, time.UTC)
+	if got, want := formatEndsAt(now.Add(25*time.Minute), now), "(ends 14:32)"; got != want {
+		t.Fatalf("formatEndsAt(today) = %q, want %q", got, want)
+	}
+	if got, want := formatEndsAt(now.Add(12*time.Hour), now), "(ends Mar 11 02:07)"; got != want {
+		t.Fatalf("formatEndsAt(tomorrow) = %q, want %q", got, want)
+	}
+}
+
+func TestFormatLifecycleStartedWithEnd(t *testing.T) {
+	t.Parallel()
+
+	end := time.Date(2025, 3, 10, 14, 32, 10, 0, time.UTC)
+	if got, want := formatLifecycleStartedWithEnd(25*time.Minute, end), "after: started (25m0s, ends 14:32:10)"; got != want {
+		t.Fatalf("formatLifecycleStartedWithEnd() = %q, want %q", got, want)
+	}
+	if got, want := formatLifecycleStartedWithEnd(48*time.Hour, end), "after: started (48h0m0s, ends 2025-03-10 14:32:10)"; got != want {
+		t.Fatalf("formatLifecycleStartedWithEnd(48h) = %q, want %q", got, want)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_NonTTYShowEnd(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(false, false)
+
+	inv := invocation{duration: 10 * time.Millisecond, showEnd: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	if !strings.HasPrefix(out.String(), "after: started (10ms, ends ") {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want start line with end time", out.String())
+	}
+}
+
+func TestFormatCountdownTime(t *testing.T) {
+	t.Parallel()
+
+	elapsed := 12*time.Minute + 4*time.Second + 300*time.Millisecond
+	remaining := 17*time.Minute + 55*time.Second + 700*time.Millisecond
+	tests := []struct {
+		mode displayMode
+		want string
+	}{
+		{mode: displayRemaining, want: "17:56"},
+		{mode: displayElapsed, want: "12:04"},
+		{mode: displayBoth, want: "+12:04 -17:56"},
+	}
+	for _, tc := range tests {
+		if got := formatCountdownTime(tc.mode, elapsed, remaining); got != tc.want {
+			t.Fatalf("formatCountdownTime(%v) = %q, want %q", tc.mode, got, tc.want)
+		}
+	}
+	if got, want := formatElapsedTime(time.Hour+2*time.Minute+3*time.Second), "1:02:03"; got != want {
+		t.Fatalf("formatElapsedTime() = %q, want %q", got, want)
+	}
+	if got, want := formatElapsedTime(-time.Second), "0"; got != want {
+		t.Fatalf("formatElapsedTime(negative) = %q, want %q", got, want)
+	}
+}
+
+func TestParseInvocation_DisplayMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want displayMode
+	}{
+		{args: cliArgs("5m"), want: displayRemaining},
+		{args: cliArgs("--elapsed", "5m"), want: displayElapsed},
+		{args: cliArgs("--display", "both", "5m"), want: displayBoth},
+		{args: cliArgs("--elapsed", "--display", "remaining", "5m"), want: displayRemaining},
+	}
+	for _, tc := range tests {
+		inv, err := parseInvocation(tc.args)
+		if err != nil || inv.display != tc.want {
+			t.Fatalf("parseInvocation(%q) display = %v, %v; want %v", tc.args, inv.display, err, tc.want)
+		}
+	}
+	var flagErr invalidFlagValueError
+	if _, err := parseInvocation(cliArgs("--display", "sideways", "5m")); !errors.As(err, &flagErr) {
+		t.Fatalf("parseInvocation(--display sideways) error = %v, want invalidFlagValueError", err)
+	}
+}
+
+func TestFormatFinalStretchTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remaining time.Duration
+		want      string
+	}{
+		{remaining: 3*time.Second + 400*time.Millisecond, want: "3.4"},
+		{remaining: 3*time.Second + 310*time.Millisecond, want: "3.4"},
+		{remaining: time.Millisecond, want: "0.1"},
+		{remaining: 9*time.Second + 950*time.Millisecond, want: "10"},
+		{remaining: 10 * time.Second, want: "10"},
+		{remaining: 83 * time.Second, want: "1:23"},
+		{remaining: 0, want: "0"},
+	}
+	for _, tc := range tests {
+		if got := formatFinalStretchTime(tc.remaining); got != tc.want {
+			t.Fatalf("formatFinalStretchTime(%v) = %q, want %q", tc.remaining, got, tc.want)
+		}
+	}
+}
+
+func TestCountdownTickInterval(t *testing.T) {
+	t.Parallel()
+
+	if got := countdownTickInterval(time.Minute, false, 0); got != 500*time.Millisecond {
+		t.Fatalf("countdownTickInterval(1m) = %v, want 500ms", got)
+	}
+	if got := countdownTickInterval(5*time.Second, false, 0); got != 100*time.Millisecond {
+		t.Fatalf("countdownTickInterval(5s) = %v, want 100ms", got)
+	}
+	if got := countdownTickInterval(5*time.Second, true, 0); got != 500*time.Millisecond {
+		t.Fatalf("countdownTickInterval(5s, paused) = %v, want 500ms", got)
+	}
+	if got := countdownTickInterval(5*time.Second, false, 2*time.Second); got != 2*time.Second {
+		t.Fatalf("countdownTickInterval(5s, refresh 2s) = %v, want 2s", got)
+	}
+}
+
+func TestParseInvocation_Refresh(t *testing.T) {
+	t.Parallel()
+
+	inv, err := parseInvocation(cliArgs("--refresh", "2s", "5m"))
+	if err != nil || inv.refresh != 2*time.Second {
+		t.Fatalf("parseInvocation(--refresh 2s) = %+v, %v; want refresh 2s", inv, err)
+	}
+	var flagErr invalidFlagValueError
+	for _, value := range []string{"1ms", "0", "fast"} {
+		if _, err := parseInvocation(cliArgs("--refresh", value, "5m")); !errors.As(err, &flagErr) {
+			t.Fatalf("parseInvocation(--refresh %s) error = %v, want invalidFlagValueError", value, err)
+		}
+	}
+}
+
+func TestCountdownColor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remaining time.Duration
+		th        colorThresholds
+		want      string
+	}{
+		{remaining: 5 * time.Minute, want: "32"},
+		{remaining: time.Minute, want: "33"},
+		{remaining: 30 * time.Second, want: "33"},
+		{remaining: 10 * time.Second, want: "31"},
+		{remaining: 4 * time.Minute, th: colorThresholds{yellow: 5 * time.Minute, red: 30 * time.Second}, want: "33"},
+	}
+	for _, tc := range tests {
+		if got := countdownColor(tc.remaining, tc.th); got != tc.want {
+			t.Fatalf("countdownColor(%v, %+v) = %q, want %q", tc.remaining, tc.th, got, tc.want)
+		}
+	}
+}
+
+func TestParseColorThresholds(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseColorThresholds("5m,30s")
+	if want := (colorThresholds{yellow: 5 * time.Minute, red: 30 * time.Second}); err != nil || got != want {
+		t.Fatalf("parseColorThresholds(5m,30s) = %+v, %v; want %+v", got, err, want)
+	}
+	for _, value := range []string{"5m", "10s,1m", "5m,soon"} {
+		var flagErr invalidFlagValueError
+		if _, err := parseColorThresholds(value); !errors.As(err, &flagErr) {
+			t.Fatalf("parseColorThresholds(%q) error = %v, want invalidFlagValueError", value, err)
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Parallel()
+
+	ansi := statusDisplay{interactive: true, supportsAdvanced: true}
+	noEnv := func(string) string { return "" }
+	noColor := func(key string) string {
+		if key == "NO_COLOR" {
+			return "1"
+		}
+		return ""
+	}
+	tests := []struct {
+		name   string
+		mode   colorMode
+		status statusDisplay
+		getenv func(string) string
+		want   bool
+	}{
+		{name: "auto on ansi terminal", mode: colorAuto, status: ansi, getenv: noEnv, want: true},
+		{name: "auto honors NO_COLOR", mode: colorAuto, status: ansi, getenv: noColor, want: false},
+		{name: "auto on plain terminal", mode: colorAuto, status: statusDisplay{interactive: true}, getenv: noEnv, want: false},
+		{name: "always overrides NO_COLOR", mode: colorAlways, status: ansi, getenv: noColor, want: true},
+		{name: "always needs a countdown", mode: colorAlways, status: statusDisplay{}, getenv: noEnv, want: false},
+		{name: "never", mode: colorNever, status: ansi, getenv: noEnv, want: false},
+		{name: "auto on monochrome terminal", mode: colorAuto, status: statusDisplay{interactive: true, supportsAdvanced: true, monochrome: true}, getenv: noEnv, want: false},
+	}
+	for _, tc := range tests {
+		if got := colorEnabled(tc.mode, tc.status, tc.getenv); got != tc.want {
+			t.Fatalf("%s: colorEnabled() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseInvocation_Color(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{cliArgs("--color", "never", "5m"), cliArgs("--color=never", "5m")} {
+		inv, err := parseInvocation(args)
+		if err != nil || inv.color != colorNever {
+			t.Fatalf("parseInvocation(%q) color = %v, %v; want never", args, inv.color, err)
+		}
+	}
+	var flagErr invalidFlagValueError
+	if _, err := parseInvocation(cliArgs("--color=rainbow", "5m")); !errors.As(err, &flagErr) {
+		t.Fatalf("parseInvocation(--color=rainbow) error = %v, want invalidFlagValueError", err)
+	}
+}
+
+func TestRenderColoredCountdown(t *testing.T) {
+	t.Parallel()
+
+	out, status := newCapturedStatus(true, true)
+	renderColoredCountdown(status, "1:00", "1:00", "33", "", false)
+	if got, want := out.String(), "\033]0;1:00\007\r\033[K\033[33m1:00\033[0m"; got != want {
+		t.Fatalf("renderColoredCountdown() = %q, want %q", got, want)
+	}
+}
+
+func TestLookupTheme(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"default", "plain", "minimal", "bold", "solarized"} {
+		if _, err := lookupTheme(name); err != nil {
+			t.Fatalf("lookupTheme(%q) error = %v", name, err)
+		}
+	}
+	_, err := lookupTheme("neon")
+	if err == nil || !strings.Contains(err.Error(), "available: bold, default, minimal, plain, solarized") {
+		t.Fatalf("lookupTheme(neon) error = %v, want list of themes", err)
+	}
+	if got := resolveThemeName("", ""); got != defaultThemeName {
+		t.Fatalf("resolveThemeName() = %q, want %q", got, defaultThemeName)
+	}
+	if got := resolveThemeName("bold", "plain"); got != "bold" {
+		t.Fatalf("resolveThemeName(bold, plain) = %q, want bold", got)
+	}
+	if got := resolveThemeName("", "plain"); got != "plain" {
+		t.Fatalf("resolveThemeName(\"\", plain) = %q, want plain", got)
+	}
+}
+
+func TestThemeCountdown(t *testing.T) {
+	t.Parallel()
+
+	solarized := themes["solarized"]
+	if got, want := solarized.countdownLine("tea", "3:59", true), "tea · 3:59 (paused)"; got != want {
+		t.Fatalf("countdownLine() = %q, want %q", got, want)
+	}
+	if got, want := solarized.progressBar(6*time.Minute, 10*time.Minute), "■■■■□□□□□□"; got != want {
+		t.Fatalf("progressBar() = %q, want %q", got, want)
+	}
+	if got := themes["plain"].progressBar(time.Minute, 10*time.Minute); got != "" {
+		t.Fatalf("plain progressBar() = %q, want none", got)
+	}
+	bold := themes["bold"]
+	for _, tc := range []struct {
+		remaining time.Duration
+		want      string
+	}{
+		{remaining: 10 * time.Minute, want: "░░░░░░░░░░"},
+		{remaining: 5*time.Minute + 45*time.Second, want: "████▎░░░░░"},
+		{remaining: 3 * time.Second, want: "█████████▉"},
+		{remaining: 0, want: "██████████"},
+	} {
+		if got := bold.progressBar(tc.remaining, 10*time.Minute); got != tc.want {
+			t.Fatalf("bold progressBar(%v) = %q, want %q", tc.remaining, got, tc.want)
+		}
+	}
+	if got := themes["plain"].color(5*time.Second, colorThresholds{}); got != "" {
+		t.Fatalf("plain color() = %q, want none", got)
+	}
+	if got, want := themes["minimal"].color(5*time.Second, colorThresholds{}), "31"; got != want {
+		t.Fatalf("minimal color() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintCompleteThemedBanner(t *testing.T) {
+	t.Parallel()
+
+	out, status := newCapturedStatus(true, true)
+	bold := themes["bold"]
+	status.theme = &bold
+	status.color = true
+	printComplete(status, false, "", "", "")
+	if got, want := out.String(), "\r\033[K\033[1mafter complete\033[0m\n"; got != want {
+		t.Fatalf("printComplete() = %q, want %q", got, want)
+	}
+}
+
+func TestParseInvocation_Parallel(t *testing.T) {
+	t.Parallel()
+
+	if inv, err := parseInvocation(cliArgs("--stdin", "--parallel")); err != nil || !inv.parallel || !inv.batch {
+		t.Fatalf("parseInvocation(--stdin --parallel) = %+v, %v; want parallel batch", inv, err)
+	}
+	if _, err := parseInvocation(cliArgs("--parallel", "5m")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--parallel 5m) error = %v, want %v", err, errUsage)
+	}
+	for _, tc := range []struct {
+		args []string
+		flag string
+	}{
+		{args: cliArgs("-", "--parallel", "--exec", "true"), flag: "--exec"},
+		{args: cliArgs("-", "--parallel", "--json"), flag: "--json"},
+		{args: cliArgs("-", "--parallel", "--result-file", "out.json"), flag: "--result-file"},
+		{args: cliArgs("-", "--parallel", "--notify-cancel"), flag: "--notify-cancel"},
+		{args: cliArgs("-", "--parallel", "--realert"), flag: "--realert"},
+		{args: cliArgs("-", "--parallel", "--pause-on-suspend"), flag: "--pause-on-suspend"},
+		{args: cliArgs("-", "--parallel", "-l", "x"), flag: "--label"},
+		{args: cliArgs("-", "--parallel", "--title-only"), flag: "--title-only"},
+		{args: cliArgs("-", "--parallel", "--percent"), flag: "--percent"},
+		{args: cliArgs("-", "--parallel", "--show-end"), flag: "--show-end"},
+		{args: cliArgs("-", "--parallel", "--spinner"), flag: "--spinner"},
+		{args: cliArgs("-", "--parallel", "--elapsed"), flag: "--elapsed"},
+		{args: cliArgs("-", "--parallel", "--display", "both"), flag: "--display"},
+		{args: cliArgs("-", "--parallel", "--color", "always"), flag: "--color"},
+		{args: cliArgs("-", "--parallel", "--color-thresholds", "5m,30s"), flag: "--color-thresholds"},
+		{args: cliArgs("-", "--parallel", "--big"), flag: "--big"},
+		{args: cliArgs("-", "--parallel", "--fullscreen"), flag: "--fullscreen"},
+		{args: cliArgs("-", "--parallel", "--theme", "bold"), flag: "--theme"},
+		{args: cliArgs("-", "--parallel", "--style", "full"), flag: "--style"},
+		{args: cliArgs("-", "--parallel", "--icons", "emoji"), flag: "--icons"},
+	} {
+		var flagErr flagConflictError
+		if _, err := parseInvocation(tc.args); !errors.As(err, &flagErr) || flagErr.flag != tc.flag || flagErr.with != "--parallel" {
+			t.Fatalf("parseInvocation(%q) error = %v, want %s rejected", tc.args, err, tc.flag)
+		}
+	}
+	// Settings the plain block already matches are accepted.
+	for _, args := range [][]string{
+		cliArgs("-", "--parallel", "--color", "never"),
+		cliArgs("-", "--parallel", "--icons", "ascii"),
+		cliArgs("-", "--parallel", "--display", "remaining"),
+		cliArgs("-", "--parallel", "--no-title", "--refresh", "2s", "-q"),
+	} {
+		if _, err := parseInvocation(args); err != nil {
+			t.Fatalf("parseInvocation(%q) error = %v, want nil", args, err)
+		}
+	}
+}
+
+func TestFormatParallelBlock(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{"[1/2] tea: 3:59", "[2/2] 9:59"}
+	if got, want := formatParallelBlock(lines, 0, 80), "\r\033[K[1/2] tea: 3:59\r\n\033[K[2/2] 9:59"; got != want {
+		t.Fatalf("first formatParallelBlock() = %q, want %q", got, want)
+	}
+	if got, want := formatParallelBlock(lines, 2, 80), "\r\033[1A\033[K[1/2] tea: 3:59\r\n\033[K[2/2] 9:59"; got != want {
+		t.Fatalf("repaint formatParallelBlock() = %q, want %q", got, want)
+	}
+	if got, want := formatParallelBlock(lines[:1], 2, 8), "\r\033[1A\033[K[1/2] …\033[J"; got != want {
+		t.Fatalf("shrunk formatParallelBlock() = %q, want %q", got, want)
+	}
+}
+
+func TestFitParallelLines(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{"a", "b", "c", "d"}
+	if got := fitParallelLines(lines, 24); !reflect.DeepEqual(got, lines) {
+		t.Fatalf("fitParallelLines(24) = %q, want all lines", got)
+	}
+	if got, want := fitParallelLines(lines, 4), []string{"a", "b", "… and 2 more"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fitParallelLines(4) = %q, want %q", got, want)
+	}
+	if got, want := fitParallelLines(lines, 1), []string{"… 4 timers"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fitParallelLines(1) = %q, want %q", got, want)
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	t.Parallel()
+
+	entries := []batchEntry{
+		{duration: 60 * time.Millisecond, label: "tea"},
+		{duration: 20 * time.Millisecond},
+	}
+
+	t.Run("redirected", func(t *testing.T) {
+		t.Parallel()
+		out, status := newCapturedStatus(false, false)
+		alarms := 0
+		if err := runParallel(context.Background(), invocation{forceAlarm: true}, entries, status, false, func(alarmSound) { alarms++ }); err != nil {
+			t.Fatalf("runParallel() error = %v, want nil", err)
+		}
+		want := "after: [1/2] tea (60ms)\nafter: [2/2] (20ms)\nafter: [2/2] complete\nafter: [1/2] tea: complete\n"
+		if got := out.String(); got != want || alarms != 2 {
+			t.Fatalf("runParallel() output = %q with %d alarms, want %q with 2", got, alarms, want)
+		}
+	})
+
+	t.Run("cancelled on a terminal", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancelCause(context.Background())
+		out, status := newCapturedStatus(true, true)
+		time.AfterFunc(40*time.Millisecond, func() { cancel(signalCause{sig: os.Interrupt}) })
+		err := runParallel(ctx, invocation{}, entries, status, false, func(alarmSound) {})
+		var sc signalCause
+		if !errors.As(err, &sc) {
+			t.Fatalf("runParallel() error = %v, want signal cause", err)
+		}
+		if got, want := out.String(), "\r\033[1A\033[K[1/2] tea: cancelled\r\n\033[K[2/2] complete\n"; !strings.HasSuffix(got, want) {
+			t.Fatalf("runParallel() output = %q, want final block %q", got, want)
+		}
+	})
+}
+
+func TestCountdownStyleFormat(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 14, 0, 0, 0, time.Local)
+	part := countdownPart{
+		label:     "tea",
+		icon:      "⏳",
+		timeStr:   "2:00",
+		remaining: 2 * time.Minute,
+		total:     4 * time.Minute,
+		now:       now,
+	}
+	th := themes["default"]
+	tests := []struct {
+		name    string
+		style   string
+		inv     invocation
+		spinner string
+		want    string
+	}{
+		{name: "default", style: "default", want: "⏳ tea: 2:00"},
+		{name: "compact", style: "compact", want: "2:00"},
+		{name: "compact with --percent", style: "compact", inv: invocation{showPercent: true}, want: "2:00 50%"},
+		{name: "full", style: "full", want: "⏳ tea: 2:00 50% █████░░░░░ (ends 14:02)"},
+		{name: "default with --show-end", style: "default", inv: invocation{showEnd: true}, want: "⏳ tea: 2:00 (ends 14:02)"},
+		{name: "spinner", style: "full", spinner: "⠹", want: "⏳ tea: 2:00 ⠹ 50% █████░░░░░ (ends 14:02)"},
+	}
+	for _, tc := range tests {
+		st, err := lookupStyle(tc.style)
+		if err != nil {
+			t.Fatalf("lookupStyle(%q) error = %v", tc.style, err)
+		}
+		p := part
+		p.spinner = tc.spinner
+		if got := st.withFlags(tc.inv).format(th, p); got != tc.want {
+			t.Errorf("%s: format() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if _, err := lookupStyle("fancy"); err == nil {
+		t.Error("lookupStyle(fancy) error = nil, want unknown style")
+	}
+	if got := resolveStyleName("", "full"); got != "full" {
+		t.Errorf("resolveStyleName(\"\", full) = %q, want full", got)
+	}
+}
+
+func TestBuildConfigDisplayStyle(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[display]\nstyle = compact\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.style != "compact" {
+		t.Fatalf("buildConfig() style = %q, %v; want compact", cfg.style, err)
+	}
+
+	sections, err = parseConfigSections(strings.NewReader("[display]\nstyle = huge\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	var cfgErr configError
+	if _, err := buildConfig(sections); !errors.As(err, &cfgErr) || cfgErr.line != 2 {
+		t.Fatalf("buildConfig(unknown style) error = %v, want configError on line 2", err)
+	}
+}
+
+func TestBuildConfigDisplayTheme(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[display]\ntheme = solarized\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.theme != "solarized" {
+		t.Fatalf("buildConfig() theme = %q, %v; want solarized", cfg.theme, err)
+	}
+
+	sections, err = parseConfigSections(strings.NewReader("[display]\ntheme = neon\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	var cfgErr configError
+	if _, err := buildConfig(sections); !errors.As(err, &cfgErr) || cfgErr.line != 2 {
+		t.Fatalf("buildConfig(unknown theme) error = %v, want configError on line 2", err)
+	}
+}
+
+func TestParseInvocation_Label(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{cliArgs("--label", "tea", "4m"), cliArgs("-l", "tea", "4m"), cliArgs("-ql", "tea", "4m")} {
+		inv, err := parseInvocation(args)
+		if err != nil || inv.label != "tea" || inv.duration != 4*time.Minute {
+			t.Fatalf("parseInvocation(%q) = %+v, %v; want label tea for 4m", args, inv, err)
+		}
+	}
+	if _, err := parseInvocation(cliArgs("4m", "--label")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--label without value) error = %v, want %v", err, errUsage)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_NonTTYLabelledLifecycle(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(false, false)
+
+	inv := invocation{duration: 10 * time.Millisecond, label: "tea"}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	if got, want := out.String(), "after: tea: started (10ms)\nafter: tea: complete\n"; got != want {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want %q", got, want)
+	}
+}
+
+func TestPrintCancelledWithLabel(t *testing.T) {
+	t.Parallel()
+
+	out, status := newCapturedStatus(true, true)
+	printCancelled(status, false, "tea", "", "paused 3m0s")
+	if got, want := out.String(), "\r\033[Kafter cancelled: tea (paused 3m0s)\n"; got != want {
+		t.Fatalf("printCancelled() = %q, want %q", got, want)
+	}
+}
+
+func TestFinalStatusText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		outcome, label, message string
+		wantTTY, wantLog        string
+	}{
+		{outcome: "complete", wantTTY: "after complete", wantLog: "after: complete"},
+		{outcome: "complete", label: "tea", wantTTY: "after complete: tea", wantLog: "after: tea: complete"},
+		{outcome: "complete", message: "Pizza is done", wantTTY: "Pizza is done", wantLog: "after: Pizza is done"},
+		{outcome: "cancelled", label: "pizza", message: "No pizza", wantTTY: "No pizza", wantLog: "after: pizza: No pizza"},
+	}
+	for _, tc := range tests {
+		gotTTY, gotLog := finalStatusText(tc.outcome, tc.label, tc.message)
+		if gotTTY != tc.wantTTY || gotLog != tc.wantLog {
+			t.Fatalf("finalStatusText(%q, %q, %q) = %q, %q; want %q, %q", tc.outcome, tc.label, tc.message, gotTTY, gotLog, tc.wantTTY, tc.wantLog)
+		}
+	}
+}
+
+func TestCustomMessageReachesBackends(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	if got, want := formatBroadcastMessage("pizza", "Pizza is done", at), "\r\n\a*** after: Pizza is done at 15:04 ***\r\n"; got != want {
+		t.Fatalf("formatBroadcastMessage() = %q, want %q", got, want)
+	}
+	if got, want := formatRealert("pizza", "Pizza is done", at), "Pizza is done at 15:04 (while you were away)"; got != want {
+		t.Fatalf("formatRealert() = %q, want %q", got, want)
+	}
+
+	inv := invocation{duration: time.Minute, message: "Pizza is done", cancelMessage: "No pizza"}
+	complete := newTimerEvent(outcomeComplete, inv, at, at, at, nil)
+	if env := hookEnv(complete); env[len(env)-1] != "AFTER_MESSAGE=Pizza is done" {
+		t.Fatalf("hookEnv(complete) = %q, want AFTER_MESSAGE", env)
+	}
+	if got := newTimerResult(newTimerEvent(outcomeCancelled, inv, at, at, at, nil), "", nil).Message; got != "No pizza" {
+		t.Fatalf("newTimerResult(cancelled).Message = %q, want %q", got, "No pizza")
+	}
+	if env := hookEnv(newTimerEvent(outcomeComplete, invocation{duration: time.Minute}, at, at, at, nil)); env[len(env)-1] != "AFTER_MESSAGE=" {
+		t.Fatalf("hookEnv() = %q, want an empty AFTER_MESSAGE without --message", env)
+	}
+}
+
+func TestBigText(t *testing.T) {
+	t.Parallel()
+
+	rows, ok := bigText("1:0")
+	if !ok || len(rows) != 5 {
+		t.Fatalf("bigText(1:0) = %q, %v; want five rows", rows, ok)
+	}
+	if got, want := rows[0], "  █       █████"; got != want {
+		t.Fatalf("bigText(1:0) first row = %q, want %q", got, want)
+	}
+	if _, ok := bigText("42%"); ok {
+		t.Fatal("bigText(42%) ok = true, want false for unsupported characters")
+	}
+}
+
+func TestFormatFullscreenFrame(t *testing.T) {
+	t.Parallel()
+
+	got := formatFullscreenFrame([]string{"tea", "", "3:59"}, "", 20, 10)
+	if want := "\033[H\033[2J\033[4;9Htea\033[5;11H\033[6;9H3:59"; got != want {
+		t.Fatalf("formatFullscreenFrame() = %q, want %q", got, want)
+	}
+	if got, want := fullscreenLines("", "3.4", true, true), []string{"3.4 (paused)"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fullscreenLines(paused, big) = %q, want plain fallback %q", got, want)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_FullscreenRestoresScreen(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, true)
+
+	inv := invocation{duration: 20 * time.Millisecond, fullscreen: true, noTitle: true}
+	if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	got := out.String()
+	leave := strings.Index(got, leaveFullscreen)
+	if !strings.HasPrefix(got, enterFullscreen) || leave < 0 || leave > strings.Index(got, "after complete") {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want alternate screen left before the final line", got)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{s: "tea", want: 3},
+		{s: "café", want: 4},
+		{s: "cafe\u0301", want: 4},
+		{s: "お茶", want: 4},
+		{s: "⏳12:30", want: 7},
+		{s: "🍵 tea", want: 6},
+		{s: "████▎░", want: 6},
+	}
+	for _, tc := range tests {
+		if got := stringWidth(tc.s); got != tc.want {
+			t.Errorf("stringWidth(%q) = %d, want %d", tc.s, got, tc.want)
+		}
+	}
+	if got, want := cutToWidth("お茶の時間", 5), "お茶"; got != want {
+		t.Errorf("cutToWidth() = %q, want %q", got, want)
+	}
+	if got, want := padToWidth("お茶", 6), "お茶  "; got != want {
+		t.Errorf("padToWidth() = %q, want %q", got, want)
+	}
+}
+
+func TestTruncateToWidth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{s: "tea: 3:59", width: 80, want: "tea: 3:59"},
+		{s: "tea: 3:59", width: 10, want: "tea: 3:59"},
+		{s: "tea: 3:59", width: 9, want: "tea: 3:…"},
+		{s: "tea · 3:59", width: 6, want: "tea …"},
+		{s: "tea: 3:59", width: 2, want: "t"},
+		{s: "tea: 3:59", width: 0, want: "tea: 3:59"},
+		{s: "お茶: 3:59", width: 10, want: "お茶: 3:…"},
+		{s: "お茶: 3:59", width: 5, want: "お…"},
+	}
+	for _, tc := range tests {
+		if got := truncateToWidth(tc.s, tc.width); got != tc.want {
+			t.Fatalf("truncateToWidth(%q, %d) = %q, want %q", tc.s, tc.width, got, tc.want)
+		}
+	}
+}
+
+func TestFormatCountdownTitle(t *testing.T) {
+	t.Parallel()
+
+	if got, want := formatCountdownTitle(5*time.Minute+48*time.Second, 10*time.Minute, "tea: 5:48"), "[42%] tea: 5:48"; got != want {
+		t.Fatalf("formatCountdownTitle() = %q, want %q", got, want)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_NoTitleSkipsTitleStack(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, true)
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, invocation{noTitle: true}, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	if strings.Contains(out.String(), pushTitle) || strings.Contains(out.String(), popTitle) {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want no title stack with --no-title", out.String())
+	}
+}
+
+func TestRunTimerWithAlarmStarter_TitleOnly(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	w := &recordingWriter{}
+	status := newStatusDisplay(w, true, true)
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, invocation{titleOnly: true}, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	want := []string{"after: started (0s)\n", pushTitle, "\033]0;[100%] 0\007", popTitle, "after: complete\n"}
+	if !reflect.DeepEqual(w.writes, want) {
+		t.Fatalf("runTimerWithAlarmStarter() writes = %q, want %q", w.writes, want)
+	}
+}
+
+func TestParseInvocation_StdoutStreams(t *testing.T) {
+	t.Parallel()
+
+	if inv, err := parseInvocation(cliArgs("--json", "5m")); err != nil || !inv.jsonEvents {
+		t.Fatalf("parseInvocation(--json) = %+v, %v; want jsonEvents", inv, err)
+	}
+	if _, err := parseInvocation(cliArgs("--porcelain", "--json", "5m")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--porcelain --json) error = %v, want %v", err, errUsage)
+	}
+	if _, err := parseInvocation(cliArgs("--stdout", "--json", "5m")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--stdout --json) error = %v, want %v", err, errUsage)
+	}
+}
+
+func TestParseInvocation_TitleOnlyConflictsWithNoTitle(t *testing.T) {
+	t.Parallel()
+
+	if inv, err := parseInvocation(cliArgs("--title-only", "5m")); err != nil || !inv.titleOnly {
+		t.Fatalf("parseInvocation(--title-only) = %+v, %v; want titleOnly", inv, err)
+	}
+	if _, err := parseInvocation(cliArgs("--title-only", "-t", "5m")); !errors.Is(err, errUsage) {
+		t.Fatalf("parseInvocation(--title-only -t) error = %v, want %v", err, errUsage)
+	}
+}
+
+func TestDetectTerminalNotifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		env  map[string]string
+		want terminalNotifier
+	}{
+		{env: map[string]string{"TERM_PROGRAM": "iTerm.app"}, want: notifierOSC9},
+		{env: map[string]string{"LC_TERMINAL": "iTerm2", "TERM_PROGRAM": "tmux"}, want: notifierOSC9},
+		{env: map[string]string{"TERM_PROGRAM": "WezTerm"}, want: notifierOSC9},
+		{env: map[string]string{"TERM": "xterm-kitty"}, want: notifierOSC99},
+		{env: map[string]string{"TERM": "foot-extra"}, want: notifierOSC777},
+		{env: map[string]string{"TERM": "xterm-256color"}, want: notifierNone},
+	}
+	for _, tc := range tests {
+		getenv := func(key string) string { return tc.env[key] }
+		if got := detectTerminalNotifier(getenv); got != tc.want {
+			t.Fatalf("detectTerminalNotifier(%v) = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestFormatTerminalNotification(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		n      terminalNotifier
+		inTmux bool
+		want   string
+	}{
+		{n: notifierNone, want: ""},
+		{n: notifierOSC9, want: "\033]9;after: tea\007"},
+		{n: notifierOSC99, want: "\033]99;i=1:d=0;after\033\\\033]99;i=1:d=1:p=body;tea\033\\"},
+		{n: notifierOSC777, want: "\033]777;notify;after;tea\033\\"},
+		{n: notifierOSC777, inTmux: true, want: "\033Ptmux;\033\033]777;notify;after;tea\033\033\\\033\\"},
+	}
+	for _, tc := range tests {
+		if got := formatTerminalNotification(tc.n, "after", "t\007ea", tc.inTmux); got != tc.want {
+			t.Fatalf("formatTerminalNotification(%v, tmux %v) = %q, want %q", tc.n, tc.inTmux, got, tc.want)
+		}
+	}
+	if got, want := completionNotificationBody("tea", ""), "timer complete: tea"; got != want {
+		t.Fatalf("completionNotificationBody() = %q, want %q", got, want)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_TerminalNotification(t *testing.T) {
+	t.Parallel()
+
+	for _, noNotify := range []bool{false, true} {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		out, status := newCapturedStatus(true, true)
+		status.notifier = notifierOSC9
+
+		inv := invocation{message: "Pizza is done", noNotify: noNotify, noTitle: true}
+		if err := runTimerWithAlarmStarter(ctx, cancel, inv, status, false, func(alarmSound) {}); err != nil {
+			t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+		}
+		cancel(nil)
+		if got := strings.Contains(out.String(), "\033]9;after: Pizza is done\007"); got == noNotify {
+			t.Fatalf("runTimerWithAlarmStarter(noNotify %v) output = %q", noNotify, out.String())
+		}
+	}
+}
+
+func TestFormatChromeUpdate(t *testing.T) {
+	t.Parallel()
+
+	if got, want := formatChromeUpdate(chromeITermBadge, "tea: 3:59", false), "\033]1337;SetBadgeFormat=dGVhOiAzOjU5\007"; got != want {
+		t.Fatalf("formatChromeUpdate(badge) = %q, want %q", got, want)
+	}
+	if got, want := formatChromeUpdate(chromeWezTermUserVar, "", false), "\033]1337;SetUserVar=after_remaining=\007"; got != want {
+		t.Fatalf("formatChromeUpdate(user var, clear) = %q, want %q", got, want)
+	}
+	if got := formatChromeUpdate(chromeNone, "3:59", false); got != "" {
+		t.Fatalf("formatChromeUpdate(none) = %q, want empty", got)
+	}
+	if got := detectChromeIntegration(func(key string) string { return map[string]string{"WEZTERM_PANE": "3"}[key] }); got != chromeWezTermUserVar {
+		t.Fatalf("detectChromeIntegration(WezTerm) = %v, want %v", got, chromeWezTermUserVar)
+	}
+}
+
+func TestRunTimerWithAlarmStarter_ChromeSetAndCleared(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	out, status := newCapturedStatus(true, true)
+	status.chrome = chromeITermBadge
+
+	if err := runTimerWithAlarmStarter(ctx, cancel, invocation{}, status, false, func(alarmSound) {}); err != nil {
+		t.Fatalf("runTimerWithAlarmStarter() error = %v, want nil", err)
+	}
+	set := strings.Index(out.String(), formatChromeUpdate(chromeITermBadge, "0", false))
+	clear := strings.LastIndex(out.String(), formatChromeUpdate(chromeITermBadge, "", false))
+	if set < 0 || clear < set {
+		t.Fatalf("runTimerWithAlarmStarter() output = %q, want badge set then cleared", out.String())
+	}
+}
+
+func TestTmuxPopupArgs(t *testing.T) {
+	t.Parallel()
+
+	got := tmuxPopupArgs("/usr/local/bin/after", []string{"-l", "tea time", "--tmux-popup", "4m", "--", "--tmux-popup"}, false)
+	want := []string{"display-popup", "-E", "-T", " after ", "-w", "40", "-h", "3", `/usr/local/bin/after -l 'tea time' 4m -- --tmux-popup`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tmuxPopupArgs() = %q, want %q", got, want)
+	}
+	if got := tmuxPopupArgs("after", []string{"--big", "5m"}, true); got[5] != "60" || got[7] != "9" {
+		t.Fatalf("tmuxPopupArgs(big) = %q, want a 60x9 popup", got)
+	}
+	if err := startTmuxPopup([]string{"5m"}, false, func(string) string { return "" }); !errors.Is(err, errTmuxPopupOutsideTmux) {
+		t.Fatalf("startTmuxPopup() outside tmux error = %v, want %v", err, errTmuxPopupOutsideTmux)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"5m":          "5m",
+		"--label=tea": "--label=tea",
+		"tea time":    "'tea time'",
+		"it's":        `'it'\''s'`,
+		"":            "''",
+		"$HOME":       "'$HOME'",
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Fatalf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatDesktopNotification(t *testing.T) {
+	t.Parallel()
+
+	ended := time.Date(2026, 3, 1, 14, 5, 0, 0, time.Local)
+	event := timerEvent{outcome: outcomeComplete, requested: 4 * time.Minute, ended: ended}
+	want := desktopNotification{title: "after: tea", subtitle: "4 minutes, ended 14:05", text: "timer complete"}
+	if got := formatDesktopNotification(event, "tea", 0); got != want {
+		t.Fatalf("formatDesktopNotification(complete) = %+v, want %+v", got, want)
+	}
+	if got, want := want.body(), "timer complete (4 minutes, ended 14:05)"; got != want {
+		t.Fatalf("body() = %q, want %q", got, want)
+	}
+	event.message = "Tea is ready"
+	if got := formatDesktopNotification(event, "", 0); got.title != "after" || got.text != "Tea is ready" {
+		t.Fatalf("formatDesktopNotification(message) = %+v", got)
+	}
+	event = timerEvent{outcome: outcomeCancelled, requested: 25 * time.Minute}
+	if got := formatDesktopNotification(event, "focus", 3*time.Minute+2*time.Second); got.body() != "timer cancelled (3 minutes 2 seconds of 25 minutes left)" {
+		t.Fatalf("formatDesktopNotification(cancelled) body = %q", got.body())
+	}
+}
+
+func TestMacNotificationArgs(t *testing.T) {
+	t.Parallel()
+
+	n := desktopNotification{title: `after: "tea"`, subtitle: "4 minutes, ended 14:05", text: "timer complete"}
+	args := macNotificationArgs(n)
+	if got, want := args[len(args)-3:], []string{`after: "tea"`, "4 minutes, ended 14:05", "timer complete"}; !slices.Equal(got, want) {
+		t.Fatalf("macNotificationArgs() arguments = %q, want %q", got, want)
+	}
+	if script := args[3]; !strings.Contains(script, "subtitle (item 2 of argv)") || strings.Contains(script, "sound name") {
+		t.Fatalf("macNotificationArgs() script = %q, want a subtitle and no sound", script)
+	}
+	n.sound = true
+	if script := macNotificationArgs(n)[3]; !strings.HasSuffix(script, ` sound name "Glass"`) {
+		t.Fatalf("macNotificationArgs(sound) script = %q, want the Glass sound", script)
+	}
+}
+
+// dbusTestReply renders the answer to call serial: an error reply if
+// errorName is set, otherwise a method return of body with signature sig.
+func dbusTestReply(serial uint32, errorName, sig string, body func(e *dbusEncoder)) []byte {
+	var b dbusEncoder
+	if body != nil {
+		body(&b)
+	}
+	var e dbusEncoder
+	e.WriteString("l")
+	if errorName != "" {
+		e.WriteByte(dbusError)
+	} else {
+		e.WriteByte(dbusMethodReturn)
+	}
+	e.WriteByte(0)
+	e.WriteByte(1)
+	e.uint32(uint32(b.Len()))
+	e.uint32(100 + serial)
+	e.array(8, func() {
+		e.align(8)
+		e.WriteByte(dbusFieldReplySerial)
+		e.signature("u")
+		e.uint32(serial)
+		if errorName != "" {
+			e.align(8)
+			e.WriteByte(dbusFieldErrorName)
+			e.signature("s")
+			e.string(errorName)
+		}
+		if sig != "" {
+			e.align(8)
+			e.WriteByte(dbusFieldSignature)
+			e.signature("g")
+			e.signature(sig)
+		}
+	})
+	e.align(8)
+	e.Write(b.Bytes())
+	return e.Bytes()
+}
+
+// serveSessionBus accepts one connection at a unix socket, authenticates
+// it, and hands it to serve. It returns the bus address.
+func serveSessionBus(t *testing.T, serve func(conn net.Conn, r io.Reader)) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "bus")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "\x00AUTH EXTERNAL ") {
+			return
+		}
+		io.WriteString(conn, "OK 0123456789abcdef\r\n")
+		if line, err := r.ReadString('\n'); err != nil || line != "BEGIN\r\n" {
+			return
+		}
+		serve(conn, r)
+	}()
+	return "unix:path=" + path
+}
+
+// fakeSessionBus answers Hello and the call after it, with errorName as an
+// error if set. It returns the bus address and a channel receiving the
+// bytes of the call.
+func fakeSessionBus(t *testing.T, errorName string) (string, <-chan []byte) {
+	t.Helper()
+
+	calls := make(chan []byte, 1)
+	addr := serveSessionBus(t, func(conn net.Conn, r io.Reader) {
+		var raw bytes.Buffer
+		tee := io.TeeReader(r, &raw)
+		for serial := uint32(1); serial <= 2; serial++ {
+			raw.Reset()
+			if _, err := readDBusMessage(tee); err != nil {
+				return
+			}
+			if serial == 2 {
+				conn.Write(dbusTestReply(serial, errorName, "", nil))
+			} else {
+				conn.Write(dbusTestReply(serial, "", "", nil))
+			}
+		}
+		calls <- raw.Bytes()
+	})
+	return addr, calls
+}
+
+func TestCallSessionBusNotify(t *testing.T) {
+	t.Parallel()
+
+	addr, calls := fakeSessionBus(t, "")
+	if err := callSessionBus("tcp:host=localhost;"+addr, notifyCall(desktopNotification{title: "after: tea", subtitle: "4 minutes, ended 14:05", text: "timer complete", sound: true})); err != nil {
+		t.Fatalf("callSessionBus() error = %v", err)
+	}
+	call := <-calls
+	for _, want := range []string{"org.freedesktop.Notifications", "Notify", "susssasa{sv}i", "after: tea", "timer complete (4 minutes, ended 14:05)", "sound-name", "complete"} {
+		if !bytes.Contains(call, []byte(want)) {
+			t.Errorf("Notify call %q does not contain %q", call, want)
+		}
+	}
+	if !bytes.HasSuffix(call, []byte{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("Notify call does not end with the default expire_timeout: %q", call)
+	}
+
+	addr, _ = fakeSessionBus(t, "org.freedesktop.DBus.Error.ServiceUnknown")
+	err := callSessionBus(addr, notifyCall(desktopNotification{title: "after", text: "timer complete"}))
+	if err == nil || !strings.Contains(err.Error(), "ServiceUnknown") {
+		t.Fatalf("callSessionBus(no notification server) error = %v, want ServiceUnknown", err)
+	}
+}
+
+func TestWatchNotification(t *testing.T) {
+	t.Parallel()
+
+	// The notification server answers Notify with id 7, then reports a
+	// press of Snooze on another notification and on this one.
+	closed := make(chan string, 1)
+	addr := serveSessionBus(t, func(conn net.Conn, r io.Reader) {
+		signal := func(id uint32, member string, body func(e *dbusEncoder)) []byte {
+			b := encodeDBusCall(dbusCall{path: "/org/freedesktop/Notifications", iface: "org.freedesktop.Notifications", member: member, signature: "us", body: func(e *dbusEncoder) {
+				e.uint32(id)
+				body(e)
+			}}, 50+id)
+			b[1] = dbusSignal
+			return b
+		}
+		for serial := uint32(1); ; serial++ {
+			m, err := readDBusMessage(r)
+			if err != nil {
+				return
+			}
+			switch m.member {
+			case "Notify":
+				conn.Write(dbusTestReply(serial, "", "u", func(e *dbusEncoder) { e.uint32(7) }))
+				conn.Write(signal(6, "ActionInvoked", func(e *dbusEncoder) { e.string(alarmActionDismiss) }))
+				conn.Write(signal(7, "ActionInvoked", func(e *dbusEncoder) { e.string(alarmActionSnooze) }))
+			case "CloseNotification":
+				closed <- m.member
+				return
+			default:
+				conn.Write(dbusTestReply(serial, "", "", nil))
+			}
+		}
+	})
+	w, err := watchNotification(addr, desktopNotification{title: "after", text: "timer complete"}, alarmNotificationActions...)
+	if err != nil {
+		t.Fatalf("watchNotification() error = %v", err)
+	}
+	select {
+	case action := <-w.C:
+		if action != alarmActionSnooze {
+			t.Fatalf("action = %q, want %q from notification 7 only", action, alarmActionSnooze)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no action received")
+	}
+	w.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not take the notification down")
+	}
+}
+
+func TestDBusServiceCall(t *testing.T) {
+	t.Parallel()
+
+	var got []controlRequest
+	dispatch := func(req controlRequest) controlResponse {
+		got = append(got, req)
+		switch req.Command {
+		case controlCommandStatus:
+			return controlResponse{OK: true, State: &controlState{ID: 42, Label: "tea", State: timerStateRunning, Remaining: 90, Total: 240}}
+		case controlCommandResume:
+			return controlResponse{Error: "timer is not paused"}
+		}
+		return controlResponse{OK: true}
+	}
+	// call sends the method call through the wire format both ways, as the
+	// bus would.
+	call := func(iface, member, sig string, body func(e *dbusEncoder)) dbusMessage {
+		t.Helper()
+		m, err := readDBusMessage(bytes.NewReader(encodeDBusCall(dbusCall{path: dbusServicePath, iface: iface, member: member, signature: sig, body: body}, 9)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.sender = ":1.7"
+		reply, err := readDBusMessage(bytes.NewReader(encodeDBusReply(dbusServiceCall(m, dispatch), 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.replySerial != 9 {
+			t.Fatalf("%s reply serial = %d, want 9", member, reply.replySerial)
+		}
+		return reply
+	}
+
+	if reply := call(dbusServiceInterface, "Extend", "i", func(e *dbusEncoder) { e.int32(-30) }); reply.kind != dbusMethodReturn {
+		t.Fatalf("Extend(-30) = %s, want a return", reply.errorName)
+	}
+	if len(got) != 1 || got[0].Command != controlCommandExtend || got[0].ExtendSeconds != -30 {
+		t.Fatalf("Extend(-30) dispatched %+v", got)
+	}
+	if reply := call(dbusServiceInterface, "Extend", "", nil); reply.errorName != "org.freedesktop.DBus.Error.InvalidArgs" {
+		t.Fatalf("Extend() error = %q, want InvalidArgs", reply.errorName)
+	}
+	reply := call(dbusServiceInterface, "Resume", "", nil)
+	if reply.errorName != dbusServiceFailed || !bytes.Contains(reply.body, []byte("timer is not paused")) {
+		t.Fatalf("Resume() = %q %q, want the timer's refusal", reply.errorName, reply.body)
+	}
+
+	reply = call(dbusPropertiesInterface, "Get", "ss", func(e *dbusEncoder) {
+		e.string(dbusServiceInterface)
+		e.string("Remaining")
+	})
+	var want dbusEncoder
+	want.signature("d")
+	want.float64(90)
+	if reply.signature != "v" || !bytes.Equal(reply.body, want.Bytes()) {
+		t.Fatalf("Get(Remaining) = %q %v, want the variant 90.0", reply.signature, reply.body)
+	}
+	reply = call(dbusPropertiesInterface, "GetAll", "s", func(e *dbusEncoder) { e.string(dbusServiceInterface) })
+	for _, name := range []string{"Id", "Label", "tea", "State", "running", "Remaining", "Deadline"} {
+		if !bytes.Contains(reply.body, []byte(name)) {
+			t.Errorf("GetAll() body %q does not contain %q", reply.body, name)
+		}
+	}
+	if reply := call(dbusIntrospectableInterface, "Introspect", "", nil); !bytes.Contains(reply.body, []byte(`<method name="Extend">`)) {
+		t.Fatalf("Introspect() = %q", reply.body)
+	}
+	if reply := call(dbusServiceInterface, "Reset", "", nil); reply.errorName != "org.freedesktop.DBus.Error.UnknownMethod" {
+		t.Fatalf("Reset() error = %q, want UnknownMethod", reply.errorName)
+	}
+}
+
+func TestSessionBusAddress(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"}
+	if got := sessionBusAddress(func(k string) string { return env[k] }); got != "unix:path=/run/user/1000/bus" {
+		t.Fatalf("sessionBusAddress(runtime dir) = %q", got)
+	}
+	env["DBUS_SESSION_BUS_ADDRESS"] = "unix:abstract=/tmp/dbus-x,guid=1"
+	if got := sessionBusAddress(func(k string) string { return env[k] }); got != env["DBUS_SESSION_BUS_ADDRESS"] {
+		t.Fatalf("sessionBusAddress(exported) = %q", got)
+	}
+}
+
+func TestDesktopNotifiersForGOOS(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"}
+	getenv := func(k string) string { return env[k] }
+	installed := func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	missing := func(string) (string, error) { return "", exec.ErrNotFound }
+	terminal := desktopNotifier{name: "terminal"}
+	names := func(notifiers []desktopNotifier) []string {
+		var names []string
+		for _, n := range notifiers {
+			names = append(names, n.name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		goos     string
+		getenv   func(string) string
+		lookPath func(string) (string, error)
+		want     []string
+	}{
+		{goos: "linux", getenv: getenv, lookPath: installed, want: []string{"dbus", "notify-send", "terminal"}},
+		{goos: "linux", getenv: func(string) string { return "" }, lookPath: missing, want: []string{"terminal"}},
+		{goos: "freebsd", getenv: getenv, lookPath: missing, want: []string{"dbus", "terminal"}},
+		{goos: "darwin", getenv: getenv, lookPath: installed, want: []string{"osascript", "terminal"}},
+	}
+	for _, tc := range tests {
+		if got := names(desktopNotifiersForGOOS(tc.goos, tc.getenv, tc.lookPath, terminal)); !slices.Equal(got, tc.want) {
+			t.Errorf("desktopNotifiersForGOOS(%s) = %q, want %q", tc.goos, got, tc.want)
+		}
+	}
+}
+
+func TestPostDesktopNotificationFallsBack(t *testing.T) {
+	t.Parallel()
+
+	var posted []string
+	notifier := func(name string, err error) desktopNotifier {
+		return desktopNotifier{name: name, post: func(n desktopNotification) error {
+			posted = append(posted, name+": "+n.title+": "+n.body())
+			return err
+		}}
+	}
+	var debug []string
+	logDebug := func(format string, args ...any) { debug = append(debug, fmt.Sprintf(format, args...)) }
+
+	notifiers := []desktopNotifier{notifier("dbus", errors.New("no bus")), notifier("notify-send", nil), notifier("terminal", nil)}
+	name, err := postDesktopNotification(notifiers, desktopNotification{title: "after", text: "timer complete"}, logDebug)
+	if err != nil || name != "notify-send" {
+		t.Fatalf("postDesktopNotification() = %q, %v; want notify-send", name, err)
+	}
+	if want := []string{"dbus: after: timer complete", "notify-send: after: timer complete"}; !slices.Equal(posted, want) {
+		t.Fatalf("postDesktopNotification() tried %q, want %q", posted, want)
+	}
+	if want := []string{"notifier dbus failed: no bus"}; !slices.Equal(debug, want) {
+		t.Fatalf("postDesktopNotification() debug = %q, want %q", debug, want)
+	}
+
+	_, err = postDesktopNotification([]desktopNotifier{notifier("dbus", errors.New("no bus")), notifier("terminal", errors.New("quiet"))}, desktopNotification{title: "after", text: "timer complete"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "dbus: no bus") || !strings.Contains(err.Error(), "terminal: quiet") {
+		t.Fatalf("postDesktopNotification(none works) error = %v, want every failure", err)
+	}
+}
+
+func TestDebugf(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	debugf(&out, func(string) string { return "" }, "notification posted via %s", "dbus")
+	if out.Len() != 0 {
+		t.Fatalf("debugf() without %s wrote %q", debugEnvVar, out.String())
+	}
+	debugf(&out, func(k string) string {
+		if k == debugEnvVar {
+			return "1"
+		}
+		return ""
+	}, "notification posted via %s", "dbus")
+	if got, want := out.String(), "after: debug: notification posted via dbus\n"; got != want {
+		t.Fatalf("debugf() = %q, want %q", got, want)
+	}
+}
+
+func TestParseSystemdArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseSystemdArgs([]string{"--on", "Mon..Fri 09:55", "--name", "standup", "--", "5m", "--dir", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := systemdArgs{calendar: "Mon..Fri 09:55", name: "standup", after: []string{"5m", "--dir", "x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseSystemdArgs() = %+v, want %+v", got, want)
+	}
+	for _, args := range [][]string{{"5m"}, {"--on", "daily"}, {"--on"}} {
+		if _, err := parseSystemdArgs(args); !errors.Is(err, errUsage) {
+			t.Errorf("parseSystemdArgs(%q) error = %v, want %v", args, err, errUsage)
+		}
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	t.Parallel()
+
+	service, timer := systemdUnits("after: 100% done", "Mon..Fri 09:55", "/usr/local/bin/after",
+		[]string{"5m", "-l", "Stand up", "--message", `$HOME "now"`})
+	wantService := "[Unit]\n" +
+		"Description=after: 100%% done\n\n" +
+		"[Service]\n" +
+		"Type=notify\n" +
+		`ExecStart=/usr/local/bin/after 5m -l "Stand up" --message "$$HOME \"now\""` + "\n"
+	if service != wantService {
+		t.Errorf("service =\n%s\nwant\n%s", service, wantService)
+	}
+	wantTimer := "[Unit]\n" +
+		"Description=after: 100%% done\n\n" +
+		"[Timer]\n" +
+		"OnCalendar=Mon..Fri 09:55\n\n" +
+		"[Install]\n" +
+		"WantedBy=timers.target\n"
+	if timer != wantTimer {
+		t.Errorf("timer =\n%s\nwant\n%s", timer, wantTimer)
+	}
+
+	for label, want := range map[string]string{"": "after", "Stand up!": "after-stand-up", "tea.time": "after-tea.time"} {
+		if got := defaultScheduleName(label); got != want {
+			t.Errorf("defaultScheduleName(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestLaunchdArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseLaunchdArgs([]string{"--at", "07:00", "--weekdays", "mon,fri", "--install", "--label", "wakeup", "--sound"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := launchdArgs{at: "07:00", weekdays: "mon,fri", install: true, after: []string{"--label", "wakeup", "--sound"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseLaunchdArgs() = %+v, want %+v", got, want)
+	}
+	if _, err := parseLaunchdArgs([]string{"5m"}); !errors.Is(err, errUsage) {
+		t.Fatalf("parseLaunchdArgs() without --at error = %v, want %v", err, errUsage)
+	}
+
+	if hour, minute, err := parseLaunchdTime("6:55pm"); hour != 18 || minute != 55 || err != nil {
+		t.Fatalf("parseLaunchdTime(6:55pm) = %d, %d, %v, want 18, 55", hour, minute, err)
+	}
+	for _, token := range []string{":30", "5m", "25:00"} {
+		if _, _, err := parseLaunchdTime(token); err == nil {
+			t.Errorf("parseLaunchdTime(%q) succeeded, want an error", token)
+		}
+	}
+	if _, err := parseLaunchdWeekdays("mon,funday"); err == nil {
+		t.Fatal("parseLaunchdWeekdays(mon,funday) succeeded, want an error")
+	}
+
+	// Without a duration, the alarm rings as the job starts.
+	run, inv, err := launchdArgsToRun([]string{"--label", "wakeup", "--sound"}, nil)
+	if err != nil || !slices.Equal(run, []string{"0s", "--label", "wakeup", "--sound"}) || inv.label != "wakeup" {
+		t.Fatalf("launchdArgsToRun() = %q, %+v, %v, want the alarm at once", run, inv, err)
+	}
+	run, _, err = launchdArgsToRun([]string{"5m", "--sound"}, nil)
+	if err != nil || !slices.Equal(run, []string{"5m", "--sound"}) {
+		t.Fatalf("launchdArgsToRun() = %q, %v, want the arguments as given", run, err)
+	}
+	if _, _, err := launchdArgsToRun([]string{"banana"}, nil); err == nil {
+		t.Fatal("launchdArgsToRun(banana) succeeded, want an error")
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	t.Parallel()
+
+	got := launchdPlist("dev.mtnman.after-tea", "/usr/local/bin/after", []string{"5m", "-l", "tea & cake"}, 7, 5, []int{1})
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>dev.mtnman.after-tea</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/usr/local/bin/after</string>
+		<string>5m</string>
+		<string>-l</string>
+		<string>tea &amp; cake</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<array>
+		<dict>
+			<key>Weekday</key>
+			<integer>1</integer>
+			<key>Hour</key>
+			<integer>7</integer>
+			<key>Minute</key>
+			<integer>5</integer>
+		</dict>
+	</array>
+</dict>
+</plist>
+`
+	if got != want {
+		t.Fatalf("launchdPlist() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatICSEvent(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	got := formatICSEvent("deploy; then coffee, maybe", "", started, started.Add(2*time.Hour), started, "build01", 42)
+	want := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//mtn-man//after//EN\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		fmt.Sprintf("UID:%d-42@build01\r\n", started.UnixNano()) +
+		"DTSTAMP:20250301T080000Z\r\n" +
+		"DTSTART:20250301T100000Z\r\n" +
+		"SUMMARY:deploy\\; then coffee\\, maybe\r\n" +
+		"DESCRIPTION:2 hours timer started at 09:00 on build01\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"DESCRIPTION:deploy\\; then coffee\\, maybe\r\n" +
+		"TRIGGER:PT0S\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	if got != want {
+		t.Fatalf("formatICSEvent() =\n%q\nwant\n%q", got, want)
+	}
+
+	folded := icsFold("SUMMARY:" + strings.Repeat("é", 40))
+	for _, line := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(line) > 75 || !utf8.ValidString(strings.TrimPrefix(line, " ")) {
+			t.Fatalf("icsFold() line %q is too long or splits a character", line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != "SUMMARY:"+strings.Repeat("é", 40)+"\r\n" {
+		t.Fatalf("icsFold() unfolds to %q", unfolded)
+	}
+}
+
+func TestRunCtl(t *testing.T) {
+	t.Parallel()
+
+	// This process stands in for the timer ctl starts.
+	dir := t.TempDir()
+	srv, err := listenControl(dir)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer srv.close()
+	requests := make(chan controlRequest, 8)
+	go func() {
+		for call := range srv.calls {
+			requests <- call.req
+			state := controlState{ID: os.Getpid(), Label: "tea", State: timerStateRunning, Remaining: 90}
+			call.reply <- controlResponse{OK: true, State: &state}
+		}
+	}()
+	var started []string
+	start := func(args []string) (int, error) {
+		started = args
+		return os.Getpid(), nil
+	}
+	run := func(args ...string) (any, error) {
+		return runCtl(args, dir, nil, start)
+	}
+
+	got, err := run("start", "4m", "--label", "tea", "--sound")
+	if state, ok := got.(controlState); err != nil || !ok || state.Label != "tea" {
+		t.Fatalf("ctl start = %+v, %v, want the new timer", got, err)
+	}
+	if want := []string{"4m", "--label", "tea", "--sound"}; !slices.Equal(started, want) {
+		t.Fatalf("started %q, want %q", started, want)
+	}
+	<-requests
+
+	if got, err := run("list"); err != nil || len(got.([]controlState)) != 1 {
+		t.Fatalf("ctl list = %+v, %v, want this timer", got, err)
+	}
+	<-requests
+
+	if _, err := run("extend", strconv.Itoa(os.Getpid()), "5m"); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.Command != controlCommandExtend || req.ExtendSeconds != 300 {
+		t.Fatalf("ctl extend sent %+v, want extend by 300s", req)
+	}
+	if _, err := run("cancel", strconv.Itoa(os.Getpid())); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.Command != controlCommandCancel {
+		t.Fatalf("ctl cancel sent %+v, want cancel", req)
+	}
+
+	for _, args := range [][]string{nil, {"start"}, {"list", "1"}, {"bogus"}, {"pause", "tea"}, {"extend"}} {
+		if _, err := run(args...); !errors.Is(err, errUsage) {
+			t.Errorf("ctl %q error = %v, want %v", args, err, errUsage)
+		}
+	}
+	if _, err := run("extend", "9am"); err == nil {
+		t.Error("ctl extend 9am succeeded, want an error")
+	}
+}
+
+func TestBuildConfigToggl(t *testing.T) {
+	t.Parallel()
+
+	sections, err := parseConfigSections(strings.NewReader("[toggl]\ntoken = abc\nworkspace = 12\nproject = 34\ntags = pomodoro, focus\n"))
+	if err != nil {
+		t.Fatalf("parseConfigSections() error = %v", err)
+	}
+	cfg, err := buildConfig(sections)
+	if err != nil || cfg.toggl != (togglConfig{token: "abc", workspace: 12, project: 34, tags: "pomodoro, focus"}) {
+		t.Fatalf("buildConfig() toggl = %+v, %v", cfg.toggl, err)
+	}
+	if got := resolveToggl(cfg.toggl, func(string) string { return "env" }); got.token != "env" {
+		t.Fatalf("resolveToggl() token = %q, want the environment's", got.token)
+	}
+
+	sections, _ = parseConfigSections(strings.NewReader("[toggl]\nworkspace = mine\n"))
+	if _, err := buildConfig(sections); err == nil || !strings.Contains(err.Error(), "workspace") {
+		t.Fatalf("buildConfig(bad workspace) error = %v, want it named", err)
+	}
+}
+
+func TestTogglEntry(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if user, password, _ := r.BasicAuth(); user != "secret" || password != "api_token" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `"Incorrect username and/or password"`)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /me":
+			io.WriteString(w, `{"id":1,"default_workspace_id":12}`)
+		case "POST /workspaces/12/time_entries":
+			json.NewDecoder(r.Body).Decode(&body)
+			io.WriteString(w, `{"id":99,"workspace_id":12}`)
+		case "PATCH /workspaces/12/time_entries/99/stop":
+			io.WriteString(w, `{"id":99}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := togglConfig{token: "secret", project: 34, tags: "pomodoro, focus"}
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	entry, err := startTogglEntry(server.Client(), server.URL, c, "write report", start)
+	if err != nil || entry != (togglEntry{workspace: 12, id: 99}) {
+		t.Fatalf("startTogglEntry() = %+v, %v, want entry 99 in the default workspace", entry, err)
+	}
+	want := map[string]any{
+		"created_with": "after",
+		"description":  "write report",
+		"workspace_id": float64(12),
+		"project_id":   float64(34),
+		"tags":         []any{"pomodoro", "focus"},
+		"start":        "2025-03-01T08:00:00Z",
+		"duration":     float64(-1),
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Fatalf("time entry = %v, want %v", body, want)
+	}
+	if err := stopTogglEntry(server.Client(), server.URL, c, entry); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GET /me", "POST /workspaces/12/time_entries", "PATCH /workspaces/12/time_entries/99/stop"}; !slices.Equal(requests, want) {
+		t.Fatalf("requests = %q, want %q", requests, want)
+	}
+
+	_, err = startTogglEntry(server.Client(), server.URL, togglConfig{token: "wrong", workspace: 12}, "", start)
+	if err == nil || !strings.Contains(err.Error(), "Incorrect username") {
+		t.Fatalf("startTogglEntry(wrong token) error = %v, want Toggl's reason", err)
 	}
 }
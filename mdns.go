@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// A minimal multicast DNS (RFC 6762) and DNS-SD (RFC 6763) implementation,
+// just enough to advertise shared timers and find them from another machine.
+// Only IPv4 is supported.
+
+const (
+	mdnsPort        = 5353
+	mdnsServiceType = "_after._tcp.local."
+	mdnsTTL         = 120
+
+	dnsTypeA   = 1
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+
+	dnsClassIN         = 1
+	dnsClassCacheFlush = 0x8000
+	dnsFlagResponse    = 0x8400 // QR and AA
+)
+
+var mdnsGroup = net.IPv4(224, 0, 0, 251)
+
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+}
+
+// dnsRecord holds the decoded form of the record types used here. Only the
+// fields for rtype are meaningful.
+type dnsRecord struct {
+	name   string
+	rtype  uint16
+	ttl    uint32
+	target string // PTR and SRV
+	port   uint16 // SRV
+	ip     net.IP // A
+	txt    []string
+}
+
+type dnsMessage struct {
+	id        uint16
+	response  bool
+	questions []dnsQuestion
+	records   []dnsRecord // answers and additional records together
+}
+
+func (m dnsMessage) pack() []byte {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	if m.response {
+		binary.BigEndian.PutUint16(b[2:], dnsFlagResponse)
+	}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.records)))
+
+	for _, q := range m.questions {
+		b = appendDNSName(b, q.name)
+		b = binary.BigEndian.AppendUint16(b, q.qtype)
+		b = binary.BigEndian.AppendUint16(b, dnsClassIN)
+	}
+	for _, r := range m.records {
+		b = appendDNSName(b, r.name)
+		b = binary.BigEndian.AppendUint16(b, r.rtype)
+		class := uint16(dnsClassIN)
+		if r.rtype != dnsTypePTR {
+			class |= dnsClassCacheFlush // unique records
+		}
+		b = binary.BigEndian.AppendUint16(b, class)
+		b = binary.BigEndian.AppendUint32(b, r.ttl)
+
+		var data []byte
+		switch r.rtype {
+		case dnsTypePTR:
+			data = appendDNSName(nil, r.target)
+		case dnsTypeSRV:
+			data = binary.BigEndian.AppendUint16(data, 0) // priority
+			data = binary.BigEndian.AppendUint16(data, 0) // weight
+			data = binary.BigEndian.AppendUint16(data, r.port)
+			data = appendDNSName(data, r.target)
+		case dnsTypeA:
+			data = append(data, r.ip.To4()...)
+		case dnsTypeTXT:
+			for _, s := range r.txt {
+				data = append(data, byte(len(s)))
+				data = append(data, s...)
+			}
+			if len(r.txt) == 0 {
+				data = append(data, 0)
+			}
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+		b = append(b, data...)
+	}
+	return b
+}
+
+func appendDNSName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+var errMalformedDNS = errors.New("malformed DNS message")
+
+func parseDNSMessage(msg []byte) (dnsMessage, error) {
+	if len(msg) < 12 {
+		return dnsMessage{}, errMalformedDNS
+	}
+	m := dnsMessage{
+		id:       binary.BigEndian.Uint16(msg[0:]),
+		response: msg[2]&0x80 != 0,
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	rrcount := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := 12
+	for range qdcount {
+		name, next, err := readDNSName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return dnsMessage{}, errMalformedDNS
+		}
+		m.questions = append(m.questions, dnsQuestion{name: name, qtype: binary.BigEndian.Uint16(msg[next:])})
+		off = next + 4
+	}
+	for range rrcount {
+		name, next, err := readDNSName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return dnsMessage{}, errMalformedDNS
+		}
+		r := dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			ttl:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		end := start + length
+		if end > len(msg) {
+			return dnsMessage{}, errMalformedDNS
+		}
+		switch r.rtype {
+		case dnsTypePTR:
+			r.target, _, err = readDNSName(msg, start)
+		case dnsTypeSRV:
+			if length < 7 {
+				return dnsMessage{}, errMalformedDNS
+			}
+			r.port = binary.BigEndian.Uint16(msg[start+4:])
+			r.target, _, err = readDNSName(msg, start+6)
+		case dnsTypeA:
+			if length != 4 {
+				return dnsMessage{}, errMalformedDNS
+			}
+			r.ip = net.IPv4(msg[start], msg[start+1], msg[start+2], msg[start+3])
+		case dnsTypeTXT:
+			for i := start; i < end; {
+				n := int(msg[i])
+				if i+1+n > end {
+					return dnsMessage{}, errMalformedDNS
+				}
+				if n > 0 {
+					r.txt = append(r.txt, string(msg[i+1:i+1+n]))
+				}
+				i += 1 + n
+			}
+		}
+		if err != nil {
+			return dnsMessage{}, errMalformedDNS
+		}
+		m.records = append(m.records, r)
+		off = end
+	}
+	return m, nil
+}
+
+// readDNSName decodes the name at off, following compression pointers. It
+// returns the name with a trailing dot and the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 64 {
+			return "", 0, errMalformedDNS
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errMalformedDNS
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errMalformedDNS
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// mdnsService describes one advertised timer.
+type mdnsService struct {
+	instance string // e.g. "tea-4242._after._tcp.local."
+	host     string // e.g. "kitchen.local."
+	port     uint16
+	ips      []net.IP
+	txt      []string
+}
+
+func (s mdnsService) records(ttl uint32) []dnsRecord {
+	records := []dnsRecord{
+		{name: mdnsServiceType, rtype: dnsTypePTR, ttl: ttl, target: s.instance},
+		{name: s.instance, rtype: dnsTypeSRV, ttl: ttl, target: s.host, port: s.port},
+		{name: s.instance, rtype: dnsTypeTXT, ttl: ttl, txt: s.txt},
+	}
+	for _, ip := range s.ips {
+		records = append(records, dnsRecord{name: s.host, rtype: dnsTypeA, ttl: ttl, ip: ip})
+	}
+	return records
+}
+
+// answers reports whether q asks about this service.
+func (s mdnsService) answers(q dnsQuestion) bool {
+	name := strings.ToLower(q.name)
+	switch {
+	case name == mdnsServiceType:
+		return q.qtype == dnsTypePTR || q.qtype == dnsTypeANY
+	case name == strings.ToLower(s.instance):
+		return q.qtype == dnsTypeSRV || q.qtype == dnsTypeTXT || q.qtype == dnsTypeANY
+	case name == strings.ToLower(s.host):
+		return q.qtype == dnsTypeA || q.qtype == dnsTypeANY
+	}
+	return false
+}
+
+// mdnsResponder answers queries for a single service until closed.
+type mdnsResponder struct {
+	conn    *net.UDPConn
+	service mdnsService
+	group   *net.UDPAddr
+}
+
+func newMDNSResponder(service mdnsService) (*mdnsResponder, error) {
+	group := &net.UDPAddr{IP: mdnsGroup, Port: mdnsPort}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, err
+	}
+	r := &mdnsResponder{conn: conn, service: service, group: group}
+	go r.serve()
+	r.announce(mdnsTTL)
+	return r, nil
+}
+
+func (r *mdnsResponder) announce(ttl uint32) {
+	msg := dnsMessage{response: true, records: r.service.records(ttl)}
+	_, _ = r.conn.WriteToUDP(msg.pack(), r.group)
+}
+
+func (r *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		query, err := parseDNSMessage(buf[:n])
+		if err != nil || query.response {
+			continue
+		}
+		var matched []dnsQuestion
+		for _, q := range query.questions {
+			if r.service.answers(q) {
+				matched = append(matched, q)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		resp := dnsMessage{response: true, records: r.service.records(mdnsTTL)}
+		if from.Port != mdnsPort {
+			// Legacy unicast query (RFC 6762 section 6.7): reply directly,
+			// echoing the id and questions.
+			resp.id = query.id
+			resp.questions = matched
+			_, _ = r.conn.WriteToUDP(resp.pack(), from)
+			continue
+		}
+		_, _ = r.conn.WriteToUDP(resp.pack(), r.group)
+	}
+}
+
+// close sends a goodbye (TTL 0) so browsers forget the timer at once.
+func (r *mdnsResponder) close() {
+	r.announce(0)
+	_ = r.conn.Close()
+}
+
+// browseMDNS sends a query for the after service and collects replies until
+// timeout. It returns every instance with a usable address.
+func browseMDNS(timeout time.Duration) ([]mdnsService, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query := dnsMessage{id: uint16(os.Getpid()), questions: []dnsQuestion{{name: mdnsServiceType, qtype: dnsTypePTR}}}
+	if _, err := conn.WriteToUDP(query.pack(), &net.UDPAddr{IP: mdnsGroup, Port: mdnsPort}); err != nil {
+		return nil, fmt.Errorf("sending mDNS query: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var records []dnsRecord
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		msg, err := parseDNSMessage(buf[:n])
+		if err != nil || !msg.response {
+			continue
+		}
+		records = append(records, msg.records...)
+	}
+	return servicesFromRecords(records), nil
+}
+
+// servicesFromRecords joins PTR, SRV, TXT, and A records into services,
+// dropping instances whose address never arrived or that said goodbye.
+func servicesFromRecords(records []dnsRecord) []mdnsService {
+	var instances []string
+	byInstance := map[string]*mdnsService{}
+	hostIPs := map[string][]net.IP{}
+	gone := map[string]bool{}
+
+	for _, r := range records {
+		switch r.rtype {
+		case dnsTypePTR:
+			if !strings.EqualFold(r.name, mdnsServiceType) {
+				continue
+			}
+			if r.ttl == 0 {
+				gone[r.target] = true
+				continue
+			}
+			if _, ok := byInstance[r.target]; !ok {
+				instances = append(instances, r.target)
+				byInstance[r.target] = &mdnsService{instance: r.target}
+			}
+		case dnsTypeA:
+			hostIPs[r.name] = append(hostIPs[r.name], r.ip)
+		}
+	}
+	for _, r := range records {
+		s, ok := byInstance[r.name]
+		if !ok {
+			continue
+		}
+		switch r.rtype {
+		case dnsTypeSRV:
+			s.host, s.port = r.target, r.port
+		case dnsTypeTXT:
+			s.txt = r.txt
+		}
+	}
+
+	var services []mdnsService
+	for _, name := range instances {
+		s := byInstance[name]
+		s.ips = hostIPs[s.host]
+		if gone[name] || s.port == 0 || len(s.ips) == 0 {
+			continue
+		}
+		services = append(services, *s)
+	}
+	return services
+}
+
+// mdnsInstanceLabel strips the service type from an instance name.
+func mdnsInstanceLabel(instance string) string {
+	return strings.TrimSuffix(instance, "."+mdnsServiceType)
+}
+
+// localIPv4s returns the machine's non-loopback IPv4 addresses.
+func localIPv4s() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+			continue
+		}
+		ips = append(ips, ipnet.IP.To4())
+	}
+	return ips
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Terminals with native desktop notifications take them as escape
+// sequences, which also travel over SSH. There is no way to query support,
+// so the terminal is recognized from its environment.
+type terminalNotifier int
+
+const (
+	notifierNone   terminalNotifier = iota
+	notifierOSC9                    // iTerm2, WezTerm
+	notifierOSC99                   // kitty
+	notifierOSC777                  // foot, and other VTE-style terminals
+)
+
+func detectTerminalNotifier(getenv func(string) string) terminalNotifier {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || getenv("TERM") == "xterm-kitty":
+		return notifierOSC99
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("LC_TERMINAL") == "iTerm2":
+		return notifierOSC9
+	case getenv("TERM_PROGRAM") == "WezTerm" || getenv("WEZTERM_PANE") != "":
+		return notifierOSC9
+	case strings.HasPrefix(getenv("TERM"), "foot"):
+		return notifierOSC777
+	}
+	return notifierNone
+}
+
+// formatTerminalNotification returns the escape sequence posting a desktop
+// notification, or "" for notifierNone. Inside tmux the sequence is wrapped
+// for passthrough, which needs "set -g allow-passthrough on".
+func formatTerminalNotification(n terminalNotifier, title, body string, inTmux bool) string {
+	title, body = stripControl(title), stripControl(body)
+	var seq string
+	switch n {
+	case notifierOSC9:
+		seq = fmt.Sprintf("\033]9;%s: %s\007", title, body)
+	case notifierOSC99:
+		seq = fmt.Sprintf("\033]99;i=1:d=0;%s\033\\\033]99;i=1:d=1:p=body;%s\033\\", title, body)
+	case notifierOSC777:
+		seq = fmt.Sprintf("\033]777;notify;%s;%s\033\\", strings.ReplaceAll(title, ";", ","), body)
+	default:
+		return ""
+	}
+	return tmuxPassthrough(seq, inTmux)
+}
+
+func tmuxPassthrough(seq string, inTmux bool) string {
+	if !inTmux || seq == "" {
+		return seq
+	}
+	return "\033Ptmux;" + strings.ReplaceAll(seq, "\033", "\033\033") + "\033\\"
+}
+
+// chromeIntegration publishes the countdown to the terminal's own UI, where
+// it stays visible while the pane is scrolled or hidden: an iTerm2 badge or
+// a WezTerm user variable for status bars and tab titles.
+type chromeIntegration int
+
+const (
+	chromeNone chromeIntegration = iota
+	chromeITermBadge
+	chromeWezTermUserVar
+)
+
+// weztermUserVar is the user variable holding the countdown; read it in
+// wezterm.lua with pane:get_user_vars().after_remaining.
+const weztermUserVar = "after_remaining"
+
+func detectChromeIntegration(getenv func(string) string) chromeIntegration {
+	switch {
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("LC_TERMINAL") == "iTerm2":
+		return chromeITermBadge
+	case getenv("TERM_PROGRAM") == "WezTerm" || getenv("WEZTERM_PANE") != "":
+		return chromeWezTermUserVar
+	}
+	return chromeNone
+}
+
+// formatChromeUpdate sets the badge or user variable to text; "" clears it.
+// Both take base64 through iTerm2's proprietary OSC 1337.
+func formatChromeUpdate(c chromeIntegration, text string, inTmux bool) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(stripControl(text)))
+	switch c {
+	case chromeITermBadge:
+		return tmuxPassthrough(fmt.Sprintf("\033]1337;SetBadgeFormat=%s\007", encoded), inTmux)
+	case chromeWezTermUserVar:
+		return tmuxPassthrough(fmt.Sprintf("\033]1337;SetUserVar=%s=%s\007", weztermUserVar, encoded), inTmux)
+	}
+	return ""
+}
+
+// stripControl drops control characters, which would end the escape
+// sequence early.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// completionNotificationBody is the notification text: --message if given,
+// otherwise "timer complete" with the label.
+func completionNotificationBody(label, message string) string {
+	switch {
+	case message != "":
+		return message
+	case label != "":
+		return "timer complete: " + label
+	}
+	return "timer complete"
+}
+
+// desktopNotification is a --notify notification. Notification Center
+// shows subtitle on a line of its own; elsewhere it follows text in
+// parentheses (see body). sound asks for the notification sound, for when
+// the alarm does not ring.
+type desktopNotification struct {
+	title    string
+	subtitle string
+	text     string
+	sound    bool
+}
+
+// body is text and subtitle on one line, for notifiers without subtitles.
+func (n desktopNotification) body() string {
+	if n.subtitle == "" {
+		return n.text
+	}
+	return fmt.Sprintf("%s (%s)", n.text, n.subtitle)
+}
+
+// formatDesktopNotification returns the --notify notification for a timer
+// that ended as event says, e.g. "after: tea", "timer complete", and "4
+// minutes, ended 14:05". remaining is what a cancelled timer had left.
+func formatDesktopNotification(event timerEvent, label string, remaining time.Duration) desktopNotification {
+	n := desktopNotification{title: "after", text: event.message}
+	if label != "" {
+		n.title = "after: " + label
+	}
+	if event.outcome == outcomeCancelled {
+		if n.text == "" {
+			n.text = "timer cancelled"
+		}
+		n.subtitle = fmt.Sprintf("%s of %s left", formatSpokenDuration(remaining), formatSpokenDuration(event.requested))
+		return n
+	}
+	if n.text == "" {
+		n.text = "timer complete"
+	}
+	n.subtitle = fmt.Sprintf("%s, ended %s", formatSpokenDuration(event.requested), event.ended.Format("15:04"))
+	return n
+}
+
+// A desktopNotifier is one way to post a --notify notification. Like the
+// alarm backends, they are tried in order until one works.
+type desktopNotifier struct {
+	name string
+	post func(n desktopNotification) error
+}
+
+// The buttons on the notification of an alarm ringing until answered.
+const (
+	alarmActionSnooze  = "snooze"
+	alarmActionDismiss = "dismiss"
+)
+
+// alarmNotificationActions are the buttons as pairs of key and label.
+var alarmNotificationActions = []string{alarmActionSnooze, "Snooze", alarmActionDismiss, "Dismiss"}
+
+// macNotificationSound is the Notification Center sound a notification
+// plays when asked to.
+const macNotificationSound = "Glass"
+
+// desktopNotifiersForGOOS lists the notifiers goos can use, best first:
+// the session D-Bus, notify-send, and osascript where they exist, then
+// terminal, which posts through the terminal (OSC 9 and kin) when it can.
+func desktopNotifiersForGOOS(goos string, getenv func(string) string, lookPath func(string) (string, error), terminal desktopNotifier) []desktopNotifier {
+	var notifiers []desktopNotifier
+	if addr := sessionBusAddress(getenv); addr != "" && goos != "darwin" && goos != "windows" {
+		notifiers = append(notifiers, desktopNotifier{name: "dbus", post: func(n desktopNotification) error {
+			return callSessionBus(addr, notifyCall(n))
+		}})
+	}
+	if _, err := lookPath("notify-send"); err == nil && goos != "darwin" {
+		notifiers = append(notifiers, desktopNotifier{name: "notify-send", post: func(n desktopNotification) error {
+			args := []string{"--app-name=after"}
+			if n.sound {
+				args = append(args, "--hint=string:sound-name:"+freedesktopCompleteSound)
+			}
+			return runNotifierCommand("notify-send", append(args, "--", n.title, n.body())...)
+		}})
+	}
+	if _, err := lookPath("osascript"); err == nil && goos == "darwin" {
+		notifiers = append(notifiers, desktopNotifier{name: "osascript", post: func(n desktopNotification) error {
+			return runNotifierCommand("osascript", macNotificationArgs(n)...)
+		}})
+	}
+	return append(notifiers, terminal)
+}
+
+// macNotificationArgs are the osascript arguments posting n to
+// Notification Center, with the label in the title, the length and end
+// time as the subtitle, and a sound if n asks for one. The strings go in
+// as arguments, so they need no AppleScript quoting.
+func macNotificationArgs(n desktopNotification) []string {
+	script := "display notification (item 3 of argv) with title (item 1 of argv) subtitle (item 2 of argv)"
+	if n.sound {
+		script += fmt.Sprintf(" sound name %q", macNotificationSound)
+	}
+	return []string{"-e", "on run argv", "-e", script, "-e", "end run", n.title, n.subtitle, n.text}
+}
+
+// runNotifierCommand runs a notification command, giving up after
+// dbusTimeout like the bus itself.
+func runNotifierCommand(name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbusTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// postDesktopNotification posts through the first notifier that works and
+// returns its name. It returns an error only when none did, after trying
+// them all; debug, when not nil, hears of each failure.
+func postDesktopNotification(notifiers []desktopNotifier, notification desktopNotification, debug func(format string, args ...any)) (string, error) {
+	var errs []error
+	for _, n := range notifiers {
+		err := n.post(notification)
+		if err == nil {
+			return n.name, nil
+		}
+		if debug != nil {
+			debug("notifier %s failed: %v", n.name, err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", n.name, err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// --flash switches the terminal to reverse video (DECSCNM) and back: the
+// same visible bell terminals use for "flash", without needing terminfo.
+const (
+	flashOn       = "\033[?5h"
+	flashOff      = "\033[?5l"
+	flashDuration = 150 * time.Millisecond
+)
+
+func flashScreen(w io.Writer, sleep func(time.Duration)) {
+	writeStatus(w, flashOn)
+	sleep(flashDuration)
+	writeStatus(w, flashOff)
+}
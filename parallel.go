@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// --parallel runs every --stdin entry at once. On a terminal the timers
+// share a block of lines, one per timer, which is repainted in place by
+// moving the cursor back to its top; elsewhere each start and completion
+// is logged as it happens.
+
+// parallelTimer is the state of one entry of a parallel run. outcome is
+// empty while it runs.
+type parallelTimer struct {
+	entry    batchEntry
+	deadline time.Time
+	outcome  string
+}
+
+// formatParallelLine describes timer index of total, e.g.
+// "[2/3] tea: 4:59" or "[2/3] tea: complete".
+func formatParallelLine(index, total int, t parallelTimer, now time.Time) string {
+	text := t.outcome
+	if text == "" {
+		text = formatRemainingTime(t.deadline.Sub(now))
+	}
+	return fmt.Sprintf("[%d/%d] %s", index, total, formatCountdownLine(t.entry.label, text, false))
+}
+
+// fitParallelLines keeps lines within a terminal of the given height,
+// leaving a row for the cursor. Timers that do not fit are summed up in
+// the last visible line.
+func fitParallelLines(lines []string, height int) []string {
+	rows := max(height-1, 1)
+	if len(lines) <= rows {
+		return lines
+	}
+	if rows == 1 {
+		return []string{fmt.Sprintf("… %d timers", len(lines))}
+	}
+	fitted := append([]string(nil), lines[:rows-1]...)
+	return append(fitted, fmt.Sprintf("… and %d more", len(lines)-(rows-1)))
+}
+
+// formatParallelBlock repaints the block in one write: back to the first
+// of the prevLines lines drawn last time, then every line cleared and
+// redrawn. Leftover lines from a taller block are erased. The cursor ends
+// on the last line, so the next repaint knows where it is.
+func formatParallelBlock(lines []string, prevLines, width int) string {
+	var b strings.Builder
+	b.WriteString("\r")
+	if prevLines > 1 {
+		fmt.Fprintf(&b, "\033[%dA", prevLines-1)
+	}
+	for i, line := range lines {
+		// \r as well: the terminal is in raw mode while keys are read.
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("\033[K" + truncateToWidth(line, width))
+	}
+	if len(lines) < prevLines {
+		b.WriteString("\033[J")
+	}
+	return b.String()
+}
+
+// parallelConflict returns the first flag set in inv that a parallel run
+// cannot honor, or "". Parallel timers share one display and alarm; there
+// is no per-timer hook, output stream, notification, or pause, and the
+// block of plain countdown lines takes no label, theme, style, color,
+// icons, or full-screen layout.
+func parallelConflict(inv invocation) string {
+	for _, c := range []struct {
+		flag string
+		set  bool
+	}{
+		{"--label", inv.label != ""},
+		{"--title-only", inv.titleOnly},
+		{"--percent", inv.showPercent},
+		{"--show-end", inv.showEnd},
+		{"--spinner", inv.spinner},
+		{"--elapsed", inv.display == displayElapsed},
+		{"--display", inv.display != displayRemaining},
+		{"--color", inv.color == colorAlways},
+		{"--color-thresholds", inv.colorThresholds != (colorThresholds{})},
+		{"--big", inv.bigDigits},
+		{"--fullscreen", inv.fullscreen},
+		{"--theme", inv.theme != ""},
+		{"--style", inv.style != ""},
+		{"--icons", inv.icons == iconsEmoji || inv.icons == iconsNerd},
+		{"--exec", inv.execCommand != ""},
+		{"--result-fd", inv.resultFD != 0},
+		{"--result-file", inv.resultFile != ""},
+		{"--stdout", inv.stdoutStream},
+		{"--json", inv.jsonEvents},
+		{"--porcelain", inv.porcelain},
+		{"--share", inv.share},
+		{"--dbus", inv.dbus},
+		{"--log", inv.logTo != ""},
+		{"--ics", inv.ics != ""},
+		{"--toggl", inv.trackToggl},
+		{"--notify-cancel", inv.notifyCancel},
+		{"--notify", inv.notify},
+		{"--emergency", inv.emergency},
+		{"--broadcast", inv.broadcast},
+		{"--wall", inv.wall},
+		{"--flash", inv.flash},
+		{"--realert", inv.realert},
+		{"--ack", inv.ack},
+		{"--escalate", inv.escalate},
+		{"--announce", inv.announce != ""},
+		{"--tick-sound", inv.tickSound},
+		{"--every-notify", inv.everyNotify},
+		{"--every", inv.every != 0},
+		{"--halfway", inv.halfway},
+		{"--final-beeps", inv.finalBeeps != 0},
+		{"--message", inv.message != ""},
+		{"--cancel-message", inv.cancelMessage != ""},
+		{"--pause-on-suspend", inv.pauseOnSuspend},
+		{"--idle-pause", inv.idlePause != 0},
+	} {
+		if c.set {
+			return c.flag
+		}
+	}
+	return ""
+}
+
+// runParallel runs entries side by side and returns once all of them are
+// complete. Of the flags in inv it honors only --quiet, --refresh, and the
+// alarm settings; parallelConflict rejects the rest. Cancelling stops them all.
+func runParallel(ctx context.Context, inv invocation, entries []batchEntry, status statusDisplay, sideEffectsInteractive bool, alarmStarter func(alarmSound)) error {
+	started := time.Now()
+	timers := make([]parallelTimer, len(entries))
+	for i, entry := range entries {
+		timers[i] = parallelTimer{entry: entry, deadline: started.Add(entry.duration)}
+		if !entry.target.IsZero() {
+			timers[i].deadline = entry.target
+		}
+	}
+
+	drawing := status.interactive && status.supportsAdvanced
+	width, height := stderrSize()
+	drawn := 0
+	repaint := func() {
+		if !drawing {
+			return
+		}
+		now := time.Now()
+		lines := make([]string, len(timers))
+		for i, t := range timers {
+			lines[i] = formatParallelLine(i+1, len(timers), t, now)
+		}
+		lines = fitParallelLines(lines, height)
+		writeStatus(status.writer, formatParallelBlock(lines, drawn, width))
+		drawn = len(lines)
+	}
+
+	if !inv.quiet && !drawing {
+		for i, entry := range entries {
+			writeStatusln(status.writer, formatBatchEntryStart(i+1, len(entries), entry))
+		}
+	}
+	repaint()
+
+	// The cancel keys work as they do for a single timer. Only the drawn
+	// block is written with raw-mode line endings, so keys are read only
+	// while it is shown.
+	var keyCh <-chan struct{}
+	restoreTerminal := func() {}
+	if drawing {
+		keyCh, restoreTerminal = watchCancelKeys()
+	}
+	defer restoreTerminal()
+	finishBlock := func() {
+		if drawing {
+			restoreTerminal()
+			writeStatus(status.writer, "\n")
+		}
+	}
+	cancelAll := func(cause error) error {
+		for i := range timers {
+			if timers[i].outcome == "" {
+				timers[i].outcome = outcomeCancelled
+			}
+		}
+		repaint()
+		finishBlock()
+		if !drawing && !inv.quiet {
+			writeStatusln(status.writer, "after: cancelled")
+		}
+		return cause
+	}
+
+	// ticker repaints; next fires at the earliest deadline still pending.
+	ticker := time.NewTicker(countdownTickInterval(time.Minute, false, inv.refresh))
+	defer ticker.Stop()
+	next := time.NewTimer(0)
+	defer next.Stop()
+	resize := make(chan os.Signal, 1)
+	defer notifyResize(resize)()
+
+	shouldAlarm := !inv.muteAlarm && shouldTriggerAlarm(sideEffectsInteractive && status.interactive, inv.quiet, inv.forceAlarm)
+	for {
+		select {
+		case <-ctx.Done():
+			return cancelAll(context.Cause(ctx))
+
+		case <-keyCh:
+			return cancelAll(signalCause{sig: os.Interrupt})
+
+		case <-resize:
+			width, height = stderrSize()
+			repaint()
+
+		case <-ticker.C:
+			repaint()
+
+		case now := <-next.C:
+			var pending time.Time
+			for i := range timers {
+				t := &timers[i]
+				if t.outcome != "" {
+					continue
+				}
+				if now.Before(t.deadline) {
+					if pending.IsZero() || t.deadline.Before(pending) {
+						pending = t.deadline
+					}
+					continue
+				}
+				t.outcome = outcomeComplete
+				if !inv.quiet && !drawing {
+					writeStatusln(status.writer, "after: "+formatParallelLine(i+1, len(timers), *t, now))
+				}
+				if shouldAlarm {
+					alarmStarter(inv.alarmSound())
+				}
+			}
+			repaint()
+			if pending.IsZero() {
+				finishBlock()
+				return nil
+			}
+			next.Reset(time.Until(pending))
+		}
+	}
+}
+
+// watchCancelKeys puts the controlling terminal in raw mode and reports
+// the first cancel key (q, esc, ctrl+c, ctrl+d). Keys are read from
+// /dev/tty, since stdin holds the timer list. The returned function
+// restores the terminal; it is safe to call more than once. The channel is
+// nil when there is no terminal or after runs in the background.
+func watchCancelKeys() (<-chan struct{}, func()) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, func() {}
+	}
+	if !isInForeground(tty.Fd()) {
+		_ = tty.Close()
+		return nil, func() {}
+	}
+	oldState, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		_ = tty.Close()
+		return nil, func() {}
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil || n == 0 {
+				return
+			}
+			if slices.Contains(parseTimerInput(buf[:n]), timerInputCancel) {
+				ch <- struct{}{}
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			_ = term.Restore(int(tty.Fd()), oldState)
+			_ = tty.Close()
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math"
+	"math/rand/v2"
 	"strconv"
 	"strings"
 	"time"
@@ -10,6 +12,12 @@ import (
 // unknown options (before "--") beat help/version, then help beats version.
 // Run mode requires exactly one duration token.
 func parseInvocation(args []string) (invocation, error) {
+	return parseInvocationWithUnits(args, nil)
+}
+
+// parseInvocationWithUnits is parseInvocation with custom duration units
+// from the config file.
+func parseInvocationWithUnits(args []string, units map[string]time.Duration) (invocation, error) {
 	if len(args) <= 1 {
 		return invocation{mode: modeRun}, errUsage
 	}
@@ -24,6 +32,10 @@ func parseInvocation(args []string) (invocation, error) {
 	seenDoubleDash := false
 	var firstUnknownOption string
 	var durationToken string
+	var atToken string
+	var untilToken string
+	var tzName string
+	var round time.Duration
 
 	for i := 1; i < len(args); i++ {
 		arg := args[i]
@@ -54,14 +66,407 @@ func parseInvocation(args []string) (invocation, error) {
 				inv.forceAlarm = true
 				i++ // skip path
 				continue
+			case "--announce":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				milestones, err := parseMilestones(args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				inv.announce = formatMilestones(milestones)
+				i++ // skip milestones
+				continue
+			case "--tick-sound":
+				inv.tickSound = true
+				continue
+			case "--tick-interval":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				d, err := parseFlagDuration(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				if d < 100*time.Millisecond {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.tickSound = true
+				inv.tickInterval = d
+				i++ // skip interval
+				continue
+			case "--every":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				d, err := parseFlagDuration(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				if d < time.Second {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.every = d
+				i++ // skip interval
+				continue
+			case "--every-notify":
+				inv.everyNotify = true
+				continue
+			case "--halfway":
+				inv.halfway = true
+				continue
+			case "--final-beeps":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 || n > maxFinalBeeps {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.finalBeeps = n
+				i++ // skip count
+				continue
+			case "--alarm-backend":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				backends, err := parseAlarmBackendFlag(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				inv.alarmBackends = backends
+				i++ // skip backends
+				continue
+			case "--sound-name":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				if lookupSoundName(args[i+1]) != nil {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.soundFile = soundNamePrefix + args[i+1]
+				inv.forceAlarm = true
+				i++ // skip name
+				continue
+			case "--sound-dir":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.soundFile = soundDirPrefix + args[i+1]
+				inv.forceAlarm = true
+				i++ // skip directory
+				continue
+			case "-a", "--alert":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.alertProfile = args[i+1]
+				i++ // skip profile name
+				continue
+			case "-l", "--label":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.label = args[i+1]
+				i++ // skip label
+				continue
+			case "-m", "--message":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.message = args[i+1]
+				i++ // skip message
+				continue
+			case "--cancel-message":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.cancelMessage = args[i+1]
+				i++ // skip message
+				continue
+			case "-e", "--exec":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.execCommand = args[i+1]
+				i++ // skip command
+				continue
+			case "--at":
+				if i+1 >= len(args) || atToken != "" {
+					return invocation{mode: modeRun}, errUsage
+				}
+				i++ // skip time
+				atToken = args[i]
+				if i+1 < len(args) && isAMPMToken(args[i+1]) {
+					i++
+					atToken += " " + args[i]
+				}
+				continue
+			case "--until":
+				if i+1 >= len(args) || untilToken != "" {
+					return invocation{mode: modeRun}, errUsage
+				}
+				i++ // skip date
+				untilToken = args[i]
+				// Accept an unquoted "2025-12-31 09:00".
+				if i+1 < len(args) && isDateOnlyToken(untilToken) && strings.ContainsRune(args[i+1], ':') {
+					i++
+					untilToken += " " + args[i]
+				}
+				continue
+			case "--round":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				d, err := parseFlagDuration(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				if d > 24*time.Hour {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				round = d
+				i++ // skip interval
+				continue
+			case "--stdin", "-":
+				inv.batch = true
+				continue
+			case "--parallel":
+				inv.parallel = true
+				continue
+			case "--tz":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				tzName = args[i+1]
+				i++ // skip zone
+				continue
+			case "--result-fd":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				fd, err := strconv.Atoi(args[i+1])
+				if err != nil || fd < 1 {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.resultFD = fd
+				i++ // skip descriptor
+				continue
+			case "--result-file":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.resultFile = args[i+1]
+				i++ // skip path
+				continue
+			case "--toggl":
+				inv.trackToggl = true
+				continue
+			case "--ics":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.ics = args[i+1]
+				i++ // skip path
+				continue
+			case "--idle-pause":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				d, err := parseFlagDuration(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				inv.idlePause = d
+				i++ // skip threshold
+				continue
+			case "-b", "--broadcast":
+				inv.broadcast = true
+				continue
+			case "--wall":
+				inv.wall = true
+				continue
+			case "--log":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				if args[i+1] != logSyslog {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.logTo = args[i+1]
+				i++ // skip destination
+				continue
+			case "--percent":
+				inv.showPercent = true
+				continue
+			case "--spinner":
+				inv.spinner = true
+				continue
+			case "--elapsed":
+				inv.display = displayElapsed
+				continue
+			case "--display":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				mode, ok := parseDisplayMode(args[i+1])
+				if !ok {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.display = mode
+				i++ // skip mode
+				continue
+			case "--refresh":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				d, err := parseFlagDuration(args[i], args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				if d < minRefresh {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.refresh = d
+				i++ // skip interval
+				continue
+			case "--color":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				mode, ok := parseColorMode(args[i+1])
+				if !ok {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.color = mode
+				i++ // skip mode
+				continue
+			case "--style":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				if _, err := lookupStyle(args[i+1]); err != nil {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.style = args[i+1]
+				i++ // skip style name
+				continue
+			case "--icons":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				mode, ok := parseIconMode(args[i+1])
+				if !ok {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: args[i], value: args[i+1]}
+				}
+				inv.icons = mode
+				i++ // skip mode
+				continue
+			case "--color-thresholds":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				th, err := parseColorThresholds(args[i+1])
+				if err != nil {
+					return invocation{mode: modeRun}, err
+				}
+				inv.colorThresholds = th
+				i++ // skip thresholds
+				continue
+			case "--fullscreen":
+				inv.fullscreen = true
+				continue
+			case "--big":
+				inv.fullscreen = true
+				inv.bigDigits = true
+				continue
+			case "--tmux-popup":
+				inv.tmuxPopup = true
+				continue
+			case "--theme":
+				if i+1 >= len(args) {
+					return invocation{mode: modeRun}, errUsage
+				}
+				inv.theme = args[i+1]
+				i++ // skip theme name
+				continue
+			case "--show-end":
+				inv.showEnd = true
+				continue
+			case "--stdout":
+				inv.stdoutStream = true
+				continue
+			case "--json":
+				inv.jsonEvents = true
+				continue
+			case "--porcelain":
+				inv.porcelain = true
+				continue
+			case "--share":
+				inv.share = true
+				continue
+			case "--dbus":
+				inv.dbus = true
+				continue
+			case "--no-notify":
+				inv.noNotify = true
+				continue
+			case "--notify":
+				inv.notify = true
+				continue
+			case "--notify-cancel":
+				inv.notify = true
+				inv.notifyCancel = true
+				continue
+			case "--emergency":
+				inv.emergency = true
+				continue
+			case "--flash":
+				inv.flash = true
+				continue
+			case "--realert":
+				inv.realert = true
+				continue
+			case "--ack":
+				inv.ack = true
+				continue
+			case "--escalate":
+				inv.escalate = true
+				continue
+			case "--gentle":
+				inv.gentle = true
+				continue
+			case "--pause-on-suspend":
+				inv.pauseOnSuspend = true
+				continue
 			case "-t", "--no-title":
 				inv.noTitle = true
 				continue
+			case "--title-only":
+				inv.titleOnly = true
+				continue
 			case "-c", "--caffeinate":
 				inv.forceAwake = true
 				continue
 			}
 
+			if value, ok := strings.CutPrefix(arg, "--icons="); ok {
+				mode, ok := parseIconMode(value)
+				if !ok {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: "--icons", value: value}
+				}
+				inv.icons = mode
+				continue
+			}
+			if value, ok := strings.CutPrefix(arg, "--color="); ok {
+				mode, ok := parseColorMode(value)
+				if !ok {
+					return invocation{mode: modeRun}, invalidFlagValueError{flag: "--color", value: value}
+				}
+				inv.color = mode
+				continue
+			}
+
 			if len(arg) > 0 && arg[0] == '-' && !isPotentialNegativeDuration(arg) {
 				if firstUnknownOption == "" {
 					firstUnknownOption = arg
@@ -89,14 +494,117 @@ func parseInvocation(args []string) (invocation, error) {
 	if hasVersion {
 		return invocation{mode: modeVersion}, nil
 	}
+	if inv.noTitle && inv.titleOnly {
+		return invocation{mode: modeRun}, errUsage
+	}
+	// --stdout, --json, --porcelain, and --ics - each take over stdout.
+	if (inv.stdoutStream && inv.jsonEvents) || (inv.porcelain && (inv.stdoutStream || inv.jsonEvents)) ||
+		(inv.ics == "-" && (inv.stdoutStream || inv.jsonEvents || inv.porcelain)) {
+		return invocation{mode: modeRun}, errUsage
+	}
+	if inv.parallel {
+		if !inv.batch {
+			return invocation{mode: modeRun}, errUsage
+		}
+		if flag := parallelConflict(inv); flag != "" {
+			return invocation{mode: modeRun}, flagConflictError{flag: flag, with: "--parallel"}
+		}
+	}
+	if inv.batch {
+		// Every entry would write the same file, each overwriting the last.
+		// Calendars on stdout simply follow one another.
+		if inv.resultFile != "" {
+			return invocation{mode: modeRun}, flagConflictError{flag: "--result-file", with: "--stdin"}
+		}
+		if inv.ics != "" && inv.ics != "-" {
+			return invocation{mode: modeRun}, flagConflictError{flag: "--ics", with: "--stdin"}
+		}
+		if durationToken != "" || atToken != "" || untilToken != "" || tzName != "" || round != 0 || inv.tmuxPopup {
+			return invocation{mode: modeRun}, errUsage
+		}
+		return inv, nil
+	}
+	// Targets in another zone are converted to local time once resolved, so
+	// lifecycle messages show when the event happens here.
+	loc := time.Local
+	if tzName != "" {
+		l, err := time.LoadLocation(tzName)
+		if err != nil {
+			return invocation{mode: modeRun}, invalidFlagValueError{flag: "--tz", value: tzName}
+		}
+		loc = l
+	}
+	if round != 0 {
+		if durationToken != "" || atToken != "" || untilToken != "" {
+			return invocation{mode: modeRun}, errUsage
+		}
+		now := time.Now().In(loc)
+		target := nextBoundary(now, round)
+		inv.duration = target.Sub(now)
+		inv.wallClockTarget = target.In(time.Local)
+		return inv, nil
+	}
+	if untilToken != "" {
+		if durationToken != "" || atToken != "" {
+			return invocation{mode: modeRun}, errUsage
+		}
+		target, err := parseDateTime(untilToken, loc)
+		if err != nil {
+			return invocation{mode: modeRun}, invalidFlagValueError{flag: "--until", value: untilToken}
+		}
+		now := time.Now()
+		if !target.After(now) {
+			return invocation{mode: modeRun}, errTargetInPast
+		}
+		inv.duration = target.Sub(now)
+		inv.wallClockTarget = target.In(time.Local)
+		return inv, nil
+	}
+	if atToken != "" {
+		if durationToken != "" {
+			return invocation{mode: modeRun}, errUsage
+		}
+		duration, target, ok, err := parseWallClockTime(atToken, time.Now().In(loc))
+		if !ok {
+			return invocation{mode: modeRun}, invalidFlagValueError{flag: "--at", value: atToken}
+		}
+		if err != nil {
+			return invocation{mode: modeRun}, err
+		}
+		inv.duration = duration
+		inv.wallClockTarget = target.In(time.Local)
+		return inv, nil
+	}
 	if durationToken == "" {
 		return invocation{mode: modeRun}, errUsage
 	}
+	if tzName != "" {
+		duration, target, ok, err := parseWallClockTime(durationToken, time.Now().In(loc))
+		if !ok {
+			return invocation{mode: modeRun}, errTimeZoneWithoutTarget
+		}
+		if err != nil {
+			return invocation{mode: modeRun}, err
+		}
+		inv.duration = duration
+		inv.wallClockTarget = target.In(time.Local)
+		return inv, nil
+	}
 
+	if d, ok, err := parseCustomUnitDuration(normalizeDecimalComma(durationToken), units); ok {
+		if err != nil {
+			return invocation{mode: modeRun}, err
+		}
+		inv.duration = d
+		return inv, nil
+	}
 	duration, target, err := parseDurationToken(durationToken)
 	if err != nil {
 		return invocation{mode: modeRun}, err
 	}
+	if _, _, ok, _ := parseDurationRange(durationToken); ok {
+		inv.durationRange = durationToken
+	}
 	inv.duration = duration
 	inv.wallClockTarget = target
 	return inv, nil
@@ -177,10 +685,43 @@ func expandCombinedShortFlag(arg string, knownShortFlags map[rune]cliFlag) ([]st
 	return expanded, true
 }
 
+// minRefresh keeps --refresh from turning the countdown into a busy loop.
+const minRefresh = 10 * time.Millisecond
+
+// parseFlagDuration parses a positive duration option value. Bare numbers are
+// seconds, matching the positional duration syntax.
+func parseFlagDuration(flag, value string) (time.Duration, error) {
+	value = normalizeDecimalComma(value)
+	d, err := time.ParseDuration(value)
+	if err != nil && isBareDecimalSecondsToken(value) {
+		d, err = time.ParseDuration(value + "s")
+	}
+	if err != nil || d <= 0 {
+		return 0, invalidFlagValueError{flag: flag, value: value}
+	}
+	return d, nil
+}
+
+// parseDurationToken parses the positional duration: a wall clock time, a Go
+// duration ("1h30m"), a unit-less number, which means seconds as it does
+// for sleep(1) and timeout(1) ("90" is 90s), a "+"-prefixed clock-style
+// duration ("+1:30", "+01:15:00"), or a range ("5m-10m") from which a
+// duration is picked at random.
 func parseDurationToken(token string) (time.Duration, time.Time, error) {
+	if rest, ok := strings.CutPrefix(token, "+"); ok && strings.ContainsRune(rest, ':') {
+		d, err := parseColonDuration(rest)
+		return d, time.Time{}, err
+	}
 	if d, target, ok, err := parseWallClockTime(token, time.Now()); ok {
 		return d, target, err
 	}
+	token = normalizeDecimalComma(token)
+	if lo, hi, ok, err := parseDurationRange(token); ok {
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return pickDuration(lo, hi, rand.Int64N), time.Time{}, nil
+	}
 
 	duration, err := time.ParseDuration(token)
 	if err != nil {
@@ -208,6 +749,8 @@ func parseDurationToken(token string) (time.Duration, time.Time, error) {
 //     space-separated (e.g. "9am", "9:30 PM", "12:00:00AM")
 //   - Bare hour shorthand with AM/PM suffix only (e.g. "9am", "9 pm")
 //   - Special case: 24:00 and 24:00:00 are accepted and normalized to 00:00(:00)
+//   - Minute only: :MM is the next time the minute reads MM (":00" is the top
+//     of the hour, ":30" the next half past)
 //
 // 12-hour clock conventions: 12:00 AM is midnight (00:00), 12:00 PM is noon (12:00).
 // Valid 12-hour hours are [1,12]; 0am and 13pm are rejected.
@@ -228,6 +771,18 @@ func parseWallClockTime(token string, now time.Time) (time.Duration, time.Time,
 		stripped = "00:00"
 	}
 
+	if rest, ok := strings.CutPrefix(stripped, ":"); ok && !hasSuffix {
+		min, ok := parseTimeField(rest, 0, 59)
+		if !ok || len(rest) != 2 {
+			return 0, time.Time{}, true, errInvalidTime
+		}
+		target := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), min, 0, 0, now.Location())
+		if !target.After(now) {
+			target = target.Add(time.Hour)
+		}
+		return target.Sub(now), target, true, nil
+	}
+
 	hasColon := strings.ContainsRune(stripped, ':')
 	if !hasSuffix && !hasColon {
 		return 0, time.Time{}, false, nil
@@ -291,6 +846,14 @@ func parseWallClockTime(token string, now time.Time) (time.Duration, time.Time,
 	return target.Sub(now), target, true, nil
 }
 
+// nextBoundary returns the first instant after now that is a whole multiple
+// of interval past local midnight, so 15m lands on :00, :15, :30 and :45.
+func nextBoundary(now time.Time, interval time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	elapsed := now.Sub(midnight)
+	return midnight.Add((elapsed/interval + 1) * interval)
+}
+
 // stripAMPM removes a trailing AM or PM suffix from token, case-insensitively.
 // The suffix may be directly attached ("9am", "9a") or preceded by a single space ("9 am", "9 a").
 // Returns the stripped token, whether the suffix was PM, and whether any suffix was found.
@@ -343,6 +906,17 @@ func isAMPMToken(s string) bool {
 	return lower == "am" || lower == "pm" || lower == "a" || lower == "p"
 }
 
+// isClockToken reports whether s could be the clock part of a time written
+// with a separate AM/PM word, like the "9" of "9 pm" or the "9:30" of
+// "9:30 am": digits with at most one colon between them.
+func isClockToken(s string) bool {
+	hour, minute, hasColon := strings.Cut(s, ":")
+	if hour == "" || (hasColon && minute == "") {
+		return false
+	}
+	return strings.Trim(hour+minute, "0123456789") == ""
+}
+
 // parseTimeField parses a numeric string and checks it falls within [min, max].
 // Leading zeros are accepted (e.g. "09" parses as 9). Empty strings and
 // non-numeric characters (including signs and decimal points) are rejected.
@@ -357,6 +931,123 @@ func parseTimeField(s string, min, max int) (int, bool) {
 	return v, true
 }
 
+// normalizeDecimalComma accepts a decimal comma ("1,5h") as typed on many
+// European keyboards by turning each comma between two digits into a dot.
+func normalizeDecimalComma(token string) string {
+	if !strings.ContainsRune(token, ',') {
+		return token
+	}
+	b := []byte(token)
+	for i := 1; i < len(b)-1; i++ {
+		if b[i] == ',' && isDigit(b[i-1]) && isDigit(b[i+1]) {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// dateTimeLayouts are the absolute timestamps accepted by --until, tried in
+// order. Layouts without a zone are read in the caller's location.
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDateTime parses an absolute date and time such as "2025-12-31 09:00"
+// or RFC 3339. A date alone means midnight at the start of that day.
+func parseDateTime(token string, loc *time.Location) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, token, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errInvalidTime
+}
+
+func isDateOnlyToken(token string) bool {
+	_, err := time.Parse("2006-01-02", token)
+	return err == nil
+}
+
+// parseDurationRange parses "<min>-<max>", each side a duration or bare
+// seconds. ok reports whether token is shaped like a range at all.
+func parseDurationRange(token string) (time.Duration, time.Duration, bool, error) {
+	loToken, hiToken, found := strings.Cut(token, "-")
+	if !found || loToken == "" || hiToken == "" {
+		return 0, 0, false, nil
+	}
+	lo, loErr := parseRangeBound(loToken)
+	hi, hiErr := parseRangeBound(hiToken)
+	if loErr != nil || hiErr != nil || lo >= hi {
+		return 0, 0, true, errInvalidDuration
+	}
+	return lo, hi, true, nil
+}
+
+func parseRangeBound(token string) (time.Duration, error) {
+	d, err := time.ParseDuration(token)
+	if err != nil && isBareDecimalSecondsToken(token) {
+		d, err = time.ParseDuration(token + "s")
+	}
+	if err != nil || d < 0 || token[0] == '+' {
+		return 0, errInvalidDuration
+	}
+	return d, nil
+}
+
+// pickDuration returns a uniformly random duration in [lo, hi], in whole
+// seconds when the range spans at least one.
+func pickDuration(lo, hi time.Duration, int64N func(int64) int64) time.Duration {
+	if hi-lo < time.Second {
+		return lo + time.Duration(int64N(int64(hi-lo)+1))
+	}
+	lo = (lo + time.Second - 1).Truncate(time.Second)
+	hi = hi.Truncate(time.Second)
+	return lo + time.Duration(int64N(int64((hi-lo)/time.Second)+1))*time.Second
+}
+
+// parseColonDuration parses MM:SS and HH:MM:SS. The leading field may be
+// any size ("90:00" is 90 minutes); later fields must be two digits below 60.
+// A bare colon token is a time of day, so callers require a "+" prefix.
+func parseColonDuration(token string) (time.Duration, error) {
+	fields := strings.Split(token, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, errInvalidDuration
+	}
+	units := []time.Duration{time.Minute, time.Second}
+	if len(fields) == 3 {
+		units = []time.Duration{time.Hour, time.Minute, time.Second}
+	}
+
+	var total time.Duration
+	for i, field := range fields {
+		if field == "" || (i > 0 && len(field) != 2) {
+			return 0, errInvalidDuration
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || field[0] == '+' || field[0] == '-' || (i > 0 && n > 59) {
+			return 0, errInvalidDuration
+		}
+		if int64(n) > math.MaxInt64/int64(units[i]) {
+			return 0, errDurationOutOfRange
+		}
+		d := time.Duration(n) * units[i]
+		if total > math.MaxInt64-d {
+			return 0, errDurationOutOfRange
+		}
+		total += d
+	}
+	return total, nil
+}
+
 func isBareDecimalSecondsToken(token string) bool {
 	if token == "" {
 		return false
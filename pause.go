@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	pauseReasonIdle   = "idle"
+	pauseReasonManual = "manual"
+)
+
+// errCancelledRemotely is the cancel cause when a control client (for
+// example the dashboard) cancels the timer.
+var errCancelledRemotely = errors.New("cancelled remotely")
+
+func formatManualPause(d time.Duration) string {
+	return fmt.Sprintf("paused %s", d.Round(time.Second))
+}
+
+// printManualPauseTransition reports remote pause and resume in non-TTY
+// mode. Interactive sessions show the paused state in the countdown line.
+func printManualPauseTransition(status statusDisplay, quiet bool, paused bool) {
+	if quiet || status.interactive {
+		return
+	}
+	if paused {
+		writeStatusln(status.writer, "after: paused")
+		return
+	}
+	writeStatusln(status.writer, "after: resumed")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const externalSubcommandPrefix = "after-"
+
+// externalSubcommand resolves a git-style plugin for the first argument.
+// A plugin is only considered when the first argument is not an option and
+// does not parse as a duration or time, so plugins can never shadow a timer.
+// The returned slice is the argv to exec: the plugin path followed by the
+// remaining arguments.
+func externalSubcommand(args []string, lookPath func(string) (string, error)) (string, []string, bool) {
+	if len(args) < 2 || !isExternalSubcommandName(args[1]) {
+		return "", nil, false
+	}
+	if _, _, err := parseDurationToken(args[1]); err == nil {
+		return "", nil, false
+	}
+
+	path, err := lookPath(externalSubcommandPrefix + args[1])
+	if err != nil {
+		return "", nil, false
+	}
+
+	argv := append([]string{path}, args[2:]...)
+	return path, argv, true
+}
+
+// isExternalSubcommandName reports whether name is a plausible plugin name:
+// a letter followed by letters, digits, dashes, or underscores.
+func isExternalSubcommandName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// execExternalSubcommand replaces the current process with the plugin.
+// It only returns on failure.
+func execExternalSubcommand(path string, argv []string) error {
+	return syscall.Exec(path, argv, os.Environ())
+}
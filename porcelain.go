@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// --porcelain writes one tab-separated record per lifecycle event to
+// stdout. The format is a contract with scripts: fields are never
+// reordered or reworded, new fields are only ever appended, times are
+// RFC 3339 in UTC, and seconds have millisecond precision.
+//
+//	start      started  deadline  requested_seconds  label
+//	complete   ended    elapsed_seconds  label
+//	cancelled  ended    elapsed_seconds  reason  signal  label
+//	error      exit_code  message
+//
+// Empty fields are left empty. Tabs and control characters in free text
+// are replaced with spaces.
+
+func porcelainField(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func porcelainTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatPorcelainRecord(fields ...string) string {
+	return strings.Join(fields, "\t") + "\n"
+}
+
+func formatPorcelainStart(started, deadline time.Time, label string) string {
+	return formatPorcelainRecord("start", porcelainTime(started), porcelainTime(deadline), formatSeconds(deadline.Sub(started)), porcelainField(label))
+}
+
+func formatPorcelainEnd(outcome string, started, ended time.Time, label string, cause error) string {
+	elapsed := formatSeconds(ended.Sub(started))
+	if outcome != outcomeCancelled {
+		return formatPorcelainRecord(outcome, porcelainTime(ended), elapsed, porcelainField(label))
+	}
+	reason := reasonSignal
+	if errors.Is(cause, errCancelledRemotely) {
+		reason = reasonRemote
+	}
+	signal := ""
+	var sc signalCause
+	if errors.As(cause, &sc) {
+		signal = signalName(sc.sig)
+	}
+	return formatPorcelainRecord(outcome, porcelainTime(ended), elapsed, reason, signal, porcelainField(label))
+}
+
+func formatPorcelainError(exitCode int, err error) string {
+	return formatPorcelainRecord("error", fmt.Sprint(exitCode), porcelainField(err.Error()))
+}
+
+func writePorcelain(w io.Writer, record string) {
+	_, _ = io.WriteString(w, record)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// preset is a named argument template from the config file:
+//
+//	[preset brew]
+//	args = {{.minutes}}m --sound
+//	minutes = 4
+//
+// "args" is required and may be wrapped in double quotes. Every other key is
+// the default for a placeholder and can be overridden per run:
+// "after brew minutes=3".
+type preset struct {
+	args     string
+	defaults map[string]string
+}
+
+type presetError struct {
+	name string
+	msg  string
+}
+
+func (e presetError) Error() string {
+	return fmt.Sprintf("preset %s: %s", e.name, e.msg)
+}
+
+func parsePreset(section configSection) (preset, error) {
+	p := preset{defaults: map[string]string{}}
+	hasArgs := false
+	for _, entry := range section.entries {
+		if entry.key == "args" {
+			p.args = entry.value
+			if len(p.args) >= 2 && strings.HasPrefix(p.args, `"`) && strings.HasSuffix(p.args, `"`) {
+				p.args = p.args[1 : len(p.args)-1]
+			}
+			hasArgs = true
+			continue
+		}
+		p.defaults[entry.key] = entry.value
+	}
+	if !hasArgs {
+		return preset{}, configError{line: section.line, msg: fmt.Sprintf("preset %q has no args", section.name)}
+	}
+	if _, err := newPresetTemplate(section.name, p.args); err != nil {
+		return preset{}, configError{line: section.line, msg: fmt.Sprintf("preset %q: %v", section.name, err)}
+	}
+	return p, nil
+}
+
+func newPresetTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// isPresetCandidate reports whether the first argument could name a preset.
+// Durations and times always win, as they do over plugins.
+func isPresetCandidate(args []string) bool {
+	if len(args) < 2 || !isExternalSubcommandName(args[1]) {
+		return false
+	}
+	_, _, err := parseDurationToken(args[1])
+	return err != nil
+}
+
+// expandPreset rewrites "after <preset> [key=value...] [args...]" into the
+// preset's arguments followed by any remaining arguments. key=value pairs
+// directly after the preset name fill its placeholders.
+func expandPreset(args []string, name string, p preset) ([]string, error) {
+	values := make(map[string]string, len(p.defaults))
+	for k, v := range p.defaults {
+		values[k] = v
+	}
+
+	rest := args[2:]
+	for len(rest) > 0 {
+		key, value, ok := strings.Cut(rest[0], "=")
+		if !ok || !isExternalSubcommandName(key) {
+			break
+		}
+		values[key] = value
+		rest = rest[1:]
+	}
+
+	tmpl, err := newPresetTemplate(name, p.args)
+	if err != nil {
+		return nil, presetError{name: name, msg: err.Error()}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, values); err != nil {
+		return nil, presetError{name: name, msg: fmt.Sprintf("missing value (known: %s)", strings.Join(sortedKeys(values), ", "))}
+	}
+
+	expanded, err := splitArgs(b.String())
+	if err != nil {
+		return nil, presetError{name: name, msg: err.Error()}
+	}
+
+	out := make([]string, 0, 1+len(expanded)+len(rest))
+	out = append(out, args[0])
+	out = append(out, expanded...)
+	out = append(out, rest...)
+	return out, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return []string{"none"}
+	}
+	return keys
+}
+
+// splitArgs splits s into words with POSIX-shell-like quoting: single quotes
+// are literal, double quotes allow backslash escapes, and a backslash
+// outside quotes escapes the next character.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]):
+				i++
+				cur.WriteRune(runes[i])
+			default:
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// alertProfile is a named bundle of alert settings selected with --alert.
+// Settings left unset in the profile keep their command-line values.
+type alertProfile struct {
+	sound        *bool
+	soundFile    string
+	quiet        *bool
+	broadcast    *bool
+	wall         *bool
+	flash        *bool
+	gentle       *bool
+	notify       *bool
+	notifyCancel *bool
+	// remote lists the remote notifiers to send to, comma-separated, or
+	// is "none"; empty leaves every configured one on.
+	remote string
+}
+
+type unknownAlertProfileError struct {
+	name string
+}
+
+func (e unknownAlertProfileError) Error() string {
+	return fmt.Sprintf("unknown alert profile: %s", e.name)
+}
+
+func parseAlertProfile(section configSection) (alertProfile, error) {
+	var profile alertProfile
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "sound":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.sound = &v
+		case "sound-file":
+			profile.soundFile = entry.value
+		case "sound-name":
+			if err := lookupSoundName(entry.value); err != nil {
+				return alertProfile{}, configError{line: entry.line, msg: err.Error()}
+			}
+			profile.soundFile = soundNamePrefix + entry.value
+		case "sound-dir":
+			profile.soundFile = soundDirPrefix + entry.value
+		case "quiet":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.quiet = &v
+		case "broadcast":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.broadcast = &v
+		case "wall":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.wall = &v
+		case "flash":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.flash = &v
+		case "gentle":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.gentle = &v
+		case "notify":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.notify = &v
+		case "notify-cancel":
+			v, err := parseConfigBool(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.notifyCancel = &v
+		case "remote":
+			remote, err := parseRemoteNotifierList(entry)
+			if err != nil {
+				return alertProfile{}, err
+			}
+			profile.remote = remote
+		default:
+			return alertProfile{}, configError{line: entry.line, msg: fmt.Sprintf("unknown alert setting %q", entry.key)}
+		}
+	}
+	return profile, nil
+}
+
+// parseRemoteNotifierList checks a "pushover, slack" list of remote
+// notifier names, or "none", and returns it without spaces.
+func parseRemoteNotifierList(entry configEntry) (string, error) {
+	if strings.TrimSpace(entry.value) == "none" {
+		return "none", nil
+	}
+	var names []string
+	for _, name := range strings.Split(entry.value, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(remoteNotifierNames, name) {
+			return "", configError{line: entry.line, msg: fmt.Sprintf("unknown remote notifier %q (want %s, or none)", name, strings.Join(remoteNotifierNames, ", "))}
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ","), nil
+}
+
+// applyAlertProfile layers profile settings onto inv. Explicit flags that
+// enable behavior (--sound, --sound-file, --quiet, ...) are never switched off
+// by a profile, so a one-off flag can still add to a named profile.
+func applyAlertProfile(inv invocation, profile alertProfile) invocation {
+	if profile.quiet != nil && *profile.quiet {
+		inv.quiet = true
+	}
+	if profile.broadcast != nil && *profile.broadcast {
+		inv.broadcast = true
+	}
+	if profile.wall != nil && *profile.wall {
+		inv.wall = true
+	}
+	if profile.flash != nil && *profile.flash {
+		inv.flash = true
+	}
+	if profile.gentle != nil && *profile.gentle {
+		inv.gentle = true
+	}
+	if profile.notify != nil && *profile.notify {
+		inv.notify = true
+	}
+	if profile.notifyCancel != nil && *profile.notifyCancel {
+		inv.notify = true
+		inv.notifyCancel = true
+	}
+	if profile.remote != "" {
+		inv.remote = profile.remote
+	}
+	if profile.soundFile != "" && inv.soundFile == "" {
+		inv.soundFile = profile.soundFile
+		inv.forceAlarm = true
+	}
+	if profile.sound != nil {
+		if *profile.sound {
+			inv.forceAlarm = true
+		} else if !inv.forceAlarm {
+			inv.muteAlarm = true
+		}
+	}
+	return inv
+}
+
+// alertFlags records the alert settings given on the command line, so a
+// profile can be swapped on a running timer without losing them.
+type alertFlags struct {
+	quiet        bool
+	forceAlarm   bool
+	soundFile    string
+	broadcast    bool
+	wall         bool
+	flash        bool
+	gentle       bool
+	notify       bool
+	notifyCancel bool
+}
+
+// defaultAlertProfile names the profile used when --alert is not given, so
+// the config can set, say, a sound file for every timer.
+const defaultAlertProfile = "default"
+
+// resolveAlertProfile looks up inv.alertProfile, or the default profile
+// when there is none, in cfg and applies it.
+func resolveAlertProfile(inv invocation, cfg config) (invocation, error) {
+	if inv.alertProfile == "" {
+		if _, ok := cfg.alerts[defaultAlertProfile]; !ok {
+			return inv, nil
+		}
+		inv.alertProfile = defaultAlertProfile
+	}
+	profile, ok := cfg.alerts[inv.alertProfile]
+	if !ok {
+		return inv, unknownAlertProfileError{name: inv.alertProfile}
+	}
+	inv.alertBase = alertFlags{quiet: inv.quiet, forceAlarm: inv.forceAlarm, soundFile: inv.soundFile, broadcast: inv.broadcast, wall: inv.wall, flash: inv.flash, gentle: inv.gentle, notify: inv.notify, notifyCancel: inv.notifyCancel}
+	return applyAlertProfile(inv, profile), nil
+}
+
+// switchAlertProfile replaces the active profile, starting again from the
+// command-line alert flags. An empty name goes back to the default profile,
+// or to none.
+func switchAlertProfile(inv invocation, name string, cfg config) (invocation, error) {
+	if inv.alertProfile != "" {
+		inv.quiet = inv.alertBase.quiet
+		inv.forceAlarm = inv.alertBase.forceAlarm
+		inv.soundFile = inv.alertBase.soundFile
+		inv.broadcast = inv.alertBase.broadcast
+		inv.wall = inv.alertBase.wall
+		inv.flash = inv.alertBase.flash
+		inv.gentle = inv.alertBase.gentle
+		inv.notify = inv.alertBase.notify
+		inv.notifyCancel = inv.alertBase.notifyCancel
+		inv.remote = ""
+		inv.muteAlarm = false
+	}
+	inv.alertProfile = name
+	return resolveAlertProfile(inv, cfg)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const promptUsageText = "Usage: after prompt [<id>]\n\n" +
+	"Prints a compact countdown such as \"⏳12:30\" for shell prompt segments\n" +
+	"(starship, powerlevel10k); prints nothing when no timer is running."
+
+const (
+	promptRunningIcon = "⏳"
+	promptPausedIcon  = "⏸"
+)
+
+// formatPromptSegment renders state for a shell prompt, e.g. "⏳12:30".
+func formatPromptSegment(state controlState) string {
+	icon := promptRunningIcon
+	if state.State == timerStatePaused {
+		icon = promptPausedIcon
+	}
+	return icon + formatRemainingTime(time.Duration(state.Remaining*float64(time.Second)))
+}
+
+func runPromptCommand(args []string, stdout, stderr io.Writer) int {
+	id := 0
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && isTimerID(args[0]):
+		id, _ = strconv.Atoi(args[0])
+	default:
+		fmt.Fprintln(stderr, promptUsageText)
+		return 2
+	}
+
+	// Prompts run this on every command line, so it never waits: a timer
+	// that is not there is simply not shown.
+	if state, ok := pickStatusTimer(runningTimers(controlDir(os.Getenv)), id); ok {
+		fmt.Fprintln(stdout, formatPromptSegment(state))
+	}
+	return 0
+}
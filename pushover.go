@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With a Pushover application token and user key, from the [pushover]
+// config section or the environment, a completed timer also sends a push
+// to the user's devices:
+//
+//	[pushover]
+//	token = <application token>
+//	user = <user key>
+//	priority = high
+//
+// --emergency sends that timer's push at emergency priority, which
+// Pushover repeats until it is acknowledged on a device.
+
+const (
+	pushoverTokenEnvVar = "AFTER_PUSHOVER_TOKEN"
+	pushoverUserEnvVar  = "AFTER_PUSHOVER_USER"
+)
+
+const pushoverEndpoint = "https://api.pushover.net/1/messages.json"
+
+// Pushover repeats an emergency push every pushoverRetry until it is
+// acknowledged, for at most pushoverExpire.
+const (
+	pushoverRetry  = time.Minute
+	pushoverExpire = time.Hour
+)
+
+const pushoverEmergency = 2
+
+// pushoverPriorities names Pushover's message priorities.
+var pushoverPriorities = map[string]int{
+	"lowest":    -2,
+	"low":       -1,
+	"normal":    0,
+	"high":      1,
+	"emergency": pushoverEmergency,
+}
+
+// pushoverConfig is where and how to push. The zero value sends nothing.
+type pushoverConfig struct {
+	token    string
+	user     string
+	priority int
+}
+
+func (p pushoverConfig) set() bool {
+	return p.token != "" && p.user != ""
+}
+
+func parsePushoverSection(section configSection) (pushoverConfig, error) {
+	var p pushoverConfig
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "token":
+			p.token = entry.value
+		case "user":
+			p.user = entry.value
+		case "priority":
+			priority, err := parsePushoverPriority(entry.value)
+			if err != nil {
+				return pushoverConfig{}, configError{line: entry.line, msg: err.Error()}
+			}
+			p.priority = priority
+		default:
+			return pushoverConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown pushover setting %q", entry.key)}
+		}
+	}
+	return p, nil
+}
+
+// parsePushoverPriority reads a priority by name or as a number from -2
+// to 2.
+func parsePushoverPriority(s string) (int, error) {
+	if p, ok := pushoverPriorities[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	if p, err := strconv.Atoi(s); err == nil && p >= -2 && p <= pushoverEmergency {
+		return p, nil
+	}
+	return 0, fmt.Errorf("priority: expected lowest, low, normal, high, or emergency, got %q", s)
+}
+
+// resolvePushover fills in the token and user key from the environment,
+// which take precedence over the config file.
+func resolvePushover(p pushoverConfig, getenv func(string) string) pushoverConfig {
+	if token := getenv(pushoverTokenEnvVar); token != "" {
+		p.token = token
+	}
+	if user := getenv(pushoverUserEnvVar); user != "" {
+		p.user = user
+	}
+	return p
+}
+
+// pushoverForm is the request that pushes n, at emergency priority if
+// emergency is set.
+func pushoverForm(p pushoverConfig, n desktopNotification, emergency bool) url.Values {
+	form := url.Values{
+		"token":   {p.token},
+		"user":    {p.user},
+		"title":   {n.title},
+		"message": {n.body()},
+	}
+	priority := p.priority
+	if emergency {
+		priority = pushoverEmergency
+	}
+	if priority != 0 {
+		form.Set("priority", strconv.Itoa(priority))
+	}
+	if priority == pushoverEmergency {
+		form.Set("retry", strconv.Itoa(int(pushoverRetry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(pushoverExpire.Seconds())))
+	}
+	return form
+}
+
+// sendPushover posts form to endpoint and reports what Pushover rejected.
+func sendPushover(client *http.Client, endpoint string, form url.Values) error {
+	resp, err := client.PostForm(endpoint, form)
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Status int      `json:"status"`
+		Errors []string `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode == http.StatusOK && decodeErr == nil && reply.Status == 1 {
+		return nil
+	}
+	if len(reply.Errors) > 0 {
+		return fmt.Errorf("pushover: %s", strings.Join(reply.Errors, "; "))
+	}
+	return fmt.Errorf("pushover: %s", resp.Status)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quietHours is a daily window during which the completion alarm flashes
+// the screen instead of playing a sound, so a timer left running overnight
+// does not wake anyone. start and end are minutes after midnight; the
+// window may wrap past midnight. The zero value is no window.
+type quietHours struct {
+	start, end int
+	set        bool
+}
+
+// parseQuietHours reads a window such as "22:00-07:00" or "10pm-7am".
+func parseQuietHours(s string) (quietHours, error) {
+	from, to, ok := strings.Cut(strings.ReplaceAll(s, "–", "-"), "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("quiet hours %q: expected a range like 22:00-07:00", s)
+	}
+	start, err := parseMinuteOfDay(strings.TrimSpace(from))
+	if err != nil {
+		return quietHours{}, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	end, err := parseMinuteOfDay(strings.TrimSpace(to))
+	if err != nil {
+		return quietHours{}, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	if start == end {
+		return quietHours{}, fmt.Errorf("quiet hours %q: start and end are the same", s)
+	}
+	return quietHours{start: start, end: end, set: true}, nil
+}
+
+// parseMinuteOfDay reads a time of day in any form --at accepts.
+func parseMinuteOfDay(token string) (int, error) {
+	ref := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, target, ok, err := parseWallClockTime(token, ref)
+	if !ok || err != nil || strings.HasPrefix(token, ":") {
+		return 0, errInvalidTime
+	}
+	return target.Hour()*60 + target.Minute(), nil
+}
+
+// contains reports whether t falls inside the window, by its local time of
+// day. The end is exclusive: 22:00-07:00 is quiet until 06:59.
+func (q quietHours) contains(t time.Time) bool {
+	if !q.set {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return q.start <= m && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+func (q quietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.start/60, q.start%60, q.end/60, q.end%60)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Remote notifiers tell services off this machine that a timer completed,
+// or for some that it was cancelled: Pushover, email, and the chat
+// services configured in their own sections. They are all sent at the same time,
+// while the alarm rings.
+
+// remoteTimeout bounds sending to each service, so an unreachable network
+// cannot hold up the completion for long.
+const remoteTimeout = 10 * time.Second
+
+// A remoteNotifier sends one service the message about event. Only those
+// with onCancel are told about cancelled timers.
+type remoteNotifier struct {
+	name     string
+	onCancel bool
+	send     func(client *http.Client, event remoteEvent) error
+}
+
+// remoteEvent is what remote notifiers know about how the timer ended.
+type remoteEvent struct {
+	timerEvent
+	label        string
+	remaining    time.Duration
+	host         string
+	notification desktopNotification
+}
+
+func newRemoteEvent(event timerEvent, label string, remaining time.Duration, host string) remoteEvent {
+	return remoteEvent{
+		timerEvent:   event,
+		label:        label,
+		remaining:    remaining,
+		host:         host,
+		notification: formatDesktopNotification(event, label, remaining),
+	}
+}
+
+// remoteNotifierNames are the remote notifiers, in the order they are listed.
+var remoteNotifierNames = []string{"pushover", "slack", "telegram", "email", "discord"}
+
+// remoteNotifiers lists the services inv is set up to notify of outcome:
+// every configured one, or those an alert profile's remote key names.
+func remoteNotifiers(inv invocation, outcome string) []remoteNotifier {
+	var notifiers []remoteNotifier
+	if inv.pushover.set() {
+		notifiers = append(notifiers, remoteNotifier{name: "pushover", send: func(client *http.Client, event remoteEvent) error {
+			return sendPushover(client, pushoverEndpoint, pushoverForm(inv.pushover, event.notification, inv.emergency))
+		}})
+	}
+	if inv.slack.set() {
+		notifiers = append(notifiers, remoteNotifier{name: "slack", send: func(client *http.Client, event remoteEvent) error {
+			return sendSlack(client, inv.slack, event)
+		}})
+	}
+	if inv.telegram.set() {
+		notifiers = append(notifiers, remoteNotifier{name: "telegram", send: func(client *http.Client, event remoteEvent) error {
+			return sendTelegram(client, telegramEndpoint, inv.telegram, event)
+		}})
+	}
+	if inv.email.set() {
+		notifiers = append(notifiers, remoteNotifier{name: "email", send: func(_ *http.Client, event remoteEvent) error {
+			return sendEmail(inv.email, event, remoteTimeout)
+		}})
+	}
+	if inv.discord.set() {
+		notifiers = append(notifiers, remoteNotifier{name: "discord", onCancel: !inv.discord.noCancel, send: func(client *http.Client, event remoteEvent) error {
+			return sendDiscord(client, inv.discord, event)
+		}})
+	}
+	if inv.remote != "" {
+		enabled := strings.Split(inv.remote, ",")
+		notifiers = slices.DeleteFunc(notifiers, func(n remoteNotifier) bool { return !slices.Contains(enabled, n.name) })
+	}
+	if outcome == outcomeCancelled {
+		notifiers = slices.DeleteFunc(notifiers, func(n remoteNotifier) bool { return !n.onCancel })
+	}
+	return notifiers
+}
+
+// sendRemoteNotifications sends event through every notifier at once and
+// returns the errors of those that failed.
+func sendRemoteNotifications(notifiers []remoteNotifier, event remoteEvent) []error {
+	client := &http.Client{Timeout: remoteTimeout}
+	errs := make([]error, len(notifiers))
+	var wg sync.WaitGroup
+	for i, n := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.send(client, event)
+		}()
+	}
+	wg.Wait()
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// printRemoteFailure reports a notifier that failed. It arrives while the
+// countdown or the alarm line may be drawn, so it takes the line over
+// rather than landing in the middle of a frame.
+func printRemoteFailure(status statusDisplay, err error) {
+	exclusiveStatus(status, func() {
+		if status.interactive {
+			writeInteractiveLine(status, "Warning: "+err.Error())
+			return
+		}
+		writeStatusln(status.writer, "Warning:", err)
+	})
+}
+
+// withoutURL drops the URL from an error of the HTTP client, for services
+// whose URL is a secret.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// A message template is a text/template with the fields of messageFields,
+// set per service with "template = ...", e.g.
+//
+//	template = {{.label}} done on {{.host}} after {{.elapsed}}
+const defaultMessageTemplate = "{{.title}}: {{.text}} ({{.detail}})"
+
+// messageFields are the values a message template can use.
+func messageFields(event remoteEvent) map[string]string {
+	return map[string]string{
+		"title":   event.notification.title,
+		"text":    event.notification.text,
+		"detail":  event.notification.subtitle,
+		"label":   event.label,
+		"outcome": event.outcome,
+		"length":  formatSpokenDuration(event.requested),
+		"elapsed": formatSpokenDuration(event.ended.Sub(event.started)),
+		"left":    formatSpokenDuration(event.remaining),
+		"ended":   event.ended.Format("15:04"),
+		"host":    event.host,
+	}
+}
+
+// renderMessage fills in text, or the default template when text is empty.
+func renderMessage(text string, event remoteEvent) (string, error) {
+	if text == "" {
+		text = defaultMessageTemplate
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, messageFields(event)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// parseMessageTemplate checks a "template" config entry, so a mistake is
+// reported when the config is read rather than when a timer completes.
+func parseMessageTemplate(entry configEntry) (string, error) {
+	text := entry.value
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		text = text[1 : len(text)-1]
+	}
+	if text == "" {
+		return "", configError{line: entry.line, msg: "template: expected a message template"}
+	}
+	if _, err := renderMessage(text, newRemoteEvent(timerEvent{outcome: outcomeComplete}, "", 0, "")); err != nil {
+		var execErr template.ExecError
+		if errors.As(err, &execErr) {
+			err = execErr.Err
+		}
+		return "", configError{line: entry.line, msg: fmt.Sprintf("template: %v", err)}
+	}
+	return text, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// slowWriteThreshold is the write latency above which the terminal is
+	// considered slow (laggy SSH, serial consoles) and redraws are backed off.
+	slowWriteThreshold = 100 * time.Millisecond
+	maxRenderInterval  = 5 * time.Second
+)
+
+// A countdownFrame is one redraw of the countdown. prefix is always
+// written; title and line, both complete escape sequences, only when they
+// differ from what is on the terminal.
+type countdownFrame struct {
+	prefix string
+	title  string
+	line   string
+}
+
+// frameDiff remembers what the last written frame left on the terminal, so
+// an unchanged title or line is not sent again: the countdown ticks more
+// often than its text changes, and every redundant write is SSH traffic
+// and, in some emulators, flicker.
+type frameDiff struct {
+	drawn bool
+	title string
+	line  string
+}
+
+// render returns what must be written to turn the last frame into f, or ""
+// when nothing changed.
+func (d *frameDiff) render(f countdownFrame) string {
+	out := f.prefix
+	if !d.drawn || f.title != d.title {
+		out += f.title
+	}
+	if !d.drawn || f.line != d.line {
+		out += f.line
+	}
+	d.drawn, d.title, d.line = true, f.title, f.line
+	return out
+}
+
+// frameRenderer writes countdown frames from its own goroutine so a slow or
+// blocked terminal never stalls the timer loop. Only the latest frame is
+// kept: if the terminal falls behind, intermediate frames are dropped, and
+// the minimum interval between writes adapts to the observed write latency.
+// Frames are diffed against the last one written, not the last submitted,
+// so dropping frames never leaves a stale title or line behind.
+type frameRenderer struct {
+	out     io.Writer
+	pending chan countdownFrame
+	stop    chan struct{}
+	done    chan struct{}
+
+	// mu is held for the duration of every terminal write so that other
+	// output (lifecycle messages) can be serialized against frames.
+	mu       sync.Mutex
+	interval time.Duration
+	// diff is guarded by mu.
+	diff frameDiff
+}
+
+func newFrameRenderer(out io.Writer) *frameRenderer {
+	r := &frameRenderer{
+		out:     out,
+		pending: make(chan countdownFrame, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// submit queues frame for writing in full, replacing any frame not yet
+// written. It never blocks.
+func (r *frameRenderer) submit(frame string) {
+	r.submitFrame(countdownFrame{prefix: frame})
+}
+
+// submitFrame is submit for a frame whose unchanged parts may be skipped.
+func (r *frameRenderer) submitFrame(frame countdownFrame) {
+	for {
+		select {
+		case r.pending <- frame:
+			return
+		default:
+		}
+		select {
+		case <-r.pending:
+		default:
+		}
+	}
+}
+
+// exclusive runs fn while no frame is being written and discards any queued
+// frame, so fn's output cannot interleave with or be overwritten by a stale
+// countdown frame. fn may move the cursor, so the next frame is drawn in
+// full.
+func (r *frameRenderer) exclusive(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.pending:
+	default:
+	}
+	fn()
+	r.diff = frameDiff{}
+}
+
+// redraw makes the next frame be drawn in full, e.g. after a resize
+// reflowed the line.
+func (r *frameRenderer) redraw() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diff = frameDiff{}
+}
+
+// close stops the renderer after writing any queued frame, so output stays
+// in the order it was produced. The interval wait is skipped.
+func (r *frameRenderer) close() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *frameRenderer) run() {
+	defer close(r.done)
+	var lastWrite time.Time
+
+	for {
+		var frame countdownFrame
+		select {
+		case <-r.stop:
+			r.flush()
+			return
+		case frame = <-r.pending:
+		}
+
+		if wait := r.interval - time.Since(lastWrite); wait > 0 {
+			select {
+			case <-r.stop:
+				select {
+				case frame = <-r.pending:
+				default:
+				}
+				r.write(frame)
+				return
+			case <-time.After(wait):
+			}
+			// Prefer whatever arrived while waiting; it is more current.
+			select {
+			case frame = <-r.pending:
+			default:
+			}
+		}
+
+		cost, wrote := r.write(frame)
+		if !wrote {
+			// Nothing changed; the interval only tracks real writes.
+			continue
+		}
+		lastWrite = time.Now()
+		r.interval = nextRenderInterval(r.interval, cost)
+	}
+}
+
+// write writes what changed since the last frame and reports how long it
+// took, or false when there was nothing to write.
+func (r *frameRenderer) write(frame countdownFrame) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.diff.render(frame)
+	if out == "" {
+		return 0, false
+	}
+	start := time.Now()
+	writeStatus(r.out, out)
+	return time.Since(start), true
+}
+
+func (r *frameRenderer) flush() {
+	select {
+	case frame := <-r.pending:
+		r.write(frame)
+	default:
+	}
+}
+
+// nextRenderInterval backs off quickly when writes are slow and recovers
+// gradually once they are fast again. An interval of zero means frames are
+// written as soon as the timer loop produces them.
+func nextRenderInterval(current, writeCost time.Duration) time.Duration {
+	if writeCost >= slowWriteThreshold {
+		next := max(current*2, writeCost*4)
+		return min(next, maxRenderInterval)
+	}
+	if writeCost < slowWriteThreshold/4 {
+		next := current / 2
+		if next < slowWriteThreshold {
+			return 0
+		}
+		return next
+	}
+	return current
+}
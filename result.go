@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	reasonDeadline = "deadline"
+	reasonSignal   = "signal"
+	reasonRemote   = "remote"
+)
+
+// timerResult is the JSON object written by --result-fd and --result-file.
+type timerResult struct {
+	Outcome          string    `json:"outcome"`
+	Reason           string    `json:"reason"`
+	Signal           string    `json:"signal,omitempty"`
+	ExitCode         int       `json:"exit_code"`
+	Label            string    `json:"label,omitempty"`
+	Message          string    `json:"message,omitempty"`
+	RequestedSeconds float64   `json:"requested_seconds"`
+	ElapsedSeconds   float64   `json:"elapsed_seconds"`
+	SuspendedSeconds float64   `json:"suspended_seconds,omitempty"`
+	Started          time.Time `json:"started"`
+	Ended            time.Time `json:"ended"`
+	Deadline         time.Time `json:"deadline"`
+}
+
+func newTimerResult(event timerEvent, label string, cause error) timerResult {
+	result := timerResult{
+		Outcome:          event.outcome,
+		Reason:           reasonDeadline,
+		Signal:           signalName(event.signal),
+		Label:            label,
+		Message:          event.message,
+		RequestedSeconds: event.requested.Round(time.Millisecond).Seconds(),
+		ElapsedSeconds:   event.ended.Sub(event.started).Round(time.Millisecond).Seconds(),
+		SuspendedSeconds: event.suspended.Round(time.Millisecond).Seconds(),
+		Started:          event.started,
+		Ended:            event.ended,
+		Deadline:         event.deadline,
+	}
+	if event.outcome == outcomeCancelled {
+		result.ExitCode = exitCodeForCancelError(cause)
+		result.Reason = reasonSignal
+		if errors.Is(cause, errCancelledRemotely) {
+			result.Reason = reasonRemote
+		}
+	}
+	return result
+}
+
+// writeResult writes result as a single JSON line to the descriptor or file
+// requested on the command line.
+func writeResult(inv invocation, result timerResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if inv.resultFile != "" {
+		if err := os.WriteFile(inv.resultFile, data, 0o644); err != nil {
+			return fmt.Errorf("--result-file: %w", err)
+		}
+	}
+	if inv.resultFD > 0 {
+		f := os.NewFile(uintptr(inv.resultFD), "result-fd")
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("--result-fd %d: %w", inv.resultFD, err)
+		}
+	}
+	return nil
+}
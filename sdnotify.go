@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Under systemd with Type=notify, $NOTIFY_SOCKET is set and after reports
+// to the service manager like a proper service: READY=1 once the countdown
+// starts, the time left in STATUS= (shown by systemctl status), and
+// WATCHDOG=1 pings when the unit sets WatchdogSec=.
+
+const (
+	sdNotifySocketEnvVar = "NOTIFY_SOCKET"
+	sdWatchdogUSecEnvVar = "WATCHDOG_USEC"
+	sdWatchdogPIDEnvVar  = "WATCHDOG_PID"
+)
+
+// sdStatusInterval is how often STATUS= is updated with the time left.
+const sdStatusInterval = time.Second
+
+// sdNotifier sends state to the service manager. A nil one sends nothing.
+type sdNotifier struct {
+	conn *net.UnixConn
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openSDNotify connects to the service manager's socket, or returns nil
+// when after was not started by one.
+func openSDNotify(getenv func(string) string) (*sdNotifier, error) {
+	path := getenv(sdNotifySocketEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+	// A leading @ names an abstract socket, which net understands.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &sdNotifier{conn: conn, stop: make(chan struct{})}, nil
+}
+
+// send writes state, newline-separated assignments like READY=1. Failures
+// are dropped: the timer outlives a service manager that stops listening.
+func (n *sdNotifier) send(state string) {
+	if n == nil {
+		return
+	}
+	_, _ = n.conn.Write([]byte(state))
+}
+
+// sdWatchdogInterval is how often to ping the watchdog: half its timeout,
+// as sd_watchdog_enabled(3) advises, or 0 when it is off or meant for
+// another process.
+func sdWatchdogInterval(getenv func(string) string, pid int) time.Duration {
+	usec, err := strconv.ParseInt(getenv(sdWatchdogUSecEnvVar), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if p := getenv(sdWatchdogPIDEnvVar); p != "" && p != strconv.Itoa(pid) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// startWatchdog pings every interval until close. The pings come from
+// their own goroutine so that a timer ringing for an --ack, which can take
+// any time, is not mistaken for a hung one.
+func (n *sdNotifier) startWatchdog(interval time.Duration) {
+	if n == nil || interval <= 0 {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-n.stop:
+				return
+			case <-tick.C:
+				n.send("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+func (n *sdNotifier) close() {
+	if n == nil {
+		return
+	}
+	close(n.stop)
+	n.wg.Wait()
+	_ = n.conn.Close()
+}
+
+// formatSDStatus describes the countdown for STATUS=.
+func formatSDStatus(label string, remaining time.Duration, paused bool) string {
+	status := formatSpokenDuration(max(remaining, 0)) + " left"
+	if paused {
+		status = "paused, " + status
+	}
+	if label != "" {
+		status = label + ": " + status
+	}
+	return "STATUS=" + status
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "after serve" runs an HTTP server for timers shared by a team, say on a
+// wall display: a page showing every running timer, a small REST API to
+// start, list, and cancel them, and a stream of their state as Server-Sent
+// Events for pages and OBS browser sources. Timers it starts are ordinary
+// after processes, detached from the server, so "after status" and friends
+// see them too, and they keep running if the server stops.
+
+const serveUsageText = "Usage: after serve [--listen <addr>]\n\n" +
+	"Serves a page of running timers and a REST API to start, list, and\n" +
+	"cancel them, on localhost:8080 unless --listen says otherwise\n" +
+	"(--listen :8080 for every interface). Timers are started and\n" +
+	"cancelled only through localhost; elsewhere the page is read-only."
+
+const serveDefaultListen = "localhost:8080"
+
+// serveStartTimeout bounds waiting for a started timer's control socket.
+const serveStartTimeout = 2 * time.Second
+
+// serveRefreshSeconds is how often the page reloads without JavaScript.
+const serveRefreshSeconds = 1
+
+// serveTickInterval is how often the event stream sends the timers, often
+// enough that a countdown shown from it changes right on the second.
+const serveTickInterval = 250 * time.Millisecond
+
+// serveTimer is a timer as the page and the event stream show it: its
+// state, and its time left as after displays it.
+type serveTimer struct {
+	controlState
+	Display string `json:"display"`
+}
+
+func newServeTimer(t controlState) serveTimer {
+	remaining := time.Duration(t.Remaining * float64(time.Second))
+	return serveTimer{controlState: t, Display: formatRemainingTime(max(remaining, 0))}
+}
+
+// serveStartRequest is the body of POST /api/timers.
+type serveStartRequest struct {
+	Duration string `json:"duration"`
+	Label    string `json:"label,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// args are the command-line arguments of the timer req starts.
+func (req serveStartRequest) args() []string {
+	args := []string{req.Duration, "--quiet"}
+	if req.Label != "" {
+		args = append(args, "--label", req.Label)
+	}
+	if req.Message != "" {
+		args = append(args, "--message", req.Message)
+	}
+	return args
+}
+
+// timerServer answers the API from the timers in dir, starting new ones
+// with start, which returns the new timer's id. Durations may use the
+// custom units.
+type timerServer struct {
+	dir   string
+	units map[string]time.Duration
+	start func(args []string) (int, error)
+}
+
+func (s timerServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.page)
+	mux.HandleFunc("GET /api/timers", s.list)
+	mux.HandleFunc("POST /api/timers", s.create)
+	mux.HandleFunc("GET /api/timers/{id}", s.get)
+	mux.HandleFunc("DELETE /api/timers/{id}", s.cancel)
+	mux.HandleFunc("GET /api/events", s.events)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s timerServer) list(w http.ResponseWriter, r *http.Request) {
+	timers := runningTimers(s.dir)
+	if timers == nil {
+		timers = []controlState{}
+	}
+	writeJSON(w, http.StatusOK, timers)
+}
+
+// isLoopbackHost reports whether hostport, a Host header or the host of an
+// Origin, names this machine.
+func isLoopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkLocalRequest refuses a request that changes timers unless it came
+// through localhost. A page on another site can make the browser send one:
+// its Origin gives it away, and a name it rebinds to 127.0.0.1 still
+// arrives as the Host.
+func checkLocalRequest(r *http.Request) error {
+	if !isLoopbackHost(r.Host) {
+		return fmt.Errorf("host %q: timers are started and cancelled only through localhost", r.Host)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !isLoopbackHost(u.Host) {
+			return fmt.Errorf("origin %q: timers are started and cancelled only from localhost", origin)
+		}
+	}
+	return nil
+}
+
+func (s timerServer) create(w http.ResponseWriter, r *http.Request) {
+	if err := checkLocalRequest(r); err != nil {
+		writeJSONError(w, http.StatusForbidden, err)
+		return
+	}
+	// A form post needs no preflight, so it must not pass for JSON.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+		return
+	}
+	var req serveStartRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("malformed request: %w", err))
+		return
+	}
+	// The duration must not pass for an option, which could run a
+	// command through --exec.
+	if req.Duration == "" || strings.HasPrefix(req.Duration, "-") {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("duration: expected a duration or time, got %q", req.Duration))
+		return
+	}
+	args := req.args()
+	inv, err := parseInvocationWithUnits(append([]string{"after"}, args...), s.units)
+	if err == nil && inv.mode != modeRun {
+		err = errUsage
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	state, err := startTimer(s.dir, s.start, args)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// send delivers req to the timer named in the path, answering for it when
+// that fails.
+func (s timerServer) send(w http.ResponseWriter, r *http.Request, req controlRequest) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("no timer %q", r.PathValue("id")))
+		return
+	}
+	resp, err := sendControl(s.dir, id, req)
+	var missing noSuchTimerError
+	switch {
+	case errors.As(err, &missing):
+		writeJSONError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeJSONError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusOK, resp.State)
+	}
+}
+
+func (s timerServer) get(w http.ResponseWriter, r *http.Request) {
+	s.send(w, r, controlRequest{Command: controlCommandStatus})
+}
+
+func (s timerServer) cancel(w http.ResponseWriter, r *http.Request) {
+	if err := checkLocalRequest(r); err != nil {
+		writeJSONError(w, http.StatusForbidden, err)
+		return
+	}
+	s.send(w, r, controlRequest{Command: controlCommandCancel})
+}
+
+// events streams the running timers every serveTickInterval as "timers"
+// events, each a JSON array of serveTimer, until the client goes away.
+func (s timerServer) events(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	tick := time.NewTicker(serveTickInterval)
+	defer tick.Stop()
+	for {
+		timers := []serveTimer{}
+		for _, t := range runningTimers(s.dir) {
+			timers = append(timers, newServeTimer(t))
+		}
+		data, _ := json.Marshal(timers)
+		if _, err := fmt.Fprintf(w, "event: timers\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// servePage shows the timers as the server saw them, then keeps them up
+// to date from the event stream, or by reloading without JavaScript.
+var servePage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<noscript><meta http-equiv="refresh" content="{{.Refresh}}"></noscript>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>after</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #111; color: #eee; margin: 2em; }
+.timer { margin-bottom: 1.5em; }
+.label { font-size: 2em; color: #aaa; }
+.time { font-size: 6em; font-variant-numeric: tabular-nums; }
+.paused .time { color: #888; }
+.alerting .time { color: #e74c3c; }
+.empty { font-size: 2em; color: #666; }
+</style>
+</head>
+<body>
+<div id="timers">
+{{range .Timers}}<div class="timer {{.State}}">
+<div class="label">{{if .Label}}{{.Label}}{{else}}timer {{.ID}}{{end}}{{if ne .State "running"}} ({{.State}}){{end}}</div>
+<div class="time">{{.Display}}</div>
+</div>
+{{else}}<div class="empty">No timers running.</div>
+{{end}}</div>
+<script>
+const list = document.getElementById("timers");
+const div = (className, text) => {
+  const el = document.createElement("div");
+  el.className = className;
+  el.textContent = text;
+  return el;
+};
+new EventSource("/api/events").addEventListener("timers", event => {
+  const timers = JSON.parse(event.data);
+  if (timers.length === 0) {
+    list.replaceChildren(div("empty", "No timers running."));
+    return;
+  }
+  list.replaceChildren(...timers.map(t => {
+    const el = div("timer " + t.state, "");
+    const label = (t.label || "timer " + t.id) + (t.state === "running" ? "" : " (" + t.state + ")");
+    el.append(div("label", label), div("time", t.display));
+    return el;
+  }));
+});
+</script>
+</body>
+</html>
+`))
+
+func (s timerServer) page(w http.ResponseWriter, r *http.Request) {
+	var timers []serveTimer
+	for _, t := range runningTimers(s.dir) {
+		timers = append(timers, newServeTimer(t))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = servePage.Execute(w, struct {
+		Refresh int
+		Timers  []serveTimer
+	}{serveRefreshSeconds, timers})
+}
+
+// startTimer starts a timer with args through start and returns its state
+// once its control socket answers.
+func startTimer(dir string, start func(args []string) (int, error), args []string) (controlState, error) {
+	id, err := start(args)
+	if err != nil {
+		return controlState{}, err
+	}
+	for wait := time.Now().Add(serveStartTimeout); ; {
+		resp, err := sendControl(dir, id, controlRequest{Command: controlCommandStatus})
+		if err == nil && resp.State != nil {
+			return *resp.State, nil
+		}
+		if time.Now().After(wait) {
+			return controlState{}, fmt.Errorf("timer %d did not start", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// startDetachedTimer runs after with args in its own session, with no
+// terminal, and returns its pid, which is its timer id.
+func startDetachedTimer(args []string) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command(self, args...)
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	go func() { _ = cmd.Wait() }()
+	return cmd.Process.Pid, nil
+}
+
+func runServeCommand(args []string, stdout, stderr io.Writer) int {
+	listen := serveDefaultListen
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "--listen":
+		listen = args[1]
+	default:
+		fmt.Fprintln(stderr, serveUsageText)
+		return 2
+	}
+	// A broken config is the timers' to report; the server just goes
+	// without custom units.
+	cfg, _ := loadConfig(configPath(os.Getenv))
+	s := timerServer{dir: controlDir(os.Getenv), units: cfg.units, start: startDetachedTimer}
+	server := &http.Server{Addr: listen, Handler: s.handler(), ReadHeaderTimeout: 5 * time.Second}
+	fmt.Fprintf(stdout, "after: serving timers at %s\n", listen)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// --share publishes a running timer on the local network: a read-only HTTP
+// endpoint serving its status, advertised over mDNS so "after discover" on
+// another machine can find and mirror it. Nothing on the endpoint can change
+// the timer.
+
+const shareStatusPath = "/status"
+
+type shareServer struct {
+	http *http.Server
+	mdns *mdnsResponder
+}
+
+// startShare serves status from control's timer and advertises it. The
+// endpoint listens on an ephemeral port on all IPv4 interfaces.
+func startShare(control *controlServer, label string) (*shareServer, error) {
+	listener, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+shareStatusPath, func(w http.ResponseWriter, r *http.Request) {
+		resp := control.dispatch(controlRequest{Command: controlCommandStatus})
+		if !resp.OK || resp.State == nil {
+			http.Error(w, resp.Error, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp.State)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = server.Serve(listener) }()
+
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	responder, err := newMDNSResponder(newShareService(label, port, os.Getpid()))
+	if err != nil {
+		_ = server.Close()
+		return nil, fmt.Errorf("mDNS: %w", err)
+	}
+	return &shareServer{http: server, mdns: responder}, nil
+}
+
+func (s *shareServer) close() {
+	s.mdns.close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.http.Shutdown(ctx)
+}
+
+func newShareService(label string, port uint16, pid int) mdnsService {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	if host == "" {
+		host = "after"
+	}
+	return mdnsService{
+		instance: shareInstanceName(label, host, pid) + "." + mdnsServiceType,
+		host:     mdnsSafeLabel(host) + ".local.",
+		port:     port,
+		ips:      localIPv4s(),
+		txt:      []string{"path=" + shareStatusPath, fmt.Sprintf("id=%d", pid)},
+	}
+}
+
+// shareInstanceName is the human-readable name shown by "after discover",
+// e.g. "tea on kitchen (4242)".
+func shareInstanceName(label, host string, pid int) string {
+	if label == "" {
+		label = "timer"
+	}
+	return mdnsSafeLabel(fmt.Sprintf("%s on %s (%d)", label, host, pid))
+}
+
+// mdnsSafeLabel makes s usable as a single DNS label: no dots, at most 63
+// bytes, cut between characters.
+func mdnsSafeLabel(s string) string {
+	s = strings.ReplaceAll(s, ".", "-")
+	if len(s) > 63 {
+		n := 63
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// A finished timer does not sound the alarm while the system is muted or
+// in Do Not Disturb; like quiet hours, it flashes the screen and posts the
+// notification instead. --sound on the command line rings regardless.
+
+// silenceCheckTimeout bounds all the checks together, so a wedged sound
+// server cannot hold up the completion.
+const silenceCheckTimeout = time.Second
+
+// A silenceCheck runs a command and reads from its output whether the
+// system wants quiet, for reason.
+type silenceCheck struct {
+	reason   string
+	name     string
+	args     []string
+	silenced func(out string) bool
+}
+
+func silenceChecksForGOOS(goos string) []silenceCheck {
+	switch goos {
+	case "darwin":
+		return []silenceCheck{
+			{reason: "output muted", name: "osascript", args: []string{"-e", "output muted of (get volume settings)"}, silenced: outputIs("true")},
+			{reason: "do not disturb", name: "defaults", args: []string{"-currentHost", "read", "com.apple.notificationcenterui", "doNotDisturb"}, silenced: outputIs("1")},
+		}
+	case "linux":
+		return []silenceCheck{
+			{reason: "output muted", name: "pactl", args: []string{"get-sink-mute", "@DEFAULT_SINK@"}, silenced: outputIs("Mute: yes")},
+			{reason: "output muted", name: "pamixer", args: []string{"--get-mute"}, silenced: outputIs("true")},
+			{reason: "do not disturb", name: "gsettings", args: []string{"get", "org.gnome.desktop.notifications", "show-banners"}, silenced: outputIs("false")},
+			{reason: "do not disturb", name: "dunstctl", args: []string{"is-paused"}, silenced: outputIs("true")},
+		}
+	}
+	return nil
+}
+
+func outputIs(want string) func(string) bool {
+	return func(out string) bool { return out == want }
+}
+
+// systemSilence returns why the system wants quiet, or "" when it does not
+// or cannot tell. output runs a command and returns its trimmed output, or
+// "" when the command is missing or fails.
+func systemSilence(checks []silenceCheck, output func(ctx context.Context, name string, args ...string) string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), silenceCheckTimeout)
+	defer cancel()
+	for _, c := range checks {
+		if c.silenced(output(ctx, c.name, c.args...)) {
+			return c.reason
+		}
+	}
+	return ""
+}
+
+func commandOutput(ctx context.Context, name string, args ...string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return ""
+	}
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// With an incoming-webhook URL in the [slack] config section, a completed
+// timer also posts to that Slack channel, so everyone watching a deploy
+// freeze or a bake time sees it end:
+//
+//	[slack]
+//	webhook = https://hooks.slack.com/services/...
+//	template = {{.label}} is done on {{.host}}
+
+// defaultSlackTemplate names the timer in bold and says where it ran.
+const defaultSlackTemplate = "*{{.title}}*: {{.text}} ({{.detail}}) on {{.host}}"
+
+// slackConfig is the webhook to post to. The zero value posts nothing.
+type slackConfig struct {
+	webhook  string
+	template string
+}
+
+func (c slackConfig) set() bool {
+	return c.webhook != ""
+}
+
+func parseSlackSection(section configSection) (slackConfig, error) {
+	var c slackConfig
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "webhook":
+			if !strings.HasPrefix(entry.value, "https://") && !strings.HasPrefix(entry.value, "http://") {
+				return slackConfig{}, configError{line: entry.line, msg: fmt.Sprintf("webhook: expected a URL, got %q", entry.value)}
+			}
+			c.webhook = entry.value
+		case "template":
+			text, err := parseMessageTemplate(entry)
+			if err != nil {
+				return slackConfig{}, err
+			}
+			c.template = text
+		default:
+			return slackConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown slack setting %q", entry.key)}
+		}
+	}
+	return c, nil
+}
+
+// sendSlack posts the message about event to the webhook, and reports
+// what Slack rejected.
+func sendSlack(client *http.Client, c slackConfig, event remoteEvent) error {
+	template := c.template
+	if template == "" {
+		template = defaultSlackTemplate
+	}
+	text, err := renderMessage(template, event)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	resp, err := client.Post(c.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The webhook URL is its own credential.
+		return fmt.Errorf("slack: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	// Slack answers a rejected post with a short reason, e.g.
+	// invalid_payload or no_service.
+	reason, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if s := strings.TrimSpace(string(reason)); s != "" {
+		return fmt.Errorf("slack: %s: %s", resp.Status, s)
+	}
+	return fmt.Errorf("slack: %s", resp.Status)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+import "time"
+
+// bootClock is unavailable elsewhere; see clockStepExcludingSuspend.
+func bootClock() (time.Duration, bool) {
+	return 0, false
+}
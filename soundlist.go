@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const soundsUsageText = "Usage: after sounds [--sound-file <path> | --sound-name <name> | --sound-dir <dir>] [--alarm-backend <names>]\n\n" +
+	"Lists the alarm backends after knows on this system in the order they\n" +
+	"are tried, which of them are installed, and which one would play, along\n" +
+	"with the sound the alarm uses."
+
+// describeAlarmSound names the sound a finished timer plays.
+func describeAlarmSound(soundFile string) string {
+	if name, ok := strings.CutPrefix(soundFile, soundNamePrefix); ok {
+		return name + " (built in)"
+	}
+	if dir, ok := strings.CutPrefix(soundFile, soundDirPrefix); ok {
+		return "a random file from " + dir
+	}
+	if soundFile == "" {
+		return "default"
+	}
+	return soundFile
+}
+
+// describeAlarmOrder says where the order of the backends comes from.
+func describeAlarmOrder(sound alarmSound) string {
+	switch {
+	case sound.backends == "":
+		return "default"
+	case sound.pinned:
+		return "only " + strings.ReplaceAll(sound.backends, ",", ", ") + " (--alarm-backend)"
+	}
+	return strings.ReplaceAll(sound.backends, ",", ", ") + " first (config)"
+}
+
+// formatSoundsReport renders the after sounds listing. candidates are in
+// the order they are tried; installed reports whether one can run here.
+// silence is why the system wants quiet right now, if it does.
+func formatSoundsReport(goos string, sound alarmSound, quiet quietHours, silence string, candidates []alarmCommand, installed func(alarmCommand) bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "sound:   %s\n", describeAlarmSound(sound.soundFile))
+	fmt.Fprintf(&b, "order:   %s\n", describeAlarmOrder(sound))
+	if quiet.set {
+		fmt.Fprintf(&b, "quiet:   %s (flash instead of sound; --sound overrides)\n", quiet)
+	}
+	if silence != "" {
+		fmt.Fprintf(&b, "silence: %s now (flash instead of sound; --sound overrides)\n", silence)
+	}
+	fmt.Fprintf(&b, "sounds:  %s\n", strings.Join(soundNames(), ", "))
+	fmt.Fprintf(&b, "backends on %s (* plays first):\n", goos)
+	if len(candidates) == 0 {
+		b.WriteString("  none\n")
+		return b.String()
+	}
+	commands := make([]string, len(candidates))
+	width := 0
+	for i, c := range candidates {
+		commands[i] = strings.Join(append([]string{c.name}, c.args...), " ")
+		width = max(width, len(commands[i]))
+	}
+	chosen := false
+	for i, c := range candidates {
+		mark, state := " ", "not installed"
+		if _, ok := builtinAlarmBackends[c.name]; ok {
+			state = "built in"
+		}
+		if installed(c) {
+			if state != "built in" {
+				state = "installed"
+			}
+			if !chosen {
+				mark, chosen = "*", true
+			}
+		}
+		fmt.Fprintf(&b, "%s %-*s  %s\n", mark, width, commands[i], state)
+	}
+	if !chosen {
+		b.WriteString("no backend is installed; only the terminal bell will ring\n")
+	}
+	return b.String()
+}
+
+func runSoundsCommand(args []string, stdout, stderr io.Writer) int {
+	sound, err := parseTestSoundArgs(args)
+	if err != nil || sound.gentle {
+		var unknownErr unknownOptionError
+		var valueErr invalidFlagValueError
+		switch {
+		case errors.As(err, &valueErr):
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		case errors.As(err, &unknownErr):
+			fmt.Fprintf(stderr, "%s\n\n", unknownErr)
+		}
+		fmt.Fprintln(stderr, soundsUsageText)
+		return 2
+	}
+	// Without flags, report what a plain timer would use: the default
+	// alert profile's sound and the configured order.
+	var quiet quietHours
+	if cfg, err := loadConfig(configPath(os.Getenv)); err == nil {
+		quiet = cfg.quietHours
+		if profile, ok := cfg.alerts[defaultAlertProfile]; ok && sound.soundFile == "" {
+			sound.soundFile = profile.soundFile
+		}
+		if !sound.pinned {
+			sound.backends = cfg.alarmOrder
+		}
+	}
+	sound.soundFile = resolveRunSoundFile(invocation{soundFile: sound.soundFile}, stderr).soundFile
+
+	candidates := orderAlarmCandidates(alarmCandidatesForGOOS(currentAlarmPlatform(), sound.soundFile), parseAlarmBackends(sound.backends), sound.pinned)
+	fmt.Fprint(stdout, formatSoundsReport(currentAlarmPlatform(), sound, quiet, systemSilence(silenceChecksForGOOS(runtime.GOOS), commandOutput), candidates, alarmCommandInstalled))
+	return 0
+}
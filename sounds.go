@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Built-in sounds are synthesized rather than shipped as audio files. The
+// first time one is needed it is rendered to a WAV file in the user cache
+// directory, which every file-aware alarm backend can play. --sound-name
+// selects one by storing soundNamePrefix+name as the sound file.
+
+const soundNamePrefix = "sound:"
+
+// --sound-dir stores soundDirPrefix+dir as the sound file, and each alarm
+// plays a file from dir picked at random, so a timer run every day does
+// not always sound the same and fade into the background.
+const soundDirPrefix = "dir:"
+
+// soundFileExtensions are the audio formats --sound-dir picks from.
+var soundFileExtensions = []string{".aif", ".aiff", ".caf", ".flac", ".m4a", ".mp3", ".oga", ".ogg", ".opus", ".wav"}
+
+const soundSampleRate = 22050
+
+// A soundPartial is an overtone at ratio times the note's frequency. Higher
+// partials die away faster, as they do on real bars and bells.
+type soundPartial struct {
+	ratio     float64
+	amplitude float64
+}
+
+type soundNote struct {
+	freq   float64
+	at     time.Duration
+	length time.Duration
+}
+
+type builtinSound struct {
+	notes    []soundNote
+	partials []soundPartial
+	// decay is the fundamental's exponential decay rate per second.
+	decay float64
+}
+
+var builtinSounds = map[string]builtinSound{
+	"beep": {
+		notes: []soundNote{
+			{freq: 880, length: 120 * time.Millisecond},
+			{freq: 880, at: 200 * time.Millisecond, length: 120 * time.Millisecond},
+		},
+		partials: []soundPartial{{1, 1}, {3, 0.2}},
+	},
+	"chime": {
+		notes: []soundNote{
+			{freq: 1318.5, length: 900 * time.Millisecond},
+			{freq: 1046.5, at: 250 * time.Millisecond, length: 900 * time.Millisecond},
+			{freq: 784, at: 500 * time.Millisecond, length: 1200 * time.Millisecond},
+		},
+		partials: []soundPartial{{1, 1}, {2, 0.3}, {3, 0.1}},
+		decay:    3,
+	},
+	"bell": {
+		notes:    []soundNote{{freq: 660, length: 1800 * time.Millisecond}},
+		partials: []soundPartial{{1, 1}, {2.76, 0.5}, {5.4, 0.25}, {8.93, 0.12}},
+		decay:    2,
+	},
+	"marimba": {
+		notes: []soundNote{
+			{freq: 523.25, length: 500 * time.Millisecond},
+			{freq: 659.25, at: 130 * time.Millisecond, length: 500 * time.Millisecond},
+			{freq: 783.99, at: 260 * time.Millisecond, length: 500 * time.Millisecond},
+			{freq: 1046.5, at: 390 * time.Millisecond, length: 700 * time.Millisecond},
+		},
+		partials: []soundPartial{{1, 1}, {4, 0.3}, {9.9, 0.08}},
+		decay:    8,
+	},
+}
+
+func soundNames() []string {
+	names := make([]string, 0, len(builtinSounds))
+	for name := range builtinSounds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupSoundName(name string) error {
+	if _, ok := builtinSounds[name]; !ok {
+		return fmt.Errorf("unknown sound %q (available: %s)", name, strings.Join(soundNames(), ", "))
+	}
+	return nil
+}
+
+// samples renders s as mono samples in [-1, 1].
+func (s builtinSound) samples() []float64 {
+	var end time.Duration
+	for _, n := range s.notes {
+		end = max(end, n.at+n.length)
+	}
+	out := make([]float64, int(end.Seconds()*soundSampleRate))
+	// A short attack and release keep the notes from clicking.
+	const ramp = 0.005
+	for _, n := range s.notes {
+		start := int(n.at.Seconds() * soundSampleRate)
+		length := n.length.Seconds()
+		for i := 0; i < int(length*soundSampleRate) && start+i < len(out); i++ {
+			t := float64(i) / soundSampleRate
+			env := min(t/ramp, (length-t)/ramp, 1)
+			var v float64
+			for _, p := range s.partials {
+				v += p.amplitude * math.Exp(-s.decay*math.Sqrt(p.ratio)*t) * math.Sin(2*math.Pi*n.freq*p.ratio*t)
+			}
+			out[start+i] += env * v
+		}
+	}
+	peak := 0.0
+	for _, v := range out {
+		peak = max(peak, math.Abs(v))
+	}
+	if peak > 0 {
+		for i := range out {
+			out[i] *= 0.8 / peak
+		}
+	}
+	return out
+}
+
+// encodeWAV encodes samples as a 16-bit mono PCM WAV file.
+func encodeWAV(samples []float64) []byte {
+	var b bytes.Buffer
+	dataSize := uint32(len(samples) * 2)
+	b.WriteString("RIFF")
+	_ = binary.Write(&b, binary.LittleEndian, 36+dataSize)
+	b.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(16),                  // fmt chunk size
+		uint16(1),                   // PCM
+		uint16(1),                   // mono
+		uint32(soundSampleRate),     // sample rate
+		uint32(soundSampleRate * 2), // byte rate
+		uint16(2),                   // block align
+		uint16(16),                  // bits per sample
+	} {
+		_ = binary.Write(&b, binary.LittleEndian, field)
+	}
+	b.WriteString("data")
+	_ = binary.Write(&b, binary.LittleEndian, dataSize)
+	for _, v := range samples {
+		_ = binary.Write(&b, binary.LittleEndian, int16(v*math.MaxInt16))
+	}
+	return b.Bytes()
+}
+
+// soundCacheDir is where built-in sounds are rendered to.
+func soundCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "after", "sounds"), nil
+}
+
+// writeBuiltinSound renders sound name into dir, unless an identical file is
+// already there, and returns its path.
+func writeBuiltinSound(dir, name string) (string, error) {
+	s, ok := builtinSounds[name]
+	if !ok {
+		return "", lookupSoundName(name)
+	}
+	return writeSound(dir, name, s)
+}
+
+func writeSound(dir, name string, s builtinSound) (string, error) {
+	data := encodeWAV(s.samples())
+	path := filepath.Join(dir, name+".wav")
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	// Rename into place so a concurrent alarm never plays half a file.
+	tmp, err := os.CreateTemp(dir, name+".*.wav")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// soundDirFiles lists the sound files in dir, skipping hidden files and
+// anything that is not audio.
+func soundDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !slices.Contains(soundFileExtensions, strings.ToLower(filepath.Ext(name))) {
+			continue
+		}
+		// Follow symlinks, so a directory of links to sounds works.
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no sound files in %s", dir)
+	}
+	return files, nil
+}
+
+// pickSoundFile picks one of the sound files in dir with pick, which
+// returns a number in [0, n).
+func pickSoundFile(dir string, pick func(n int) int) (string, error) {
+	files, err := soundDirFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	return files[pick(len(files))], nil
+}
+
+// resolveSoundName turns a built-in sound into the path of its file, and a
+// sound directory into one of its files. Other sound files are returned
+// unchanged; a sound that cannot be written or picked falls back to the
+// platform sound.
+func resolveSoundName(soundFile string) string {
+	if dir, ok := strings.CutPrefix(soundFile, soundDirPrefix); ok {
+		path, err := pickSoundFile(dir, rand.IntN)
+		if err != nil {
+			return ""
+		}
+		return path
+	}
+	name, ok := strings.CutPrefix(soundFile, soundNamePrefix)
+	if !ok {
+		return soundFile
+	}
+	dir, err := soundCacheDir()
+	if err != nil {
+		return ""
+	}
+	path, err := writeBuiltinSound(dir, name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --announce speaks the remaining time aloud at chosen milestones ("5
+// minutes remaining") through the platform's text-to-speech command, for
+// when nobody is looking at the screen.
+
+const announceCheckInterval = 250 * time.Millisecond
+
+// parseMilestones reads a comma-separated list of --announce durations and
+// returns them longest first, without repeats.
+func parseMilestones(s string) ([]time.Duration, error) {
+	var milestones []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		d, err := parseFlagDuration("--announce", strings.TrimSpace(field))
+		if err != nil || d <= 0 {
+			return nil, invalidFlagValueError{flag: "--announce", value: s}
+		}
+		if !slices.Contains(milestones, d) {
+			milestones = append(milestones, d)
+		}
+	}
+	slices.SortFunc(milestones, func(a, b time.Duration) int { return int(b - a) })
+	return milestones, nil
+}
+
+// formatMilestones is the canonical form of milestones, which parseMilestones
+// reads back.
+func formatMilestones(milestones []time.Duration) string {
+	fields := make([]string, len(milestones))
+	for i, d := range milestones {
+		fields[i] = d.String()
+	}
+	return strings.Join(fields, ",")
+}
+
+// crossedMilestone reports the milestone passed as the remaining time fell
+// from prev to cur. When several were passed at once, as after a suspend,
+// only the last one is worth saying. Time added to the timer crosses none,
+// so a milestone is announced again when the countdown reaches it again.
+func crossedMilestone(milestones []time.Duration, prev, cur time.Duration) (time.Duration, bool) {
+	for i := len(milestones) - 1; i >= 0; i-- {
+		if m := milestones[i]; cur <= m && m < prev {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// formatSpokenDuration says d in words a speech engine reads naturally,
+// e.g. "1 hour 30 minutes" rather than "1:30:00".
+func formatSpokenDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	var parts []string
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{{time.Hour, "hour"}, {time.Minute, "minute"}, {time.Second, "second"}} {
+		n := d / unit.size
+		d -= n * unit.size
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+unit.name)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, unit.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatMilestoneAnnouncement is what is said at milestone m.
+func formatMilestoneAnnouncement(label string, m time.Duration) string {
+	text := formatSpokenDuration(m) + " remaining"
+	if label != "" {
+		return label + ": " + text
+	}
+	return text
+}
+
+func speechCandidatesForGOOS(goos string, text string) []alarmCommand {
+	switch goos {
+	case "darwin":
+		return []alarmCommand{{name: "say", args: []string{text}}}
+	case "linux":
+		return []alarmCommand{
+			{name: "spd-say", args: []string{text}},
+			{name: "espeak-ng", args: []string{text}},
+			{name: "espeak", args: []string{text}},
+		}
+	}
+	return []alarmCommand{{name: "espeak", args: []string{text}}}
+}
+
+// speak says text through the first installed speech command without
+// waiting for it to finish. It is best-effort: without one, nothing is
+// said.
+func speak(text string) {
+	for _, c := range speechCandidatesForGOOS(runtime.GOOS, text) {
+		if _, err := exec.LookPath(c.name); err != nil {
+			continue
+		}
+		cmd := quietCmd(c.name, c.args...)
+		if cmd.Start() == nil {
+			go func() { _ = cmd.Wait() }()
+		}
+		return
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const statusUsageText = "Usage: after status [--json] [<id>]\n\n" +
+	"Prints one short line describing a running timer, for status bars such\n" +
+	"as polybar or i3blocks; prints nothing when no timer is running. With\n" +
+	"several timers and no id, the one ending soonest is shown. --json prints\n" +
+	"waybar's custom module format instead."
+
+type statusArgs struct {
+	id   int
+	json bool
+}
+
+func parseStatusArgs(args []string) (statusArgs, error) {
+	var parsed statusArgs
+	for _, arg := range args {
+		switch {
+		case arg == "--json":
+			parsed.json = true
+		case isTimerID(arg) && parsed.id == 0:
+			parsed.id, _ = strconv.Atoi(arg)
+		case len(arg) > 0 && arg[0] == '-':
+			return statusArgs{}, unknownOptionError{option: arg}
+		default:
+			return statusArgs{}, errUsage
+		}
+	}
+	return parsed, nil
+}
+
+// pickStatusTimer chooses the timer a status bar shows: the one with the
+// given id, or else the running timer ending soonest, falling back to the
+// first paused one.
+func pickStatusTimer(states []controlState, id int) (controlState, bool) {
+	var picked controlState
+	found := false
+	for _, s := range states {
+		if id != 0 {
+			if s.ID == id {
+				return s, true
+			}
+			continue
+		}
+		switch {
+		case !found:
+			picked, found = s, true
+		case picked.State == timerStatePaused && s.State != timerStatePaused:
+			picked = s
+		case s.State != timerStatePaused && s.Remaining < picked.Remaining:
+			picked = s
+		}
+	}
+	return picked, found
+}
+
+// formatStatusLine renders the bar text for state, e.g. "tea: 3:59".
+func formatStatusLine(state controlState) string {
+	remaining := formatRemainingTime(time.Duration(state.Remaining * float64(time.Second)))
+	return formatCountdownLine(state.Label, remaining, state.State == timerStatePaused)
+}
+
+// waybarStatus is the JSON shape waybar's custom module reads with
+// return-type set to json.
+type waybarStatus struct {
+	Text       string `json:"text"`
+	Tooltip    string `json:"tooltip"`
+	Class      string `json:"class"`
+	Percentage int    `json:"percentage"`
+}
+
+// formatWaybarStatus renders state for waybar; ok false yields the idle
+// module, whose empty text makes waybar hide it.
+func formatWaybarStatus(state controlState, ok bool) string {
+	status := waybarStatus{Class: "idle"}
+	if ok {
+		status = waybarStatus{
+			Text:       formatStatusLine(state),
+			Tooltip:    formatTimerSummary(state),
+			Class:      state.State,
+			Percentage: int(timerProgress(state) * 100),
+		}
+	}
+	b, _ := json.Marshal(status)
+	return string(b)
+}
+
+func runStatusCommand(args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseStatusArgs(args)
+	if err != nil {
+		var unknownErr unknownOptionError
+		if errors.As(err, &unknownErr) {
+			fmt.Fprintf(stderr, "%s\n\n", unknownErr)
+		}
+		fmt.Fprintln(stderr, statusUsageText)
+		return 2
+	}
+
+	state, ok := pickStatusTimer(runningTimers(controlDir(os.Getenv)), parsed.id)
+	if parsed.json {
+		fmt.Fprintln(stdout, formatWaybarStatus(state, ok))
+		return 0
+	}
+	if ok {
+		fmt.Fprintln(stdout, formatStatusLine(state))
+	}
+	return 0
+}
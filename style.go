@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A countdownStyle decides which parts make up the countdown line; the
+// theme decides how they look. The zero value is the default style: icon,
+// label, and time. --percent and --show-end add their parts to any style.
+type countdownStyle struct {
+	hideLabel bool
+	hideIcon  bool
+	percent   bool
+	// bar draws a progress bar next to the percentage, with the theme's
+	// glyphs or plain blocks when the theme has none.
+	bar bool
+	end bool
+}
+
+const defaultStyleName = "default"
+
+var countdownStyles = map[string]countdownStyle{
+	"default": {},
+	"compact": {hideLabel: true, hideIcon: true},
+	"full":    {percent: true, bar: true, end: true},
+}
+
+// fallbackBar draws the bar of styles that ask for one when the theme has
+// no glyphs of its own.
+var fallbackBar = theme{barFull: "█", barEmpty: "░"}
+
+func lookupStyle(name string) (countdownStyle, error) {
+	if st, ok := countdownStyles[name]; ok {
+		return st, nil
+	}
+	return countdownStyle{}, fmt.Errorf("unknown style %q (available: %s)", name, strings.Join(styleNames(), ", "))
+}
+
+func styleNames() []string {
+	names := make([]string, 0, len(countdownStyles))
+	for name := range countdownStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveStyleName picks --style over the config file over the default.
+func resolveStyleName(flag, configured string) string {
+	if flag != "" {
+		return flag
+	}
+	if configured != "" {
+		return configured
+	}
+	return defaultStyleName
+}
+
+// withFlags adds the parts requested by --percent and --show-end.
+func (st countdownStyle) withFlags(inv invocation) countdownStyle {
+	st.percent = st.percent || inv.showPercent
+	st.end = st.end || inv.showEnd
+	return st
+}
+
+// countdownPart is everything a style may show, computed once per frame.
+type countdownPart struct {
+	label   string
+	icon    string
+	timeStr string
+	// spinner is the current spinner frame, or "" when it is off.
+	spinner   string
+	remaining time.Duration
+	total     time.Duration
+	paused    bool
+	now       time.Time
+}
+
+// format renders the countdown line for p in theme th, e.g.
+// "⏳ tea: 12:30 58% █████▊░░░░ (ends 14:32)".
+func (st countdownStyle) format(th theme, p countdownPart) string {
+	line := th.countdownLine(st.label(p), st.timeText(th, p), p.paused)
+	if st.hideIcon {
+		return line
+	}
+	return withIcon(p.icon, line)
+}
+
+// label is p's label, or "" when the style hides it.
+func (st countdownStyle) label(p countdownPart) string {
+	if st.hideLabel {
+		return ""
+	}
+	return p.label
+}
+
+// timeText is the time with the spinner and the style's percentage, bar,
+// and end time.
+func (st countdownStyle) timeText(th theme, p countdownPart) string {
+	timeStr := p.timeStr
+	if p.spinner != "" {
+		timeStr += " " + p.spinner
+	}
+	if st.percent {
+		timeStr += " " + formatPercentComplete(p.remaining, p.total)
+		bar := th.progressBar(p.remaining, p.total)
+		if bar == "" && st.bar {
+			bar = fallbackBar.progressBar(p.remaining, p.total)
+		}
+		if bar != "" {
+			timeStr += " " + bar
+		}
+	}
+	if st.end {
+		timeStr += " " + formatEndsAt(p.now.Add(p.remaining), p.now)
+	}
+	return timeStr
+}
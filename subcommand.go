@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io"
+)
+
+// subcommands are built into after. They are matched on the first argument
+// before plugins, so an after-<name> plugin can never shadow one.
+var subcommands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"ack":        runAckCommand,
+	"ctl":        runCtlCommand,
+	"dash":       runDashCommand,
+	"discover":   runDiscoverCommand,
+	"edit":       runEditCommand,
+	"launchd":    runLaunchdCommand,
+	"prompt":     runPromptCommand,
+	"serve":      runServeCommand,
+	"sounds":     runSoundsCommand,
+	"status":     runStatusCommand,
+	"systemd":    runSystemdCommand,
+	"test-sound": runTestSoundCommand,
+}
+
+func lookupSubcommand(args []string) (func(args []string, stdout, stderr io.Writer) int, bool) {
+	if len(args) < 2 {
+		return nil, false
+	}
+	run, ok := subcommands[args[1]]
+	return run, ok
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// suspendThreshold is the smallest wall-clock jump treated as a system
+// suspend. It comfortably exceeds scheduling jitter on a 1s check interval.
+const suspendThreshold = 2 * time.Second
+
+// suspendGap reports how long the system was suspended between two readings
+// of time.Now. The monotonic clock stops while the machine sleeps but the
+// wall clock keeps going, so the difference between them is the time spent
+// suspended. A wall clock step (NTP, manual change) is indistinguishable from
+// suspend here; callers only use this when pausing on suspend was requested.
+func suspendGap(prev, now time.Time) time.Duration {
+	return suspendGapFromElapsed(now.Round(0).Sub(prev.Round(0)), now.Sub(prev))
+}
+
+// suspendGapFromElapsed compares wall and monotonic elapsed time. Gaps below
+// suspendThreshold are treated as noise and return 0.
+func suspendGapFromElapsed(wall, mono time.Duration) time.Duration {
+	gap := wall - mono
+	if gap < suspendThreshold {
+		return 0
+	}
+	return gap
+}
+
+func formatSuspendPause(gap time.Duration) string {
+	return fmt.Sprintf("paused %s during system suspend", gap.Round(time.Second))
+}
+
+func printSuspendPause(status statusDisplay, quiet bool, gap time.Duration) {
+	if quiet {
+		return
+	}
+	if status.interactive {
+		writeInteractiveLine(status, "after "+formatSuspendPause(gap))
+		return
+	}
+	writeStatusln(status.writer, "after: "+formatSuspendPause(gap))
+}
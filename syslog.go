@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --log syslog records each timer's start and end with the system logger,
+// which the systemd journal reads too, so timers run for operations leave
+// a trail that outlives the terminal:
+//
+//	after[4242]: start label="backup window" length=2h0m0s deadline=2026-10-18T22:00:00Z
+//	after[4242]: cancelled label="backup window" length=2h0m0s elapsed=41m3.2s reason=signal signal=SIGTERM
+//
+// Starts and completions are logged at info priority, cancellations at
+// notice.
+
+const logSyslog = "syslog"
+
+const syslogTag = "after"
+
+// formatSyslogFields joins the record's event and its key=value fields,
+// leaving out empty values and quoting the label.
+func formatSyslogFields(event string, fields ...[2]string) string {
+	parts := []string{event}
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if f[0] == "label" {
+			f[1] = fmt.Sprintf("%q", f[1])
+		}
+		parts = append(parts, f[0]+"="+f[1])
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatSyslogStart(label string, length time.Duration, deadline time.Time) string {
+	return formatSyslogFields("start",
+		[2]string{"label", label},
+		[2]string{"length", length.String()},
+		[2]string{"deadline", deadline.UTC().Format(time.RFC3339)})
+}
+
+func formatSyslogEnd(outcome, label string, length, elapsed time.Duration, cause error) string {
+	fields := [][2]string{
+		{"label", label},
+		{"length", length.String()},
+		{"elapsed", elapsed.Round(100 * time.Millisecond).String()},
+	}
+	if outcome == outcomeCancelled {
+		reason := reasonSignal
+		if errors.Is(cause, errCancelledRemotely) {
+			reason = reasonRemote
+		}
+		signal := ""
+		var sc signalCause
+		if errors.As(cause, &sc) {
+			signal = signalName(sc.sig)
+		}
+		fields = append(fields, [2]string{"reason", reason}, [2]string{"signal", signal})
+	}
+	return formatSyslogFields(outcome, fields...)
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// lifecycleLog writes the records for --log. A nil one logs nothing.
+type lifecycleLog struct {
+	w *syslog.Writer
+}
+
+// openLifecycleLog connects to the system logger for dest.
+func openLifecycleLog(dest string) (*lifecycleLog, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dest, err)
+	}
+	return &lifecycleLog{w: w}, nil
+}
+
+// record logs a start, complete, or cancelled record. Failures are
+// dropped: the system logger going away must not stop the timer.
+func (l *lifecycleLog) record(outcome, record string) {
+	if l == nil {
+		return
+	}
+	if outcome == outcomeCancelled {
+		_ = l.w.Notice(record)
+		return
+	}
+	_ = l.w.Info(record)
+}
+
+func (l *lifecycleLog) close() {
+	if l != nil {
+		_ = l.w.Close()
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// lifecycleLog writes the records for --log. Windows has no syslog, so
+// there is never a non-nil one.
+type lifecycleLog struct{}
+
+func openLifecycleLog(dest string) (*lifecycleLog, error) {
+	return nil, fmt.Errorf("%s: %w", dest, errors.ErrUnsupported)
+}
+
+func (l *lifecycleLog) record(outcome, record string) {}
+
+func (l *lifecycleLog) close() {}
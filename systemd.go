@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const systemdUsageText = "Usage: after systemd --on <calendar> [--name <name>] [--dir <dir>] [--] <duration|time> [<flags>...]\n\n" +
+	"Prints a systemd service and timer unit that run after with the given\n" +
+	"duration and flags whenever the OnCalendar= expression <calendar>\n" +
+	"elapses, e.g. --on 'Mon..Fri 09:55'. --dir writes them there instead,\n" +
+	"e.g. ~/.config/systemd/user. Units are named after-<label>, or --name."
+
+// systemdArgs are the options of "after systemd", and the after command
+// line its units run.
+type systemdArgs struct {
+	calendar string
+	name     string
+	dir      string
+	after    []string
+}
+
+func parseSystemdArgs(args []string) (systemdArgs, error) {
+	var parsed systemdArgs
+	i := 0
+	for ; i < len(args); i++ {
+		var value *string
+		switch args[i] {
+		case "--on":
+			value = &parsed.calendar
+		case "--name":
+			value = &parsed.name
+		case "--dir":
+			value = &parsed.dir
+		case "--":
+			i++
+		}
+		if value == nil {
+			break
+		}
+		if i+1 >= len(args) {
+			return systemdArgs{}, errUsage
+		}
+		*value = args[i+1]
+		i++
+	}
+	parsed.after = args[i:]
+	if parsed.calendar == "" || len(parsed.after) == 0 {
+		return systemdArgs{}, errUsage
+	}
+	return parsed, nil
+}
+
+// systemdUnitName matches the names systemd accepts for a unit, less its
+// suffix.
+var systemdUnitName = regexp.MustCompile(`^[A-Za-z0-9:_.\-]+$`)
+
+var systemdUnitNameRefused = regexp.MustCompile(`[^a-z0-9_.]+`)
+
+// defaultScheduleName names the units of a scheduled timer, here and for
+// launchd: after-<label>, with everything systemd would refuse in the
+// label replaced by dashes, or after without a label.
+func defaultScheduleName(label string) string {
+	name := strings.Trim(systemdUnitNameRefused.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if name == "" {
+		return "after"
+	}
+	return "after-" + name
+}
+
+// systemdEscape escapes what systemd would expand in a unit setting:
+// specifiers like %h, and, in ExecStart=, variables like $HOME.
+func systemdEscape(s string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$").Replace(s)
+}
+
+// systemdQuote makes arg one word of ExecStart=, double-quoted when it has
+// anything systemd would split on or unquote.
+func systemdQuote(arg string) string {
+	arg = systemdEscape(arg)
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(arg) + `"`
+}
+
+// systemdUnits renders the service running exe with args, and the timer of
+// the same name starting it on calendar. The service is Type=notify, which after
+// answers, so systemctl status shows the time left.
+func systemdUnits(description, calendar, exe string, args []string) (service, timer string) {
+	words := []string{systemdQuote(exe)}
+	for _, arg := range args {
+		words = append(words, systemdQuote(arg))
+	}
+	description = systemdEscape(strings.ReplaceAll(description, "\n", " "))
+	service = "[Unit]\n" +
+		"Description=" + description + "\n\n" +
+		"[Service]\n" +
+		"Type=notify\n" +
+		"ExecStart=" + strings.Join(words, " ") + "\n"
+	timer = "[Unit]\n" +
+		"Description=" + description + "\n\n" +
+		"[Timer]\n" +
+		"OnCalendar=" + systemdEscape(calendar) + "\n\n" +
+		"[Install]\n" +
+		"WantedBy=timers.target\n"
+	return service, timer
+}
+
+func runSystemdCommand(args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseSystemdArgs(args)
+	if err != nil {
+		fmt.Fprintln(stderr, systemdUsageText)
+		return 2
+	}
+	// The units are checked now rather than when they first fire.
+	cfg, _ := loadConfig(configPath(os.Getenv))
+	inv, err := parseInvocationWithUnits(append([]string{"after"}, parsed.after...), cfg.units)
+	if err == nil && inv.mode != modeRun {
+		err = errors.New("the units must run a timer")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	name := parsed.name
+	if name == "" {
+		name = defaultScheduleName(inv.label)
+	}
+	if !systemdUnitName.MatchString(name) {
+		fmt.Fprintf(stderr, "Error: --name: %q is not a valid unit name\n", name)
+		return 2
+	}
+	description := "after " + strings.Join(parsed.after, " ")
+	if inv.label != "" {
+		description = "after: " + inv.label
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	service, timer := systemdUnits(description, parsed.calendar, exe, parsed.after)
+
+	if parsed.dir == "" {
+		fmt.Fprintf(stdout, "# %s.service\n%s\n# %s.timer\n%s", name, service, name, timer)
+		return 0
+	}
+	for _, unit := range []struct{ file, text string }{{name + ".service", service}, {name + ".timer", timer}} {
+		path := filepath.Join(parsed.dir, unit.file)
+		if err := os.WriteFile(path, []byte(unit.text), 0o644); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "after: wrote %s\n", path)
+	}
+	fmt.Fprintf(stdout, "Enable it with: systemctl --user daemon-reload && systemctl --user enable --now %s.timer\n", name)
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// With a bot token and a chat id in the [telegram] config section, a
+// completed timer also posts its message to that Telegram chat:
+//
+//	[telegram]
+//	token = <bot token>
+//	chat = <chat id>
+//	template = {{.label}} is done ({{.elapsed}})
+
+const telegramEndpoint = "https://api.telegram.org"
+
+// telegramConfig is the bot and chat to post to. The zero value posts
+// nothing.
+type telegramConfig struct {
+	token    string
+	chat     string
+	template string
+}
+
+func (c telegramConfig) set() bool {
+	return c.token != "" && c.chat != ""
+}
+
+func parseTelegramSection(section configSection) (telegramConfig, error) {
+	var c telegramConfig
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "token":
+			c.token = entry.value
+		case "chat":
+			c.chat = entry.value
+		case "template":
+			text, err := parseMessageTemplate(entry)
+			if err != nil {
+				return telegramConfig{}, err
+			}
+			c.template = text
+		default:
+			return telegramConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown telegram setting %q", entry.key)}
+		}
+	}
+	return c, nil
+}
+
+// sendTelegram posts the message about event to the chat through the Bot
+// API at endpoint, and reports what Telegram rejected.
+func sendTelegram(client *http.Client, endpoint string, c telegramConfig, event remoteEvent) error {
+	text, err := renderMessage(c.template, event)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{"chat_id": c.chat, "text": text})
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	resp, err := client.Post(endpoint+"/bot"+c.token+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL holds the token.
+		return fmt.Errorf("telegram: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode == http.StatusOK && decodeErr == nil && reply.OK {
+		return nil
+	}
+	if reply.Description != "" {
+		return fmt.Errorf("telegram: %s", reply.Description)
+	}
+	return fmt.Errorf("telegram: %s", resp.Status)
+}
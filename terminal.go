@@ -0,0 +1,63 @@
+package main
+
+import "strings"
+
+// terminalCaps is what the countdown uses beyond plain text.
+type terminalCaps struct {
+	// clearLine is clr_eol (ESC [ K), needed to redraw the line in place.
+	clearLine bool
+	// title is OSC 0, which sets the window title.
+	title bool
+	// color is SGR foreground colors.
+	color bool
+}
+
+var (
+	capsNone  = terminalCaps{}
+	capsFull  = terminalCaps{clearLine: true, title: true, color: true}
+	capsVT100 = terminalCaps{clearLine: true}
+	capsANSI  = terminalCaps{clearLine: true, color: true}
+)
+
+// terminalFamilies maps the part of $TERM before the first "-" or "." to
+// its capabilities, so screen.xterm-256color is screen. It lists only
+// terminals that lack something: anything not named here is assumed to be
+// a modern xterm-compatible emulator.
+var terminalFamilies = map[string]terminalCaps{
+	"dumb": capsNone,
+	"vt52": capsNone,
+	// Serial consoles and hardware terminals.
+	"vt100": capsVT100,
+	"vt102": capsVT100,
+	"vt220": capsVT100,
+	"vt320": capsVT100,
+	"ansi":  capsANSI,
+	// The Linux and BSD consoles have colors but no window to title.
+	"linux":  capsANSI,
+	"cons25": capsANSI,
+	"wsvt25": capsANSI,
+	// screen turns OSC 0 into its own window name or prints it; tmux
+	// forwards it to the pane title.
+	"screen": capsANSI,
+}
+
+// lookupTerminalCaps returns the capabilities of termName ($TERM). Names
+// ending in -m or -mono lose color.
+func lookupTerminalCaps(termName string) terminalCaps {
+	normalized := strings.TrimSpace(strings.ToLower(termName))
+	if normalized == "" {
+		return capsNone
+	}
+	family := normalized
+	if i := strings.IndexAny(normalized, "-."); i >= 0 {
+		family = normalized[:i]
+	}
+	caps, ok := terminalFamilies[family]
+	if !ok {
+		caps = capsFull
+	}
+	if strings.HasSuffix(normalized, "-m") || strings.HasSuffix(normalized, "-mono") {
+		caps.color = false
+	}
+	return caps
+}
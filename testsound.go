@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const testSoundUsageText = "Usage: after test-sound [--sound-file <path> | --sound-name <name> | --sound-dir <dir>] [--alarm-backend <names>] [--gentle]\n\n" +
+	"Plays the alarm right away, the way a finished timer would, and prints\n" +
+	"each backend it tries, so you can check your audio setup."
+
+func parseTestSoundArgs(args []string) (alarmSound, error) {
+	var sound alarmSound
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--gentle":
+			sound.gentle = true
+			continue
+		case "-f", "--sound-file", "--sound-name", "--sound-dir", "--alarm-backend":
+			if i+1 >= len(args) {
+				return alarmSound{}, errUsage
+			}
+			value := args[i+1]
+			i++
+			switch arg {
+			case "--sound-name":
+				if lookupSoundName(value) != nil {
+					return alarmSound{}, invalidFlagValueError{flag: arg, value: value}
+				}
+				sound.soundFile = soundNamePrefix + value
+			case "--alarm-backend":
+				backends, err := parseAlarmBackendFlag(arg, value)
+				if err != nil {
+					return alarmSound{}, err
+				}
+				sound.backends, sound.pinned = backends, true
+			default:
+				sound.soundFile = value
+			}
+			continue
+		}
+		if len(arg) > 0 && arg[0] == '-' {
+			return alarmSound{}, unknownOptionError{option: arg}
+		}
+		return alarmSound{}, errUsage
+	}
+	return sound, nil
+}
+
+// testSound plays sound through commands as a finished timer would,
+// reporting every attempt on w, and returns the backend that played last.
+func testSound(w io.Writer, commands []alarmCommand, gentle bool, runner func(alarmCommand) error) (string, bool) {
+	if len(commands) == 0 {
+		fmt.Fprintln(w, "after: alarm backends: none installed")
+		return "", false
+	}
+	fmt.Fprintln(w, "after: alarm backends:", formatAlarmCommandNames(commands))
+	var last string
+	play := 0
+	report := func(c alarmCommand) error {
+		name := alarmBackendName(c)
+		if err := runner(c); err != nil {
+			fmt.Fprintf(w, "after: %s failed: %v\n", name, err)
+			return err
+		}
+		play++
+		fmt.Fprintf(w, "after: play %d of %d: %s\n", play, alarmPlays, name)
+		last = name
+		return nil
+	}
+	playAlarmAttempts(commands, alarmPlays, 100*time.Millisecond, gentle, report)
+	return last, last != ""
+}
+
+func runTestSoundCommand(args []string, stdout, stderr io.Writer) int {
+	sound, err := parseTestSoundArgs(args)
+	if err != nil {
+		var unknownErr unknownOptionError
+		var valueErr invalidFlagValueError
+		switch {
+		case errors.As(err, &valueErr):
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		case errors.As(err, &unknownErr):
+			fmt.Fprintf(stderr, "%s\n\n", unknownErr)
+		}
+		fmt.Fprintln(stderr, testSoundUsageText)
+		return 2
+	}
+	inv := resolveRunSoundFile(invocation{soundFile: sound.soundFile}, stderr)
+	sound.soundFile = inv.soundFile
+	// A broken config only loses the configured backend order here.
+	if cfg, err := loadConfig(configPath(os.Getenv)); err == nil && !sound.pinned {
+		sound.backends = cfg.alarmOrder
+	}
+
+	name, ok := testSound(stdout, resolveAlarmCommands(sound), sound.gentle, runAlarmCommand)
+	if !ok {
+		fmt.Fprintln(stderr, "Error: no alarm backend could play the sound")
+		return 1
+	}
+	fmt.Fprintln(stdout, "after: the alarm played through", name)
+	return 0
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A theme styles the interactive countdown: the colors used at each
+// threshold, the separator after a label, the glyphs of the --percent
+// progress bar, the completion banner, and the pulse of the final seconds.
+// Colors and the banner only apply when color is enabled (see
+// colorEnabled).
+type theme struct {
+	// colors are SGR parameters for plenty of time, the yellow threshold
+	// and the red threshold. "" leaves that stage uncolored.
+	colors    [3]string
+	separator string
+	// barFull and barEmpty draw a progress bar next to --percent; themes
+	// without glyphs show the percentage alone.
+	barFull  string
+	barEmpty string
+	// banner is the SGR parameter for the "after complete" line.
+	banner string
+	// pulse is an SGR attribute switched on and off every pulsePeriod in
+	// the final stretch, so imminent expiry catches the eye. Unlike
+	// SGR 5 (blink), it works on terminals that do not blink.
+	pulse string
+}
+
+const defaultThemeName = "default"
+
+const progressBarWidth = 10
+
+const pulsePeriod = 500 * time.Millisecond
+
+var themes = map[string]theme{
+	"default": {colors: [3]string{"32", "33", "31"}, separator: ": ", pulse: "7"},
+	"plain":   {separator: ": "},
+	"minimal": {colors: [3]string{"", "", "31"}, separator: " "},
+	"bold": {
+		colors:    [3]string{"1;32", "1;33", "1;31"},
+		separator: ": ",
+		barFull:   "█",
+		barEmpty:  "░",
+		banner:    "1",
+		pulse:     "7",
+	},
+	"solarized": {
+		colors:    [3]string{"38;5;64", "38;5;136", "38;5;160"},
+		separator: " · ",
+		barFull:   "■",
+		barEmpty:  "□",
+		banner:    "38;5;37",
+		pulse:     "7",
+	},
+}
+
+func lookupTheme(name string) (theme, error) {
+	if t, ok := themes[name]; ok {
+		return t, nil
+	}
+	return theme{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(themeNames(), ", "))
+}
+
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveThemeName picks --theme over the config file over the default.
+func resolveThemeName(flag, configured string) string {
+	if flag != "" {
+		return flag
+	}
+	if configured != "" {
+		return configured
+	}
+	return defaultThemeName
+}
+
+// countdownTheme returns the theme for status, defaulting when unset.
+func countdownTheme(status statusDisplay) theme {
+	if status.theme == nil {
+		return themes[defaultThemeName]
+	}
+	return *status.theme
+}
+
+func (t theme) color(remaining time.Duration, th colorThresholds) string {
+	return t.colors[colorLevel(remaining, th)]
+}
+
+func (t theme) countdownLine(label, timeStr string, paused bool) string {
+	line := timeStr
+	if label != "" {
+		line = label + t.separator + line
+	}
+	if paused {
+		line += " (paused)"
+	}
+	return line
+}
+
+// pulseSGR adds the pulse attribute to sgr for every other pulsePeriod of
+// the final stretch. The phase follows the remaining time, so the pulse
+// stays even however often the countdown redraws.
+func (t theme) pulseSGR(sgr string, remaining time.Duration, paused bool) string {
+	if t.pulse == "" || paused || remaining <= 0 || remaining >= finalStretch {
+		return sgr
+	}
+	if (remaining/pulsePeriod)%2 == 0 {
+		return sgr
+	}
+	if sgr == "" {
+		return t.pulse
+	}
+	return sgr + ";" + t.pulse
+}
+
+// progressBar draws elapsed/total with the theme's glyphs, or "" when the
+// theme has none.
+func (t theme) progressBar(remaining, total time.Duration) string {
+	if t.barFull == "" {
+		return ""
+	}
+	// Progress in eighths of a cell; block bars show the remainder with a
+	// partial block, other glyphs round down to whole cells.
+	eighths := progressBarWidth * 8
+	if total > 0 {
+		elapsed := min(max(total-remaining, 0), total)
+		eighths = int(int64(elapsed) * progressBarWidth * 8 / int64(total))
+	}
+	filled, part := eighths/8, eighths%8
+	bar := strings.Repeat(t.barFull, filled)
+	if t.barFull == "█" && part > 0 {
+		return bar + partialBlocks[part] + strings.Repeat(t.barEmpty, progressBarWidth-filled-1)
+	}
+	return bar + strings.Repeat(t.barEmpty, progressBarWidth-filled)
+}
+
+// partialBlocks[n] is a left block n/8 of a cell wide.
+var partialBlocks = [8]string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// displaySettings are the [display] defaults; "" leaves a setting unset.
+type displaySettings struct {
+	theme string
+	style string
+}
+
+// parseDisplaySection reads the [display] config section:
+//
+//	[display]
+//	theme = solarized
+//	style = compact
+func parseDisplaySection(section configSection) (displaySettings, error) {
+	var settings displaySettings
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "theme":
+			if _, err := lookupTheme(entry.value); err != nil {
+				return displaySettings{}, configError{line: entry.line, msg: err.Error()}
+			}
+			settings.theme = entry.value
+		case "style":
+			if _, err := lookupStyle(entry.value); err != nil {
+				return displaySettings{}, configError{line: entry.line, msg: err.Error()}
+			}
+			settings.style = entry.value
+		default:
+			return displaySettings{}, configError{line: entry.line, msg: fmt.Sprintf("unknown display setting %q", entry.key)}
+		}
+	}
+	return settings, nil
+}
@@ -2,65 +2,646 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
-func runTimer(ctx context.Context, cancel context.CancelCauseFunc, duration time.Duration, wallClockTarget time.Time, status statusDisplay, sideEffectsInteractive bool, quiet bool, noTitle bool, forceAlarm bool, forceAwake bool, soundFile string) error {
-	return runTimerWithAlarmStarter(ctx, cancel, duration, wallClockTarget, status, sideEffectsInteractive, quiet, noTitle, forceAlarm, forceAwake, soundFile, startAlarmProcess)
+func runTimer(ctx context.Context, cancel context.CancelCauseFunc, inv invocation, status statusDisplay, sideEffectsInteractive bool) error {
+	// Remote control is best-effort; the timer runs normally without it.
+	control, err := listenControl(controlDir(os.Getenv))
+	if err == nil {
+		defer control.close()
+	}
+	if inv.share {
+		if control == nil {
+			writeStatusln(status.writer, "Warning: --share needs the control socket:", err)
+		} else if share, err := startShare(control, inv.label); err != nil {
+			writeStatusln(status.writer, "Warning: --share unavailable:", err)
+		} else {
+			defer share.close()
+		}
+	}
+	if inv.dbus {
+		if control == nil {
+			writeStatusln(status.writer, "Warning: --dbus needs the control socket:", err)
+		} else if service, err := startDBusService(sessionBusAddress(os.Getenv), control.dispatch); err != nil {
+			writeStatusln(status.writer, "Warning: --dbus unavailable:", err)
+		} else {
+			defer service.close()
+		}
+	}
+
+	// SIGQUIT dumps internal state before exiting, in place of Go's default
+	// goroutine dump, which lacks the timer's own view of things.
+	quitC := make(chan os.Signal, 1)
+	signal.Notify(quitC, syscall.SIGQUIT)
+	defer signal.Stop(quitC)
+
+	return runTimerWithControl(ctx, cancel, inv, status, sideEffectsInteractive, startAlarm, control.callsChan(), quitC)
 }
 
-func runTimerWithAlarmStarter(ctx context.Context, cancel context.CancelCauseFunc, duration time.Duration, wallClockTarget time.Time, status statusDisplay, sideEffectsInteractive bool, quiet bool, noTitle bool, forceAlarm bool, forceAwake bool, soundFile string, alarmStarter func(string)) error {
+func runTimerWithAlarmStarter(ctx context.Context, cancel context.CancelCauseFunc, inv invocation, status statusDisplay, sideEffectsInteractive bool, alarmStarter func(alarmSound)) error {
+	return runTimerWithControl(ctx, cancel, inv, status, sideEffectsInteractive, alarmStarter, nil, nil)
+}
+
+func runTimerWithControl(ctx context.Context, cancel context.CancelCauseFunc, inv invocation, status statusDisplay, sideEffectsInteractive bool, alarmStarter func(alarmSound), controlC <-chan controlCall, dumpC <-chan os.Signal) error {
+	duration, wallClockTarget := inv.duration, inv.wallClockTarget
+	// Terminals without a window title get none of the title features.
+	if status.noTitles {
+		inv.noTitle = true
+	}
+
 	bothStreamsInteractive := sideEffectsInteractive && status.interactive
 
-	if shouldStartSleepInhibitor(runtime.GOOS, sideEffectsInteractive, status.interactive, forceAwake) {
+	if shouldStartSleepInhibitor(runtime.GOOS, sideEffectsInteractive, status.interactive, inv.forceAwake) {
 		pid := strconv.Itoa(os.Getpid())
 		cmd := quietCmd("caffeinate", sleepInhibitorArgs(sideEffectsInteractive, status.interactive, pid)...)
 		go func() { _ = cmd.Run() }() // best-effort; -w <pid> ensures caffeinate exits when we do
 	}
 
+	// --title-only draws the countdown in the window title alone and leaves
+	// the terminal line to the shell: everything else is reported as for a
+	// non-interactive stream.
+	titleOnly := inv.titleOnly && !inv.noTitle && status.interactive && (status.supportsAdvanced || status.setTitle != nil)
+	if titleOnly {
+		status.interactive = false
+	}
+	drawing := status.interactive || titleOnly
+
 	isWallClock := !wallClockTarget.IsZero()
+	started := time.Now()
 
 	var deadline time.Time
 	if isWallClock {
 		deadline = wallClockTarget
 	} else {
-		deadline = time.Now().Add(duration)
+		deadline = started.Add(duration)
+	}
+
+	var suspended time.Duration
+	var idlePaused time.Duration
+	var manualPaused time.Duration
+	summary := func() string {
+		var parts []string
+		if suspended >= time.Second {
+			parts = append(parts, formatSuspendPause(suspended))
+		}
+		if idlePaused >= time.Second {
+			parts = append(parts, formatIdlePause(idlePaused))
+		}
+		if manualPaused >= time.Second {
+			parts = append(parts, formatManualPause(manualPaused))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	finish := func(outcome string, cause error) {
+		if inv.execCommand == "" && inv.resultFD == 0 && inv.resultFile == "" {
+			return
+		}
+		event := newTimerEvent(outcome, inv, started, time.Now(), deadline, cause)
+		event.suspended = suspended
+		if inv.resultFD != 0 || inv.resultFile != "" {
+			if err := writeResult(inv, newTimerResult(event, inv.label, cause)); err != nil {
+				writeStatusln(status.writer, "Warning:", err)
+			}
+		}
+		if inv.execCommand == "" {
+			return
+		}
+		// With --json, --porcelain, --stdout, or --ics -, stdout belongs to
+		// the program reading it, and hook output there would corrupt it.
+		hookStdout := io.Writer(os.Stdout)
+		if status.stream != nil || inv.ics == "-" {
+			hookStdout = os.Stderr
+		}
+		if err := runExecHook(inv.execCommand, event, hookStdout); err != nil {
+			writeStatusln(status.writer, "Warning:", err)
+		}
+	}
+
+	// --notify posts to the desktop, which needs no terminal, so it works
+	// with --quiet and with output piped away. Only the last resort, the
+	// terminal's own notification, needs the terminal and stays quiet.
+	notifyDesktop := func(outcome string, remaining time.Duration, sound bool) {
+		if !inv.notify || inv.noNotify || outcome == outcomeCancelled && !inv.notifyCancel {
+			return
+		}
+		terminal := desktopNotifier{name: "terminal", post: func(n desktopNotification) error {
+			if !drawing || inv.quiet {
+				return errors.New("no terminal to notify through")
+			}
+			notifier := status.notifier
+			if notifier == notifierNone {
+				notifier = notifierOSC9
+			}
+			writeStatus(status.writer, formatTerminalNotification(notifier, n.title, n.body(), status.inTmux))
+			return nil
+		}}
+		debug := func(format string, args ...any) { debugf(status.writer, os.Getenv, format, args...) }
+		notification := formatDesktopNotification(newTimerEvent(outcome, inv, started, time.Now(), deadline, nil), inv.label, remaining)
+		notification.sound = sound
+		name, err := postDesktopNotification(desktopNotifiersForGOOS(runtime.GOOS, os.Getenv, exec.LookPath, terminal), notification, debug)
+		if err != nil {
+			debug("no notifier posted the notification")
+			return
+		}
+		debug("notification posted via %s", name)
+	}
+	// The end of the timer also goes to the remote services set up in the
+	// config, while the alarm rings; the returned channel is closed once
+	// they are through.
+	notifyRemote := func(outcome string, remaining time.Duration) <-chan struct{} {
+		pushed := make(chan struct{})
+		notifiers := remoteNotifiers(inv, outcome)
+		if len(notifiers) == 0 {
+			close(pushed)
+			return pushed
+		}
+		host, _ := os.Hostname()
+		event := newRemoteEvent(newTimerEvent(outcome, inv, started, time.Now(), deadline, nil), inv.label, remaining, host)
+		go func() {
+			defer close(pushed)
+			for _, err := range sendRemoteNotifications(notifiers, event) {
+				printRemoteFailure(status, err)
+			}
+		}()
+		return pushed
+	}
+	// With --notify, the notification of an alarm ringing until answered
+	// carries Snooze and Dismiss buttons, where the desktop takes them
+	// over D-Bus. nil means a plain notification has to do.
+	watchAlarmNotification := func() *notificationWatch {
+		addr := sessionBusAddress(os.Getenv)
+		if !inv.notify || inv.noNotify || addr == "" || runtime.GOOS == "darwin" {
+			return nil
+		}
+		notification := formatDesktopNotification(newTimerEvent(outcomeComplete, inv, started, time.Now(), deadline, nil), inv.label, 0)
+		w, err := watchNotification(addr, notification, alarmNotificationActions...)
+		if err != nil {
+			debugf(status.writer, os.Getenv, "notifier dbus failed: %v", err)
+			return nil
+		}
+		debugf(status.writer, os.Getenv, "notification posted via dbus, with buttons")
+		return w
 	}
 
 	done := time.NewTimer(time.Until(deadline))
 	defer done.Stop()
 
-	if shouldPrintLifecycleStart(status.interactive, quiet) && ctx.Err() == nil {
-		writeStatusln(status.writer, formatLifecycleStarted(duration, wallClockTarget))
+	// While paused the deadline is meaningless; pausedRemaining is frozen and
+	// the deadline is recomputed from it on resume. pausedBy records who
+	// paused so that idle detection never resumes a manual pause.
+	var paused bool
+	var pausedBy string
+	var pausedRemaining time.Duration
+	var pausedAt time.Time
+	var pauses []pauseRecord
+	pause := func(reason string) {
+		pausedRemaining = max(time.Until(deadline), 0)
+		pausedAt = time.Now()
+		paused = true
+		pausedBy = reason
+		done.Stop()
+		pauses = append(pauses, pauseRecord{reason: reason, start: pausedAt})
+	}
+	resume := func() {
+		paused = false
+		pauses[len(pauses)-1].end = time.Now()
+		deadline = time.Now().Add(pausedRemaining)
+		done.Reset(pausedRemaining)
+		if pausedBy == pauseReasonIdle {
+			idlePaused += time.Since(pausedAt)
+		} else {
+			manualPaused += time.Since(pausedAt)
+		}
+	}
+	remainingNow := func() time.Duration {
+		if paused {
+			return pausedRemaining
+		}
+		return time.Until(deadline)
+	}
+	// total is the full length of the countdown, kept current across edits
+	// so progress can be reported to control clients and --percent.
+	total := deadline.Sub(started)
+	// The alternate screen needs escape sequences and the frame renderer,
+	// so it is only used on ANSI terminals.
+	fullscreen := inv.fullscreen && status.interactive && status.supportsAdvanced
+	// --no-title keeps the countdown out of all terminal chrome.
+	useChrome := status.chrome != chromeNone && !inv.noTitle && status.supportsAdvanced && drawing
+	var lastChrome string
+	// The terminal size is measured once and again on every resize.
+	width, height := stderrSize()
+	// The spinner moves one frame per redraw, so a stalled line shows.
+	// It is only drawn where the line is redrawn in place.
+	spinning := inv.spinner && status.interactive && status.supportsAdvanced && !titleOnly
+	spin := 0
+	renderCountdown := func() {
+		remaining := remainingNow()
+		th := countdownTheme(status)
+		style := status.style.withFlags(inv)
+		part := countdownPart{
+			label:     inv.label,
+			icon:      status.icons.countdownIcon(paused),
+			timeStr:   formatCountdownTime(inv.display, total-remaining, remaining),
+			remaining: remaining,
+			total:     total,
+			paused:    paused,
+			now:       time.Now(),
+		}
+		sgr := ""
+		if status.color {
+			sgr = th.color(remaining, inv.colorThresholds)
+		}
+		if status.supportsAdvanced && !inv.quiet {
+			sgr = th.pulseSGR(sgr, remaining, paused)
+		}
+		title := formatCountdownTitle(remaining, total, style.format(th, part))
+		if spinning && !paused {
+			part.spinner = status.icons.spinnerFrame(spin)
+			spin++
+		}
+		line := style.format(th, part)
+		// The badge changes at most once a second; rewriting it with every
+		// frame would make iTerm2 re-layout it constantly.
+		extra := ""
+		if useChrome {
+			if text := th.countdownLine(inv.label, formatRemainingTime(remaining), paused); text != lastChrome {
+				extra = formatChromeUpdate(status.chrome, text, status.inTmux)
+				lastChrome = text
+			}
+		}
+		if titleOnly {
+			if status.supportsAdvanced {
+				submitCountdownFrame(status, countdownFrame{prefix: extra, title: fmt.Sprintf("\033]0;%s\007", title)})
+			} else {
+				status.setTitle(title)
+			}
+			return
+		}
+		if fullscreen {
+			frame := countdownFrame{
+				prefix: extra,
+				line:   formatFullscreenFrame(fullscreenLines(style.label(part), style.timeText(th, part), paused, inv.bigDigits), sgr, width, height),
+			}
+			if !inv.noTitle {
+				frame.title = fmt.Sprintf("\033]0;%s\007", title)
+			}
+			submitCountdownFrame(status, frame)
+			return
+		}
+		renderColoredCountdown(status, title, truncateToWidth(line, width), sgr, extra, inv.noTitle)
+	}
+	snapshot := func() controlState {
+		state := timerStateRunning
+		if paused {
+			state = timerStatePaused
+		}
+		return controlState{
+			ID:        os.Getpid(),
+			Label:     inv.label,
+			Alert:     inv.alertProfile,
+			State:     state,
+			Started:   started,
+			Deadline:  deadline.Round(0),
+			Remaining: max(remainingNow(), 0).Seconds(),
+			Total:     total.Seconds(),
+		}
+	}
+	handleControl := func(req controlRequest) controlResponse {
+		switch req.Command {
+		case controlCommandStatus:
+		case controlCommandEdit:
+			if req.Alert != nil {
+				cfg, err := loadConfig(configPath(os.Getenv))
+				if err != nil {
+					return controlResponse{Error: err.Error()}
+				}
+				edited, err := switchAlertProfile(inv, *req.Alert, cfg)
+				if err != nil {
+					return controlResponse{Error: err.Error()}
+				}
+				inv = resolveRunSoundFile(edited, io.Discard)
+			}
+			if req.Label != nil {
+				inv.label = *req.Label
+			}
+			if req.Deadline != nil {
+				before := remainingNow()
+				remaining := max(time.Until(*req.Deadline), 0)
+				switch {
+				case paused:
+					pausedRemaining = remaining
+				case isWallClock:
+					deadline = *req.Deadline
+					done.Reset(remaining)
+				default:
+					deadline = time.Now().Add(remaining)
+					done.Reset(remaining)
+				}
+				total += remaining - before
+				if !status.interactive && !inv.quiet {
+					writeStatusln(status.writer, labelLifecycle(inv.label, formatLifecycleEdited(deadline)))
+				}
+			}
+		case controlCommandPause:
+			if !paused {
+				pause(pauseReasonManual)
+				printManualPauseTransition(status, inv.quiet, true)
+			}
+		case controlCommandResume:
+			if paused {
+				resume()
+				printManualPauseTransition(status, inv.quiet, false)
+			}
+		case controlCommandExtend:
+			// A negative extension takes away at most the time left, and
+			// total shrinks only by what was taken.
+			by := time.Duration(req.ExtendSeconds * float64(time.Second))
+			if paused {
+				by = max(by, -pausedRemaining)
+				pausedRemaining += by
+			} else {
+				by = max(by, -max(time.Until(deadline), 0))
+				deadline = deadline.Add(by)
+				done.Reset(max(time.Until(deadline), 0))
+			}
+			total += by
+			if !status.interactive && !inv.quiet {
+				writeStatusln(status.writer, labelLifecycle(inv.label, formatLifecycleEdited(deadline)))
+			}
+		case controlCommandCancel:
+			cancel(errCancelledRemotely)
+		case controlCommandAck:
+			return controlResponse{Error: "the timer has not finished"}
+		default:
+			return controlResponse{Error: fmt.Sprintf("unknown command %q", req.Command)}
+		}
+		state := snapshot()
+		return controlResponse{OK: true, State: &state}
+	}
+
+	if shouldPrintLifecycleStart(status.interactive, inv.quiet) && ctx.Err() == nil {
+		if inv.showEnd && wallClockTarget.IsZero() {
+			writeStatusln(status.writer, labelLifecycle(inv.label, formatLifecycleStartedWithEnd(duration, deadline)))
+		} else {
+			writeStatusln(status.writer, labelLifecycle(inv.label, formatLifecycleStarted(duration, wallClockTarget)))
+		}
 	}
 
 	var tickC <-chan time.Time
-	if status.interactive {
-		ticker := time.NewTicker(500 * time.Millisecond)
+	var resizeC <-chan os.Signal
+	// retick speeds the countdown up for the final stretch, when tenths of
+	// a second are shown, and back down if the timer is extended.
+	retick := func() {}
+	stopFrames := func() {}
+	renderInterval := func() time.Duration { return 0 }
+	if drawing {
+		interval := countdownTickInterval(remainingNow(), paused, inv.refresh)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		tickC = ticker.C
+		retick = func() {
+			if next := countdownTickInterval(remainingNow(), paused, inv.refresh); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+
+		renderer := newFrameRenderer(status.writer)
+		status.frames = renderer
+		stopFrames = sync.OnceFunc(renderer.close)
+
+		resize := make(chan os.Signal, 1)
+		defer notifyResize(resize)()
+		resizeC = resize
+		// Every exit path stops the frames first, so the title is restored
+		// and the final status line lands on the normal screen.
+		var enter, leave string
+		if status.supportsAdvanced && !inv.noTitle {
+			enter, leave = pushTitle, popTitle
+		}
+		if fullscreen {
+			enter, leave = enter+enterFullscreen, leaveFullscreen+leave
+		}
+		if useChrome {
+			leave += formatChromeUpdate(status.chrome, "", status.inTmux)
+		}
+		if enter != "" {
+			writeStatus(status.writer, enter)
+			stopFrames = sync.OnceFunc(func() {
+				renderer.close()
+				writeStatus(status.writer, leave)
+			})
+		}
+		defer stopFrames()
+		// Only read once stopFrames has returned; the renderer owns it until then.
+		renderInterval = func() time.Duration { return renderer.interval }
 	}
 
 	var resyncC <-chan time.Time
+	lastResync := readClock()
 	if isWallClock {
 		resync := time.NewTicker(1 * time.Second)
 		defer resync.Stop()
 		resyncC = resync.C
 	}
 
-	if status.interactive {
-		renderInteractiveCountdown(status, formatRemainingTime(duration), noTitle)
+	var suspendC <-chan time.Time
+	lastSuspendCheck := started
+	if inv.pauseOnSuspend {
+		suspendTicker := time.NewTicker(1 * time.Second)
+		defer suspendTicker.Stop()
+		suspendC = suspendTicker.C
 	}
 
+	// Milestones are checked a few times a second, so an announcement is
+	// never more than a moment late whatever pauses and edits happen.
+	var announceC <-chan time.Time
+	milestones, _ := parseMilestones(inv.announce)
+	lastAnnounceCheck := remainingNow()
+	if len(milestones) > 0 {
+		announceTicker := time.NewTicker(announceCheckInterval)
+		defer announceTicker.Stop()
+		announceC = announceTicker.C
+	}
+
+	var tickSoundC <-chan time.Time
+	var ticks *cuePlayer
+	if inv.tickSound {
+		if player, ok := newCuePlayer(inv.alarmSound(), "tick", tickSound, tickVolume); ok {
+			interval := inv.tickInterval
+			if interval == 0 {
+				interval = defaultTickInterval
+			}
+			tickSoundTicker := time.NewTicker(interval)
+			defer tickSoundTicker.Stop()
+			tickSoundC = tickSoundTicker.C
+			ticks = player
+		}
+	}
+
+	// --every chimes whenever another interval of the countdown has run,
+	// and --halfway once half of it has, counting only the time the timer
+	// was running.
+	var chimeC <-chan time.Time
+	var chimes *cuePlayer
+	lastPeriods := 0
+	halfwayDone := false
+	if inv.every > 0 || inv.halfway {
+		chimeTicker := time.NewTicker(announceCheckInterval)
+		defer chimeTicker.Stop()
+		chimeC = chimeTicker.C
+		chimes, _ = newCuePlayer(inv.alarmSound(), "chime", builtinSounds["chime"], everyVolume)
+	}
+	chime := func(notify bool, body string) {
+		if chimes != nil {
+			chimes.play()
+		}
+		if notify && drawing && status.notifier != notifierNone && !inv.noNotify {
+			exclusiveStatus(status, func() {
+				writeStatus(status.writer, formatTerminalNotification(status.notifier, "after", withIcon(status.icons.notify, body), status.inTmux))
+			})
+		}
+	}
+
+	var finalBeepC <-chan time.Time
+	var beeps *cuePlayer
+	beepMilestones := finalBeepMilestones(inv.finalBeeps)
+	lastBeepCheck := remainingNow()
+	if inv.finalBeeps > 0 {
+		if player, ok := newCuePlayer(inv.alarmSound(), "final-beep", finalBeepSound, 1); ok {
+			finalBeepTicker := time.NewTicker(finalBeepCheckInterval)
+			defer finalBeepTicker.Stop()
+			finalBeepC = finalBeepTicker.C
+			beeps = player
+		}
+	}
+
+	var idleC <-chan bool
+	idleMode := "off"
+	if inv.idlePause > 0 {
+		probe, err := idleProbeForGOOS(runtime.GOOS, os.Getenv)
+		if err != nil {
+			writeStatusln(status.writer, idleUnsupportedWarning())
+			idleMode = "unavailable: " + err.Error()
+		} else {
+			idleMode = fmt.Sprintf("pause after %s idle, polled every %s", inv.idlePause, idlePollInterval)
+			idleCtx, stopIdle := context.WithCancel(ctx)
+			defer stopIdle()
+			idleC = watchIdle(idleCtx, probe, inv.idlePause, idlePollInterval)
+		}
+	}
+
+	if drawing {
+		renderCountdown()
+	}
+
+	var lifecycle *lifecycleLog
+	if inv.logTo != "" {
+		if l, err := openLifecycleLog(inv.logTo); err != nil {
+			writeStatusln(status.writer, "Warning: --log unavailable:", err)
+		} else {
+			lifecycle = l
+			defer lifecycle.close()
+		}
+	}
+	sd, err := openSDNotify(os.Getenv)
+	if err != nil {
+		writeStatusln(status.writer, "Warning: systemd notification unavailable:", err)
+	}
+	defer sd.close()
+	var sdStatusC <-chan time.Time
+	if sd != nil {
+		sd.startWatchdog(sdWatchdogInterval(os.Getenv, os.Getpid()))
+		sdTicker := time.NewTicker(sdStatusInterval)
+		defer sdTicker.Stop()
+		sdStatusC = sdTicker.C
+	}
+	// stopToggl stops the --toggl entry when the timer ends.
+	stopToggl := func() <-chan struct{} {
+		stopped := make(chan struct{})
+		close(stopped)
+		return stopped
+	}
+	if inv.trackToggl {
+		if !inv.toggl.set() {
+			writeStatusln(status.writer, "Warning: --toggl needs an API token in the [toggl] config section or $"+togglTokenEnvVar)
+		} else {
+			description := inv.label
+			if description == "" {
+				description = "after"
+			}
+			stopToggl = trackToggl(inv.toggl, description, started, func(err error) {
+				writeStatusln(status.writer, "Warning:", err)
+			})
+		}
+	}
+	if inv.ics != "" {
+		host, _ := os.Hostname()
+		calendar := formatICSEvent(inv.label, inv.message, started, deadline, time.Now(), host, os.Getpid())
+		if err := writeICS(inv.ics, calendar); err != nil {
+			writeStatusln(status.writer, "Warning: --ics:", err)
+		}
+	}
+
+	// report writes to stdout for other programs: the remaining time for
+	// --stdout, a JSON event for --json, or a lifecycle record for
+	// --porcelain. Starts and ends also go to --log and to systemd.
+	report := func(event string, remaining time.Duration, cause error) {
+		switch event {
+		case progressEventStart:
+			lifecycle.record(event, formatSyslogStart(inv.label, total, deadline))
+			sd.send("READY=1\n" + formatSDStatus(inv.label, remaining, paused))
+		case progressEventComplete, progressEventCancelled:
+			lifecycle.record(event, formatSyslogEnd(event, inv.label, total, time.Since(started), cause))
+			sd.send("STOPPING=1\nSTATUS=" + event)
+		}
+		switch {
+		case status.stream == nil:
+		case inv.jsonEvents:
+			writeProgressEvent(status.stream, newProgressEvent(event, inv.label, remaining, total, deadline, paused, cause))
+		case inv.porcelain:
+			switch event {
+			case progressEventStart:
+				writePorcelain(status.stream, formatPorcelainStart(started, deadline, inv.label))
+			case progressEventComplete, progressEventCancelled:
+				writePorcelain(status.stream, formatPorcelainEnd(event, started, time.Now(), inv.label, cause))
+			}
+		case event != progressEventCancelled:
+			writeStreamLine(status.stream, remaining)
+		}
+	}
+	var streamC <-chan time.Time
+	if status.stream != nil && !inv.porcelain {
+		interval := inv.refresh
+		if interval == 0 {
+			interval = streamInterval
+		}
+		streamTicker := time.NewTicker(interval)
+		defer streamTicker.Stop()
+		streamC = streamTicker.C
+	}
+	report(progressEventStart, max(remainingNow(), 0), nil)
+
 	var keyCh <-chan struct{}
+	var focusCh <-chan bool
+	var pressCh <-chan struct{}
+	focused := true
+	trackFocus := false
 	restoreTerminal := func() {}
 	if status.interactive && stdinIsTTY() {
 		tty, err := os.Open("/dev/tty")
@@ -68,9 +649,16 @@ func runTimerWithAlarmStarter(ctx context.Context, cancel context.CancelCauseFun
 			if !isInForeground(tty.Fd()) {
 				_ = tty.Close()
 			} else if oldState, err := term.MakeRaw(int(tty.Fd())); err == nil {
+				trackFocus = shouldTrackFocus(inv, status)
+				if trackFocus {
+					writeStatus(status.writer, focusReportingOn)
+				}
 				var once sync.Once
 				restoreTerminal = func() {
 					once.Do(func() {
+						if trackFocus {
+							writeStatus(status.writer, focusReportingOff)
+						}
 						_ = term.Restore(int(tty.Fd()), oldState)
 						_ = tty.Close()
 					})
@@ -79,20 +667,39 @@ func runTimerWithAlarmStarter(ctx context.Context, cancel context.CancelCauseFun
 
 				ch := make(chan struct{}, 1)
 				keyCh = ch
+				fch := make(chan bool, 1)
+				focusCh = fch
+				pch := make(chan struct{}, 1)
+				pressCh = pch
 				go func() {
-					buf := make([]byte, 1)
+					buf := make([]byte, 16)
 					for {
 						n, err := tty.Read(buf)
 						if err != nil || n == 0 {
 							return
 						}
-						b := buf[0]
-						if b == 'q' || b == 'Q' || b == 0x1B || b == 0x03 || b == 0x04 {
+						events := parseTimerInput(buf[:n])
+						if len(events) == 0 {
+							// Any other key; only --ack listens for it.
 							select {
-							case ch <- struct{}{}:
+							case pch <- struct{}{}:
 							default:
 							}
-							return
+						}
+						for _, event := range events {
+							if event == timerInputCancel {
+								select {
+								case ch <- struct{}{}:
+								default:
+								}
+								return
+							}
+							// Keep only the latest focus state.
+							select {
+							case <-fch:
+							default:
+							}
+							fch <- event == timerInputFocusIn
 						}
 					}
 				}()
@@ -105,41 +712,328 @@ func runTimerWithAlarmStarter(ctx context.Context, cancel context.CancelCauseFun
 	for {
 		select {
 		case <-ctx.Done():
+			stopFrames()
 			restoreTerminal()
-			printCancelled(status, quiet)
+			printCancelled(status, inv.quiet, inv.label, inv.cancelMessage, summary())
+			notifyDesktop(outcomeCancelled, max(remainingNow(), 0), false)
+			report(progressEventCancelled, max(remainingNow(), 0), context.Cause(ctx))
+			tracked := stopToggl()
+			<-notifyRemote(outcomeCancelled, max(remainingNow(), 0))
+			<-tracked
+			finish(outcomeCancelled, context.Cause(ctx))
 			return context.Cause(ctx)
 
 		case <-keyCh:
+			stopFrames()
 			restoreTerminal()
 			cancel(signalCause{sig: os.Interrupt})
-			printCancelled(status, quiet)
+			printCancelled(status, inv.quiet, inv.label, inv.cancelMessage, summary())
+			notifyDesktop(outcomeCancelled, max(remainingNow(), 0), false)
+			report(progressEventCancelled, max(remainingNow(), 0), context.Cause(ctx))
+			tracked := stopToggl()
+			<-notifyRemote(outcomeCancelled, max(remainingNow(), 0))
+			<-tracked
+			finish(outcomeCancelled, context.Cause(ctx))
 			return context.Cause(ctx)
 
 		case <-done.C:
-			restoreTerminal()
-			printComplete(status, quiet)
-			shouldAlarm := shouldTriggerAlarm(bothStreamsInteractive, quiet, forceAlarm)
-			if shouldAlarm {
-				alarmStarter(soundFile)
+			stopFrames()
+			shouldAlarm := !inv.muteAlarm && shouldTriggerAlarm(bothStreamsInteractive, inv.quiet, inv.forceAlarm)
+			// In quiet hours, or while the system is muted or in Do Not
+			// Disturb, the alarm is a flash instead of a sound.
+			hushed := shouldAlarm && (inv.quietHours.contains(time.Now()) ||
+				inv.respectSilence && systemSilence(silenceChecksForGOOS(runtime.GOOS), commandOutput) != "")
+			if hushed {
+				shouldAlarm = false
 			}
+			// --ack needs the raw terminal to hear the key; having pressed
+			// it, the user is back, so there is nothing to realert.
+			// --escalate can also be acknowledged with after ack.
+			awaitAck := shouldAlarm && (inv.ack && keyCh != nil || inv.escalate && (keyCh != nil || controlC != nil))
+			awaitFocus := trackFocus && !focused && !awaitAck
+			if !awaitFocus && !awaitAck {
+				restoreTerminal()
+			}
+			// --notify posts through the terminal itself when nothing better
+			// works.
+			if drawing && status.notifier != notifierNone && !inv.quiet && !inv.noNotify && !inv.notify {
+				writeStatus(status.writer, formatTerminalNotification(status.notifier, "after", withIcon(status.icons.notify, completionNotificationBody(inv.label, inv.message)), status.inTmux))
+			}
+			// When the alarm does not ring, as with output piped away, the
+			// notification makes the sound, unless the timer should be
+			// quiet.
+			var alarmNotification *notificationWatch
+			if awaitAck {
+				alarmNotification = watchAlarmNotification()
+			}
+			if alarmNotification == nil {
+				notifyDesktop(outcomeComplete, 0, !shouldAlarm && !hushed && !inv.quiet && !inv.muteAlarm)
+			}
+			report(progressEventComplete, 0, nil)
+			pushed := notifyRemote(outcomeComplete, 0)
+			tracked := stopToggl()
+			// A deliberate --flash works even with --quiet, like --sound.
+			canFlash := (inv.flash || hushed) && drawing && status.supportsAdvanced
+			if canFlash {
+				flashScreen(status.writer, time.Sleep)
+			}
+			printComplete(status, inv.quiet, inv.label, inv.message, summary())
+			if awaitFocus || awaitAck {
+				// Still in raw mode, where \n does not return the cursor.
+				writeStatus(status.writer, "\r")
+			}
+			completedAt := time.Now()
+			stopAlarm := func() {}
+			switch {
+			case awaitAck && inv.escalate:
+				// The sound waits for escalateDelay, below.
+			case awaitAck:
+				stopAlarm = startAckAlarm(inv.alarmSound())
+			case shouldAlarm:
+				alarmStarter(inv.alarmSound())
+			}
+			switch {
+			case inv.wall:
+				wallCompletion(terminalGlobsForGOOS(runtime.GOOS), broadcastText(inv.label, inv.message, completedAt))
+			case inv.broadcast:
+				ttys := userTerminals(terminalGlobsForGOOS(runtime.GOOS), os.Getuid(), ownTerminalRdev())
+				broadcastCompletion(ttys, formatBroadcastMessage(inv.label, inv.message, completedAt))
+			}
+			if awaitAck {
+				prompted := !inv.quiet && keyCh != nil
+				if prompted {
+					writeStatus(status.writer, ackPrompt)
+				}
+				// A finished timer answers status as alerting and turns
+				// away everything but after ack.
+				answer := func(req controlRequest) controlResponse {
+					if req.Command != controlCommandStatus {
+						return controlResponse{Error: "the timer has finished"}
+					}
+					state := snapshot()
+					state.State = timerStateAlerting
+					return controlResponse{OK: true, State: &state}
+				}
+				// The alarm is silent while --escalate waits and while
+				// snoozed, and rings again when that time is up.
+				sound := inv.alarmSound()
+				if inv.escalate {
+					sound = escalatedSound(sound)
+				}
+				wait, silent := ackAlarmLimit, inv.escalate
+				if silent {
+					wait = escalateDelay
+				}
+				for {
+					var actionC <-chan string
+					if alarmNotification != nil {
+						actionC = alarmNotification.C
+					}
+					result := waitForAck(ctx, keyCh, pressCh, controlC, actionC, answer, wait)
+					stopAlarm()
+					stopAlarm = func() {}
+					if result == ackSnoozed {
+						wait, silent = snoozeDelay, true
+						continue
+					}
+					if result == ackTimedOut && silent {
+						if alarmNotification != nil {
+							alarmNotification.Close()
+							alarmNotification = watchAlarmNotification()
+						}
+						stopAlarm = startAckAlarm(sound)
+						wait, silent = ackAlarmLimit, false
+						continue
+					}
+					break
+				}
+				if alarmNotification != nil {
+					alarmNotification.Close()
+				}
+				switch {
+				case !prompted:
+				case status.supportsAdvanced:
+					writeStatus(status.writer, "\r\033[K")
+				default:
+					writeStatus(status.writer, "\r\n")
+				}
+			}
+			if awaitFocus && waitForFocus(ctx, focusCh, keyCh) {
+				restoreTerminal()
+				writeInteractiveLine(status, formatRealert(inv.label, inv.message, completedAt))
+				if canFlash {
+					flashScreen(status.writer, time.Sleep)
+				}
+				if shouldAlarm {
+					alarmStarter(inv.alarmSound())
+				}
+			}
+			restoreTerminal()
+			<-pushed
+			<-tracked
+			finish(outcomeComplete, nil)
 			return nil
 
 		case <-tickC:
-			remaining := time.Until(deadline)
+			if remainingNow() <= 0 {
+				// done is the authoritative completion signal; ticks are UI-only.
+				continue
+			}
+
+			renderCountdown()
+			retick()
 
+		case <-announceC:
+			remaining := remainingNow()
+			if m, ok := crossedMilestone(milestones, lastAnnounceCheck, remaining); ok && remaining > 0 {
+				speak(formatMilestoneAnnouncement(inv.label, m))
+			}
+			lastAnnounceCheck = remaining
+
+		case <-tickSoundC:
+			if !paused && remainingNow() > 0 {
+				ticks.play()
+			}
+
+		case <-chimeC:
+			remaining := remainingNow()
 			if remaining <= 0 {
-				// done is the authoritative completion signal; ticks are UI-only.
 				continue
 			}
+			elapsed := total - remaining
+			if inv.halfway && !halfwayDone && reachedHalfway(elapsed, total) {
+				halfwayDone = true
+				chime(true, halfwayNotificationBody(inv.label, remaining))
+			}
+			periods := everyPeriods(elapsed, inv.every)
+			if periods > lastPeriods {
+				chime(inv.everyNotify, everyNotificationBody(inv.label, time.Duration(periods)*inv.every, remaining))
+			}
+			lastPeriods = periods
 
-			renderInteractiveCountdown(status, formatRemainingTime(remaining), noTitle)
+		case <-finalBeepC:
+			remaining := remainingNow()
+			if _, ok := crossedMilestone(beepMilestones, lastBeepCheck, remaining); ok && remaining > 0 {
+				beeps.play()
+			}
+			lastBeepCheck = remaining
+
+		case <-streamC:
+			if remaining := remainingNow(); remaining > 0 {
+				report(progressEventTick, remaining, nil)
+			}
+
+		case <-sdStatusC:
+			if remaining := remainingNow(); remaining > 0 {
+				sd.send(formatSDStatus(inv.label, remaining, paused))
+			}
+
+		case <-resizeC:
+			width, height = stderrSize()
+			// The terminal may have reflowed the line; draw it in full.
+			status.frames.redraw()
+			if remainingNow() > 0 {
+				renderCountdown()
+			}
+
+		case <-dumpC:
+			stopFrames()
+			restoreTerminal()
+			socket := "none"
+			if controlC != nil {
+				socket = controlSocketPath(controlDir(os.Getenv), os.Getpid())
+			}
+			dump := stateDump{
+				at:            time.Now(),
+				inv:           inv,
+				started:       started,
+				deadline:      deadline,
+				remaining:     remainingNow(),
+				total:         total,
+				pauses:        pauses,
+				renderer:      formatRendererMode(status, renderInterval()),
+				alarm:         resolveAlarmCommands(inv.alarmSound()),
+				idle:          idleMode,
+				controlSocket: socket,
+			}
+			if paused {
+				dump.pausedBy = pausedBy
+			}
+			text := formatStateDump(dump) + "\ngoroutines:\n" + goroutineSnapshot()
+			if path, err := writeStateDump(status, os.Getenv, text); err != nil {
+				writeStatusln(status.writer, "Warning: state dump failed:", err)
+				writeStatus(status.writer, text)
+			} else if path != "" {
+				writeStatusln(status.writer, "after: state dumped to", path)
+			}
+			cancel(signalCause{sig: syscall.SIGQUIT})
+
+		case f := <-focusCh:
+			focused = f
+
+		case call := <-controlC:
+			call.reply <- handleControl(call.req)
+			if status.interactive && ctx.Err() == nil {
+				renderCountdown()
+			}
+
+		case idle := <-idleC:
+			if idle == paused || (paused && pausedBy != pauseReasonIdle) {
+				continue
+			}
+			if idle {
+				pause(pauseReasonIdle)
+			} else {
+				resume()
+			}
+			printIdleTransition(status, inv.quiet, idle)
+			if status.interactive {
+				renderCountdown()
+			}
 
 		case <-resyncC:
+			// Wall clock targets follow the wall clock. The done timer runs on
+			// the monotonic clock, so re-anchor it whenever the two disagree.
+			reading := readClock()
+			step := clockStep(lastResync, reading)
+			lastResync = reading
+			if paused {
+				continue
+			}
 			remaining := time.Until(deadline)
 			if remaining < 0 {
 				remaining = 0
 			}
 			done.Reset(remaining)
+			if step > 0 && inv.pauseOnSuspend {
+				// Forward jumps are reported (and compensated) as suspend.
+				continue
+			}
+			if step != 0 {
+				exclusiveStatus(status, func() { printClockStep(status, inv.quiet, step, deadline) })
+				if status.interactive {
+					renderCountdown()
+				}
+			}
+
+		case now := <-suspendC:
+			gap := suspendGap(lastSuspendCheck, now)
+			lastSuspendCheck = now
+			if gap == 0 {
+				continue
+			}
+			// The monotonic clock already excluded the suspend for relative
+			// timers; wall clock targets must be pushed back explicitly.
+			suspended += gap
+			pauses = append(pauses, pauseRecord{reason: pauseReasonSuspend, start: now.Add(-gap), end: now})
+			if isWallClock && !paused {
+				deadline = deadline.Add(gap)
+				done.Reset(time.Until(deadline))
+			}
+			exclusiveStatus(status, func() { printSuspendPause(status, inv.quiet, gap) })
+			if status.interactive {
+				renderCountdown()
+			}
 		}
 	}
 }
@@ -173,11 +1067,6 @@ func stderrIsTTY() bool {
 	return isTerminal(os.Stderr.Fd())
 }
 
-func supportsAdvancedTerminal(termName string) bool {
-	normalized := strings.TrimSpace(strings.ToLower(termName))
-	return normalized != "" && normalized != "dumb"
-}
-
 func isTerminal(fd uintptr) bool {
 	return term.IsTerminal(int(fd))
 }
@@ -186,10 +1075,93 @@ func stdinIsTTY() bool {
 	return isTerminal(os.Stdin.Fd())
 }
 
-func isInForeground(fd uintptr) bool {
-	pgrp, err := unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
-	if err != nil {
-		return true // assume foreground if we can't determine
+// waitForFocus blocks until the terminal regains focus, returning true, or
+// until the user cancels or the context ends, returning false.
+func waitForFocus(ctx context.Context, focusC <-chan bool, keyC <-chan struct{}) bool {
+	for {
+		select {
+		case focused := <-focusC:
+			if focused {
+				return true
+			}
+		case <-keyC:
+			return false
+		case <-ctx.Done():
+			return false
+		}
 	}
-	return pgrp == unix.Getpgrp()
+}
+
+// ackPrompt is shown while --ack rings, in place of a line of its own.
+const ackPrompt = "press any key to stop the alarm"
+
+// ackResult is how waiting for an answer to the alarm ended.
+type ackResult int
+
+const (
+	ackTimedOut ackResult = iota
+	ackAnswered
+	ackSnoozed
+)
+
+// waitForAck waits for a key press, an after ack, or a press of the
+// notification's Dismiss or Snooze button (keys received on actionC) after
+// completion, for at most limit. Keys pressed during the countdown do not
+// count. Other control requests get answer's reply.
+func waitForAck(ctx context.Context, keyC, pressC <-chan struct{}, controlC <-chan controlCall, actionC <-chan string, answer func(controlRequest) controlResponse, limit time.Duration) ackResult {
+	select {
+	case <-pressC:
+	default:
+	}
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-keyC:
+		case <-pressC:
+		case <-ctx.Done():
+		case call := <-controlC:
+			if call.req.Command != controlCommandAck {
+				call.reply <- answer(call.req)
+				continue
+			}
+			call.reply <- controlResponse{OK: true}
+		case action, ok := <-actionC:
+			switch {
+			case !ok:
+				// The notification is gone; the other ways remain.
+				actionC = nil
+				continue
+			case action == alarmActionSnooze:
+				return ackSnoozed
+			case action != alarmActionDismiss:
+				continue
+			}
+		case <-timeout.C:
+			return ackTimedOut
+		}
+		return ackAnswered
+	}
+}
+
+// countdownTickInterval is how often the interactive countdown redraws. An
+// explicit --refresh applies throughout, including the final stretch.
+func countdownTickInterval(remaining time.Duration, paused bool, refresh time.Duration) time.Duration {
+	if refresh > 0 {
+		return refresh
+	}
+	if !paused && remaining < finalStretch {
+		return 100 * time.Millisecond
+	}
+	return 500 * time.Millisecond
+}
+
+// streamInterval is how often --stdout and --json report the remaining time
+// unless --refresh says otherwise.
+const streamInterval = time.Second
+
+// writeStreamLine writes remaining to the --stdout stream, e.g. "12:30".
+// Lines carry no escape codes so they can be piped anywhere.
+func writeStreamLine(w io.Writer, remaining time.Duration) {
+	fmt.Fprintln(w, formatRemainingTime(remaining))
 }
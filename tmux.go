@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var errTmuxPopupOutsideTmux = errors.New("--tmux-popup needs to run inside tmux")
+
+// tmuxPopupArgs returns the tmux command that reruns after, minus
+// --tmux-popup, in a popup over the current pane. -E closes the popup when
+// the timer ends. tmux runs the command through the shell, so every
+// argument is quoted.
+func tmuxPopupArgs(exe string, args []string, big bool) []string {
+	command := []string{shellQuote(exe)}
+	for i, arg := range args {
+		if arg == "--" {
+			for _, rest := range args[i:] {
+				command = append(command, shellQuote(rest))
+			}
+			break
+		}
+		if arg == "--tmux-popup" {
+			continue
+		}
+		command = append(command, shellQuote(arg))
+	}
+
+	width, height := 40, 3
+	if big {
+		width, height = 60, 9
+	}
+	return []string{"display-popup", "-E", "-T", " after ",
+		"-w", strconv.Itoa(width), "-h", strconv.Itoa(height),
+		strings.Join(command, " ")}
+}
+
+// startTmuxPopup opens the popup and returns without waiting for it, so
+// the invoking pane is free again at once.
+func startTmuxPopup(args []string, big bool, getenv func(string) string) error {
+	if getenv("TMUX") == "" {
+		return errTmuxPopupOutsideTmux
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("tmux", tmuxPopupArgs(exe, args, big)...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// shellQuote quotes s for sh, leaving plain words as they are.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
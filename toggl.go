@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --toggl tracks the timer as a Toggl Track time entry, described by its
+// label: the entry starts with the countdown and stops when it completes
+// or is cancelled. The API token comes from the [toggl] config section or
+// $AFTER_TOGGL_TOKEN:
+//
+//	[toggl]
+//	token = <API token>
+//	workspace = 1234567
+//	project = 7654321
+//	tags = pomodoro, focus
+//
+// Without a workspace, entries go to the account's default one.
+
+const togglTokenEnvVar = "AFTER_TOGGL_TOKEN"
+
+const togglEndpoint = "https://api.track.toggl.com/api/v9"
+
+// togglConfig is the account and project to track in. tags is
+// comma-separated, so that invocation stays comparable.
+type togglConfig struct {
+	token     string
+	workspace int64
+	project   int64
+	tags      string
+}
+
+func (c togglConfig) set() bool {
+	return c.token != ""
+}
+
+func parseTogglSection(section configSection) (togglConfig, error) {
+	var c togglConfig
+	for _, entry := range section.entries {
+		switch entry.key {
+		case "token":
+			c.token = entry.value
+		case "workspace", "project":
+			id, err := strconv.ParseInt(entry.value, 10, 64)
+			if err != nil || id <= 0 {
+				return togglConfig{}, configError{line: entry.line, msg: fmt.Sprintf("%s: expected a Toggl id, got %q", entry.key, entry.value)}
+			}
+			if entry.key == "workspace" {
+				c.workspace = id
+			} else {
+				c.project = id
+			}
+		case "tags":
+			c.tags = entry.value
+		default:
+			return togglConfig{}, configError{line: entry.line, msg: fmt.Sprintf("unknown toggl setting %q", entry.key)}
+		}
+	}
+	return c, nil
+}
+
+// resolveToggl takes the token from the environment, which takes
+// precedence over the config file.
+func resolveToggl(c togglConfig, getenv func(string) string) togglConfig {
+	if token := getenv(togglTokenEnvVar); token != "" {
+		c.token = token
+	}
+	return c
+}
+
+// togglEntry is a time entry after started.
+type togglEntry struct {
+	workspace int64
+	id        int64
+}
+
+// togglRequest sends body, if any, as JSON to the API at endpoint and
+// decodes the reply into reply, if any.
+func togglRequest(client *http.Client, c togglConfig, method, url string, body, reply any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("toggl: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return fmt.Errorf("toggl: %w", err)
+	}
+	req.SetBasicAuth(c.token, "api_token")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("toggl: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		// Toggl explains a refusal in the body, as text or a JSON string.
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if reason := strings.Trim(strings.TrimSpace(string(text)), `"`); reason != "" {
+			return fmt.Errorf("toggl: %s: %s", resp.Status, reason)
+		}
+		return fmt.Errorf("toggl: %s", resp.Status)
+	}
+	if reply == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return fmt.Errorf("toggl: %w", err)
+	}
+	return nil
+}
+
+// startTogglEntry starts a running time entry described by description
+// at start, in the configured workspace or else the default one.
+func startTogglEntry(client *http.Client, endpoint string, c togglConfig, description string, start time.Time) (togglEntry, error) {
+	workspace := c.workspace
+	if workspace == 0 {
+		var me struct {
+			DefaultWorkspaceID int64 `json:"default_workspace_id"`
+		}
+		if err := togglRequest(client, c, http.MethodGet, endpoint+"/me", nil, &me); err != nil {
+			return togglEntry{}, err
+		}
+		workspace = me.DefaultWorkspaceID
+	}
+	body := map[string]any{
+		"created_with": "after",
+		"description":  description,
+		"workspace_id": workspace,
+		"start":        start.UTC().Format(time.RFC3339),
+		"duration":     -1, // running
+	}
+	if c.project != 0 {
+		body["project_id"] = c.project
+	}
+	var tags []string
+	for _, tag := range strings.Split(c.tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if tags != nil {
+		body["tags"] = tags
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	url := fmt.Sprintf("%s/workspaces/%d/time_entries", endpoint, workspace)
+	if err := togglRequest(client, c, http.MethodPost, url, body, &created); err != nil {
+		return togglEntry{}, err
+	}
+	return togglEntry{workspace: workspace, id: created.ID}, nil
+}
+
+// stopTogglEntry stops entry now.
+func stopTogglEntry(client *http.Client, endpoint string, c togglConfig, entry togglEntry) error {
+	url := fmt.Sprintf("%s/workspaces/%d/time_entries/%d/stop", endpoint, entry.workspace, entry.id)
+	return togglRequest(client, c, http.MethodPatch, url, nil, nil)
+}
+
+// trackToggl starts an entry in the background, so the countdown does not
+// wait on the network, and returns a function that stops it once started.
+// The channel that function returns is closed when the entry is stopped.
+// Failures go to warn.
+func trackToggl(c togglConfig, description string, start time.Time, warn func(error)) (stop func() <-chan struct{}) {
+	client := &http.Client{Timeout: remoteTimeout}
+	started := make(chan togglEntry, 1)
+	go func() {
+		defer close(started)
+		entry, err := startTogglEntry(client, togglEndpoint, c, description, start)
+		if err != nil {
+			warn(err)
+			return
+		}
+		started <- entry
+	}()
+	return func() <-chan struct{} {
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			entry, ok := <-started
+			if !ok {
+				return
+			}
+			if err := stopTogglEntry(client, togglEndpoint, c, entry); err != nil {
+				warn(err)
+			}
+		}()
+		return stopped
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom units are declared in a [units] section of the config file and
+// extend the duration syntax, so "after 2pomo" or "after 1ep5m" work:
+//
+//	[units]
+//	pomo = 25m
+//	ep = 42m
+
+// builtinDurationUnits are the units time.ParseDuration already understands.
+// Custom units may not redefine them.
+var builtinDurationUnits = map[string]bool{
+	"ns": true, "us": true, "µs": true, "μs": true, "ms": true, "s": true, "m": true, "h": true,
+}
+
+func parseUnits(section configSection) (map[string]time.Duration, error) {
+	units := make(map[string]time.Duration, len(section.entries))
+	for _, entry := range section.entries {
+		if !isUnitName(entry.key) {
+			return nil, configError{line: entry.line, msg: fmt.Sprintf("unit name %q must be letters only", entry.key)}
+		}
+		if builtinDurationUnits[entry.key] {
+			return nil, configError{line: entry.line, msg: fmt.Sprintf("unit %q is built in", entry.key)}
+		}
+		if _, dup := units[entry.key]; dup {
+			return nil, configError{line: entry.line, msg: fmt.Sprintf("duplicate unit %q", entry.key)}
+		}
+		d, err := time.ParseDuration(entry.value)
+		if err != nil || d <= 0 {
+			return nil, configError{line: entry.line, msg: fmt.Sprintf("unit %s: expected a positive duration, got %q", entry.key, entry.value)}
+		}
+		units[entry.key] = d
+	}
+	return units, nil
+}
+
+func isUnitName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCustomUnitDuration parses a sequence of <number><unit> segments where
+// each unit is either custom or built in, e.g. "2pomo" or "1ep5m". It
+// reports false when the token is malformed or uses no custom unit, leaving
+// it to the normal parser, and errDurationOutOfRange when the total does not
+// fit in a time.Duration.
+func parseCustomUnitDuration(token string, units map[string]time.Duration) (time.Duration, bool, error) {
+	if len(units) == 0 || token == "" {
+		return 0, false, nil
+	}
+
+	var total time.Duration
+	usedCustom, overflow := false, false
+	rest := token
+	for rest != "" {
+		numEnd := strings.IndexFunc(rest, func(c rune) bool { return !(c >= '0' && c <= '9' || c == '.') })
+		if numEnd <= 0 {
+			return 0, false, nil
+		}
+		unitEnd := strings.IndexFunc(rest[numEnd:], func(c rune) bool { return c >= '0' && c <= '9' || c == '.' })
+		if unitEnd < 0 {
+			unitEnd = len(rest) - numEnd
+		}
+		number, unit := rest[:numEnd], rest[numEnd:numEnd+unitEnd]
+		rest = rest[numEnd+unitEnd:]
+
+		var d time.Duration
+		if size, ok := units[unit]; ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, false, nil
+			}
+			usedCustom = true
+			// Converting a float outside the int64 range to a Duration is
+			// implementation-defined, so the product is checked first.
+			f := n * float64(size)
+			if math.IsNaN(f) || f < 0 || f >= math.MaxInt64 {
+				overflow = true
+				continue
+			}
+			d = time.Duration(f)
+		} else {
+			var err error
+			if d, err = time.ParseDuration(number + unit); err != nil {
+				return 0, false, nil
+			}
+		}
+		if total > math.MaxInt64-d {
+			overflow = true
+			continue
+		}
+		total += d
+	}
+	if !usedCustom {
+		return 0, false, nil
+	}
+	if overflow {
+		return 0, true, errDurationOutOfRange
+	}
+	return total, true, nil
+}
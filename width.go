@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Terminal column widths. Combining marks and format characters take no
+// column; East Asian wide and fullwidth characters and emoji take two.
+// The table covers the ranges that show up in labels in practice, not all
+// of Unicode's EastAsianWidth.txt.
+
+type runeRange struct{ lo, hi rune }
+
+// wideRunes is sorted by lo.
+var wideRunes = []runeRange{
+	{0x1100, 0x115F},   // Hangul Jamo initials
+	{0x231A, 0x231B},   // ⌚⌛
+	{0x2329, 0x232A},   // angle brackets
+	{0x23E9, 0x23EC},   // ⏩⏪⏫⏬
+	{0x23F0, 0x23F0},   // ⏰
+	{0x23F3, 0x23F3},   // ⏳
+	{0x25FD, 0x25FE},   // ◽◾
+	{0x2614, 0x2615},   // ☔☕
+	{0x2648, 0x2653},   // zodiac
+	{0x267F, 0x267F},   // ♿
+	{0x2693, 0x2693},   // ⚓
+	{0x26A1, 0x26A1},   // ⚡
+	{0x26AA, 0x26AB},   // ⚪⚫
+	{0x26BD, 0x26BE},   // ⚽⚾
+	{0x26C4, 0x26C5},   // ⛄⛅
+	{0x26CE, 0x26CE},   // ⛎
+	{0x26D4, 0x26D4},   // ⛔
+	{0x26EA, 0x26EA},   // ⛪
+	{0x26F2, 0x26F3},   // ⛲⛳
+	{0x26F5, 0x26F5},   // ⛵
+	{0x26FA, 0x26FA},   // ⛺
+	{0x26FD, 0x26FD},   // ⛽
+	{0x2705, 0x2705},   // ✅
+	{0x270A, 0x270B},   // ✊✋
+	{0x2728, 0x2728},   // ✨
+	{0x274C, 0x274C},   // ❌
+	{0x274E, 0x274E},   // ❎
+	{0x2753, 0x2755},   // ❓❔❕
+	{0x2757, 0x2757},   // ❗
+	{0x2795, 0x2797},   // ➕➖➗
+	{0x27B0, 0x27B0},   // ➰
+	{0x27BF, 0x27BF},   // ➿
+	{0x2B1B, 0x2B1C},   // ⬛⬜
+	{0x2B50, 0x2B50},   // ⭐
+	{0x2B55, 0x2B55},   // ⭕
+	{0x2E80, 0x303E},   // CJK radicals, punctuation
+	{0x3041, 0x33FF},   // kana, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x16FE0, 0x18AFF}, // Tangut, Khitan
+	{0x1B000, 0x1B2FF}, // kana supplement, Nüshu
+	{0x1F004, 0x1F004}, // 🀄
+	{0x1F0CF, 0x1F0CF}, // 🃏
+	{0x1F18E, 0x1F18E}, // 🆎
+	{0x1F191, 0x1F19A}, // squared letters
+	{0x1F200, 0x1F251}, // enclosed ideographs
+	{0x1F300, 0x1F64F}, // pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // transport
+	{0x1F7E0, 0x1F7EB}, // colored circles and squares
+	{0x1F90C, 0x1F9FF}, // supplemental pictographs
+	{0x1FA70, 0x1FAFF}, // pictographs extended A
+	{0x20000, 0x2FFFD}, // CJK extensions B-F
+	{0x30000, 0x3FFFD}, // CJK extension G
+}
+
+// runeWidth is the number of terminal columns r occupies.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || r < 0x20 || (r >= 0x7F && r < 0xA0):
+		return 0
+	case r < 0x300:
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	i := sort.Search(len(wideRunes), func(i int) bool { return wideRunes[i].hi >= r })
+	if i < len(wideRunes) && wideRunes[i].lo <= r {
+		return 2
+	}
+	return 1
+}
+
+// stringWidth is the number of terminal columns s occupies.
+func stringWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// cutToWidth returns the longest prefix of s that fits in width columns.
+func cutToWidth(s string, width int) string {
+	n := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if n+w > width {
+			return s[:i]
+		}
+		n += w
+	}
+	return s
+}
+
+// padToWidth pads s with spaces to width columns; %-*s counts bytes.
+func padToWidth(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-stringWidth(s), 0))
+}